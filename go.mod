@@ -1,6 +1,6 @@
 module mynewt.apache.org/newtmgr
 
-go 1.12
+go 1.14
 
 require (
 	github.com/JuulLabs-OSS/ble v0.0.0-20200517053828-ca7534402217
@@ -41,6 +41,6 @@ require (
 	gopkg.in/mattn/go-colorable.v0 v0.1.0 // indirect
 	gopkg.in/mattn/go-isatty.v0 v0.0.4 // indirect
 	gopkg.in/mattn/go-runewidth.v0 v0.0.4 // indirect
-	gopkg.in/yaml.v2 v2.2.4 // indirect
+	gopkg.in/yaml.v2 v2.2.4
 	mynewt.apache.org/newt v0.0.0-20200409145402-c5d1e422bfa3
 )
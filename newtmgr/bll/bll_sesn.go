@@ -393,7 +393,13 @@ func (s *BllSesn) RxCoap(opt sesn.TxOptions) (coap.Message, error) {
 //     * other error
 func (s *BllSesn) TxRxMgmt(m *nmp.NmpMsg,
 	timeout time.Duration) (nmp.NmpRsp, error) {
-	_, task := trace.NewTask(context.Background(), "newtmgr/bll/bll_sesn.go/TxRxMgmt")
+
+	return s.TxRxMgmtContext(context.Background(), m, timeout)
+}
+
+func (s *BllSesn) TxRxMgmtContext(ctx context.Context, m *nmp.NmpMsg,
+	timeout time.Duration) (nmp.NmpRsp, error) {
+	_, task := trace.NewTask(ctx, "newtmgr/bll/bll_sesn.go/TxRxMgmt")
 	//// time.Sleep(100 * time.Millisecond) ////
 	defer task.End()
 
@@ -412,7 +418,7 @@ func (s *BllSesn) TxRxMgmt(m *nmp.NmpMsg,
 		return s.txWriteCharacteristic(s.nmpReqChr, b, true)
 	}
 
-	return s.txvr.TxRxMgmt(txRaw, m, s.MtuOut(), timeout)
+	return s.txvr.TxRxMgmtContext(ctx, txRaw, m, s.MtuOut(), timeout)
 }
 
 func (s *BllSesn) TxCoap(m coap.Message) error {
@@ -33,12 +33,24 @@ import (
 var NewtmgrLogLevel log.Level
 var NewtmgrHelp bool
 
+type versionOutput struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
 func Commands() *cobra.Command {
 	logLevelStr := ""
 	nmCmd := &cobra.Command{
 		Use:   nmutil.ToolInfo.ExeName,
 		Short: nmutil.ToolInfo.ShortName + " helps you manage remote devices",
 		PersistentPreRun: func(cmd *cobra.Command, args []string) {
+			// Checked first so that any other errors are reported in the
+			// requested format.
+			if err := checkOutputFormat(); err != nil {
+				outputFormat = OUTPUT_TEXT
+				nmUsage(nil, err)
+			}
+
 			var err error
 			NewtmgrLogLevel, err = log.ParseLevel(logLevelStr)
 			if err != nil {
@@ -68,6 +80,13 @@ func Commands() *cobra.Command {
 	nmCmd.PersistentFlags().IntVarP(&nmutil.Tries, "tries", "r", 1,
 		"total number of tries in case of timeout")
 
+	nmCmd.PersistentFlags().BoolVar(&nmutil.Backoff, "backoff", false,
+		"wait increasingly long between tries, lengthen the timeout of "+
+			"each try, and also retry after connection failures")
+
+	nmCmd.PersistentFlags().StringVarP(&outputFormat, "output", "o",
+		OUTPUT_TEXT, "output format: text, json, or yaml")
+
 	nmCmd.PersistentFlags().StringVarP(&logLevelStr, "loglevel", "l", "info",
 		"log level to use")
 
@@ -95,9 +114,13 @@ func Commands() *cobra.Command {
 		Short:   "Display the " + nmutil.ToolInfo.ShortName + " version number",
 		Example: "  " + nmutil.ToolInfo.ExeName + " version",
 		Run: func(cmd *cobra.Command, args []string) {
-			fmt.Printf("%s %s\n",
-				nmutil.ToolInfo.LongName,
-				nmutil.ToolInfo.VersionString)
+			vers := versionOutput{
+				Name:    nmutil.ToolInfo.LongName,
+				Version: nmutil.ToolInfo.VersionString,
+			}
+			printResult(vers, func() {
+				fmt.Printf("%s %s\n", vers.Name, vers.Version)
+			})
 		},
 	}
 	nmCmd.AddCommand(versCmd)
@@ -115,10 +138,14 @@ func Commands() *cobra.Command {
 	nmCmd.AddCommand(runCmd())
 	nmCmd.AddCommand(statsCmd())
 	nmCmd.AddCommand(taskStatCmd())
+	nmCmd.AddCommand(topCmd())
 	nmCmd.AddCommand(configCmd())
 	nmCmd.AddCommand(connProfileCmd())
 	nmCmd.AddCommand(echoCmd())
+	nmCmd.AddCommand(emulateCmd())
+	nmCmd.AddCommand(exporterCmd())
 	nmCmd.AddCommand(resCmd())
+	nmCmd.AddCommand(rawCmd())
 	nmCmd.AddCommand(interactiveCmd())
 	nmCmd.AddCommand(shellCmd())
 
@@ -35,6 +35,7 @@ import (
 	"mynewt.apache.org/newtmgr/nmxact/nmcoap"
 	"mynewt.apache.org/newtmgr/nmxact/nmserial"
 	"mynewt.apache.org/newtmgr/nmxact/sesn"
+	"mynewt.apache.org/newtmgr/nmxact/tcp"
 	"mynewt.apache.org/newtmgr/nmxact/udp"
 	"mynewt.apache.org/newtmgr/nmxact/xport"
 )
@@ -106,17 +107,27 @@ func getConnProfile() (*config.ConnProfile, error) {
 	return globalP, nil
 }
 
-func GetXport() (xport.Xport, error) {
-	//// time.Sleep(100 * time.Millisecond) ////
-	if globalXport != nil {
-		return globalXport, nil
-	}
-
+// Identifies the device being managed, for keeping track of per-device
+// state across invocations.  The key is derived from the connection type and
+// connection string, so it is the same whichever profile (or command line
+// override) was used to reach the device.
+func DeviceKey() (string, error) {
 	cp, err := getConnProfile()
 	if err != nil {
-		return nil, err
+		return "", err
 	}
 
+	key := config.ConnTypeToString(cp.Type) + ":" + cp.ConnString
+	if nmutil.DeviceName != "" {
+		key += ",name=" + nmutil.DeviceName
+	}
+
+	return key, nil
+}
+
+// Creates a transport for the specified connection profile.  The transport is
+// not started.
+func newXport(cp *config.ConnProfile) (xport.Xport, error) {
 	switch cp.Type {
 	case config.CONN_TYPE_SERIAL_PLAIN, config.CONN_TYPE_SERIAL_OIC:
 		sc, err := config.ParseSerialConnString(cp.ConnString)
@@ -124,7 +135,7 @@ func GetXport() (xport.Xport, error) {
 			return nil, err
 		}
 
-		globalXport = nmserial.NewSerialXport(sc)
+		return nmserial.NewSerialXport(sc), nil
 
 	case config.CONN_TYPE_BLL_PLAIN, config.CONN_TYPE_BLL_OIC:
 		bc, err := config.ParseBllConnString(cp.ConnString)
@@ -137,29 +148,46 @@ func GetXport() (xport.Xport, error) {
 			cfg.CtlrName = bc.CtlrName
 		}
 		cfg.OwnAddrType = bc.OwnAddrType
-		globalXport = bll.NewBllXport(cfg, bc.HciIdx)
+		return bll.NewBllXport(cfg, bc.HciIdx), nil
 
 	case config.CONN_TYPE_BLE_PLAIN, config.CONN_TYPE_BLE_OIC:
 		bc, err := config.ParseBleConnString(cp.ConnString)
 		if err != nil {
 			return nil, err
 		}
-		globalXport, err = config.BuildBleXport(bc)
-		if err != nil {
-			return nil, err
-		}
+		return config.BuildBleXport(bc)
 
 	case config.CONN_TYPE_UDP_PLAIN, config.CONN_TYPE_UDP_OIC:
-		globalXport = udp.NewUdpXport()
+		return udp.NewUdpXport(), nil
+
+	case config.CONN_TYPE_TCP_PLAIN, config.CONN_TYPE_TCP_OIC:
+		return tcp.NewTcpXport(), nil
 
 	case config.CONN_TYPE_MTECH_LORA_OIC:
 		cfg := mtech_lora.NewXportCfg()
-		globalXport = mtech_lora.NewLoraXport(cfg)
+		return mtech_lora.NewLoraXport(cfg), nil
 
 	default:
 		return nil, util.FmtNewtError("Unknown connection type: %s (%d)",
 			config.ConnTypeToString(cp.Type), int(cp.Type))
 	}
+}
+
+func GetXport() (xport.Xport, error) {
+	//// time.Sleep(100 * time.Millisecond) ////
+	if globalXport != nil {
+		return globalXport, nil
+	}
+
+	cp, err := getConnProfile()
+	if err != nil {
+		return nil, err
+	}
+
+	globalXport, err = newXport(cp)
+	if err != nil {
+		return nil, err
+	}
 
 	globalXportSet = true
 
@@ -178,15 +206,12 @@ func GetXportIfOpen() (xport.Xport, error) {
 	return globalXport, nil
 }
 
-func buildSesnCfg() (sesn.SesnCfg, error) {
+func buildSesnCfg(cp *config.ConnProfile,
+	x xport.Xport) (sesn.SesnCfg, error) {
+
 	//// time.Sleep(100 * time.Millisecond) ////
 	sc := sesn.NewSesnCfg()
 
-	cp, err := getConnProfile()
-	if err != nil {
-		return sc, err
-	}
-
 	switch cp.Type {
 	case config.CONN_TYPE_SERIAL_PLAIN:
 		sc.MgmtProto = sesn.MGMT_PROTO_NMP
@@ -202,10 +227,6 @@ func buildSesnCfg() (sesn.SesnCfg, error) {
 			return sc, err
 		}
 
-		x, err := GetXport()
-		if err != nil {
-			return sc, err
-		}
 		bx := x.(*nmble.BleXport)
 
 		sc.MgmtProto = sesn.MGMT_PROTO_NMP
@@ -221,10 +242,6 @@ func buildSesnCfg() (sesn.SesnCfg, error) {
 			return sc, err
 		}
 
-		x, err := GetXport()
-		if err != nil {
-			return sc, err
-		}
 		bx := x.(*nmble.BleXport)
 
 		sc.MgmtProto = sesn.MGMT_PROTO_OMP
@@ -246,6 +263,18 @@ func buildSesnCfg() (sesn.SesnCfg, error) {
 
 		return sc, nil
 
+	case config.CONN_TYPE_TCP_PLAIN:
+		sc.MgmtProto = sesn.MGMT_PROTO_NMP
+		sc.PeerSpec.Tcp = cp.ConnString
+
+		return sc, nil
+
+	case config.CONN_TYPE_TCP_OIC:
+		sc.MgmtProto = sesn.MGMT_PROTO_OMP
+		sc.PeerSpec.Tcp = cp.ConnString
+
+		return sc, nil
+
 	case config.CONN_TYPE_MTECH_LORA_OIC:
 		mc, err := config.ParseMtechLoraConnString(cp.ConnString)
 		if err != nil {
@@ -262,7 +291,9 @@ func buildSesnCfg() (sesn.SesnCfg, error) {
 
 }
 
-func buildBllSesn(cp *config.ConnProfile) (sesn.Sesn, error) {
+func buildBllSesn(cp *config.ConnProfile,
+	x xport.Xport) (sesn.Sesn, error) {
+
 	_, task := trace.NewTask(context.Background(), "newtmgr/cli/common.go/buildBllSesn")
 	//// time.Sleep(100 * time.Millisecond) ////
 	defer task.End()
@@ -277,10 +308,6 @@ func buildBllSesn(cp *config.ConnProfile) (sesn.Sesn, error) {
 		return nil, err
 	}
 
-	x, err := GetXport()
-	if err != nil {
-		return nil, err
-	}
 	bx := x.(*bll.BllXport)
 
 	switch cp.Type {
@@ -305,6 +332,34 @@ func buildBllSesn(cp *config.ConnProfile) (sesn.Sesn, error) {
 	return s, nil
 }
 
+// Creates a session for the specified connection profile on a started
+// transport.  The session is not opened.
+func buildSesn(cp *config.ConnProfile, x xport.Xport) (sesn.Sesn, error) {
+	if cp.Type == config.CONN_TYPE_BLL_PLAIN ||
+		cp.Type == config.CONN_TYPE_BLL_OIC {
+
+		s, err := buildBllSesn(cp, x)
+		if err != nil {
+			return nil, util.ChildNewtError(err)
+		}
+		return s, nil
+	}
+
+	sc, err := buildSesnCfg(cp, x)
+	if err != nil {
+		return nil, err
+	}
+	sc.TxFilterCb = globalTxFilter
+	sc.RxFilterCb = globalRxFilter
+
+	s, err := x.BuildSesn(sc)
+	if err != nil {
+		return nil, util.ChildNewtError(err)
+	}
+
+	return s, nil
+}
+
 func GetSesn() (sesn.Sesn, error) {
 	_, task := trace.NewTask(context.Background(), "newtmgr/cli/common.go/GetSesn")
 	//// time.Sleep(100 * time.Millisecond) ////
@@ -319,31 +374,14 @@ func GetSesn() (sesn.Sesn, error) {
 		return nil, err
 	}
 
-	var s sesn.Sesn
-	if cp.Type == config.CONN_TYPE_BLL_PLAIN ||
-		cp.Type == config.CONN_TYPE_BLL_OIC {
-
-		s, err = buildBllSesn(cp)
-		if err != nil {
-			return nil, util.ChildNewtError(err)
-		}
-	} else {
-		sc, err := buildSesnCfg()
-		if err != nil {
-			return nil, err
-		}
-		sc.TxFilterCb = globalTxFilter
-		sc.RxFilterCb = globalRxFilter
-
-		x, err := GetXport()
-		if err != nil {
-			return nil, err
-		}
+	x, err := GetXport()
+	if err != nil {
+		return nil, err
+	}
 
-		s, err = x.BuildSesn(sc)
-		if err != nil {
-			return nil, util.ChildNewtError(err)
-		}
+	s, err := buildSesn(cp, x)
+	if err != nil {
+		return nil, err
 	}
 
 	globalSesn = s
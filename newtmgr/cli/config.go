@@ -30,6 +30,11 @@ import (
 	"mynewt.apache.org/newtmgr/nmxact/xact"
 )
 
+type configOutput struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
 func configRead(s sesn.Sesn, args []string) {
 	c := xact.NewConfigReadCmd()
 	c.SetTxOptions(nmutil.TxOptions())
@@ -41,11 +46,10 @@ func configRead(s sesn.Sesn, args []string) {
 	}
 
 	sres := res.(*xact.ConfigReadResult)
-	if sres.Rsp.Rc != 0 {
-		fmt.Printf("Error: %d\n", sres.Rsp.Rc)
-	} else {
+	out := configOutput{Name: c.Name, Value: sres.Rsp.Val}
+	printResult(out, func() {
 		fmt.Printf("Value: %s\n", sres.Rsp.Val)
-	}
+	})
 }
 
 func configWrite(s sesn.Sesn, args []string) {
@@ -54,17 +58,14 @@ func configWrite(s sesn.Sesn, args []string) {
 	c.Name = args[0]
 	c.Val = args[1]
 
-	res, err := c.Run(s)
-	if err != nil {
+	if _, err := c.Run(s); err != nil {
 		nmUsage(nil, util.ChildNewtError(err))
 	}
 
-	sres := res.(*xact.ConfigWriteResult)
-	if sres.Rsp.Rc != 0 {
-		fmt.Printf("Error: %d\n", sres.Rsp.Rc)
-	} else {
+	out := configOutput{Name: c.Name, Value: c.Val}
+	printResult(out, func() {
 		fmt.Printf("Done\n")
-	}
+	})
 }
 
 func configSave(s sesn.Sesn, args []string) {
@@ -72,17 +73,13 @@ func configSave(s sesn.Sesn, args []string) {
 	c.SetTxOptions(nmutil.TxOptions())
 	c.Save = true
 
-	res, err := c.Run(s)
-	if err != nil {
+	if _, err := c.Run(s); err != nil {
 		nmUsage(nil, util.ChildNewtError(err))
 	}
 
-	sres := res.(*xact.ConfigWriteResult)
-	if sres.Rsp.Rc != 0 {
-		fmt.Printf("Error: %d\n", sres.Rsp.Rc)
-	} else {
+	printResult(doneOutput{}, func() {
 		fmt.Printf("Done\n")
-	}
+	})
 }
 
 func configRunCmd(cmd *cobra.Command, args []string) {
@@ -107,7 +104,9 @@ func configRunCmd(cmd *cobra.Command, args []string) {
 func configCmd() *cobra.Command {
 	configCmdLongHelp := "Read or write a config value for <var-name> variable on " +
 		"a device.\nSpecify a var-value to write a value to a device.\n" +
-		"To persist existing configuration use 'save' as the var-name.\n"
+		"To persist existing configuration use 'save' as the var-name.\n" +
+		"Use the export, import, and diff commands to work with a file " +
+		"of config values, and dump to list the saved values.\n"
 	configEx := "    " + nmutil.ToolInfo.ExeName + " -c olimex config test/8\n"
 	configEx += "    " + nmutil.ToolInfo.ExeName + " -c olimex config test/8 1\n"
 	configEx += "    " + nmutil.ToolInfo.ExeName + " -c olimex config save\n"
@@ -119,5 +118,10 @@ func configCmd() *cobra.Command {
 		Run:     configRunCmd,
 	}
 
+	configCmd.AddCommand(configExportCmd())
+	configCmd.AddCommand(configImportCmd())
+	configCmd.AddCommand(configDiffCmd())
+	configCmd.AddCommand(configDumpCmd())
+
 	return configCmd
 }
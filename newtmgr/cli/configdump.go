@@ -0,0 +1,192 @@
+/**
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package cli
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+
+	"mynewt.apache.org/newt/util"
+	"mynewt.apache.org/newtmgr/newtmgr/nmutil"
+	"mynewt.apache.org/newtmgr/nmxact/nmp"
+	"mynewt.apache.org/newtmgr/nmxact/xact"
+)
+
+// Default location of Mynewt's config file store (CONFIG_FS_FILE).
+const CONFIG_STORE_PATH = "/cfg/run"
+
+var configDumpPath string
+var configDumpFormat string
+var configDumpOut string
+
+type configDumpOutput struct {
+	Path       string            `json:"path"`
+	Values     map[string]string `json:"values"`
+	Superseded int               `json:"superseded"`
+	Deleted    int               `json:"deleted"`
+}
+
+// Replays the contents of a Mynewt config store.  The store is a log of
+// "name=value" lines that is only appended to until it gets compressed, so
+// a later line for a setting supersedes the earlier ones, and a line with
+// an empty value deletes the setting.
+func parseConfigStore(path string, data []byte) (configDumpOutput, error) {
+	out := configDumpOutput{
+		Path:   path,
+		Values: map[string]string{},
+	}
+	deleted := map[string]bool{}
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for lineNum := 1; scanner.Scan(); lineNum++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+
+		i := strings.IndexByte(line, '=')
+		if i <= 0 {
+			log.Warnf("Skipping invalid line in config store (%s:%d): %s",
+				path, lineNum, line)
+			continue
+		}
+		name := strings.TrimSpace(line[:i])
+		val := strings.TrimSpace(line[i+1:])
+
+		if _, ok := out.Values[name]; ok {
+			out.Superseded++
+		}
+
+		if val == "" {
+			delete(out.Values, name)
+			deleted[name] = true
+		} else {
+			out.Values[name] = val
+			delete(deleted, name)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return out, util.FmtNewtError("error reading config store %s: %s",
+			path, err.Error())
+	}
+
+	out.Deleted = len(deleted)
+	return out, nil
+}
+
+func configDumpRunCmd(cmd *cobra.Command, args []string) {
+	if len(args) != 0 {
+		nmUsage(cmd, nil)
+	}
+	if configDumpOut != "" {
+		if err := checkConfigFormat(configDumpFormat); err != nil {
+			nmUsage(cmd, err)
+		}
+	}
+
+	s, err := GetSesn()
+	if err != nil {
+		nmUsage(nil, err)
+	}
+
+	var data []byte
+	c := xact.NewFsDownloadCmd()
+	c.SetTxOptions(nmutil.TxOptions())
+	c.Name = configDumpPath
+	c.ProgressCb = func(c *xact.FsDownloadCmd, rsp *nmp.FsDownloadRsp) {
+		data = append(data, rsp.Data...)
+	}
+
+	if _, err := c.Run(s); err != nil {
+		nmUsage(nil, configValError(configDumpPath, err))
+	}
+
+	out, err := parseConfigStore(configDumpPath, data)
+	if err != nil {
+		nmUsage(nil, err)
+	}
+
+	if configDumpOut != "" {
+		vals := make([]configOutput, 0, len(out.Values))
+		for name, val := range out.Values {
+			vals = append(vals, configOutput{Name: name, Value: val})
+		}
+
+		b, err := encodeConfigFile(vals, configDumpFormat)
+		if err != nil {
+			nmUsage(nil, util.ChildNewtError(err))
+		}
+		if err := ioutil.WriteFile(configDumpOut, b, 0644); err != nil {
+			nmUsage(nil, util.ChildNewtError(err))
+		}
+
+		eout := configExportOutput{File: configDumpOut, Values: len(vals)}
+		printResult(eout, func() {
+			fmt.Printf("Dumped %d values to %s\n", len(vals), configDumpOut)
+		})
+		return
+	}
+
+	printResult(out, func() {
+		names := make([]string, 0, len(out.Values))
+		for name := range out.Values {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		for _, name := range names {
+			fmt.Printf("%s=%s\n", name, out.Values[name])
+		}
+	})
+}
+
+func configDumpCmd() *cobra.Command {
+	ex := nmutil.ToolInfo.ExeName + " config dump -c myserial\n"
+	ex += nmutil.ToolInfo.ExeName +
+		" config dump --out board.yml -c myserial\n"
+
+	cmd := &cobra.Command{
+		Use:   "dump -c <conn_profile>",
+		Short: "Show all config values saved on a device",
+		Long: "Download a device's config store from its file system and " +
+			"show the saved settings.  Entries that were superseded by a " +
+			"later save, or deleted, are left out.  Settings that were " +
+			"never saved, or that are kept in another store, such as " +
+			"FCB, are not shown.",
+		Example: ex,
+		Run:     configDumpRunCmd,
+	}
+
+	cmd.Flags().StringVar(&configDumpPath, "path", CONFIG_STORE_PATH,
+		"path of the config store on the device")
+	cmd.Flags().StringVar(&configDumpOut, "out", "",
+		"write the values to this config file instead, for config import")
+	cmd.Flags().StringVar(&configDumpFormat, "format", OUTPUT_YAML,
+		"format of the file written with --out: yaml or json")
+
+	return cmd
+}
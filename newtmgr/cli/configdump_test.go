@@ -0,0 +1,113 @@
+/**
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package cli
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseConfigStore(t *testing.T) {
+	tests := []struct {
+		name       string
+		data       string
+		values     map[string]string
+		superseded int
+		deleted    int
+	}{
+		{
+			name:   "empty",
+			data:   "",
+			values: map[string]string{},
+		},
+		{
+			name: "values",
+			data: "a/x=1\nb/y=two words\n",
+			values: map[string]string{
+				"a/x": "1",
+				"b/y": "two words",
+			},
+		},
+		{
+			name:       "reassignment",
+			data:       "a/x=1\na/x=2\nb/y=3\na/x=4\n",
+			values:     map[string]string{"a/x": "4", "b/y": "3"},
+			superseded: 2,
+		},
+		{
+			name:       "deletion",
+			data:       "a/x=1\nb/y=2\na/x=\n",
+			values:     map[string]string{"b/y": "2"},
+			superseded: 1,
+			deleted:    1,
+		},
+		{
+			name:    "deletion of unknown setting",
+			data:    "a/x=\n",
+			values:  map[string]string{},
+			deleted: 1,
+		},
+		{
+			name:       "set after delete",
+			data:       "a/x=1\na/x=\na/x=5\n",
+			values:     map[string]string{"a/x": "5"},
+			superseded: 1,
+		},
+		{
+			name:   "whitespace",
+			data:   "  a/x =  1 \r\n\n\t\nb/y= =v\n",
+			values: map[string]string{"a/x": "1", "b/y": "=v"},
+		},
+		{
+			name:   "no trailing newline",
+			data:   "a/x=1\nb/y=2",
+			values: map[string]string{"a/x": "1", "b/y": "2"},
+		},
+		{
+			name:   "malformed lines",
+			data:   "junk\n=1\n  =2\na/x=1\n",
+			values: map[string]string{"a/x": "1"},
+		},
+	}
+
+	for _, tt := range tests {
+		out, err := parseConfigStore("/cfg/run", []byte(tt.data))
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if out.Path != "/cfg/run" ||
+			!reflect.DeepEqual(out.Values, tt.values) ||
+			out.Superseded != tt.superseded || out.Deleted != tt.deleted {
+
+			t.Errorf("%s: got %+v; want values %v, superseded %d, "+
+				"deleted %d", tt.name, out, tt.values, tt.superseded,
+				tt.deleted)
+		}
+	}
+
+	// A line too long for the scanner is an error rather than the end of
+	// the store.
+	long := "a/x=1\nb/y=" + strings.Repeat("v", 70000) + "\nc/z=3\n"
+	if _, err := parseConfigStore("/cfg/run", []byte(long)); err == nil {
+		t.Errorf("long line accepted")
+	}
+}
@@ -0,0 +1,501 @@
+/**
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v2"
+
+	"mynewt.apache.org/newt/util"
+	"mynewt.apache.org/newtmgr/newtmgr/nmutil"
+	"mynewt.apache.org/newtmgr/nmxact/nmp"
+	"mynewt.apache.org/newtmgr/nmxact/sesn"
+	"mynewt.apache.org/newtmgr/nmxact/xact"
+)
+
+// Outcomes of importing a single config value.
+const (
+	CONFIG_IMPORT_WRITTEN   = "written"
+	CONFIG_IMPORT_CHANGED   = "changed"
+	CONFIG_IMPORT_UNCHANGED = "unchanged"
+	CONFIG_IMPORT_FAILED    = "failed"
+)
+
+var configExportFormat string
+var configExportOut string
+var configExportKeys string
+var configImportDryRun bool
+var configImportContinue bool
+
+type configExportOutput struct {
+	File   string `json:"file"`
+	Values int    `json:"values"`
+}
+
+type configImportResult struct {
+	Name     string  `json:"name"`
+	Value    string  `json:"value"`
+	OldValue *string `json:"old_value,omitempty"`
+	Status   string  `json:"status"`
+	Error    string  `json:"error,omitempty"`
+}
+
+type configImportOutput struct {
+	File    string               `json:"file"`
+	DryRun  bool                 `json:"dry_run"`
+	Saved   bool                 `json:"saved"`
+	Results []configImportResult `json:"results"`
+}
+
+// A value that differs between a file and a device.  The device value is
+// nil if the device doesn't have the setting.
+type configDiff struct {
+	Name        string  `json:"name"`
+	FileValue   string  `json:"file_value"`
+	DeviceValue *string `json:"device_value"`
+}
+
+type configDiffOutput struct {
+	File        string       `json:"file"`
+	Differences []configDiff `json:"differences"`
+}
+
+// Converts a value from a config file to the string the device expects.
+// Unquoted YAML numbers and booleans are rejected rather than converted, since
+// the parser doesn't keep their text; e.g., 1.10 would become "1.1" and yes
+// would become "true".
+func configFileValue(itf interface{}) (string, error) {
+	v, ok := itf.(string)
+	if !ok {
+		return "", fmt.Errorf("value must be a quoted string")
+	}
+
+	return v, nil
+}
+
+// Reads a YAML or JSON file of config names and values, in the order they
+// appear in the file.
+func readConfigFile(filename string) ([]configOutput, error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, util.ChildNewtError(err)
+	}
+
+	// JSON is a subset of YAML, so one parser handles both formats.
+	var ms yaml.MapSlice
+	if err := yaml.Unmarshal(data, &ms); err != nil {
+		return nil, util.FmtNewtError("error parsing config file %s: %s",
+			filename, err.Error())
+	}
+
+	vals := make([]configOutput, 0, len(ms))
+	seen := map[string]bool{}
+	for _, item := range ms {
+		name, ok := item.Key.(string)
+		if !ok {
+			return nil, util.FmtNewtError(
+				"error parsing config file %s: setting name %v must be a "+
+					"quoted string", filename, item.Key)
+		}
+		if seen[name] {
+			return nil, util.FmtNewtError(
+				"error parsing config file %s: duplicate setting \"%s\"",
+				filename, name)
+		}
+		seen[name] = true
+
+		val, err := configFileValue(item.Value)
+		if err != nil {
+			return nil, util.FmtNewtError(
+				"error parsing config file %s: setting \"%s\": %s",
+				filename, name, err.Error())
+		}
+
+		vals = append(vals, configOutput{Name: name, Value: val})
+	}
+
+	return vals, nil
+}
+
+// Encodes config values as a file, sorted by name.
+func encodeConfigFile(vals []configOutput, format string) ([]byte, error) {
+	sort.Slice(vals, func(i, j int) bool {
+		return vals[i].Name < vals[j].Name
+	})
+
+	if format == OUTPUT_JSON {
+		m := make(map[string]string, len(vals))
+		for _, v := range vals {
+			m[v.Name] = v.Value
+		}
+
+		b, err := json.MarshalIndent(m, "", "    ")
+		if err != nil {
+			return nil, err
+		}
+		return append(b, '\n'), nil
+	}
+
+	ms := make(yaml.MapSlice, len(vals))
+	for i, v := range vals {
+		ms[i] = yaml.MapItem{Key: v.Name, Value: v.Value}
+	}
+	return yaml.Marshal(ms)
+}
+
+// Wraps an error from a config command so that its message names the
+// setting.  The device's status code is kept for the exit status.
+func configValError(name string, err error) error {
+	nerr := util.ChildNewtError(err)
+	nerr.Text = fmt.Sprintf("%s: %s", name, nerr.Text)
+	return nerr
+}
+
+func readConfigVal(s sesn.Sesn, name string) (string, error) {
+	c := xact.NewConfigReadCmd()
+	c.SetTxOptions(nmutil.TxOptions())
+	c.Name = name
+
+	res, err := c.Run(s)
+	if err != nil {
+		return "", configValError(name, err)
+	}
+
+	return res.(*xact.ConfigReadResult).Rsp.Val, nil
+}
+
+func writeConfigVal(s sesn.Sesn, name string, val string) error {
+	c := xact.NewConfigWriteCmd()
+	c.SetTxOptions(nmutil.TxOptions())
+	c.Name = name
+	c.Val = val
+
+	if _, err := c.Run(s); err != nil {
+		return configValError(name, err)
+	}
+
+	return nil
+}
+
+func saveConfig(s sesn.Sesn) error {
+	c := xact.NewConfigWriteCmd()
+	c.SetTxOptions(nmutil.TxOptions())
+	c.Save = true
+
+	if _, err := c.Run(s); err != nil {
+		return util.ChildNewtError(err)
+	}
+
+	return nil
+}
+
+func checkConfigFormat(format string) error {
+	if format != OUTPUT_YAML && format != OUTPUT_JSON {
+		return util.FmtNewtError(
+			"invalid format \"%s\"; expected yaml or json", format)
+	}
+	return nil
+}
+
+func configExportRunCmd(cmd *cobra.Command, args []string) {
+	if err := checkConfigFormat(configExportFormat); err != nil {
+		nmUsage(cmd, err)
+	}
+
+	names := args
+	if configExportKeys != "" {
+		vals, err := readConfigFile(configExportKeys)
+		if err != nil {
+			nmUsage(nil, err)
+		}
+		for _, v := range vals {
+			names = append(names, v.Name)
+		}
+	}
+	if len(names) == 0 {
+		nmUsage(cmd, util.NewNewtError("no config names specified"))
+	}
+
+	s, err := GetSesn()
+	if err != nil {
+		nmUsage(nil, err)
+	}
+
+	vals := []configOutput{}
+	seen := map[string]bool{}
+	for _, name := range names {
+		if seen[name] {
+			continue
+		}
+		seen[name] = true
+
+		val, err := readConfigVal(s, name)
+		if err != nil {
+			nmUsage(nil, err)
+		}
+		vals = append(vals, configOutput{Name: name, Value: val})
+	}
+
+	b, err := encodeConfigFile(vals, configExportFormat)
+	if err != nil {
+		nmUsage(nil, util.ChildNewtError(err))
+	}
+
+	if configExportOut == "" {
+		if _, err := os.Stdout.Write(b); err != nil {
+			nmUsage(nil, util.ChildNewtError(err))
+		}
+		return
+	}
+
+	if err := ioutil.WriteFile(configExportOut, b, 0644); err != nil {
+		nmUsage(nil, util.ChildNewtError(err))
+	}
+
+	out := configExportOutput{File: configExportOut, Values: len(vals)}
+	printResult(out, func() {
+		fmt.Printf("Exported %d values to %s\n", len(vals), configExportOut)
+	})
+}
+
+func configImportRunCmd(cmd *cobra.Command, args []string) {
+	if len(args) != 1 {
+		nmUsage(cmd, nil)
+	}
+
+	vals, err := readConfigFile(args[0])
+	if err != nil {
+		nmUsage(nil, err)
+	}
+
+	s, err := GetSesn()
+	if err != nil {
+		nmUsage(nil, err)
+	}
+
+	out := configImportOutput{
+		File:    args[0],
+		DryRun:  configImportDryRun,
+		Results: []configImportResult{},
+	}
+
+	// An error response for one setting only stops the import if the user
+	// asked for that; any other error means the device can't be reached.
+	var firstErr error
+	written := 0
+	for _, v := range vals {
+		r := configImportResult{Name: v.Name, Value: v.Value}
+
+		var err error
+		if configImportDryRun {
+			var cur string
+			cur, err = readConfigVal(s, v.Name)
+			if err == nil {
+				r.OldValue = &cur
+				if cur == v.Value {
+					r.Status = CONFIG_IMPORT_UNCHANGED
+					printInfo("%s: unchanged\n", v.Name)
+				} else {
+					r.Status = CONFIG_IMPORT_CHANGED
+					printInfo("%s: %q -> %q\n", v.Name, cur, v.Value)
+				}
+			}
+		} else {
+			err = writeConfigVal(s, v.Name, v.Value)
+			if err == nil {
+				r.Status = CONFIG_IMPORT_WRITTEN
+				printInfo("%s: %q\n", v.Name, v.Value)
+				written++
+			}
+		}
+
+		if err != nil {
+			if !configImportContinue || !isNmpError(err) {
+				nmUsage(nil, err)
+			}
+
+			r.Status = CONFIG_IMPORT_FAILED
+			r.Error = err.Error()
+			printInfo("%s: error: %s\n", v.Name, err.Error())
+			if firstErr == nil {
+				firstErr = err
+			}
+		}
+
+		out.Results = append(out.Results, r)
+	}
+
+	// Values are saved together, so that an import that stops partway
+	// leaves the saved config as it was.
+	if written > 0 {
+		if err := saveConfig(s); err != nil {
+			nmUsage(nil, err)
+		}
+		out.Saved = true
+	}
+
+	printResult(out, func() {
+		if configImportDryRun {
+			fmt.Printf("Dry run; no values written\n")
+		} else {
+			fmt.Printf("Wrote and saved %d of %d values\n",
+				written, len(vals))
+		}
+	})
+
+	if firstErr != nil {
+		NmExit(exitStatus(firstErr))
+	}
+}
+
+// Indicates whether an error from a config read means the setting doesn't
+// exist.  Mynewt responds with EINVAL; some devices respond with ENOENT.
+func isConfigNotFound(err error) bool {
+	nmpErr := nmpErrorOf(err)
+	return nmpErr != nil && (nmpErr.Rc == nmp.NMP_ERR_EINVAL ||
+		nmpErr.Rc == nmp.NMP_ERR_ENOENT)
+}
+
+func configDiffRunCmd(cmd *cobra.Command, args []string) {
+	if len(args) != 1 {
+		nmUsage(cmd, nil)
+	}
+
+	vals, err := readConfigFile(args[0])
+	if err != nil {
+		nmUsage(nil, err)
+	}
+
+	s, err := GetSesn()
+	if err != nil {
+		nmUsage(nil, err)
+	}
+
+	out := configDiffOutput{File: args[0], Differences: []configDiff{}}
+	for _, v := range vals {
+		d := configDiff{Name: v.Name, FileValue: v.Value}
+
+		cur, err := readConfigVal(s, v.Name)
+		if err == nil {
+			if cur == v.Value {
+				continue
+			}
+			d.DeviceValue = &cur
+		} else if !isConfigNotFound(err) {
+			nmUsage(nil, err)
+		}
+
+		out.Differences = append(out.Differences, d)
+	}
+
+	printResult(out, func() {
+		for _, d := range out.Differences {
+			if d.DeviceValue == nil {
+				fmt.Printf("%s: file %q, not on device\n",
+					d.Name, d.FileValue)
+			} else {
+				fmt.Printf("%s: file %q, device %q\n",
+					d.Name, d.FileValue, *d.DeviceValue)
+			}
+		}
+		if len(out.Differences) == 0 {
+			fmt.Printf("No differences\n")
+		}
+	})
+
+	if len(out.Differences) > 0 {
+		NmExit(EXIT_DIFFERENT)
+	}
+}
+
+func configExportCmd() *cobra.Command {
+	ex := nmutil.ToolInfo.ExeName +
+		" config export id/serial ble/name --out board.yml -c myserial\n"
+	ex += nmutil.ToolInfo.ExeName +
+		" config export --keys template.yml --format json -c myserial\n"
+
+	cmd := &cobra.Command{
+		Use:   "export [var-name ...] -c <conn_profile>",
+		Short: "Save config values from a device to a file",
+		Long: "Read the named config values from a device and write them " +
+			"to a YAML or JSON file that can be loaded with config " +
+			"import.  Devices can't list their settings, so the names " +
+			"are given as arguments, or taken from an existing file " +
+			"with --keys.",
+		Example: ex,
+		Run:     configExportRunCmd,
+	}
+
+	cmd.Flags().StringVar(&configExportFormat, "format", OUTPUT_YAML,
+		"file format: yaml or json")
+	cmd.Flags().StringVar(&configExportOut, "out", "",
+		"file to write; default is stdout")
+	cmd.Flags().StringVar(&configExportKeys, "keys", "",
+		"read the names of the values to export from this config file")
+
+	return cmd
+}
+
+func configImportCmd() *cobra.Command {
+	ex := nmutil.ToolInfo.ExeName +
+		" config import board.yml -c myserial\n"
+	ex += nmutil.ToolInfo.ExeName +
+		" config import --dry-run board.yml -c myserial\n"
+
+	cmd := &cobra.Command{
+		Use:   "import <file> -c <conn_profile>",
+		Short: "Write config values from a file to a device",
+		Long: "Write each value in a YAML or JSON file of config names " +
+			"and values to a device, in the order they appear in the " +
+			"file, then save them.  If the device rejects a value, " +
+			"nothing is saved, unless --continue is specified.",
+		Example: ex,
+		Run:     configImportRunCmd,
+	}
+
+	cmd.Flags().BoolVar(&configImportDryRun, "dry-run", false,
+		"show which values would change without writing them")
+	cmd.Flags().BoolVar(&configImportContinue, "continue", false,
+		"keep going if the device rejects a value, and save the rest")
+
+	return cmd
+}
+
+func configDiffCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "diff <file> -c <conn_profile>",
+		Short: "Compare config values in a file with a device",
+		Long: "Read each setting in a YAML or JSON file of config names " +
+			"and values from a device, and show the ones whose value " +
+			"differs or that the device doesn't have.  Exits with status " +
+			"3 if there are differences.",
+		Example: nmutil.ToolInfo.ExeName +
+			" config diff board.yml -c myserial\n",
+		Run: configDiffRunCmd,
+	}
+
+	return cmd
+}
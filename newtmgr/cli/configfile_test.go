@@ -0,0 +1,162 @@
+/**
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package cli
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"mynewt.apache.org/newt/util"
+	"mynewt.apache.org/newtmgr/nmxact/nmp"
+)
+
+func writeTestConfigFile(t *testing.T, data string) string {
+	dir, err := ioutil.TempDir("", "configfile")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	path := filepath.Join(dir, "config")
+	if err := ioutil.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestConfigFileRoundTrip(t *testing.T) {
+	vals := []configOutput{
+		{"ver/str", "1.10"},
+		{"id/octal", "0012"},
+		{"id/hex", "0x1F"},
+		{"ble/on", "on"},
+		{"ble/yes", "yes"},
+		{"ble/true", "true"},
+		{"app/null", "null"},
+		{"app/tilde", "~"},
+		{"app/empty", ""},
+		{"app/colon", "a: b"},
+		{"app/space", " lead and trail "},
+		{"app/lines", "one\ntwo"},
+		{"app/quote", `say "hi"`},
+	}
+
+	for _, format := range []string{OUTPUT_YAML, OUTPUT_JSON} {
+		in := append([]configOutput(nil), vals...)
+		data, err := encodeConfigFile(in, format)
+		if err != nil {
+			t.Fatalf("%s: encode: %v", format, err)
+		}
+
+		got, err := readConfigFile(writeTestConfigFile(t, string(data)))
+		if err != nil {
+			t.Fatalf("%s: read: %v\n%s", format, err, data)
+		}
+
+		// The file is sorted by name.
+		if !reflect.DeepEqual(got, in) {
+			t.Errorf("%s: got %v, want %v\n%s", format, got, in, data)
+		}
+	}
+}
+
+func TestReadConfigFile(t *testing.T) {
+	got, err := readConfigFile(writeTestConfigFile(t,
+		"b/x: \"1.10\"\na/y: 'yes'\n"))
+	want := []configOutput{{"b/x", "1.10"}, {"a/y", "yes"}}
+	if err != nil || !reflect.DeepEqual(got, want) {
+		t.Errorf("read: got %v, %v; want %v", got, err, want)
+	}
+
+	got, err = readConfigFile(writeTestConfigFile(t,
+		`{"b/x": "0x1F", "a/y": ""}`))
+	want = []configOutput{{"b/x", "0x1F"}, {"a/y", ""}}
+	if err != nil || !reflect.DeepEqual(got, want) {
+		t.Errorf("read JSON: got %v, %v; want %v", got, err, want)
+	}
+
+	// Values that the parser wouldn't keep as written are rejected.
+	bad := []string{
+		"a/x: 1.10\n",
+		"a/x: 0012\n",
+		"a/x: 0x1F\n",
+		"a/x: yes\n",
+		"a/x: on\n",
+		"a/x: true\n",
+		"a/x: ~\n",
+		"a/x:\n",
+		"a/x: [1, 2]\n",
+		"a/x: {b: c}\n",
+		`{"a/x": 5}`,
+		"12: \"x\"\n",
+		"a/x: \"1\"\na/x: \"2\"\n",
+		"a/x: \"1\n",
+		"- a\n",
+	}
+	for _, data := range bad {
+		if vals, err := readConfigFile(writeTestConfigFile(t,
+			data)); err == nil {
+
+			t.Errorf("%q: accepted as %v", data, vals)
+		}
+	}
+
+	if _, err := readConfigFile(filepath.Join(os.TempDir(),
+		"no-such-config-file")); err == nil {
+
+		t.Errorf("missing file accepted")
+	}
+}
+
+func TestConfigNotFound(t *testing.T) {
+	dev, s := newEmuTestSesn(t)
+	dev.SetConfig("app/mode", "a")
+
+	if _, err := readConfigVal(s, "app/mode"); err != nil {
+		t.Fatalf("read app/mode: %v", err)
+	}
+
+	// The emulator responds like Mynewt, with EINVAL.
+	_, err := readConfigVal(s, "app/bogus")
+	if !isConfigNotFound(err) {
+		t.Errorf("read app/bogus: want not found, got %v", err)
+	}
+
+	enoent := &nmp.NmpError{
+		Op:    nmp.NMP_OP_READ_RSP,
+		Group: nmp.NMP_GROUP_CONFIG,
+		Rc:    nmp.NMP_ERR_ENOENT,
+	}
+	if !isConfigNotFound(configValError("x", enoent)) {
+		t.Errorf("ENOENT: want not found")
+	}
+
+	enomem := *enoent
+	enomem.Rc = nmp.NMP_ERR_ENOMEM
+	if isConfigNotFound(configValError("x", &enomem)) {
+		t.Errorf("ENOMEM: want other error")
+	}
+	if isConfigNotFound(util.NewNewtError("timeout")) {
+		t.Errorf("local error: want other error")
+	}
+}
@@ -30,6 +30,16 @@ import (
 	"github.com/spf13/cobra"
 )
 
+type connProfileOutput struct {
+	Profiles []connProfileEntry `json:"profiles"`
+}
+
+type connProfileEntry struct {
+	Name       string `json:"name"`
+	Type       string `json:"type"`
+	ConnString string `json:"connstring"`
+}
+
 func connProfileAddCmd(cmd *cobra.Command, args []string) {
 	cpm := config.GlobalConnProfileMgr()
 
@@ -69,7 +79,9 @@ func connProfileAddCmd(cmd *cobra.Command, args []string) {
 		nmUsage(cmd, err)
 	}
 
-	fmt.Printf("Connection profile %s successfully added\n", name)
+	printResult(doneOutput{}, func() {
+		fmt.Printf("Connection profile %s successfully added\n", name)
+	})
 }
 
 func connProfileShowCmd(cmd *cobra.Command, args []string) {
@@ -85,29 +97,38 @@ func connProfileShowCmd(cmd *cobra.Command, args []string) {
 		nmUsage(cmd, err)
 	}
 
-	found := false
+	out := connProfileOutput{Profiles: []connProfileEntry{}}
 	for _, cp := range cpList {
-		// Print out the connection profile, if name is "" or name
-		// matches cp.Name
+		// Include the connection profile if name is "" or name matches
+		// cp.Name
 		if name != "" && cp.Name != name {
 			continue
 		}
 
-		if !found {
-			found = true
-			fmt.Printf("Connection profiles: \n")
-		}
-		fmt.Printf("  %s: type=%s, connstring='%s'\n",
-			cp.Name, config.ConnTypeToString(cp.Type), cp.ConnString)
+		out.Profiles = append(out.Profiles, connProfileEntry{
+			Name:       cp.Name,
+			Type:       config.ConnTypeToString(cp.Type),
+			ConnString: cp.ConnString,
+		})
 	}
 
-	if !found {
-		if name == "" {
-			fmt.Printf("No connection profiles found!\n")
-		} else {
-			fmt.Printf("No connection profiles found matching %s\n", name)
+	printResult(out, func() {
+		if len(out.Profiles) == 0 {
+			if name == "" {
+				fmt.Printf("No connection profiles found!\n")
+			} else {
+				fmt.Printf("No connection profiles found matching %s\n",
+					name)
+			}
+			return
 		}
-	}
+
+		fmt.Printf("Connection profiles: \n")
+		for _, cp := range out.Profiles {
+			fmt.Printf("  %s: type=%s, connstring='%s'\n",
+				cp.Name, cp.Type, cp.ConnString)
+		}
+	})
 }
 
 func connProfileDelCmd(cmd *cobra.Command, args []string) {
@@ -123,7 +144,9 @@ func connProfileDelCmd(cmd *cobra.Command, args []string) {
 		nmUsage(cmd, err)
 	}
 
-	fmt.Printf("Connection profile %s successfully deleted.\n", name)
+	printResult(doneOutput{}, func() {
+		fmt.Printf("Connection profile %s successfully deleted.\n", name)
+	})
 }
 
 func connProfileCmd() *cobra.Command {
@@ -49,17 +49,13 @@ func crashRunCmd(cmd *cobra.Command, args []string) {
 	c.SetTxOptions(nmutil.TxOptions())
 	c.CrashType = ct
 
-	res, err := c.Run(s)
-	if err != nil {
+	if _, err := c.Run(s); err != nil {
 		nmUsage(nil, util.ChildNewtError(err))
 	}
 
-	sres := res.(*xact.CrashResult)
-	if sres.Rsp.Rc != 0 {
-		fmt.Printf("Error: %d\n", sres.Rsp.Rc)
-	} else {
+	printResult(doneOutput{}, func() {
 		fmt.Printf("Done\n")
-	}
+	})
 }
 
 func crashCmd() *cobra.Command {
@@ -31,6 +31,10 @@ import (
 	"mynewt.apache.org/newtmgr/nmxact/xact"
 )
 
+type dateTimeOutput struct {
+	DateTime string `json:"datetime"`
+}
+
 func dateTimeRead(s sesn.Sesn) error {
 	c := xact.NewDateTimeReadCmd()
 	c.SetTxOptions(nmutil.TxOptions())
@@ -41,7 +45,9 @@ func dateTimeRead(s sesn.Sesn) error {
 	}
 
 	sres := res.(*xact.DateTimeReadResult)
-	fmt.Println("Datetime(RFC 3339 format):", sres.Rsp.DateTime)
+	printResult(dateTimeOutput{DateTime: sres.Rsp.DateTime}, func() {
+		fmt.Println("Datetime(RFC 3339 format):", sres.Rsp.DateTime)
+	})
 
 	return nil
 }
@@ -54,20 +60,16 @@ func dateTimeWrite(s sesn.Sesn, args []string) error {
 		c.DateTime = args[0]
 	} else {
 		c.DateTime = time.Now().Format(time.RFC3339)
-		fmt.Printf("Setting time to %s\n", c.DateTime)
+		printInfo("Setting time to %s\n", c.DateTime)
 	}
 
-	res, err := c.Run(s)
-	if err != nil {
+	if _, err := c.Run(s); err != nil {
 		return util.ChildNewtError(err)
 	}
 
-	sres := res.(*xact.DateTimeWriteResult)
-	if sres.Rsp.Rc != 0 {
-		fmt.Printf("Error: %d\n", sres.Rsp.Rc)
-	} else {
+	printResult(dateTimeOutput{DateTime: c.DateTime}, func() {
 		fmt.Printf("Done\n")
-	}
+	})
 
 	return nil
 }
@@ -29,6 +29,10 @@ import (
 	"mynewt.apache.org/newtmgr/nmxact/xact"
 )
 
+type echoOutput struct {
+	Payload string `json:"payload"`
+}
+
 func echoRunCmd(cmd *cobra.Command, args []string) {
 	if len(args) != 1 {
 		nmUsage(cmd, nil)
@@ -49,7 +53,9 @@ func echoRunCmd(cmd *cobra.Command, args []string) {
 	}
 
 	eres := res.(*xact.EchoResult)
-	fmt.Println(eres.Rsp.Payload)
+	printResult(echoOutput{Payload: eres.Rsp.Payload}, func() {
+		fmt.Println(eres.Rsp.Payload)
+	})
 }
 
 func echoCmd() *cobra.Command {
@@ -0,0 +1,229 @@
+/**
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package cli
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"mynewt.apache.org/newt/util"
+	"mynewt.apache.org/newtmgr/nmxact/emulator"
+	"mynewt.apache.org/newtmgr/nmxact/nmp"
+)
+
+var emuUdpAddr string
+var emuLink string
+var emuLatency time.Duration
+var emuMtu int
+var emuInjectRcs []string
+
+var nmpGroupNames = map[string]uint16{
+	"default": nmp.NMP_GROUP_DEFAULT,
+	"image":   nmp.NMP_GROUP_IMAGE,
+	"stat":    nmp.NMP_GROUP_STAT,
+	"config":  nmp.NMP_GROUP_CONFIG,
+	"log":     nmp.NMP_GROUP_LOG,
+	"crash":   nmp.NMP_GROUP_CRASH,
+	"run":     nmp.NMP_GROUP_RUN,
+	"fs":      nmp.NMP_GROUP_FS,
+	"shell":   nmp.NMP_GROUP_SHELL,
+}
+
+// Parses an NMP group, given either as a number or as one of the standard
+// group names.
+func parseNmpGroup(s string) (uint16, error) {
+	if group, ok := nmpGroupNames[strings.ToLower(s)]; ok {
+		return group, nil
+	}
+
+	g, err := strconv.ParseUint(s, 0, 16)
+	if err != nil {
+		return 0, err
+	}
+	return uint16(g), nil
+}
+
+// Parses a fault specification of the form <group>:<id>=<rc>[x<count>].
+// The group may be numeric or one of the standard group names.
+func parseInjectRc(spec string) (uint16, uint8, int, int, error) {
+	bad := util.FmtNewtError("invalid --inject-rc value \"%s\"; "+
+		"expected <group>:<id>=<rc>[x<count>]", spec)
+
+	eq := strings.SplitN(spec, "=", 2)
+	if len(eq) != 2 {
+		return 0, 0, 0, 0, bad
+	}
+	gi := strings.SplitN(eq[0], ":", 2)
+	if len(gi) != 2 {
+		return 0, 0, 0, 0, bad
+	}
+
+	group, err := parseNmpGroup(gi[0])
+	if err != nil {
+		return 0, 0, 0, 0, bad
+	}
+
+	id, err := strconv.ParseUint(gi[1], 0, 8)
+	if err != nil {
+		return 0, 0, 0, 0, bad
+	}
+
+	rcStr := eq[1]
+	count := 0
+	if x := strings.IndexByte(rcStr, 'x'); x >= 0 {
+		count, err = strconv.Atoi(rcStr[x+1:])
+		if err != nil || count <= 0 {
+			return 0, 0, 0, 0, bad
+		}
+		rcStr = rcStr[:x]
+	}
+
+	rc, err := strconv.Atoi(rcStr)
+	if err != nil {
+		return 0, 0, 0, 0, bad
+	}
+
+	return group, uint8(id), rc, count, nil
+}
+
+func emulateRunCmd(cmd *cobra.Command, args []string) {
+	if len(args) != 0 {
+		nmUsage(cmd, nil)
+	}
+
+	if emuMtu < 0 {
+		nmUsage(cmd, util.FmtNewtError("invalid MTU: %d", emuMtu))
+	}
+
+	d := emulator.NewDevice()
+	d.SetLatency(emuLatency)
+	d.SetMtu(emuMtu)
+	for _, spec := range emuInjectRcs {
+		group, id, rc, count, err := parseInjectRc(spec)
+		if err != nil {
+			nmUsage(cmd, err)
+		}
+		d.InjectRc(group, id, rc, count)
+	}
+
+	errChan := make(chan error, 1)
+	var cleanup func()
+
+	// The emulator runs until interrupted, so that is a normal stop.  The
+	// transport is cleaned up by NmExit, however the command ends.
+	interruptStatus = 0
+	prevOnExit := onExit
+	onExit = func() {
+		if cleanup != nil {
+			cleanup()
+		}
+		if prevOnExit != nil {
+			prevOnExit()
+		}
+	}
+
+	if emuUdpAddr != "" {
+		addr, err := net.ResolveUDPAddr("udp", emuUdpAddr)
+		if err != nil {
+			nmUsage(nil, util.ChildNewtError(err))
+		}
+		conn, err := net.ListenUDP("udp", addr)
+		if err != nil {
+			nmUsage(nil, util.ChildNewtError(err))
+		}
+		cleanup = func() { conn.Close() }
+
+		fmt.Printf("Emulated device listening on udp %s\n",
+			conn.LocalAddr().String())
+		go func() { errChan <- emulator.ServeUdp(d, conn) }()
+	} else {
+		p, err := emulator.OpenPty()
+		if err != nil {
+			nmUsage(nil, util.ChildNewtError(err))
+		}
+
+		devPath := p.Name
+		if emuLink != "" {
+			os.Remove(emuLink)
+			if err := os.Symlink(p.Name, emuLink); err != nil {
+				p.Close()
+				nmUsage(nil, util.ChildNewtError(err))
+			}
+			devPath = emuLink
+		}
+		cleanup = func() {
+			if emuLink != "" {
+				os.Remove(emuLink)
+			}
+			p.Close()
+		}
+
+		fmt.Printf("Emulated device listening on serial %s\n", devPath)
+		fmt.Printf("Connect with: --conntype serial --connstring "+
+			"\"dev=%s\"\n", devPath)
+		go func() { errChan <- emulator.ServeSerial(d, p.Master) }()
+	}
+
+	if err := <-errChan; err != nil {
+		nmUsage(nil, util.ChildNewtError(err))
+	}
+	NmExit(0)
+}
+
+func emulateCmd() *cobra.Command {
+	emulateEx := "  newtmgr emulate\n" +
+		"  newtmgr emulate --link /tmp/nmgr-dev --latency 20ms\n" +
+		"  newtmgr emulate --udp 127.0.0.1:1337 --mtu 256\n" +
+		"  newtmgr emulate --inject-rc image:1=2x3\n"
+
+	emulateCmd := &cobra.Command{
+		Use:   "emulate [flags]",
+		Short: "Run an emulated device",
+		Long: "Run an emulated device that responds to newtmgr requests.  " +
+			"By default, the device is served over a pseudo-terminal " +
+			"using the serial framing; use --udp to serve it over UDP " +
+			"instead.  Both plain NMP and OMP requests are accepted.  " +
+			"Runs until interrupted.",
+		Example: emulateEx,
+		Run:     emulateRunCmd,
+	}
+
+	emulateCmd.Flags().StringVar(&emuUdpAddr, "udp", "",
+		"serve over UDP on the specified address instead of a "+
+			"pseudo-terminal")
+	emulateCmd.Flags().StringVar(&emuLink, "link", "",
+		"create a symlink to the pseudo-terminal at the specified path")
+	emulateCmd.Flags().DurationVar(&emuLatency, "latency", 0,
+		"delay every response by the specified duration")
+	emulateCmd.Flags().IntVar(&emuMtu, "mtu", 0,
+		"drop requests larger than the specified number of bytes "+
+			"(0 = no limit)")
+	emulateCmd.Flags().StringSliceVar(&emuInjectRcs, "inject-rc", nil,
+		"answer matching requests with an error code; "+
+			"<group>:<id>=<rc>[x<count>] (repeatable)")
+
+	return emulateCmd
+}
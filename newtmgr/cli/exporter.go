@@ -0,0 +1,488 @@
+/**
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package cli
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+
+	"mynewt.apache.org/newt/util"
+	"mynewt.apache.org/newtmgr/newtmgr/config"
+	"mynewt.apache.org/newtmgr/newtmgr/nmutil"
+	"mynewt.apache.org/newtmgr/nmxact/sesn"
+	"mynewt.apache.org/newtmgr/nmxact/xact"
+	"mynewt.apache.org/newtmgr/nmxact/xport"
+)
+
+var exporterListen string
+var exporterInterval time.Duration
+
+// The most recent readings from a device.  Sections that the device doesn't
+// support are nil.
+type exporterSnapshot struct {
+	stats  map[string]map[string]int64
+	tasks  map[string]map[string]int
+	mpools map[string]map[string]int
+}
+
+// A device being scraped.  Each device has its own session, which is
+// reopened if the connection is lost.
+type exporterDevice struct {
+	profile string
+	s       sesn.Sesn
+
+	mtx        sync.Mutex
+	up         bool
+	scrapeTime time.Time
+	scrapeDur  time.Duration
+	snap       exporterSnapshot
+}
+
+// Runs a command, treating an error response from the device as "not
+// supported" rather than as a failure.
+func runOptionalCmd(s sesn.Sesn, c xact.Cmd) (xact.Result, error) {
+	c.SetTxOptions(nmutil.TxOptions())
+
+	res, err := c.Run(s)
+	if err != nil {
+		if isNmpError(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return res, nil
+}
+
+func (d *exporterDevice) read() (exporterSnapshot, error) {
+	var snap exporterSnapshot
+
+	res, err := runOptionalCmd(d.s, xact.NewStatListCmd())
+	if err != nil {
+		return snap, err
+	}
+	if res != nil {
+		snap.stats = map[string]map[string]int64{}
+		for _, group := range res.(*xact.StatListResult).Rsp.List {
+			c := xact.NewStatReadCmd()
+			c.Name = group
+
+			res, err := runOptionalCmd(d.s, c)
+			if err != nil {
+				return snap, err
+			}
+			if res == nil {
+				continue
+			}
+
+			fields := map[string]int64{}
+			for name, itf := range res.(*xact.StatReadResult).Rsp.Fields {
+				if val, ok := statInt(itf); ok {
+					fields[name] = val
+				}
+			}
+			snap.stats[group] = fields
+		}
+	}
+
+	res, err = runOptionalCmd(d.s, xact.NewTaskStatCmd())
+	if err != nil {
+		return snap, err
+	}
+	if res != nil {
+		snap.tasks = res.(*xact.TaskStatResult).Rsp.Tasks
+	}
+
+	res, err = runOptionalCmd(d.s, xact.NewMempoolStatCmd())
+	if err != nil {
+		return snap, err
+	}
+	if res != nil {
+		snap.mpools = res.(*xact.MempoolStatResult).Rsp.Mpools
+	}
+
+	return snap, nil
+}
+
+// Reads everything from the device.  If the device can't be reached, it is
+// reported as down and its readings are discarded, so that stale values
+// aren't exported.
+func (d *exporterDevice) scrape() {
+	start := time.Now()
+
+	var snap exporterSnapshot
+	var err error
+	if !d.s.IsOpen() {
+		err = d.s.Open()
+	}
+	if err == nil {
+		snap, err = d.read()
+	}
+
+	d.mtx.Lock()
+	defer d.mtx.Unlock()
+
+	if err != nil {
+		if d.up || d.scrapeTime.IsZero() {
+			log.Warnf("Device %s is down: %s", d.profile, err.Error())
+		}
+		snap = exporterSnapshot{}
+	} else if !d.up && !d.scrapeTime.IsZero() {
+		log.Infof("Device %s is up", d.profile)
+	}
+
+	d.up = err == nil
+	d.snap = snap
+	d.scrapeTime = start
+	d.scrapeDur = time.Since(start)
+}
+
+func (d *exporterDevice) run(interval time.Duration) {
+	for {
+		d.scrape()
+		time.Sleep(interval)
+	}
+}
+
+// A metric in the Prometheus text format.  All samples of a metric must be
+// written together, so samples from every device are collected before
+// anything is written.
+type promMetric struct {
+	name    string
+	help    string
+	typ     string
+	samples []promSample
+}
+
+type promSample struct {
+	labels []string // Alternating names and values.
+	value  float64
+}
+
+func (m *promMetric) add(value float64, labels ...string) {
+	m.samples = append(m.samples, promSample{labels: labels, value: value})
+}
+
+var promLabelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func (m *promMetric) write(w io.Writer) {
+	if len(m.samples) == 0 {
+		return
+	}
+
+	fmt.Fprintf(w, "# HELP %s %s\n", m.name, m.help)
+	fmt.Fprintf(w, "# TYPE %s %s\n", m.name, m.typ)
+	for _, s := range m.samples {
+		var labels []string
+		for i := 0; i+1 < len(s.labels); i += 2 {
+			labels = append(labels, fmt.Sprintf("%s=\"%s\"",
+				s.labels[i], promLabelEscaper.Replace(s.labels[i+1])))
+		}
+
+		fmt.Fprintf(w, "%s{%s} %s\n", m.name, strings.Join(labels, ","),
+			strconv.FormatFloat(s.value, 'g', -1, 64))
+	}
+}
+
+func sortedStatGroups(m map[string]map[string]int64) []string {
+	names := make([]string, 0, len(m))
+	for k := range m {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+func sortedStatFields(m map[string]int64) []string {
+	names := make([]string, 0, len(m))
+	for k := range m {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// Returns the names of the tasks or mempools in a task or mempool stat
+// response.
+func sortedEntryNames(m map[string]map[string]int) []string {
+	names := make([]string, 0, len(m))
+	for k := range m {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// Task and mempool fields, as reported by the device, and the metrics they
+// are exported as.
+var exporterTaskFields = []struct {
+	field string
+	m     promMetric
+}{
+	{"prio", promMetric{name: "newtmgr_task_priority", typ: "gauge",
+		help: "Task priority."}},
+	{"runtime", promMetric{name: "newtmgr_task_runtime_total",
+		typ:  "counter",
+		help: "Time the task has spent running, in OS time units."}},
+	{"cswcnt", promMetric{name: "newtmgr_task_context_switches_total",
+		typ: "counter", help: "Number of times the task was switched in."}},
+	{"stksiz", promMetric{name: "newtmgr_task_stack_size", typ: "gauge",
+		help: "Size of the task's stack, in stack units."}},
+	{"stkuse", promMetric{name: "newtmgr_task_stack_used", typ: "gauge",
+		help: "Peak use of the task's stack, in stack units."}},
+}
+
+var exporterMempoolFields = []struct {
+	field string
+	m     promMetric
+}{
+	{"blksiz", promMetric{name: "newtmgr_mempool_block_size_bytes",
+		typ: "gauge", help: "Size of a block in the mempool."}},
+	{"nblks", promMetric{name: "newtmgr_mempool_blocks", typ: "gauge",
+		help: "Number of blocks in the mempool."}},
+	{"nfree", promMetric{name: "newtmgr_mempool_free_blocks", typ: "gauge",
+		help: "Number of free blocks in the mempool."}},
+	{"min", promMetric{name: "newtmgr_mempool_min_free_blocks",
+		typ: "gauge", help: "Lowest number of free blocks seen."}},
+}
+
+func writeExporterMetrics(w io.Writer, devs []*exporterDevice) {
+	up := promMetric{name: "newtmgr_up", typ: "gauge",
+		help: "Whether the last scrape of the device succeeded."}
+	dur := promMetric{name: "newtmgr_scrape_duration_seconds", typ: "gauge",
+		help: "Time taken by the last scrape of the device."}
+	ts := promMetric{name: "newtmgr_last_scrape_timestamp_seconds",
+		typ: "gauge", help: "Time of the last scrape of the device."}
+	stat := promMetric{name: "newtmgr_stat_total", typ: "counter",
+		help: "Value of a field in a stats group."}
+
+	tasks := make([]promMetric, len(exporterTaskFields))
+	for i, f := range exporterTaskFields {
+		tasks[i] = f.m
+	}
+	mpools := make([]promMetric, len(exporterMempoolFields))
+	for i, f := range exporterMempoolFields {
+		mpools[i] = f.m
+	}
+
+	for _, d := range devs {
+		d.mtx.Lock()
+
+		if !d.scrapeTime.IsZero() {
+			upVal := 0.0
+			if d.up {
+				upVal = 1
+			}
+			up.add(upVal, "profile", d.profile)
+			dur.add(d.scrapeDur.Seconds(), "profile", d.profile)
+			ts.add(float64(d.scrapeTime.UnixNano())/1e9,
+				"profile", d.profile)
+		}
+
+		for _, group := range sortedStatGroups(d.snap.stats) {
+			fields := d.snap.stats[group]
+			for _, field := range sortedStatFields(fields) {
+				stat.add(float64(fields[field]), "profile", d.profile,
+					"group", group, "field", field)
+			}
+		}
+
+		for _, name := range sortedEntryNames(d.snap.tasks) {
+			for i, f := range exporterTaskFields {
+				if val, ok := d.snap.tasks[name][f.field]; ok {
+					tasks[i].add(float64(val), "profile", d.profile,
+						"task", name)
+				}
+			}
+		}
+
+		for _, name := range sortedEntryNames(d.snap.mpools) {
+			for i, f := range exporterMempoolFields {
+				if val, ok := d.snap.mpools[name][f.field]; ok {
+					mpools[i].add(float64(val), "profile", d.profile,
+						"mempool", name)
+				}
+			}
+		}
+
+		d.mtx.Unlock()
+	}
+
+	for _, m := range []*promMetric{&up, &dur, &ts, &stat} {
+		m.write(w)
+	}
+	for i := range tasks {
+		tasks[i].write(w)
+	}
+	for i := range mpools {
+		mpools[i].write(w)
+	}
+}
+
+// Identifies the transport a profile needs.  Profiles that can share a
+// transport get the same key; a serial port can only be used by one device,
+// while the other transports carry sessions to any number of devices.  All
+// BLE profiles use the controller of the first one.
+func exporterXportKey(cp *config.ConnProfile) string {
+	switch cp.Type {
+	case config.CONN_TYPE_SERIAL_PLAIN, config.CONN_TYPE_SERIAL_OIC:
+		return "serial:" + cp.ConnString
+	case config.CONN_TYPE_BLE_PLAIN, config.CONN_TYPE_BLE_OIC:
+		return "ble"
+	case config.CONN_TYPE_BLL_PLAIN, config.CONN_TYPE_BLL_OIC:
+		return "bll"
+	case config.CONN_TYPE_UDP_PLAIN, config.CONN_TYPE_UDP_OIC:
+		return "udp"
+	case config.CONN_TYPE_TCP_PLAIN, config.CONN_TYPE_TCP_OIC:
+		return "tcp"
+	default:
+		return config.ConnTypeToString(cp.Type)
+	}
+}
+
+func exporterRunCmd(cmd *cobra.Command, args []string) {
+	if exporterInterval <= 0 {
+		nmUsage(cmd, util.NewNewtError("scrape interval must be positive"))
+	}
+
+	var profiles []*config.ConnProfile
+	if len(args) == 0 {
+		cp, err := getConnProfile()
+		if err != nil {
+			nmUsage(nil, err)
+		}
+		profiles = append(profiles, cp)
+	} else {
+		for _, name := range args {
+			cp, err := config.GlobalConnProfileMgr().GetConnProfile(name)
+			if err != nil {
+				nmUsage(nil, err)
+			}
+			profiles = append(profiles, cp)
+		}
+	}
+
+	xports := map[string]xport.Xport{}
+	var devs []*exporterDevice
+
+	// Stop the transports on exit, as the global one would be.
+	prevOnExit := onExit
+	onExit = func() {
+		for _, d := range devs {
+			d.s.Close()
+		}
+		for _, x := range xports {
+			x.Stop()
+		}
+		if prevOnExit != nil {
+			prevOnExit()
+		}
+	}
+
+	for _, cp := range profiles {
+		key := exporterXportKey(cp)
+		x := xports[key]
+		if x == nil {
+			var err error
+			x, err = newXport(cp)
+			if err != nil {
+				nmUsage(nil, err)
+			}
+			if err := x.Start(); err != nil {
+				nmUsage(nil, util.ChildNewtError(err))
+			}
+			xports[key] = x
+		}
+
+		s, err := buildSesn(cp, x)
+		if err != nil {
+			nmUsage(nil, err)
+		}
+
+		devs = append(devs, &exporterDevice{
+			profile: cp.Name,
+			s:       s,
+		})
+	}
+
+	for _, d := range devs {
+		go d.run(exporterInterval)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/metrics", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+		writeExporterMetrics(w, devs)
+	})
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/" {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprintf(w, "%s exporter; metrics are at /metrics\n",
+			nmutil.ToolInfo.LongName)
+	})
+
+	log.Infof("Serving metrics for %d device(s) on %s", len(devs),
+		exporterListen)
+	if err := http.ListenAndServe(exporterListen, mux); err != nil {
+		nmUsage(nil, util.ChildNewtError(err))
+	}
+}
+
+func exporterCmd() *cobra.Command {
+	ex := nmutil.ToolInfo.ExeName + " exporter board1 board2 board3\n"
+	ex += nmutil.ToolInfo.ExeName +
+		" exporter --listen localhost:9100 --interval 30s -c myserial\n"
+
+	cmd := &cobra.Command{
+		Use:   "exporter [conn_profile...]",
+		Short: "Serve device statistics as Prometheus metrics",
+		Long: "Scrape statistics, task statistics, and mempool " +
+			"statistics from one or more devices at regular intervals, " +
+			"and serve them at /metrics in the Prometheus text format.  " +
+			"Each named connection profile is scraped over its own " +
+			"session; if no profiles are named, the device specified " +
+			"with -c or --conntype is scraped.  Metrics are labeled with " +
+			"the profile name, and newtmgr_up reports whether each " +
+			"device could be reached.",
+		Example: ex,
+		Run:     exporterRunCmd,
+	}
+
+	cmd.Flags().StringVar(&exporterListen, "listen", ":9465",
+		"address to serve metrics on")
+	cmd.Flags().DurationVar(&exporterInterval, "interval", 15*time.Second,
+		"time between scrapes of each device")
+
+	return cmd
+}
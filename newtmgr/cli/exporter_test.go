@@ -0,0 +1,138 @@
+/**
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package cli
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+func TestWriteExporterMetrics(t *testing.T) {
+	scrapeTime := time.Unix(1500000000, 500000000)
+
+	devs := []*exporterDevice{
+		{
+			profile:    "b1",
+			up:         true,
+			scrapeTime: scrapeTime,
+			scrapeDur:  50 * time.Millisecond,
+			snap: exporterSnapshot{
+				stats: map[string]map[string]int64{
+					"ble_ll": {"tx_fail": 3, "rx_crc": 12},
+					"app":    {"errs": 0},
+				},
+				tasks: map[string]map[string]int{
+					"main": {"prio": 127, "runtime": 900, "cswcnt": 40,
+						"stksiz": 256, "stkuse": 100},
+					"idle": {"prio": 255, "runtime": 100},
+				},
+			},
+		},
+		{
+			// Down, so only its scrape metrics are reported.
+			profile:    `odd "name"`,
+			scrapeTime: scrapeTime,
+			scrapeDur:  2 * time.Second,
+		},
+		{
+			// Not scraped yet.
+			profile: "b3",
+		},
+	}
+
+	var buf bytes.Buffer
+	writeExporterMetrics(&buf, devs)
+
+	want := `# HELP newtmgr_up Whether the last scrape of the device succeeded.
+# TYPE newtmgr_up gauge
+newtmgr_up{profile="b1"} 1
+newtmgr_up{profile="odd \"name\""} 0
+# HELP newtmgr_scrape_duration_seconds Time taken by the last scrape of the device.
+# TYPE newtmgr_scrape_duration_seconds gauge
+newtmgr_scrape_duration_seconds{profile="b1"} 0.05
+newtmgr_scrape_duration_seconds{profile="odd \"name\""} 2
+# HELP newtmgr_last_scrape_timestamp_seconds Time of the last scrape of the device.
+# TYPE newtmgr_last_scrape_timestamp_seconds gauge
+newtmgr_last_scrape_timestamp_seconds{profile="b1"} 1.5000000005e+09
+newtmgr_last_scrape_timestamp_seconds{profile="odd \"name\""} 1.5000000005e+09
+# HELP newtmgr_stat_total Value of a field in a stats group.
+# TYPE newtmgr_stat_total counter
+newtmgr_stat_total{profile="b1",group="app",field="errs"} 0
+newtmgr_stat_total{profile="b1",group="ble_ll",field="rx_crc"} 12
+newtmgr_stat_total{profile="b1",group="ble_ll",field="tx_fail"} 3
+# HELP newtmgr_task_priority Task priority.
+# TYPE newtmgr_task_priority gauge
+newtmgr_task_priority{profile="b1",task="idle"} 255
+newtmgr_task_priority{profile="b1",task="main"} 127
+# HELP newtmgr_task_runtime_total Time the task has spent running, in OS time units.
+# TYPE newtmgr_task_runtime_total counter
+newtmgr_task_runtime_total{profile="b1",task="idle"} 100
+newtmgr_task_runtime_total{profile="b1",task="main"} 900
+# HELP newtmgr_task_context_switches_total Number of times the task was switched in.
+# TYPE newtmgr_task_context_switches_total counter
+newtmgr_task_context_switches_total{profile="b1",task="main"} 40
+# HELP newtmgr_task_stack_size Size of the task's stack, in stack units.
+# TYPE newtmgr_task_stack_size gauge
+newtmgr_task_stack_size{profile="b1",task="main"} 256
+# HELP newtmgr_task_stack_used Peak use of the task's stack, in stack units.
+# TYPE newtmgr_task_stack_used gauge
+newtmgr_task_stack_used{profile="b1",task="main"} 100
+`
+	if got := buf.String(); got != want {
+		t.Errorf("metrics:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestWriteExporterMempools(t *testing.T) {
+	devs := []*exporterDevice{{
+		profile:    "b1",
+		up:         true,
+		scrapeTime: time.Unix(1, 0),
+		snap: exporterSnapshot{
+			mpools: map[string]map[string]int{
+				"msys": {"blksiz": 292, "nblks": 12, "nfree": 10,
+					"min": 4},
+			},
+		},
+	}}
+
+	var buf bytes.Buffer
+	writeExporterMetrics(&buf, devs)
+
+	for _, line := range []string{
+		"# TYPE newtmgr_mempool_blocks gauge\n",
+		`newtmgr_mempool_block_size_bytes{profile="b1",mempool="msys"} 292` +
+			"\n",
+		`newtmgr_mempool_blocks{profile="b1",mempool="msys"} 12` + "\n",
+		`newtmgr_mempool_free_blocks{profile="b1",mempool="msys"} 10` + "\n",
+		`newtmgr_mempool_min_free_blocks{profile="b1",mempool="msys"} 4` +
+			"\n",
+	} {
+		if !bytes.Contains(buf.Bytes(), []byte(line)) {
+			t.Errorf("metrics missing %q:\n%s", line, buf.String())
+		}
+	}
+	if bytes.Contains(buf.Bytes(), []byte("newtmgr_stat_total")) ||
+		bytes.Contains(buf.Bytes(), []byte("newtmgr_task_")) {
+
+		t.Errorf("metrics for unsupported sections:\n%s", buf.String())
+	}
+}
@@ -32,6 +32,14 @@ import (
 	"mynewt.apache.org/newtmgr/nmxact/xact"
 )
 
+// Describes a file transfer.  Name is the file on the device; File is the
+// local file.
+type fsOutput struct {
+	Name string `json:"name"`
+	File string `json:"file"`
+	Size int    `json:"size"`
+}
+
 func fsDownloadRunCmd(cmd *cobra.Command, args []string) {
 	if len(args) < 2 {
 		nmUsage(cmd, nil)
@@ -52,26 +60,23 @@ func fsDownloadRunCmd(cmd *cobra.Command, args []string) {
 	c := xact.NewFsDownloadCmd()
 	c.SetTxOptions(nmutil.TxOptions())
 	c.Name = args[0]
+	size := 0
 	c.ProgressCb = func(c *xact.FsDownloadCmd, rsp *nmp.FsDownloadRsp) {
-		fmt.Printf("%d\n", rsp.Off)
+		printInfo("%d\n", rsp.Off)
 		if _, err := file.Write(rsp.Data); err != nil {
 			nmUsage(nil, util.ChildNewtError(err))
 		}
+		size += len(rsp.Data)
 	}
 
-	res, err := c.Run(s)
-	if err != nil {
+	if _, err := c.Run(s); err != nil {
 		nmUsage(nil, util.ChildNewtError(err))
 	}
 
-	sres := res.(*xact.FsDownloadResult)
-	rsp := sres.Rsps[len(sres.Rsps)-1]
-	if rsp.Rc != 0 {
-		fmt.Printf("Error: %d\n", rsp.Rc)
-		return
-	}
-
-	fmt.Printf("Done\n")
+	out := fsOutput{Name: args[0], File: args[1], Size: size}
+	printResult(out, func() {
+		fmt.Printf("Done\n")
+	})
 }
 
 func fsUploadRunCmd(cmd *cobra.Command, args []string) {
@@ -94,22 +99,17 @@ func fsUploadRunCmd(cmd *cobra.Command, args []string) {
 	c.Name = args[1]
 	c.Data = data
 	c.ProgressCb = func(c *xact.FsUploadCmd, rsp *nmp.FsUploadRsp) {
-		fmt.Printf("%d\n", rsp.Off)
+		printInfo("%d\n", rsp.Off)
 	}
 
-	res, err := c.Run(s)
-	if err != nil {
+	if _, err := c.Run(s); err != nil {
 		nmUsage(nil, util.ChildNewtError(err))
 	}
 
-	sres := res.(*xact.FsUploadResult)
-	rsp := sres.Rsps[len(sres.Rsps)-1]
-	if rsp.Rc != 0 {
-		fmt.Printf("Error: %d\n", rsp.Rc)
-		return
-	}
-
-	fmt.Printf("Done\n")
+	out := fsOutput{Name: args[1], File: args[0], Size: len(data)}
+	printResult(out, func() {
+		fmt.Printf("Done\n")
+	})
 }
 
 func fsCmd() *cobra.Command {
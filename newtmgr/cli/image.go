@@ -20,20 +20,28 @@
 package cli
 
 import (
+	"bytes"
 	"context"
+	"crypto"
+	"crypto/sha256"
 	"encoding/hex"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"os"
 	"runtime/trace"
 	"strings"
+	"time"
 
+	log "github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
 	pb "gopkg.in/cheggaaa/pb.v1"
 
 	"mynewt.apache.org/newt/util"
+	"mynewt.apache.org/newtmgr/newtmgr/config"
 	"mynewt.apache.org/newtmgr/newtmgr/core"
 	"mynewt.apache.org/newtmgr/newtmgr/nmutil"
+	"mynewt.apache.org/newtmgr/nmxact/mcuboot"
 	"mynewt.apache.org/newtmgr/nmxact/nmp"
 	"mynewt.apache.org/newtmgr/nmxact/xact"
 )
@@ -47,6 +55,24 @@ var (
 var noerase bool
 var upgrade bool
 var imageNum int
+var uploadWindow int
+var uploadResume bool
+var uploadRestart bool
+var uploadForce bool
+var imageKeyFile string
+var uploadForceUpload bool
+
+var (
+	deployCheckEcho        string
+	deployCheckStats       []string
+	deployNoConfirm        bool
+	deployNoRollback       bool
+	deployResetDelay       time.Duration
+	deployReconnectTimeout time.Duration
+)
+
+// Minimum progress, in bytes, between upload journal updates.
+const uploadJournalInterval = 4096
 
 func imageFlagsStr(image nmp.ImageStateEntry) string {
 	strs := []string{}
@@ -67,26 +93,65 @@ func imageFlagsStr(image nmp.ImageStateEntry) string {
 	return strings.Join(strs, " ")
 }
 
-func imageStatePrintRsp(rsp *nmp.ImageStateRsp) error {
-	if rsp.Rc != 0 {
-		fmt.Printf("Error: %d\n", rsp.Rc)
-		return nil
+type imageStateOutput struct {
+	Images      []imageStateEntry `json:"images"`
+	SplitStatus string            `json:"split_status"`
+}
+
+type imageStateEntry struct {
+	Image     int    `json:"image"`
+	Slot      int    `json:"slot"`
+	Version   string `json:"version"`
+	Bootable  bool   `json:"bootable"`
+	Active    bool   `json:"active"`
+	Confirmed bool   `json:"confirmed"`
+	Pending   bool   `json:"pending"`
+	Permanent bool   `json:"permanent"`
+	Hash      string `json:"hash,omitempty"`
+}
+
+func newImageStateOutput(rsp *nmp.ImageStateRsp) imageStateOutput {
+	out := imageStateOutput{
+		Images:      []imageStateEntry{},
+		SplitStatus: rsp.SplitStatus.String(),
 	}
-	fmt.Println("Images:")
+
 	for _, img := range rsp.Images {
-		fmt.Printf(" image=%d slot=%d\n", img.Image, img.Slot)
-		fmt.Printf("    version: %s\n", img.Version)
-		fmt.Printf("    bootable: %v\n", img.Bootable)
-		fmt.Printf("    flags: %s\n", imageFlagsStr(img))
-		if len(img.Hash) == 0 {
-			fmt.Printf("    hash: Unavailable\n")
-		} else {
-			fmt.Printf("    hash: %x\n", img.Hash)
-		}
+		out.Images = append(out.Images, imageStateEntry{
+			Image:     img.Image,
+			Slot:      img.Slot,
+			Version:   img.Version,
+			Bootable:  img.Bootable,
+			Active:    img.Active,
+			Confirmed: img.Confirmed,
+			Pending:   img.Pending,
+			Permanent: img.Permanent,
+			Hash:      hex.EncodeToString(img.Hash),
+		})
 	}
 
-	fmt.Printf("Split status: %s (%d)\n", rsp.SplitStatus.String(),
-		rsp.SplitStatus)
+	return out
+}
+
+func imageStatePrintRsp(rsp *nmp.ImageStateRsp) error {
+	printResult(newImageStateOutput(rsp), func() {
+		fmt.Println("Images:")
+		for _, img := range rsp.Images {
+			fmt.Printf(" image=%d slot=%d\n", img.Image, img.Slot)
+			fmt.Printf("    version: %s\n", img.Version)
+			fmt.Printf("    bootable: %v\n", img.Bootable)
+			fmt.Printf("    flags: %s\n", imageFlagsStr(img))
+			if len(img.Hash) == 0 {
+				fmt.Printf("    hash: Unavailable\n")
+			} else {
+				fmt.Printf("    hash: %x\n", img.Hash)
+			}
+		}
+
+		fmt.Printf("Split status: %s (%d)\n", rsp.SplitStatus.String(),
+			rsp.SplitStatus)
+	})
+
 	return nil
 }
 
@@ -176,6 +241,341 @@ func imageStateConfirmCmd(cmd *cobra.Command, args []string) {
 	}
 }
 
+type imageInfoOutput struct {
+	Images []imageInfoEntry `json:"images"`
+}
+
+type imageInfoEntry struct {
+	File         string         `json:"file"`
+	Magic        uint32         `json:"magic"`
+	LoadAddr     uint32         `json:"load_address"`
+	HdrSize      uint16         `json:"header_size"`
+	ProtTlvSize  uint16         `json:"protected_tlv_size"`
+	ImgSize      uint32         `json:"image_size"`
+	Version      string         `json:"version"`
+	Flags        []string       `json:"flags"`
+	ProtTlvs     []imageTlvInfo `json:"protected_tlvs"`
+	Tlvs         []imageTlvInfo `json:"tlvs"`
+	Hash         string         `json:"hash,omitempty"`
+	HashError    string         `json:"hash_error,omitempty"`
+	ComputedHash string         `json:"computed_hash"`
+	HashMatch    bool           `json:"hash_match"`
+}
+
+type imageTlvInfo struct {
+	Type     uint8  `json:"type"`
+	TypeName string `json:"type_name"`
+	Len      int    `json:"len"`
+	Data     string `json:"data"`
+}
+
+func newImageTlvInfos(tlvs []mcuboot.ImageTlv) []imageTlvInfo {
+	infos := []imageTlvInfo{}
+	for _, tlv := range tlvs {
+		infos = append(infos, imageTlvInfo{
+			Type:     tlv.Type,
+			TypeName: mcuboot.TlvTypeToString(tlv.Type),
+			Len:      len(tlv.Data),
+			Data:     hex.EncodeToString(tlv.Data),
+		})
+	}
+
+	return infos
+}
+
+func newImageInfoEntry(filename string, img *mcuboot.Image) imageInfoEntry {
+	hdr := img.Header
+
+	entry := imageInfoEntry{
+		File:        filename,
+		Magic:       hdr.Magic,
+		LoadAddr:    hdr.LoadAddr,
+		HdrSize:     hdr.HdrSz,
+		ProtTlvSize: hdr.ProtTlvSz,
+		ImgSize:     hdr.ImgSz,
+		Version:     hdr.Vers.String(),
+		Flags:       mcuboot.ImageFlagsToStrings(hdr.Flags),
+		ProtTlvs:    newImageTlvInfos(img.ProtTlvs),
+		Tlvs:        newImageTlvInfos(img.Tlvs),
+	}
+	if entry.Flags == nil {
+		entry.Flags = []string{}
+	}
+
+	hash, err := img.Hash()
+	if err != nil {
+		entry.HashError = err.Error()
+	} else {
+		entry.Hash = hex.EncodeToString(hash)
+	}
+
+	calc := img.CalcHash()
+	entry.ComputedHash = hex.EncodeToString(calc)
+	entry.HashMatch = bytes.Equal(hash, calc)
+
+	return entry
+}
+
+func imageTlvPrint(tlv mcuboot.ImageTlv) {
+	const maxBytes = 32
+
+	data := fmt.Sprintf("%x", tlv.Data)
+	if len(tlv.Data) > maxBytes {
+		data = fmt.Sprintf("%x...", tlv.Data[:maxBytes])
+	}
+
+	fmt.Printf("    %s (0x%02x) len=%d: %s\n",
+		mcuboot.TlvTypeToString(tlv.Type), tlv.Type, len(tlv.Data), data)
+}
+
+func imageInfoPrint(filename string, img *mcuboot.Image) {
+	hdr := img.Header
+
+	fmt.Printf("Image: %s\n", filename)
+	fmt.Printf("    magic: 0x%08x\n", hdr.Magic)
+	fmt.Printf("    load address: 0x%08x\n", hdr.LoadAddr)
+	fmt.Printf("    header size: %d\n", hdr.HdrSz)
+	fmt.Printf("    protected TLV size: %d\n", hdr.ProtTlvSz)
+	fmt.Printf("    image size: %d\n", hdr.ImgSz)
+	fmt.Printf("    version: %s\n", hdr.Vers.String())
+	fmt.Printf("    flags: %s\n",
+		strings.Join(mcuboot.ImageFlagsToStrings(hdr.Flags), " "))
+
+	if len(img.ProtTlvs) > 0 {
+		fmt.Println(" protected TLVs:")
+		for _, tlv := range img.ProtTlvs {
+			imageTlvPrint(tlv)
+		}
+	}
+	fmt.Println(" TLVs:")
+	for _, tlv := range img.Tlvs {
+		imageTlvPrint(tlv)
+	}
+
+	hash, err := img.Hash()
+	if err != nil {
+		fmt.Printf("    hash: Unavailable (%s)\n", err.Error())
+	} else {
+		fmt.Printf("    hash: %x\n", hash)
+	}
+
+	calc := img.CalcHash()
+	status := "match"
+	if !bytes.Equal(hash, calc) {
+		status = "MISMATCH"
+	}
+	fmt.Printf("    computed hash: %x (%s)\n", calc, status)
+}
+
+func imageInfoCmd(cmd *cobra.Command, args []string) {
+	if len(args) < 1 {
+		nmUsage(cmd, util.NewNewtError("Need to specify image file"))
+	}
+
+	out := imageInfoOutput{Images: []imageInfoEntry{}}
+	imgs := []*mcuboot.Image{}
+	for _, filename := range args {
+		data, err := ioutil.ReadFile(filename)
+		if err != nil {
+			nmUsage(nil, util.ChildNewtError(err))
+		}
+
+		img, err := mcuboot.ParseImage(data)
+		if err != nil {
+			nmUsage(nil, util.FmtNewtError("%s: %s", filename, err.Error()))
+		}
+
+		out.Images = append(out.Images, newImageInfoEntry(filename, img))
+		imgs = append(imgs, img)
+	}
+
+	printResult(out, func() {
+		for i, entry := range out.Images {
+			imageInfoPrint(entry.File, imgs[i])
+		}
+	})
+}
+
+func imageReadKey(filename string) crypto.PublicKey {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		nmUsage(nil, util.ChildNewtError(err))
+	}
+
+	key, err := mcuboot.ParsePubKeyPem(data)
+	if err != nil {
+		nmUsage(nil, util.FmtNewtError("%s: %s", filename, err.Error()))
+	}
+
+	return key
+}
+
+// Lists the images whose signatures were verified; a bad signature is
+// reported as an error.
+type imageVerifyOutput struct {
+	Images []imageVerifyEntry `json:"images"`
+}
+
+type imageVerifyEntry struct {
+	File      string `json:"file"`
+	Algorithm string `json:"algorithm"`
+}
+
+func imageVerifyCmd(cmd *cobra.Command, args []string) {
+	if len(args) < 1 {
+		nmUsage(cmd, util.NewNewtError("Need to specify image file"))
+	}
+	if imageKeyFile == "" {
+		nmUsage(cmd, util.NewNewtError("Need to specify a key with --key"))
+	}
+
+	key := imageReadKey(imageKeyFile)
+	alg, err := mcuboot.KeyAlgorithm(key)
+	if err != nil {
+		nmUsage(nil, util.FmtNewtError("%s: %s", imageKeyFile, err.Error()))
+	}
+
+	out := imageVerifyOutput{Images: []imageVerifyEntry{}}
+	for _, filename := range args {
+		data, err := ioutil.ReadFile(filename)
+		if err != nil {
+			nmUsage(nil, util.ChildNewtError(err))
+		}
+
+		img, err := mcuboot.ParseImage(data)
+		if err == nil {
+			err = img.VerifySig(key)
+		}
+		if err != nil {
+			nmUsage(nil, util.FmtNewtError("%s: %s", filename, err.Error()))
+		}
+
+		out.Images = append(out.Images, imageVerifyEntry{
+			File:      filename,
+			Algorithm: alg,
+		})
+	}
+
+	printResult(out, func() {
+		for _, entry := range out.Images {
+			fmt.Printf("%s: %s signature OK\n", entry.File, entry.Algorithm)
+		}
+	})
+}
+
+// Checks that a file is a well-formed MCUboot image whose hash matches its
+// contents.  Unless forced, a bad image is rejected before anything is sent
+// to the device.
+func imageCheckUpload(filename string, data []byte, force bool) {
+	if _, err := mcuboot.ParseAndVerify(data); err != nil {
+		if !force {
+			nmUsage(nil, util.FmtNewtError("%s: %s (use --force to upload "+
+				"anyway)", filename, err.Error()))
+		}
+
+		fmt.Fprintf(os.Stderr, "Warning: %s: %s\n", filename, err.Error())
+	}
+}
+
+// Records the progress of an image upload in the upload journal, so that an
+// interrupted upload can be resumed.
+type imageUploadJournal struct {
+	journal  *config.UploadJournal
+	devKey   string
+	hash     []byte
+	imageNum int
+	size     int
+	savedOff int
+	lastOff  int
+}
+
+// Starts recording an upload.  Returns true if the upload should resume from
+// where the device left off: either because --resume was specified, or
+// because a previous upload of this image to this device didn't finish and
+// --restart wasn't specified.
+func openImageUploadJournal(cmd *cobra.Command, data []byte,
+	imageNum int) (*imageUploadJournal, bool) {
+
+	if uploadResume && uploadRestart {
+		nmUsage(cmd, util.NewNewtError(
+			"--resume and --restart are mutually exclusive"))
+	}
+
+	devKey, err := DeviceKey()
+	if err != nil {
+		nmUsage(nil, err)
+	}
+	hash := sha256.Sum256(data)
+
+	journal, err := config.ReadUploadJournal()
+	if err != nil {
+		nmUsage(nil, err)
+	}
+	entry := journal.Find(devKey, hash[:], imageNum)
+	if uploadRestart {
+		entry = nil
+	}
+	if entry != nil {
+		printInfo("Resuming interrupted upload (%d of %d bytes sent)\n",
+			entry.Off, entry.Len)
+	}
+
+	uj := &imageUploadJournal{
+		journal:  journal,
+		devKey:   devKey,
+		hash:     hash[:],
+		imageNum: imageNum,
+		size:     len(data),
+	}
+	uj.save(0)
+
+	return uj, uploadResume || entry != nil
+}
+
+func (uj *imageUploadJournal) save(off int) {
+	uj.savedOff = off
+	err := uj.journal.Update(uj.devKey, uj.hash, uj.imageNum, uj.size, off)
+	if err != nil {
+		log.Warnf("Failed to update upload journal: %s", err.Error())
+	}
+}
+
+// Records the offset in an upload response.  The journal is only written
+// every uploadJournalInterval bytes, or if the offset moves backwards.
+func (uj *imageUploadJournal) progress(rsp *nmp.ImageUploadRsp) {
+	off := int(rsp.Off)
+	uj.lastOff = off
+
+	if rsp.Rc == 0 &&
+		(off < uj.savedOff || off-uj.savedOff >= uploadJournalInterval) {
+
+		uj.save(off)
+	}
+}
+
+// Records where a failed upload stopped.
+func (uj *imageUploadJournal) fail() {
+	uj.save(uj.lastOff)
+}
+
+// Removes a completed upload from the journal.
+func (uj *imageUploadJournal) finish() {
+	err := uj.journal.Remove(uj.devKey, uj.hash, uj.imageNum)
+	if err != nil {
+		log.Warnf("Failed to update upload journal: %s", err.Error())
+	}
+}
+
+// Describes a finished upload.  PresentSlot is only set if the upload was
+// skipped because the device already had the image.
+type imageUploadOutput struct {
+	Image       int  `json:"image"`
+	Size        int  `json:"size"`
+	Resumed     bool `json:"resumed"`
+	Skipped     bool `json:"skipped"`
+	PresentSlot *int `json:"present_slot,omitempty"`
+}
+
 func imageUploadCmd(cmd *cobra.Command, args []string) {
 	if len(args) < 1 {
 		nmUsage(cmd, util.NewNewtError("Need to specify image to upload"))
@@ -186,6 +586,8 @@ func imageUploadCmd(cmd *cobra.Command, args []string) {
 		nmUsage(cmd, util.NewNewtError(err.Error()))
 	}
 
+	imageCheckUpload(args[0], imageFile, uploadForce)
+
 	s, err := GetSesn()
 	if err != nil {
 		nmUsage(nil, err)
@@ -202,27 +604,210 @@ func imageUploadCmd(cmd *cobra.Command, args []string) {
 	}
 	c.ImageNum = imageNum
 	c.Upgrade = upgrade
-	c.ProgressBar = pb.StartNew(len(imageFile))
-	c.ProgressBar.SetUnits(pb.U_BYTES)
-	c.ProgressBar.ShowSpeed = true
+	if uploadWindow < 1 {
+		nmUsage(cmd, util.NewNewtError("Invalid window size"))
+	}
+	c.Window = uploadWindow
+	if imageKeyFile != "" {
+		c.SigKey = imageReadKey(imageKeyFile)
+	}
+
+	uj, resume := openImageUploadJournal(cmd, imageFile, imageNum)
+	c.Resume = resume
+
+	c.ForceUpload = uploadForceUpload
 	c.LastOff = 0
 	c.ProgressCb = func(cmd *xact.ImageUploadCmd, rsp *nmp.ImageUploadRsp) {
-		c.ProgressBar.Add(int(rsp.Off - c.LastOff))
+		// Don't start the progress bar until the transfer starts; it
+		// doesn't happen at all if the device already has the image.
+		if c.ProgressBar == nil && !structuredOutput() {
+			c.ProgressBar = pb.StartNew(len(imageFile))
+			c.ProgressBar.SetUnits(pb.U_BYTES)
+			c.ProgressBar.ShowSpeed = true
+		}
+
+		// The offset can move backwards if the device drops a chunk.
+		if c.ProgressBar != nil {
+			c.ProgressBar.Set(int(rsp.Off))
+		}
 		c.LastOff = rsp.Off
+		uj.progress(rsp)
 	}
 
 	res, err := c.Run(s)
 	if err != nil {
+		uj.fail()
 		nmUsage(nil, util.ChildNewtError(err))
 	}
+	uj.finish()
+
+	ures := res.(*xact.ImageUpgradeResult)
+	out := imageUploadOutput{
+		Image:   imageNum,
+		Size:    len(imageFile),
+		Resumed: c.Resume,
+		Skipped: ures.Skipped,
+	}
+	if ures.Skipped {
+		out.PresentSlot = &ures.PresentSlot
+	}
 
-	if res.Status() != 0 {
-		fmt.Printf("Error: %d\n", res.Status())
-		return
+	printResult(out, func() {
+		if ures.Skipped {
+			fmt.Printf("Image already present in slot %d; upload skipped\n",
+				ures.PresentSlot)
+			return
+		}
+
+		if c.ProgressBar != nil {
+			c.ProgressBar.Finish()
+		}
+		fmt.Printf("Done\n")
+	})
+}
+
+type imageDeployOutput struct {
+	Hash          string `json:"hash"`
+	Confirmed     bool   `json:"confirmed"`
+	UploadSkipped bool   `json:"upload_skipped"`
+}
+
+func imageDeployCmd(cmd *cobra.Command, args []string) {
+	if len(args) < 1 {
+		nmUsage(cmd, util.NewNewtError("Need to specify image to deploy"))
 	}
 
-	c.ProgressBar.Finish()
-	fmt.Printf("Done\n")
+	imageFile, err := ioutil.ReadFile(args[0])
+	if err != nil {
+		nmUsage(cmd, util.NewNewtError(err.Error()))
+	}
+
+	imageCheckUpload(args[0], imageFile, uploadForce)
+
+	c := xact.NewImageDeployCmd()
+	c.SetTxOptions(nmutil.TxOptions())
+	c.Data = imageFile
+	if imageNum < 0 {
+		nmUsage(cmd, util.NewNewtError("Invalid image number"))
+	}
+	c.ImageNum = imageNum
+	if uploadWindow < 1 {
+		nmUsage(cmd, util.NewNewtError("Invalid window size"))
+	}
+	c.Window = uploadWindow
+	c.ForceUpload = uploadForceUpload
+	if imageKeyFile != "" {
+		c.SigKey = imageReadKey(imageKeyFile)
+	}
+
+	if deployCheckEcho != "" {
+		c.HealthChecks = append(c.HealthChecks,
+			&xact.EchoHealthCheck{Payload: deployCheckEcho})
+	}
+	for _, arg := range deployCheckStats {
+		hc, err := xact.ParseStatHealthCheck(arg)
+		if err != nil {
+			nmUsage(cmd, util.ChildNewtError(err))
+		}
+		c.HealthChecks = append(c.HealthChecks, hc)
+	}
+
+	c.NoConfirm = deployNoConfirm
+	c.NoRollback = deployNoRollback
+	c.ResetDelay = deployResetDelay
+	c.ReconnectTimeout = deployReconnectTimeout
+
+	s, err := GetSesn()
+	if err != nil {
+		nmUsage(nil, err)
+	}
+
+	// The upload is journaled as with image upload; once the deployment
+	// moves past it, the upload is complete.
+	uj, resume := openImageUploadJournal(cmd, imageFile, imageNum)
+	c.Resume = resume
+	uploaded := false
+
+	var bar *pb.ProgressBar
+	c.ProgressCb = func(cmd *xact.ImageUploadCmd, rsp *nmp.ImageUploadRsp) {
+		uj.progress(rsp)
+		if structuredOutput() {
+			return
+		}
+		if bar == nil {
+			bar = pb.StartNew(len(imageFile))
+			bar.SetUnits(pb.U_BYTES)
+			bar.ShowSpeed = true
+		}
+		bar.Set(int(rsp.Off))
+	}
+	c.StageCb = func(cmd *xact.ImageDeployCmd, stage xact.DeployStage) {
+		if bar != nil {
+			bar.Finish()
+			bar = nil
+		}
+		if stage != xact.DEPLOY_STAGE_UPLOAD && !uploaded {
+			uploaded = true
+			uj.finish()
+		}
+
+		switch stage {
+		case xact.DEPLOY_STAGE_UPLOAD:
+			printInfo("Uploading image\n")
+		case xact.DEPLOY_STAGE_TEST:
+			printInfo("Marking image for test\n")
+		case xact.DEPLOY_STAGE_RESET:
+			printInfo("Resetting device\n")
+		case xact.DEPLOY_STAGE_RECONNECT:
+			printInfo("Waiting for device\n")
+		case xact.DEPLOY_STAGE_VERIFY:
+			printInfo("Verifying running image\n")
+		case xact.DEPLOY_STAGE_HEALTH:
+			printInfo("Running health checks\n")
+		case xact.DEPLOY_STAGE_CONFIRM:
+			printInfo("Confirming image\n")
+		case xact.DEPLOY_STAGE_ROLLBACK:
+			printInfo("Resetting device to revert to previous image\n")
+		}
+	}
+
+	res, err := c.Run(s)
+	if err != nil {
+		if !uploaded {
+			uj.fail()
+		}
+		nmUsage(nil, util.ChildNewtError(err))
+	}
+
+	dres := res.(*xact.ImageDeployResult)
+	out := imageDeployOutput{
+		Hash:      hex.EncodeToString(dres.Hash),
+		Confirmed: dres.Confirmed,
+	}
+	if dres.UpgradeRes != nil {
+		out.UploadSkipped = dres.UpgradeRes.Skipped
+	}
+
+	printResult(out, func() {
+		if dres.Confirmed {
+			fmt.Printf("Done; device is running image %x\n", dres.Hash)
+		} else {
+			fmt.Printf("Done; device is testing image %x (not confirmed)\n",
+				dres.Hash)
+		}
+	})
+}
+
+type coreListOutput struct {
+	Present bool `json:"present"`
+}
+
+// Describes a core file written to disk.  ImageHash is only set if the core
+// was converted to ELF.
+type coreOutput struct {
+	File      string `json:"file"`
+	Size      int    `json:"size,omitempty"`
+	ImageHash string `json:"image_hash,omitempty"`
 }
 
 func coreListCmd(cmd *cobra.Command, args []string) {
@@ -234,20 +819,19 @@ func coreListCmd(cmd *cobra.Command, args []string) {
 	c := xact.NewCoreListCmd()
 	c.SetTxOptions(nmutil.TxOptions())
 
-	res, err := c.Run(s)
-	if err != nil {
+	_, err = c.Run(s)
+	if err != nil && !errors.Is(err, nmp.ErrNoEnt) {
 		nmUsage(nil, util.ChildNewtError(err))
 	}
-	ires := res.(*xact.CoreListResult)
 
-	switch ires.Status() {
-	case 0:
-		fmt.Printf("Corefile present\n")
-	case nmp.NMP_ERR_ENOENT:
-		fmt.Printf("No corefiles\n")
-	default:
-		fmt.Printf("Error: %d\n", ires.Status())
-	}
+	out := coreListOutput{Present: err == nil}
+	printResult(out, func() {
+		if out.Present {
+			fmt.Printf("Corefile present\n")
+		} else {
+			fmt.Printf("No corefiles\n")
+		}
+	})
 }
 
 func coreDownloadCmd(cmd *cobra.Command, args []string) {
@@ -271,27 +855,24 @@ func coreDownloadCmd(cmd *cobra.Command, args []string) {
 
 	c := xact.NewCoreLoadCmd()
 	c.SetTxOptions(nmutil.TxOptions())
+	out := coreOutput{File: args[0]}
 	c.ProgressCb = func(c *xact.CoreLoadCmd, rsp *nmp.CoreLoadRsp) {
-		fmt.Printf("%d\n", rsp.Off)
+		printInfo("%d\n", rsp.Off)
 		if _, err := file.Write(rsp.Data); err != nil {
 			nmUsage(nil, util.ChildNewtError(err))
 		}
+		out.Size += len(rsp.Data)
 	}
 
-	res, err := c.Run(s)
-	if err != nil {
+	if _, err := c.Run(s); err != nil {
 		nmUsage(nil, util.ChildNewtError(err))
 	}
 
-	sres := res.(*xact.CoreLoadResult)
-	if sres.Status() != 0 {
-		fmt.Printf("Error: %d\n", sres.Status())
-		return
-	}
-
 	if !coreElfify {
 		os.Rename(tmpName, args[0])
-		fmt.Printf("Done writing core file to %s\n", args[0])
+		printResult(out, func() {
+			fmt.Printf("Done writing core file to %s\n", args[0])
+		})
 	} else {
 		coreConvert, err := core.ConvertFilenames(tmpName, args[0])
 		if err != nil {
@@ -299,8 +880,11 @@ func coreDownloadCmd(cmd *cobra.Command, args []string) {
 			return
 		}
 
-		fmt.Printf("Done writing core file to %s; hash=%x\n", args[0],
-			coreConvert.ImageHash)
+		out.ImageHash = hex.EncodeToString(coreConvert.ImageHash)
+		printResult(out, func() {
+			fmt.Printf("Done writing core file to %s; hash=%x\n", args[0],
+				coreConvert.ImageHash)
+		})
 	}
 }
 
@@ -313,18 +897,13 @@ func coreEraseCmd(cmd *cobra.Command, args []string) {
 	c := xact.NewCoreEraseCmd()
 	c.SetTxOptions(nmutil.TxOptions())
 
-	res, err := c.Run(s)
-	if err != nil {
+	if _, err := c.Run(s); err != nil {
 		nmUsage(nil, util.ChildNewtError(err))
 	}
-	ires := res.(*xact.CoreEraseResult)
-
-	if ires.Status() != 0 {
-		fmt.Printf("Error: %d\n", ires.Status())
-		return
-	}
 
-	fmt.Printf("Done\n")
+	printResult(doneOutput{}, func() {
+		fmt.Printf("Done\n")
+	})
 }
 
 func imageEraseCmd(cmd *cobra.Command, args []string) {
@@ -333,21 +912,21 @@ func imageEraseCmd(cmd *cobra.Command, args []string) {
 		nmUsage(nil, err)
 	}
 
+	if imageNum < 0 {
+		nmUsage(cmd, util.NewNewtError("Invalid image number"))
+	}
+
 	c := xact.NewImageEraseCmd()
 	c.SetTxOptions(nmutil.TxOptions())
+	c.ImageNum = imageNum
 
-	res, err := c.Run(s)
-	if err != nil {
+	if _, err := c.Run(s); err != nil {
 		nmUsage(nil, util.ChildNewtError(err))
 	}
-	ires := res.(*xact.ImageEraseResult)
 
-	if ires.Status() != 0 {
-		fmt.Printf("Error: %d\n", ires.Status())
-		return
-	}
-
-	fmt.Printf("Done\n")
+	printResult(doneOutput{}, func() {
+		fmt.Printf("Done\n")
+	})
 }
 
 func coreConvertCmd(cmd *cobra.Command, args []string) {
@@ -362,7 +941,13 @@ func coreConvertCmd(cmd *cobra.Command, args []string) {
 		return
 	}
 
-	fmt.Printf("Corefile created for\n   %x\n", coreConvert.ImageHash)
+	out := coreOutput{
+		File:      args[1],
+		ImageHash: hex.EncodeToString(coreConvert.ImageHash),
+	}
+	printResult(out, func() {
+		fmt.Printf("Corefile created for\n   %x\n", coreConvert.ImageHash)
+	})
 }
 
 func imageCmd() *cobra.Command {
@@ -417,8 +1002,107 @@ func imageCmd() *cobra.Command {
 	uploadCmd.PersistentFlags().IntVarP(&imageNum,
 		"image", "n", 0,
 		"In a multi-image system, which image should be uploaded")
+	uploadCmd.PersistentFlags().IntVarP(&uploadWindow,
+		"window", "w", 1,
+		"Maximum number of upload requests to have outstanding at once")
+	uploadCmd.PersistentFlags().BoolVar(&uploadResume, "resume", false,
+		"Continue an interrupted upload of the same image from the offset "+
+			"reported by the device; default if the upload journal has an "+
+			"entry for this image and device")
+	uploadCmd.PersistentFlags().BoolVar(&uploadRestart, "restart", false,
+		"Discard any interrupted upload and start over")
+	uploadCmd.PersistentFlags().BoolVarP(&uploadForce, "force", "f", false,
+		"Upload the file even if it isn't a valid MCUboot image")
+	uploadCmd.PersistentFlags().StringVar(&imageKeyFile, "key", "",
+		"Refuse to upload the image unless it is signed with this key "+
+			"(PEM file)")
+	uploadCmd.PersistentFlags().BoolVar(&uploadForceUpload, "force-upload",
+		false, "Upload the image even if the device already has it")
 	imageCmd.AddCommand(uploadCmd)
 
+	deployEx := "  " + nmutil.ToolInfo.ExeName +
+		" -c olimex image deploy bin/slinky_zero/apps/slinky.img\n"
+	deployEx += "  " + nmutil.ToolInfo.ExeName +
+		" -c olimex image deploy --check-echo hello " +
+		"--check-stat ble_ll.rx_crc_err<10 bin/slinky_zero/apps/slinky.img\n"
+
+	deployCmd := &cobra.Command{
+		Use:   "deploy <image-file> -c <conn_profile>",
+		Short: "Upload, test, and confirm an image on a device",
+		Long: "Upload an image, mark it for test, and reset the device.  " +
+			"Once the device comes back running the new image and passes " +
+			"the specified health checks, the image is confirmed.  If any " +
+			"step after the reset fails, the image is left unconfirmed so " +
+			"that the device reverts to its previous image.",
+		Example: deployEx,
+		Run:     imageDeployCmd,
+	}
+	deployCmd.Flags().IntVarP(&imageNum, "image", "n", 0,
+		"In a multi-image system, which image should be deployed")
+	deployCmd.Flags().IntVarP(&uploadWindow, "window", "w", 1,
+		"Maximum number of upload requests to have outstanding at once")
+	deployCmd.Flags().BoolVar(&uploadResume, "resume", false,
+		"Continue an interrupted upload of the same image from the offset "+
+			"reported by the device; default if the upload journal has an "+
+			"entry for this image and device")
+	deployCmd.Flags().BoolVar(&uploadRestart, "restart", false,
+		"Discard any interrupted upload and start over")
+	deployCmd.Flags().BoolVarP(&uploadForce, "force", "f", false,
+		"Upload the file even if it isn't a valid MCUboot image")
+	deployCmd.Flags().StringVar(&imageKeyFile, "key", "",
+		"Refuse to upload the image unless it is signed with this key "+
+			"(PEM file)")
+	deployCmd.Flags().BoolVar(&uploadForceUpload, "force-upload", false,
+		"Upload the image even if the device already has it")
+	deployCmd.Flags().StringVar(&deployCheckEcho, "check-echo", "",
+		"Health check: the device must echo this string back")
+	deployCmd.Flags().StringSliceVar(&deployCheckStats, "check-stat", nil,
+		"Health check: a stat threshold of the form "+
+			"<group>.<field><op><value>, where op is one of <, <=, >, >=, "+
+			"==, !=; may be repeated")
+	deployCmd.Flags().BoolVar(&deployNoConfirm, "no-confirm", false,
+		"Leave the new image in test mode rather than confirming it")
+	deployCmd.Flags().BoolVar(&deployNoRollback, "no-rollback", false,
+		"Don't reset the device when a health check fails")
+	deployCmd.Flags().DurationVar(&deployResetDelay, "reset-delay",
+		time.Second, "Time to wait after resetting the device")
+	deployCmd.Flags().DurationVar(&deployReconnectTimeout,
+		"reconnect-timeout", 60*time.Second,
+		"How long to wait for the device to come back after a reset")
+	imageCmd.AddCommand(deployCmd)
+
+	infoEx := "  " + nmutil.ToolInfo.ExeName +
+		" image info bin/slinky_zero/apps/slinky.img\n"
+
+	infoCmd := &cobra.Command{
+		Use:   "info <image-file> [image-file...]",
+		Short: "Show the contents of a local image file",
+		Long: "Parse the MCUboot header and TLVs of a local image file " +
+			"and check that its SHA256 TLV matches its contents.  No " +
+			"device is needed.",
+		Example: infoEx,
+		Run:     imageInfoCmd,
+	}
+	imageCmd.AddCommand(infoCmd)
+
+	verifyEx := "  " + nmutil.ToolInfo.ExeName +
+		" image verify --key root-ec-p256.pem " +
+		"bin/slinky_zero/apps/slinky.img\n"
+
+	verifyCmd := &cobra.Command{
+		Use:   "verify --key <key-file> <image-file> [image-file...]",
+		Short: "Check the signature of a local image file",
+		Long: "Check that a local image file is intact and signed with the " +
+			"specified key.  ECDSA P-256, Ed25519, and RSA-2048 (PSS) " +
+			"keys are supported; the key file may contain either the " +
+			"public key or the signing key.  No device is needed.",
+		Example: verifyEx,
+		Run:     imageVerifyCmd,
+	}
+	verifyCmd.Flags().StringVar(&imageKeyFile, "key", "",
+		"PEM file containing the key to check against")
+	imageCmd.AddCommand(verifyCmd)
+
 	coreListCmd := &cobra.Command{
 		Use:     "corelist -c <conn_profile>",
 		Short:   "List core(s) on a device",
@@ -470,6 +1154,8 @@ func imageCmd() *cobra.Command {
 		Example: imageEraseEx,
 		Run:     imageEraseCmd,
 	}
+	imageEraseCmd.Flags().IntVarP(&imageNum, "image", "n", 0,
+		"In a multi-image system, which image's secondary slot to erase")
 	imageCmd.AddCommand(imageEraseCmd)
 
 	coreConvertCmd := &cobra.Command{
@@ -23,8 +23,10 @@ import (
 	"encoding/hex"
 	"encoding/json"
 	"fmt"
+	"os"
 	"sort"
 	"strconv"
+	"time"
 
 	"github.com/spf13/cobra"
 
@@ -37,6 +39,8 @@ import (
 )
 
 var optLogShowFull bool
+var optLogShowFollow bool
+var optLogShowInterval time.Duration
 
 // Converts the provided CBOR map to a JSON string.
 func logCborMsgText(cborMap []byte) (string, error) {
@@ -45,7 +49,7 @@ func logCborMsgText(cborMap []byte) (string, error) {
 		return "", err
 	}
 
-	msg, err := json.Marshal(cm)
+	msg, err := json.Marshal(jsonValue(cm))
 	if err != nil {
 		return "", util.ChildNewtError(err)
 	}
@@ -53,6 +57,114 @@ func logCborMsgText(cborMap []byte) (string, error) {
 	return string(msg), nil
 }
 
+type logShowOutput struct {
+	NextIndex uint32      `json:"next_index"`
+	Logs      []logOutput `json:"logs"`
+}
+
+type logOutput struct {
+	Name    string           `json:"name"`
+	Type    string           `json:"type"`
+	Entries []logEntryOutput `json:"entries"`
+}
+
+// Describes a log entry.  The message is a string for string entries, an
+// object for CBOR entries, and a hex string for binary entries or entries
+// that can't be decoded.
+type logEntryOutput struct {
+	Index      uint32      `json:"index"`
+	Timestamp  int64       `json:"timestamp"`
+	Module     uint8       `json:"module"`
+	ModuleName string      `json:"module_name"`
+	Level      uint8       `json:"level"`
+	LevelName  string      `json:"level_name"`
+	Type       string      `json:"type"`
+	ImgHash    string      `json:"img_hash,omitempty"`
+	Msg        interface{} `json:"msg"`
+}
+
+func newLogEntryOutput(entry nmp.LogEntry) logEntryOutput {
+	eo := logEntryOutput{
+		Index:      entry.Index,
+		Timestamp:  entry.Timestamp,
+		Module:     entry.Module,
+		ModuleName: devLogNames.ModuleString(int(entry.Module)),
+		Level:      entry.Level,
+		LevelName:  devLogNames.LevelString(int(entry.Level)),
+		Type:       entry.Type.String(),
+		ImgHash:    hex.EncodeToString(entry.ImgHash),
+		Msg:        hex.EncodeToString(entry.Msg),
+	}
+
+	switch entry.Type {
+	case nmp.LOG_ENTRY_TYPE_STRING:
+		eo.Msg = string(entry.Msg)
+
+	case nmp.LOG_ENTRY_TYPE_CBOR:
+		if cm, err := nmxutil.DecodeCborMap(entry.Msg); err == nil {
+			eo.Msg = jsonValue(cm)
+		}
+
+	case nmp.LOG_ENTRY_TYPE_BINARY:
+		fields, err := nmp.DecodeLogEntry(entry)
+		if err == nil && fields != nil {
+			eo.Msg = logFieldsOutput(fields)
+		}
+	}
+
+	return eo
+}
+
+// Combines a sequence of log show responses into a single result.  Entries
+// from the same log are merged.
+func newLogShowOutput(rsps []*nmp.LogShowRsp) logShowOutput {
+	out := logShowOutput{Logs: []logOutput{}}
+
+	idxs := map[string]int{}
+	for _, rsp := range rsps {
+		out.NextIndex = rsp.NextIndex
+
+		for _, log := range rsp.Logs {
+			i, ok := idxs[log.Name]
+			if !ok {
+				i = len(out.Logs)
+				idxs[log.Name] = i
+				out.Logs = append(out.Logs, logOutput{
+					Name:    log.Name,
+					Type:    nmp.LogTypeToString(log.Type),
+					Entries: []logEntryOutput{},
+				})
+			}
+
+			for _, entry := range log.Entries {
+				out.Logs[i].Entries = append(out.Logs[i].Entries,
+					newLogEntryOutput(entry))
+			}
+		}
+	}
+
+	return out
+}
+
+// A log entry printed as a record of its own, e.g., by `log show --follow`,
+// tagged with the name of its log.
+type logRecordOutput struct {
+	Log string `json:"log"`
+	logEntryOutput
+}
+
+type logListOutput struct {
+	Logs []string `json:"logs"`
+}
+
+type logModuleListOutput struct {
+	Modules map[string]int `json:"modules"`
+}
+
+type logLevelListOutput struct {
+	Levels map[string]int `json:"levels"`
+}
+
 type logShowCfg struct {
 	Name      string
 	Last      bool
@@ -117,9 +229,9 @@ func printLogShowRsp(rsp *nmp.LogShowRsp, printHdr bool) {
 
 		for _, entry := range log.Entries {
 			modText := fmt.Sprintf("%s (%d)",
-				nmp.LogModuleToString(int(entry.Module)), entry.Module)
+				devLogNames.ModuleString(int(entry.Module)), entry.Module)
 			levText := fmt.Sprintf("%s (%d)",
-				nmp.LogLevelToString(int(entry.Level)), entry.Level)
+				devLogNames.LevelString(int(entry.Level)), entry.Level)
 
 			var err error
 			msgText := ""
@@ -136,7 +248,15 @@ func printLogShowRsp(rsp *nmp.LogShowRsp, printHdr bool) {
 				}
 
 			case nmp.LOG_ENTRY_TYPE_BINARY:
-				msgText = hex.EncodeToString(entry.Msg)
+				msgText, err = logBinaryMsgText(entry)
+				if err != nil {
+					fmt.Printf("Error decoding binary entry: %s; "+
+						"idx=%d",
+						err.Error(), entry.Index)
+				}
+				if msgText == "" {
+					msgText = hex.EncodeToString(entry.Msg)
+				}
 
 			default:
 				fmt.Printf(
@@ -170,18 +290,227 @@ func logShowFullCmd(s sesn.Sesn, cfg *logShowCfg) error {
 
 	first := true
 	c.ProgressCb = func(_ *xact.LogShowFullCmd, rsp *nmp.LogShowRsp) {
-		printLogShowRsp(rsp, first)
+		checkLogNames(s, rsp)
+		archiveLogShowRsp(rsp)
+		if !structuredOutput() {
+			printLogShowRsp(rsp, first)
+		}
 		first = false
 	}
 
-	_, err := c.Run(s)
+	res, err := c.Run(s)
+	if err != nil {
+		return err
+	}
+
+	// In text mode, the responses were printed as they came in.
+	sres := res.(*xact.LogShowFullResult)
+	printResult(newLogShowOutput(sres.Rsps), func() {})
+
+	return nil
+}
+
+// Tails the logs on a device.  The index of the next entry to read is kept
+// for each log, so a poll only retrieves entries that haven't been printed.
+type logFollower struct {
+	s     sesn.Sesn
+	name  string
+	start uint32
+	last  bool
+
+	next    map[string]uint32
+	devNext uint32
+	seen    map[string]bool
+	lost    bool
+}
+
+func newLogFollower(s sesn.Sesn, cfg *logShowCfg) *logFollower {
+	return &logFollower{
+		s:     s,
+		name:  cfg.Name,
+		start: cfg.Index,
+		last:  cfg.Timestamp == -1,
+		next:  map[string]uint32{},
+		seen:  map[string]bool{},
+	}
+}
+
+func (f *logFollower) nextIndex(name string) uint32 {
+	if idx, ok := f.next[name]; ok {
+		return idx
+	}
+
+	return f.start
+}
+
+// Prints the entries in a response that haven't been printed yet.
+func (f *logFollower) printNew(rsp *nmp.LogShowRsp) {
+	checkLogNames(f.s, rsp)
+	archiveLogShowRsp(rsp)
+
+	for _, log := range rsp.Logs {
+		next := f.nextIndex(log.Name)
+
+		var entries []nmp.LogEntry
+		for _, entry := range log.Entries {
+			if entry.Index >= next {
+				entries = append(entries, entry)
+				next = entry.Index + 1
+			}
+		}
+		f.next[log.Name] = next
+
+		if len(entries) == 0 {
+			continue
+		}
+
+		if structuredOutput() {
+			for _, entry := range entries {
+				printRecord(logRecordOutput{
+					Log:            log.Name,
+					logEntryOutput: newLogEntryOutput(entry),
+				})
+			}
+		} else {
+			log.Entries = entries
+			printLogShowRsp(&nmp.LogShowRsp{Logs: []nmp.LogShowLog{log}},
+				!f.seen[log.Name])
+			f.seen[log.Name] = true
+		}
+	}
+}
+
+func (f *logFollower) logNames() ([]string, error) {
+	if f.name != "" {
+		return []string{f.name}, nil
+	}
+
+	c := xact.NewLogListCmd()
+	c.SetTxOptions(nmutil.TxOptions())
+
+	res, err := c.Run(f.s)
+	if err != nil {
+		return nil, err
+	}
+
+	names := res.(*xact.LogListResult).Rsp.List
+	sort.Strings(names)
+
+	return names, nil
+}
+
+// Reads only the most recent entry of a log, for `log show <name> last`.
+func (f *logFollower) pollLast(name string) error {
+	c := xact.NewLogShowCmd()
+	c.SetTxOptions(nmutil.TxOptions())
+	c.Name = name
+	c.Timestamp = -1
+
+	res, err := c.Run(f.s)
+	if err != nil {
+		return err
+	}
+
+	f.printNew(res.(*xact.LogShowResult).Rsp)
+	return nil
+}
+
+// Reads the entries that were added to a log since the last poll.
+func (f *logFollower) poll(name string) error {
+	if _, ok := f.next[name]; !ok && f.last {
+		return f.pollLast(name)
+	}
+
+	c := xact.NewLogShowFullCmd()
+	c.SetTxOptions(nmutil.TxOptions())
+	c.Name = name
+	c.Index = f.nextIndex(name)
+	c.ProgressCb = func(_ *xact.LogShowFullCmd, rsp *nmp.LogShowRsp) {
+		f.printNew(rsp)
+	}
+
+	res, err := c.Run(f.s)
 	if err != nil {
 		return err
 	}
 
+	// If the device's next index went down, the device was erased or lost
+	// its logs; start every log over from the beginning.  The index is
+	// shared by all of a device's logs, so a log that was already read up
+	// to the new index wouldn't notice on its own.  Devices that don't
+	// report a next index send 0.
+	sres := res.(*xact.LogShowFullResult)
+	nextIdx := sres.Rsps[len(sres.Rsps)-1].NextIndex
+	if nextIdx != 0 {
+		if nextIdx < f.devNext || nextIdx < f.next[name] {
+			for n := range f.next {
+				f.next[n] = 0
+			}
+		}
+		f.devNext = nextIdx
+	}
+
 	return nil
 }
 
+func (f *logFollower) pollAll() error {
+	names, err := f.logNames()
+	if err != nil {
+		return err
+	}
+
+	for _, name := range names {
+		if err := f.poll(name); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Polls the device's logs until the process is killed.  Errors other than
+// an error response from the device are assumed to be connection problems;
+// they are reported once and retried until the device comes back.
+func (f *logFollower) run(interval time.Duration) error {
+	for {
+		err := f.pollAll()
+		if err != nil {
+			if isNmpError(err) {
+				return err
+			}
+
+			if !f.lost {
+				fmt.Fprintf(os.Stderr,
+					"Warning: lost contact with device (%s); retrying\n",
+					err.Error())
+				f.lost = true
+			}
+
+			// If the session can't be reopened, the next poll fails too.
+			if !f.s.IsOpen() {
+				f.s.Open()
+			}
+		} else if f.lost {
+			fmt.Fprintf(os.Stderr, "Reconnected to device\n")
+			f.lost = false
+		}
+
+		time.Sleep(interval)
+	}
+}
+
+func logShowFollowCmd(s sesn.Sesn, cfg *logShowCfg) error {
+	if cfg.Timestamp > 0 {
+		return util.NewNewtError(
+			"min-timestamp can't be specified with `--follow`")
+	}
+	if optLogShowInterval <= 0 {
+		return util.NewNewtError("poll interval must be positive")
+	}
+
+	return newLogFollower(s, cfg).run(optLogShowInterval)
+}
+
 func logShowPartialCmd(s sesn.Sesn, cfg *logShowCfg) error {
 	c := xact.NewLogShowCmd()
 	c.SetTxOptions(nmutil.TxOptions())
@@ -196,13 +525,18 @@ func logShowPartialCmd(s sesn.Sesn, cfg *logShowCfg) error {
 	}
 
 	sres := res.(*xact.LogShowResult)
-	fmt.Printf("Status: %d\n", sres.Status())
-	fmt.Printf("Next index: %d\n", sres.Rsp.NextIndex)
-	if len(sres.Rsp.Logs) == 0 {
-		fmt.Printf("(no logs retrieved)\n")
-	} else {
-		printLogShowRsp(sres.Rsp, true)
-	}
+	checkLogNames(s, sres.Rsp)
+	archiveLogShowRsp(sres.Rsp)
+	out := newLogShowOutput([]*nmp.LogShowRsp{sres.Rsp})
+	printResult(out, func() {
+		fmt.Printf("Status: %d\n", sres.Status())
+		fmt.Printf("Next index: %d\n", sres.Rsp.NextIndex)
+		if len(sres.Rsp.Logs) == 0 {
+			fmt.Printf("(no logs retrieved)\n")
+		} else {
+			printLogShowRsp(sres.Rsp, true)
+		}
+	})
 
 	return nil
 }
@@ -213,12 +547,20 @@ func logShowCmd(cmd *cobra.Command, args []string) {
 		nmUsage(cmd, err)
 	}
 
+	if err := loadLogLayouts(); err != nil {
+		nmUsage(nil, err)
+	}
+
 	s, err := GetSesn()
 	if err != nil {
 		nmUsage(nil, err)
 	}
 
-	if optLogShowFull {
+	loadLogNames(s)
+
+	if optLogShowFollow {
+		err = logShowFollowCmd(s, cfg)
+	} else if optLogShowFull {
 		err = logShowFullCmd(s, cfg)
 	} else {
 		err = logShowPartialCmd(s, cfg)
@@ -243,17 +585,18 @@ func logListCmd(cmd *cobra.Command, args []string) {
 	}
 
 	sres := res.(*xact.LogListResult)
-	if sres.Rsp.Rc != 0 {
-		fmt.Printf("error: %d\n", sres.Rsp.Rc)
-		return
-	}
-
 	sort.Strings(sres.Rsp.List)
 
-	fmt.Printf("available logs:\n")
-	for _, log := range sres.Rsp.List {
-		fmt.Printf("    %s\n", log)
+	out := logListOutput{Logs: sres.Rsp.List}
+	if out.Logs == nil {
+		out.Logs = []string{}
 	}
+	printResult(out, func() {
+		fmt.Printf("available logs:\n")
+		for _, log := range sres.Rsp.List {
+			fmt.Printf("    %s\n", log)
+		}
+	})
 }
 
 func logModuleListCmd(cmd *cobra.Command, args []string) {
@@ -271,21 +614,22 @@ func logModuleListCmd(cmd *cobra.Command, args []string) {
 	}
 
 	sres := res.(*xact.LogModuleListResult)
-	if sres.Rsp.Rc != 0 {
-		fmt.Printf("error: %d\n", sres.Rsp.Rc)
-		return
-	}
-
 	names := make([]string, 0, len(sres.Rsp.Map))
 	for k, _ := range sres.Rsp.Map {
 		names = append(names, k)
 	}
 	sort.Strings(names)
 
-	fmt.Printf("available modules:\n")
-	for _, name := range names {
-		fmt.Printf("    %s (%d)\n", name, sres.Rsp.Map[name])
+	out := logModuleListOutput{Modules: sres.Rsp.Map}
+	if out.Modules == nil {
+		out.Modules = map[string]int{}
 	}
+	printResult(out, func() {
+		fmt.Printf("available modules:\n")
+		for _, name := range names {
+			fmt.Printf("    %s (%d)\n", name, sres.Rsp.Map[name])
+		}
+	})
 }
 
 func logLevelListCmd(cmd *cobra.Command, args []string) {
@@ -303,11 +647,6 @@ func logLevelListCmd(cmd *cobra.Command, args []string) {
 	}
 
 	sres := res.(*xact.LogLevelListResult)
-	if sres.Rsp.Rc != 0 {
-		fmt.Printf("error: %d\n", sres.Rsp.Rc)
-		return
-	}
-
 	vals := make([]int, 0, len(sres.Rsp.Map))
 	revmap := make(map[int]string, len(sres.Rsp.Map))
 	for name, val := range sres.Rsp.Map {
@@ -316,10 +655,16 @@ func logLevelListCmd(cmd *cobra.Command, args []string) {
 	}
 	sort.Ints(vals)
 
-	fmt.Printf("available levels:\n")
-	for _, val := range vals {
-		fmt.Printf("    %d: %s\n", val, revmap[val])
+	out := logLevelListOutput{Levels: sres.Rsp.Map}
+	if out.Levels == nil {
+		out.Levels = map[string]int{}
 	}
+	printResult(out, func() {
+		fmt.Printf("available levels:\n")
+		for _, val := range vals {
+			fmt.Printf("    %d: %s\n", val, revmap[val])
+		}
+	})
 }
 
 func logClearCmd(cmd *cobra.Command, args []string) {
@@ -331,18 +676,13 @@ func logClearCmd(cmd *cobra.Command, args []string) {
 	c := xact.NewLogClearCmd()
 	c.SetTxOptions(nmutil.TxOptions())
 
-	res, err := c.Run(s)
-	if err != nil {
+	if _, err := c.Run(s); err != nil {
 		nmUsage(nil, util.ChildNewtError(err))
 	}
 
-	sres := res.(*xact.LogClearResult)
-	if sres.Rsp.Rc != 0 {
-		fmt.Printf("error: %d\n", sres.Rsp.Rc)
-		return
-	}
-
-	fmt.Printf("done\n")
+	printResult(doneOutput{}, func() {
+		fmt.Printf("done\n")
+	})
 }
 
 func logCmd() *cobra.Command {
@@ -358,13 +698,16 @@ func logCmd() *cobra.Command {
 	logShowHelpText += "- log-name specifies the log to display.  If log-name is not specified, all\nlogs are displayed.\n\n"
 	logShowHelpText += "- min-index specifies to only display the log entries with an index value equal to or higher than min-index.  "
 	logShowHelpText += "If \"last\"  is specified for min-index, the last\nlog entry is displayed.\n\n"
-	logShowHelpText += "- min-timestamp specifies to only display the log entries with a timestamp\nequal to or later than min-timestamp. Log entries with a timestamp equal to\nmin-timestamp are only displayed if the entry index is equal to or higher than min-index.\n"
+	logShowHelpText += "- min-timestamp specifies to only display the log entries with a timestamp\nequal to or later than min-timestamp. Log entries with a timestamp equal to\nmin-timestamp are only displayed if the entry index is equal to or higher than min-index.\n\n"
+	logShowHelpText += "With --follow, the logs are polled until the command is interrupted, and only\nnew entries are printed, starting from min-index (or the last entry).  Lost\nconnections are retried.  In JSON or YAML output, each entry is a separate\nrecord.\n\n"
+	logShowHelpText += "Displayed entries are saved to a local archive of the device's logs; see\n`log archive`.\n"
 
 	logShowEx := nmutil.ToolInfo.ExeName + " log show -c myserial\n"
 	logShowEx += nmutil.ToolInfo.ExeName + " log show reboot_log -c myserial\n"
 	logShowEx += nmutil.ToolInfo.ExeName + " log show reboot_log last -c myserial\n"
 	logShowEx += nmutil.ToolInfo.ExeName + " log show reboot_log 5 -c myserial\n"
 	logShowEx += nmutil.ToolInfo.ExeName + " log show reboot_log 3 1122222 -c myserial\n"
+	logShowEx += nmutil.ToolInfo.ExeName + " log show --follow -c myserial\n"
 
 	showCmd := &cobra.Command{
 		Use:     "show [log-name [min-index [min-timestamp]]] -c <conn_profile>",
@@ -374,6 +717,14 @@ func logCmd() *cobra.Command {
 		Run:     logShowCmd,
 	}
 	showCmd.PersistentFlags().BoolVarP(&optLogShowFull, "all", "a", false, "read until end of log")
+	showCmd.PersistentFlags().BoolVarP(&optLogShowFollow, "follow", "f",
+		false, "keep polling the device and print new entries as they "+
+			"are added")
+	showCmd.PersistentFlags().DurationVar(&optLogShowInterval, "interval",
+		time.Second, "time between polls with --follow")
+	showCmd.PersistentFlags().BoolVar(&optLogShowNoArchive, "no-archive",
+		false, "don't save the entries to the local log archive")
+	addLogLayoutsFlag(showCmd)
 	logCmd.AddCommand(showCmd)
 
 	clearCmd := &cobra.Command{
@@ -391,6 +742,9 @@ func logCmd() *cobra.Command {
 	}
 	logCmd.AddCommand(moduleListCmd)
 
+	logCmd.AddCommand(logExportSubCmd())
+	logCmd.AddCommand(logArchiveCmd())
+
 	levelListCmd := &cobra.Command{
 		Use:   "level_list -c <conn_profile>",
 		Short: "Show the log levels",
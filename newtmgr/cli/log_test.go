@@ -0,0 +1,254 @@
+/**
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"mynewt.apache.org/newtmgr/nmxact/emulator"
+	"mynewt.apache.org/newtmgr/nmxact/nmp"
+	"mynewt.apache.org/newtmgr/nmxact/sesn"
+	"mynewt.apache.org/newtmgr/nmxact/xact"
+)
+
+func newEmuTestSesn(t *testing.T) (*emulator.Device, sesn.Sesn) {
+	dev := emulator.NewDevice()
+	x := emulator.NewEmuXport(emulator.NewXportCfg(), dev)
+	if err := x.Start(); err != nil {
+		t.Fatalf("xport start: %v", err)
+	}
+
+	sc := sesn.NewSesnCfg()
+	sc.MgmtProto = sesn.MGMT_PROTO_NMP
+
+	s, err := x.BuildSesn(sc)
+	if err != nil {
+		t.Fatalf("build sesn: %v", err)
+	}
+	if err := s.Open(); err != nil {
+		t.Fatalf("open sesn: %v", err)
+	}
+
+	t.Cleanup(func() {
+		s.Close()
+		x.Stop()
+	})
+
+	return dev, s
+}
+
+// Prints followed entries as JSON records, without reading the device's log
+// names or archiving anything.
+func setLogFollowTest(t *testing.T) {
+	setOutputFormat(t, OUTPUT_JSON)
+
+	prevNoArchive, prevNamesRead := optLogShowNoArchive, devLogNamesRead
+	optLogShowNoArchive = true
+	devLogNamesRead = true
+	t.Cleanup(func() {
+		optLogShowNoArchive, devLogNamesRead = prevNoArchive, prevNamesRead
+	})
+}
+
+// Runs fn and returns the "log:index" of each record it printed.
+func followedEntries(t *testing.T, fn func() error) []string {
+	t.Helper()
+
+	var err error
+	out := captureStdout(t, func() { err = fn() })
+	if err != nil {
+		t.Fatalf("follow: %v", err)
+	}
+
+	var entries []string
+	for _, line := range strings.Split(strings.TrimSpace(out), "\n") {
+		if line == "" {
+			continue
+		}
+
+		var rec struct {
+			Log   string `json:"log"`
+			Index uint32 `json:"index"`
+		}
+		if err := json.Unmarshal([]byte(line), &rec); err != nil {
+			t.Fatalf("bad record %q: %v", line, err)
+		}
+		entries = append(entries, fmt.Sprintf("%s:%d", rec.Log, rec.Index))
+	}
+
+	return entries
+}
+
+func logShowRsp(logs map[string][]uint32) *nmp.LogShowRsp {
+	rsp := &nmp.LogShowRsp{}
+	for _, name := range []string{"a", "b"} {
+		l := nmp.LogShowLog{Name: name}
+		for _, idx := range logs[name] {
+			l.Entries = append(l.Entries, nmp.LogEntry{
+				Index: idx,
+				Type:  nmp.LOG_ENTRY_TYPE_STRING,
+				Msg:   []byte("m"),
+			})
+		}
+		rsp.Logs = append(rsp.Logs, l)
+	}
+
+	return rsp
+}
+
+func TestLogFollowerPrintNew(t *testing.T) {
+	setLogFollowTest(t)
+
+	// Entries repeated in overlapping responses are only printed once, and
+	// each log keeps its own next index.
+	f := newLogFollower(nil, &logShowCfg{})
+	got := followedEntries(t, func() error {
+		f.printNew(logShowRsp(map[string][]uint32{"a": {0, 1, 2}}))
+		f.printNew(logShowRsp(map[string][]uint32{
+			"a": {1, 2, 3},
+			"b": {2, 5},
+		}))
+		f.printNew(logShowRsp(map[string][]uint32{"a": {3}, "b": {5, 6}}))
+		return nil
+	})
+	want := []string{"a:0", "a:1", "a:2", "a:3", "b:2", "b:5", "b:6"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("printed %v, want %v", got, want)
+	}
+	if f.next["a"] != 4 || f.next["b"] != 7 {
+		t.Errorf("next indexes: %v", f.next)
+	}
+
+	// Entries below the starting index are skipped.
+	f = newLogFollower(nil, &logShowCfg{Index: 2})
+	got = followedEntries(t, func() error {
+		f.printNew(logShowRsp(map[string][]uint32{
+			"a": {0, 1, 2, 3},
+			"b": {1},
+		}))
+		return nil
+	})
+	want = []string{"a:2", "a:3"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("with start index: printed %v, want %v", got, want)
+	}
+}
+
+func TestLogFollowerPoll(t *testing.T) {
+	setLogFollowTest(t)
+	dev, s := newEmuTestSesn(t)
+
+	appendApp := func(n int, size int) {
+		for i := 0; i < n; i++ {
+			dev.AppendLog("app", uint8(nmp.MODULE_DEFAULT),
+				uint8(nmp.LEVEL_INFO), strings.Repeat("x", size))
+		}
+	}
+
+	// The first poll prints every log's entries.  The emulator starts with
+	// a reboot entry and a startup entry.
+	appendApp(2, 10)
+	f := newLogFollower(s, &logShowCfg{})
+	got := followedEntries(t, f.pollAll)
+	want := []string{"app:2", "app:3", "log:1", "reboot_log:0"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("first poll: printed %v, want %v", got, want)
+	}
+
+	// Nothing new.
+	if got := followedEntries(t, f.pollAll); len(got) != 0 {
+		t.Fatalf("idle poll: printed %v", got)
+	}
+
+	// New entries that take several responses to read are each printed
+	// once.
+	appendApp(30, 50)
+	got = followedEntries(t, f.pollAll)
+	if len(got) != 30 || got[0] != "app:4" || got[29] != "app:33" {
+		t.Fatalf("paged poll: printed %v", got)
+	}
+
+	// A circular log that wrapped between polls; the entries that were
+	// overwritten are gone, and the rest are printed.
+	dev.SetLogCapacity("app", 5)
+	appendApp(20, 10)
+	got = followedEntries(t, f.pollAll)
+	want = []string{"app:49", "app:50", "app:51", "app:52", "app:53"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("wrapped log: printed %v, want %v", got, want)
+	}
+
+	// Erase the logs and reboot; the device's index starts over.  Once a
+	// poll notices, every log is read from the beginning again, including
+	// ones that were already read past the device's new index.
+	runCmd := func(c xact.Cmd) {
+		if _, err := c.Run(s); err != nil {
+			t.Fatalf("%T: %v", c, err)
+		}
+	}
+	runCmd(xact.NewLogClearCmd())
+	runCmd(xact.NewResetCmd())
+	appendApp(1, 10)
+
+	got = followedEntries(t, f.pollAll)
+	want = []string{"reboot_log:0"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("poll after erase: printed %v, want %v", got, want)
+	}
+	got = followedEntries(t, f.pollAll)
+	want = []string{"app:1"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("poll after reset: printed %v, want %v", got, want)
+	}
+	if got := followedEntries(t, f.pollAll); len(got) != 0 {
+		t.Fatalf("idle poll after reset: printed %v", got)
+	}
+}
+
+func TestLogFollowerLast(t *testing.T) {
+	setLogFollowTest(t)
+	dev, s := newEmuTestSesn(t)
+
+	for i := 0; i < 3; i++ {
+		dev.AppendLog("app", uint8(nmp.MODULE_DEFAULT),
+			uint8(nmp.LEVEL_INFO), "m")
+	}
+
+	// The first poll only prints the most recent entry; later ones print
+	// everything after it.
+	f := newLogFollower(s, &logShowCfg{Name: "app", Timestamp: -1})
+	got := followedEntries(t, f.pollAll)
+	if !reflect.DeepEqual(got, []string{"app:4"}) {
+		t.Fatalf("first poll: printed %v", got)
+	}
+
+	dev.AppendLog("app", uint8(nmp.MODULE_DEFAULT), uint8(nmp.LEVEL_INFO),
+		"m")
+	dev.AppendLog("app", uint8(nmp.MODULE_DEFAULT), uint8(nmp.LEVEL_INFO),
+		"m")
+	got = followedEntries(t, f.pollAll)
+	if !reflect.DeepEqual(got, []string{"app:5", "app:6"}) {
+		t.Fatalf("second poll: printed %v", got)
+	}
+}
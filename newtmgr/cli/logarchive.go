@@ -0,0 +1,204 @@
+/**
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package cli
+
+import (
+	"sort"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+
+	"mynewt.apache.org/newt/util"
+	"mynewt.apache.org/newtmgr/newtmgr/config"
+	"mynewt.apache.org/newtmgr/newtmgr/nmutil"
+	"mynewt.apache.org/newtmgr/nmxact/nmp"
+)
+
+var optLogShowNoArchive bool
+var logArchiveImg string
+
+// The log archive of the device being managed; read when the first entries
+// are archived.
+var devLogArchive *config.LogArchive
+
+// Set if the archive can't be used; the failure is only reported once.
+var devLogArchiveFailed bool
+
+type logArchiveQueryOutput struct {
+	Logs []logOutput `json:"logs"`
+}
+
+// Saves the entries in a log show response to the device's log archive.
+// Failures are reported, but don't prevent the logs from being displayed.
+func archiveLogShowRsp(rsp *nmp.LogShowRsp) {
+	if optLogShowNoArchive || devLogArchiveFailed {
+		return
+	}
+
+	if devLogArchive == nil {
+		key, err := DeviceKey()
+		if err == nil {
+			devLogArchive, err = config.ReadLogArchive(key)
+		}
+		if err != nil {
+			log.Warnf("Failed to read log archive: %s", err.Error())
+			devLogArchiveFailed = true
+			return
+		}
+	}
+
+	if _, err := devLogArchive.Add(rsp); err != nil {
+		log.Warnf("Failed to update log archive: %s", err.Error())
+		devLogArchiveFailed = true
+	}
+}
+
+func logArchiveQueryCmd(cmd *cobra.Command, args []string) {
+	if len(args) > 1 {
+		nmUsage(cmd, nil)
+	}
+
+	key, err := DeviceKey()
+	if err != nil {
+		nmUsage(nil, err)
+	}
+
+	// The device isn't contacted, so only cached names can be used.
+	loadCachedLogNames()
+
+	filter, err := newLogFilter()
+	if err != nil {
+		nmUsage(cmd, err)
+	}
+
+	if err := loadLogLayouts(); err != nil {
+		nmUsage(nil, err)
+	}
+
+	a, err := config.ReadLogArchive(key)
+	if err != nil {
+		nmUsage(nil, err)
+	}
+
+	if err := checkHexPrefix(logArchiveImg); err != nil {
+		nmUsage(cmd, err)
+	}
+	img := strings.ToLower(logArchiveImg)
+
+	logs := map[string]*nmp.LogShowLog{}
+	for _, e := range a.Entries() {
+		if len(args) > 0 && e.Log != args[0] {
+			continue
+		}
+		if !strings.HasPrefix(e.ImgHash, img) {
+			continue
+		}
+
+		entry := e.LogEntry()
+		if !filter.match(entry) {
+			continue
+		}
+
+		l := logs[e.Log]
+		if l == nil {
+			l = &nmp.LogShowLog{
+				Name: e.Log,
+				Type: e.LogType,
+			}
+			logs[e.Log] = l
+		}
+		l.Entries = append(l.Entries, entry)
+	}
+
+	names := make([]string, 0, len(logs))
+	for name := range logs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	rsp := &nmp.LogShowRsp{}
+	for _, name := range names {
+		rsp.Logs = append(rsp.Logs, *logs[name])
+	}
+
+	out := logArchiveQueryOutput{
+		Logs: newLogShowOutput([]*nmp.LogShowRsp{rsp}).Logs,
+	}
+	printResult(out, func() {
+		printLogShowRsp(rsp, true)
+	})
+}
+
+func logArchiveCmd() *cobra.Command {
+	archiveCmd := &cobra.Command{
+		Use:   "archive",
+		Short: "Query the local archive of a device's logs",
+		Long: "Every entry displayed by `log show` is saved to a local " +
+			"archive kept for each device, so that entries remain " +
+			"available after the device's logs wrap.  An entry is only " +
+			"saved once; entries are identified by their log, index, " +
+			"timestamp, and image hash, so entries from different " +
+			"firmware, and from before and after a reboot, are kept " +
+			"apart.  The archive is kept in the " +
+			nmutil.ToolInfo.LogArchiveDir + " directory in the user's " +
+			"home directory.",
+		Run: func(cmd *cobra.Command, args []string) {
+			cmd.HelpFunc()(cmd, args)
+		},
+	}
+
+	ex := nmutil.ToolInfo.ExeName +
+		" log archive query --since 2h --level ERROR -c myserial\n"
+	ex += nmutil.ToolInfo.ExeName +
+		" log archive query reboot_log --img 4ebcd0d5 -c myserial\n"
+
+	queryCmd := &cobra.Command{
+		Use:   "query [log-name] -c <conn_profile>",
+		Short: "Show archived log entries",
+		Long: "Show the archived entries of a log, or of every log if " +
+			"log-name is not specified.  The device is not contacted.\n\n" +
+			"Filters select entries by level, module, timestamp, index, " +
+			"and image hash; all bounds are inclusive.  Timestamps are " +
+			"given in microseconds, as an RFC 3339 time, or as a duration " +
+			"before the current time (e.g., 2h).",
+		Example: ex,
+		Run:     logArchiveQueryCmd,
+	}
+	addLogFilterFlags(queryCmd)
+	queryCmd.Flags().StringVar(&logArchiveImg, "img", "",
+		"only include entries from images whose hash starts with this hex "+
+			"string")
+	addLogLayoutsFlag(queryCmd)
+	archiveCmd.AddCommand(queryCmd)
+
+	return archiveCmd
+}
+
+// Verifies that a hex string filter is valid.
+func checkHexPrefix(s string) error {
+	for _, c := range strings.ToLower(s) {
+		if !strings.ContainsRune("0123456789abcdef", c) {
+			return util.FmtNewtError("invalid hex string: %s", s)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,402 @@
+/**
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package cli
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"mynewt.apache.org/newt/util"
+	"mynewt.apache.org/newtmgr/newtmgr/nmutil"
+	"mynewt.apache.org/newtmgr/nmxact/nmp"
+	"mynewt.apache.org/newtmgr/nmxact/xact"
+)
+
+var logExportFormat string
+var logExportOut string
+
+// Filter settings, as specified on the command line.
+var logFilterArgs struct {
+	level    string
+	modules  []string
+	since    string
+	until    string
+	minIndex string
+	maxIndex string
+}
+
+// Selects log entries by level, module, timestamp, and index.  All bounds are
+// inclusive.
+type logFilter struct {
+	minLevel int
+	modules  map[int]bool // nil matches every module.
+	minTs    int64
+	maxTs    int64
+	minIndex uint32
+	maxIndex uint32
+}
+
+func addLogFilterFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&logFilterArgs.level, "level", "",
+		"only include entries at or above this level (name or number)")
+	cmd.Flags().StringSliceVar(&logFilterArgs.modules, "module", nil,
+		"only include entries from these modules (names or numbers); "+
+			"may be repeated")
+	cmd.Flags().StringVar(&logFilterArgs.since, "since", "",
+		"only include entries with this timestamp or later")
+	cmd.Flags().StringVar(&logFilterArgs.until, "until", "",
+		"only include entries with this timestamp or earlier")
+	cmd.Flags().StringVar(&logFilterArgs.minIndex, "min-index", "",
+		"only include entries with this index or higher")
+	cmd.Flags().StringVar(&logFilterArgs.maxIndex, "max-index", "",
+		"only include entries with this index or lower")
+}
+
+// Parses a log timestamp filter.  Timestamps are given in microseconds, as an
+// RFC 3339 time, or as a duration that is subtracted from the current time
+// (e.g., "2h" for two hours ago).
+func parseLogTime(s string, now time.Time) (int64, error) {
+	if ts, err := strconv.ParseInt(s, 0, 64); err == nil {
+		return ts, nil
+	}
+
+	if d, err := time.ParseDuration(s); err == nil {
+		return now.Add(-d).UnixNano() / int64(time.Microsecond), nil
+	}
+
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t.UnixNano() / int64(time.Microsecond), nil
+	}
+
+	return 0, util.FmtNewtError("invalid time \"%s\"; expected microseconds, "+
+		"an RFC 3339 time, or a duration", s)
+}
+
+func parseLogIndex(s string) (uint32, error) {
+	u64, err := strconv.ParseUint(s, 0, 32)
+	if err != nil {
+		return 0, util.FmtNewtError("invalid index: %s", s)
+	}
+
+	return uint32(u64), nil
+}
+
+func newLogFilter() (*logFilter, error) {
+	f := &logFilter{
+		minTs:    math.MinInt64,
+		maxTs:    math.MaxInt64,
+		maxIndex: math.MaxUint32,
+	}
+
+	var err error
+	if logFilterArgs.level != "" {
+		f.minLevel, err = devLogNames.LevelFromString(logFilterArgs.level)
+		if err != nil {
+			return nil, util.ChildNewtError(err)
+		}
+	}
+
+	for _, s := range logFilterArgs.modules {
+		m, err := devLogNames.ModuleFromString(s)
+		if err != nil {
+			return nil, util.ChildNewtError(err)
+		}
+		if f.modules == nil {
+			f.modules = map[int]bool{}
+		}
+		f.modules[m] = true
+	}
+
+	now := time.Now()
+	if logFilterArgs.since != "" {
+		if f.minTs, err = parseLogTime(logFilterArgs.since, now); err != nil {
+			return nil, err
+		}
+	}
+	if logFilterArgs.until != "" {
+		if f.maxTs, err = parseLogTime(logFilterArgs.until, now); err != nil {
+			return nil, err
+		}
+	}
+
+	if logFilterArgs.minIndex != "" {
+		if f.minIndex, err = parseLogIndex(logFilterArgs.minIndex); err != nil {
+			return nil, err
+		}
+	}
+	if logFilterArgs.maxIndex != "" {
+		if f.maxIndex, err = parseLogIndex(logFilterArgs.maxIndex); err != nil {
+			return nil, err
+		}
+	}
+
+	return f, nil
+}
+
+func (f *logFilter) match(entry nmp.LogEntry) bool {
+	if int(entry.Level) < f.minLevel {
+		return false
+	}
+	if f.modules != nil && !f.modules[int(entry.Module)] {
+		return false
+	}
+	if entry.Timestamp < f.minTs || entry.Timestamp > f.maxTs {
+		return false
+	}
+	if entry.Index < f.minIndex || entry.Index > f.maxIndex {
+		return false
+	}
+
+	return true
+}
+
+// Writes log entries to a file in one of the export formats.
+type logEntryWriter interface {
+	Write(log string, entry nmp.LogEntry) error
+	Flush() error
+}
+
+type jsonlLogWriter struct {
+	enc *json.Encoder
+}
+
+func newJsonlLogWriter(w io.Writer) *jsonlLogWriter {
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+
+	return &jsonlLogWriter{enc: enc}
+}
+
+func (lw *jsonlLogWriter) Write(log string, entry nmp.LogEntry) error {
+	return lw.enc.Encode(logRecordOutput{
+		Log:            log,
+		logEntryOutput: newLogEntryOutput(entry),
+	})
+}
+
+func (lw *jsonlLogWriter) Flush() error {
+	return nil
+}
+
+var logCsvHeader = []string{
+	"log", "index", "timestamp", "module", "module_name", "level",
+	"level_name", "type", "img_hash", "msg",
+}
+
+// Writes one row per entry.  CBOR messages are written as JSON.
+type csvLogWriter struct {
+	w *csv.Writer
+}
+
+func newCsvLogWriter(w io.Writer) (*csvLogWriter, error) {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(logCsvHeader); err != nil {
+		return nil, err
+	}
+
+	return &csvLogWriter{w: cw}, nil
+}
+
+func (lw *csvLogWriter) Write(log string, entry nmp.LogEntry) error {
+	eo := newLogEntryOutput(entry)
+
+	msg, ok := eo.Msg.(string)
+	if !ok {
+		b, err := json.Marshal(eo.Msg)
+		if err != nil {
+			return err
+		}
+		msg = string(b)
+	}
+
+	return lw.w.Write([]string{
+		log,
+		strconv.FormatUint(uint64(eo.Index), 10),
+		strconv.FormatInt(eo.Timestamp, 10),
+		strconv.Itoa(int(eo.Module)),
+		eo.ModuleName,
+		strconv.Itoa(int(eo.Level)),
+		eo.LevelName,
+		eo.Type,
+		eo.ImgHash,
+		msg,
+	})
+}
+
+func (lw *csvLogWriter) Flush() error {
+	lw.w.Flush()
+	return lw.w.Error()
+}
+
+var logEntryWriterCtors = map[string]func(io.Writer) (logEntryWriter, error){
+	RECORD_FORMAT_JSONL: func(w io.Writer) (logEntryWriter, error) {
+		return newJsonlLogWriter(w), nil
+	},
+	RECORD_FORMAT_CSV: func(w io.Writer) (logEntryWriter, error) {
+		return newCsvLogWriter(w)
+	},
+}
+
+type logExportOutput struct {
+	File    string `json:"file"`
+	Entries int    `json:"entries"`
+}
+
+func logExportCmd(cmd *cobra.Command, args []string) {
+	if len(args) > 1 {
+		nmUsage(cmd, nil)
+	}
+
+	newWriter := logEntryWriterCtors[logExportFormat]
+	if newWriter == nil {
+		nmUsage(cmd, util.FmtNewtError(
+			"invalid format \"%s\"; expected jsonl or csv", logExportFormat))
+	}
+
+	if err := loadLogLayouts(); err != nil {
+		nmUsage(nil, err)
+	}
+
+	s, err := GetSesn()
+	if err != nil {
+		nmUsage(nil, err)
+	}
+
+	// Module and level filters may use the device's own names.
+	loadLogNames(s)
+
+	filter, err := newLogFilter()
+	if err != nil {
+		nmUsage(cmd, err)
+	}
+
+	var names []string
+	if len(args) > 0 {
+		names = args
+	} else {
+		c := xact.NewLogListCmd()
+		c.SetTxOptions(nmutil.TxOptions())
+
+		res, err := c.Run(s)
+		if err != nil {
+			nmUsage(nil, util.ChildNewtError(err))
+		}
+		names = res.(*xact.LogListResult).Rsp.List
+		sort.Strings(names)
+	}
+
+	var w io.Writer = os.Stdout
+	if logExportOut != "" {
+		file, err := os.Create(logExportOut)
+		if err != nil {
+			nmUsage(nil, util.ChildNewtError(err))
+		}
+		defer file.Close()
+		w = file
+	}
+
+	lw, err := newWriter(w)
+	if err != nil {
+		nmUsage(nil, util.ChildNewtError(err))
+	}
+
+	count := 0
+	var writeErr error
+	for _, name := range names {
+		c := xact.NewLogShowFullCmd()
+		c.SetTxOptions(nmutil.TxOptions())
+		c.Name = name
+		c.Index = filter.minIndex
+		c.ProgressCb = func(_ *xact.LogShowFullCmd, rsp *nmp.LogShowRsp) {
+			checkLogNames(s, rsp)
+			for _, log := range rsp.Logs {
+				for _, entry := range log.Entries {
+					if writeErr != nil || !filter.match(entry) {
+						continue
+					}
+
+					writeErr = lw.Write(log.Name, entry)
+					count++
+				}
+			}
+		}
+
+		if _, err := c.Run(s); err != nil {
+			nmUsage(nil, util.ChildNewtError(err))
+		}
+		if writeErr != nil {
+			nmUsage(nil, util.ChildNewtError(writeErr))
+		}
+	}
+
+	if err := lw.Flush(); err != nil {
+		nmUsage(nil, util.ChildNewtError(err))
+	}
+
+	if logExportOut != "" {
+		out := logExportOutput{File: logExportOut, Entries: count}
+		printResult(out, func() {
+			fmt.Printf("Exported %d entries to %s\n", count, logExportOut)
+		})
+	}
+}
+
+func logExportSubCmd() *cobra.Command {
+	ex := nmutil.ToolInfo.ExeName +
+		" log export --out logs.jsonl -c myserial\n"
+	ex += nmutil.ToolInfo.ExeName +
+		" log export reboot_log --format csv --out reboot.csv -c myserial\n"
+	ex += nmutil.ToolInfo.ExeName +
+		" log export --level WARN --module NEWTMGR,REBOOT --since 2h " +
+		"-c myserial\n"
+
+	exportCmd := &cobra.Command{
+		Use:   "export [log-name] -c <conn_profile>",
+		Short: "Export the logs on a device to a file",
+		Long: "Read all the entries of a log, or of every log if log-name " +
+			"is not specified, and write them as JSON Lines or CSV.  CBOR " +
+			"messages are decoded into JSON; binary messages are written " +
+			"in hex.\n\n" +
+			"Filters select entries by level, module, timestamp, and index; " +
+			"all bounds are inclusive.  Timestamps are given in " +
+			"microseconds, as an RFC 3339 time, or as a duration before " +
+			"the current time (e.g., 2h).",
+		Example: ex,
+		Run:     logExportCmd,
+	}
+
+	exportCmd.Flags().StringVar(&logExportFormat, "format",
+		RECORD_FORMAT_JSONL, "output file format: jsonl or csv")
+	exportCmd.Flags().StringVar(&logExportOut, "out", "",
+		"file to write; default is standard output")
+	addLogFilterFlags(exportCmd)
+	addLogLayoutsFlag(exportCmd)
+
+	return exportCmd
+}
@@ -0,0 +1,218 @@
+/**
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package cli
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"mynewt.apache.org/newtmgr/nmxact/nmp"
+)
+
+// Sets the log filter flags and the device's log names for a test.
+func setLogFilterArgs(t *testing.T, level string, modules []string,
+	since, until, minIndex, maxIndex string) {
+
+	prevArgs, prevNames := logFilterArgs, devLogNames
+	t.Cleanup(func() {
+		logFilterArgs, devLogNames = prevArgs, prevNames
+	})
+
+	logFilterArgs.level = level
+	logFilterArgs.modules = modules
+	logFilterArgs.since = since
+	logFilterArgs.until = until
+	logFilterArgs.minIndex = minIndex
+	logFilterArgs.maxIndex = maxIndex
+
+	devLogNames = nmp.NewLogNames(map[string]int{"APP": 64},
+		map[string]int{"TRACE": 7})
+}
+
+func TestParseLogTime(t *testing.T) {
+	now := time.Date(2020, 6, 2, 12, 0, 0, 0, time.UTC)
+	nowUs := now.UnixNano() / int64(time.Microsecond)
+
+	tests := []struct {
+		s    string
+		want int64
+		ok   bool
+	}{
+		{"1234", 1234, true},
+		{"0x10", 16, true},
+		{"2h", nowUs - 2*3600*1000000, true},
+		{"2020-06-02T11:00:00Z", nowUs - 3600*1000000, true},
+		{"yesterday", 0, false},
+	}
+
+	for _, tt := range tests {
+		got, err := parseLogTime(tt.s, now)
+		if (err == nil) != tt.ok || got != tt.want {
+			t.Errorf("%s: got %d, %v; want %d, ok=%v",
+				tt.s, got, err, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestLogFilter(t *testing.T) {
+	entry := nmp.LogEntry{
+		Index:     10,
+		Timestamp: 5000,
+		Module:    64,
+		Level:     nmp.LEVEL_WARN,
+	}
+
+	tests := []struct {
+		name     string
+		level    string
+		modules  []string
+		since    string
+		until    string
+		minIndex string
+		maxIndex string
+		want     bool
+	}{
+		{name: "no filter", want: true},
+		{name: "level equal", level: "warn", want: true},
+		{name: "level above", level: "ERROR", want: false},
+		{name: "device level", level: "trace", want: false},
+		{name: "device module", modules: []string{"app"}, want: true},
+		{name: "module number", modules: []string{"0", "64"}, want: true},
+		{name: "other module", modules: []string{"0"}, want: false},
+		{name: "since", since: "5000", want: true},
+		{name: "since after", since: "5001", want: false},
+		{name: "until", until: "5000", want: true},
+		{name: "until before", until: "4999", want: false},
+		{name: "index range", minIndex: "10", maxIndex: "10", want: true},
+		{name: "min index", minIndex: "11", want: false},
+		{name: "max index", maxIndex: "9", want: false},
+	}
+
+	for _, tt := range tests {
+		setLogFilterArgs(t, tt.level, tt.modules, tt.since, tt.until,
+			tt.minIndex, tt.maxIndex)
+
+		f, err := newLogFilter()
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if got := f.match(entry); got != tt.want {
+			t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+		}
+	}
+
+	bad := [][]string{
+		{"level", "LOUD"},
+		{"module", "nosuchmodule"},
+		{"since", "soon"},
+		{"index", "-1"},
+	}
+	for _, b := range bad {
+		switch b[0] {
+		case "level":
+			setLogFilterArgs(t, b[1], nil, "", "", "", "")
+		case "module":
+			setLogFilterArgs(t, "", []string{b[1]}, "", "", "", "")
+		case "since":
+			setLogFilterArgs(t, "", nil, b[1], "", "", "")
+		case "index":
+			setLogFilterArgs(t, "", nil, "", "", b[1], "")
+		}
+		if _, err := newLogFilter(); err == nil {
+			t.Errorf("invalid %s %q accepted", b[0], b[1])
+		}
+	}
+}
+
+var testLogEntries = []nmp.LogEntry{
+	{
+		Index:     1,
+		Timestamp: 100,
+		Module:    64,
+		Level:     nmp.LEVEL_INFO,
+		Type:      nmp.LOG_ENTRY_TYPE_STRING,
+		ImgHash:   []byte{0xab, 0xcd},
+		Msg:       []byte(`said "hi", <ok>`),
+	},
+	{
+		Index:     2,
+		Timestamp: 200,
+		Module:    0,
+		Level:     nmp.LEVEL_ERROR,
+		Type:      nmp.LOG_ENTRY_TYPE_BINARY,
+		Msg:       []byte{0x01, 0xff},
+	},
+}
+
+func TestJsonlLogWriter(t *testing.T) {
+	setLogFilterArgs(t, "", nil, "", "", "", "")
+
+	var buf bytes.Buffer
+	w := newJsonlLogWriter(&buf)
+	for _, e := range testLogEntries {
+		if err := w.Write("app_log", e); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		t.Fatalf("flush: %v", err)
+	}
+
+	want := `{"log":"app_log","index":1,"timestamp":100,"module":64,` +
+		`"module_name":"APP","level":1,"level_name":"INFO","type":"str",` +
+		`"img_hash":"abcd","msg":"said \"hi\", <ok>"}` + "\n" +
+		`{"log":"app_log","index":2,"timestamp":200,"module":0,` +
+		`"module_name":"DEFAULT","level":3,"level_name":"ERROR",` +
+		`"type":"bin","msg":"01ff"}` + "\n"
+	if buf.String() != want {
+		t.Errorf("got:\n%s\nwant:\n%s", buf.String(), want)
+	}
+}
+
+func TestCsvLogWriter(t *testing.T) {
+	setLogFilterArgs(t, "", nil, "", "", "", "")
+
+	var buf bytes.Buffer
+	w, err := newCsvLogWriter(&buf)
+	if err != nil {
+		t.Fatalf("new writer: %v", err)
+	}
+	for _, e := range testLogEntries {
+		if err := w.Write("app_log", e); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		t.Fatalf("flush: %v", err)
+	}
+
+	want := strings.Join([]string{
+		"log,index,timestamp,module,module_name,level,level_name,type," +
+			"img_hash,msg",
+		`app_log,1,100,64,APP,1,INFO,str,abcd,"said ""hi"", <ok>"`,
+		"app_log,2,200,0,DEFAULT,3,ERROR,bin,,01ff",
+		"",
+	}, "\n")
+	if buf.String() != want {
+		t.Errorf("got:\n%s\nwant:\n%s", buf.String(), want)
+	}
+}
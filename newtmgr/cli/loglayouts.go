@@ -0,0 +1,108 @@
+/**
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+
+	"github.com/spf13/cobra"
+
+	"mynewt.apache.org/newt/util"
+	"mynewt.apache.org/newtmgr/newtmgr/config"
+	"mynewt.apache.org/newtmgr/newtmgr/nmutil"
+	"mynewt.apache.org/newtmgr/nmxact/nmp"
+)
+
+var logLayoutsFile string
+var logLayoutsLoaded bool
+
+// The fields decoded from a binary log entry.  They are output as an object
+// with the fields in the order of the layout.
+type logFieldsOutput []nmp.LogField
+
+func (fo logFieldsOutput) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+
+	buf.WriteByte('{')
+	for i, f := range fo {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+
+		name, err := json.Marshal(f.Name)
+		if err != nil {
+			return nil, err
+		}
+		val, err := json.Marshal(jsonValue(f.Value))
+		if err != nil {
+			return nil, err
+		}
+
+		buf.Write(name)
+		buf.WriteByte(':')
+		buf.Write(val)
+	}
+	buf.WriteByte('}')
+
+	return buf.Bytes(), nil
+}
+
+func addLogLayoutsFlag(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&logLayoutsFile, "layouts", "",
+		"file describing the layouts of binary log messages; default is ~/"+
+			nmutil.ToolInfo.LogLayoutsFilename)
+}
+
+// Registers decoders for the binary log layouts in the file specified with
+// --layouts, or in the default layouts file.
+func loadLogLayouts() error {
+	if logLayoutsLoaded {
+		return nil
+	}
+	logLayoutsLoaded = true
+
+	layouts, err := config.ReadLogLayouts(logLayoutsFile)
+	if err != nil {
+		return err
+	}
+
+	if err := nmp.RegisterLogLayouts(layouts); err != nil {
+		return util.ChildNewtError(err)
+	}
+
+	return nil
+}
+
+// Decodes a binary log entry and converts the fields to JSON text.  Returns
+// "" if no decoder applies to the entry.
+func logBinaryMsgText(entry nmp.LogEntry) (string, error) {
+	fields, err := nmp.DecodeLogEntry(entry)
+	if err != nil || fields == nil {
+		return "", err
+	}
+
+	msg, err := json.Marshal(logFieldsOutput(fields))
+	if err != nil {
+		return "", util.ChildNewtError(err)
+	}
+
+	return string(msg), nil
+}
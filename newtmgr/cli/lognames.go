@@ -0,0 +1,139 @@
+/**
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package cli
+
+import (
+	log "github.com/sirupsen/logrus"
+
+	"mynewt.apache.org/newtmgr/newtmgr/config"
+	"mynewt.apache.org/newtmgr/newtmgr/nmutil"
+	"mynewt.apache.org/newtmgr/nmxact/nmp"
+	"mynewt.apache.org/newtmgr/nmxact/sesn"
+	"mynewt.apache.org/newtmgr/nmxact/xact"
+)
+
+// The log module and level names of the device being managed.  Applications
+// register modules of their own, so the standard tables can't name them;
+// the device's names are read once and cached on disk.
+var devLogNames *nmp.LogNames
+
+// Set once the names have been read from the device during this run.
+var devLogNamesRead bool
+
+// Loads the device's log names from the cache, or reads them from the device
+// if they aren't cached.
+func loadLogNames(s sesn.Sesn) {
+	if !loadCachedLogNames() {
+		readLogNames(s)
+	}
+}
+
+// Loads the device's log names from the cache without contacting the device.
+// Returns false if they still need to be read from the device.
+func loadCachedLogNames() bool {
+	if devLogNames != nil || devLogNamesRead {
+		return true
+	}
+
+	// Without a device key there is nothing to look up; the names are read
+	// from the device instead.
+	key, err := DeviceKey()
+	if err != nil {
+		return false
+	}
+
+	cache, err := config.ReadLogNamesCache()
+	if err != nil {
+		log.Warnf("Failed to read log names cache: %s", err.Error())
+		return false
+	}
+
+	entry := cache.Find(key)
+	if entry == nil {
+		return false
+	}
+
+	devLogNames = nmp.NewLogNames(entry.Modules, entry.Levels)
+	return true
+}
+
+// Reads the log module and level names from the device and caches them.  A
+// device that doesn't support the list commands keeps the standard names.
+func readLogNames(s sesn.Sesn) {
+	devLogNamesRead = true
+
+	mc := xact.NewLogModuleListCmd()
+	mc.SetTxOptions(nmutil.TxOptions())
+
+	var modules map[string]int
+	if res, err := mc.Run(s); err != nil {
+		log.Debugf("Failed to read log module names: %s", err.Error())
+	} else {
+		modules = res.(*xact.LogModuleListResult).Rsp.Map
+	}
+
+	lc := xact.NewLogLevelListCmd()
+	lc.SetTxOptions(nmutil.TxOptions())
+
+	var levels map[string]int
+	if res, err := lc.Run(s); err != nil {
+		log.Debugf("Failed to read log level names: %s", err.Error())
+	} else {
+		levels = res.(*xact.LogLevelListResult).Rsp.Map
+	}
+
+	if modules == nil && levels == nil {
+		return
+	}
+	devLogNames = nmp.NewLogNames(modules, levels)
+
+	key, err := DeviceKey()
+	if err != nil {
+		return
+	}
+
+	cache, err := config.ReadLogNamesCache()
+	if err == nil {
+		err = cache.Update(key, modules, levels)
+	}
+	if err != nil {
+		log.Warnf("Failed to update log names cache: %s", err.Error())
+	}
+}
+
+// Re-reads the device's log names if a response contains a module or level
+// that the cached names don't cover; the device may be running new firmware.
+// This happens at most once per run.
+func checkLogNames(s sesn.Sesn, rsp *nmp.LogShowRsp) {
+	if devLogNamesRead {
+		return
+	}
+
+	for _, l := range rsp.Logs {
+		for _, entry := range l.Entries {
+			if !devLogNames.HasModule(int(entry.Module)) ||
+				!devLogNames.HasLevel(int(entry.Level)) {
+
+				readLogNames(s)
+				return
+			}
+		}
+	}
+}
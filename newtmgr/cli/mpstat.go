@@ -30,6 +30,18 @@ import (
 	"mynewt.apache.org/newtmgr/nmxact/xact"
 )
 
+type mempoolStatOutput struct {
+	Mempools []mempoolStatEntry `json:"mempools"`
+}
+
+type mempoolStatEntry struct {
+	Name   string `json:"name"`
+	Blksiz int    `json:"blksiz"`
+	Nblks  int    `json:"nblks"`
+	Nfree  int    `json:"nfree"`
+	Min    int    `json:"min"`
+}
+
 func mempoolStatRunCmd(cmd *cobra.Command, args []string) {
 	s, err := GetSesn()
 	if err != nil {
@@ -45,27 +57,36 @@ func mempoolStatRunCmd(cmd *cobra.Command, args []string) {
 	}
 
 	sres := res.(*xact.MempoolStatResult)
-	if sres.Rsp.Rc != 0 {
-		fmt.Printf("Error: %d\n", sres.Rsp.Rc)
-		return
-	}
-
 	names := make([]string, 0, len(sres.Rsp.Mpools))
 	for k, _ := range sres.Rsp.Mpools {
 		names = append(names, k)
 	}
 	sort.Strings(names)
 
-	fmt.Printf("%32s %5s %4s %4s %4s\n", "name", "blksz", "cnt", "free", "min")
+	out := mempoolStatOutput{Mempools: []mempoolStatEntry{}}
 	for _, n := range names {
 		mp := sres.Rsp.Mpools[n]
-		fmt.Printf("%32s %5d %4d %4d %4d\n",
-			n,
-			mp["blksiz"],
-			mp["nblks"],
-			mp["nfree"],
-			mp["min"])
+		out.Mempools = append(out.Mempools, mempoolStatEntry{
+			Name:   n,
+			Blksiz: mp["blksiz"],
+			Nblks:  mp["nblks"],
+			Nfree:  mp["nfree"],
+			Min:    mp["min"],
+		})
 	}
+
+	printResult(out, func() {
+		fmt.Printf("%32s %5s %4s %4s %4s\n",
+			"name", "blksz", "cnt", "free", "min")
+		for _, mp := range out.Mempools {
+			fmt.Printf("%32s %5d %4d %4d %4d\n",
+				mp.Name,
+				mp.Blksiz,
+				mp.Nblks,
+				mp.Nfree,
+				mp.Min)
+		}
+	})
 }
 
 func mempoolStatCmd() *cobra.Command {
@@ -0,0 +1,299 @@
+/**
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package cli
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strconv"
+
+	"gopkg.in/yaml.v2"
+
+	"mynewt.apache.org/newt/util"
+	"mynewt.apache.org/newtmgr/nmxact/nmp"
+)
+
+// Output formats accepted by the --output flag.
+const (
+	OUTPUT_TEXT = "text"
+	OUTPUT_JSON = "json"
+	OUTPUT_YAML = "yaml"
+)
+
+// Formats of the files that commands such as `log export` write records to.
+const (
+	RECORD_FORMAT_JSONL = "jsonl"
+	RECORD_FORMAT_CSV   = "csv"
+)
+
+var outputFormat string
+
+func checkOutputFormat() error {
+	switch outputFormat {
+	case OUTPUT_TEXT, OUTPUT_JSON, OUTPUT_YAML:
+		return nil
+
+	default:
+		return util.FmtNewtError(
+			"invalid output format \"%s\"; expected text, json, or yaml",
+			outputFormat)
+	}
+}
+
+// Indicates whether results are printed as JSON or YAML rather than as
+// human-readable text.  In a structured format, stdout carries nothing but
+// the result, so progress messages are suppressed.
+func structuredOutput() bool {
+	return outputFormat == OUTPUT_JSON || outputFormat == OUTPUT_YAML
+}
+
+// The result of a command that doesn't return any data.  Success is
+// indicated by the exit status alone.
+type doneOutput struct{}
+
+// Describes a failed command.
+type errorOutput struct {
+	Error errorOutputInfo `json:"error"`
+}
+
+type errorOutputInfo struct {
+	Message    string          `json:"message"`
+	ExitStatus int             `json:"exit_status"`
+	Device     *nmpErrorOutput `json:"device,omitempty"`
+}
+
+// Describes an error response from the device.
+type nmpErrorOutput struct {
+	Rc        int    `json:"rc"`
+	RcName    string `json:"rc_name"`
+	Op        uint8  `json:"op"`
+	Group     uint16 `json:"group"`
+	GroupName string `json:"group_name"`
+	Id        uint8  `json:"id"`
+}
+
+func newErrorOutput(text string, err error) *errorOutput {
+	eo := &errorOutput{
+		Error: errorOutputInfo{
+			Message:    text,
+			ExitStatus: exitStatus(err),
+		},
+	}
+
+	if nmpErr := nmpErrorOf(err); nmpErr != nil {
+		eo.Error.Device = &nmpErrorOutput{
+			Rc:        nmpErr.Rc,
+			RcName:    nmp.RcToString(nmpErr.Rc),
+			Op:        nmpErr.Op,
+			Group:     nmpErr.Group,
+			GroupName: nmp.GroupToString(nmpErr.Group),
+			Id:        nmpErr.Id,
+		}
+	}
+
+	return eo
+}
+
+// Converts a JSON document to the equivalent YAML value.  Objects become
+// MapSlices so that keys stay in the order the JSON encoder wrote them.
+func jsonToYaml(dec *json.Decoder) (interface{}, error) {
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+
+	switch t := tok.(type) {
+	case json.Delim:
+		if t == '{' {
+			ms := yaml.MapSlice{}
+			for dec.More() {
+				key, err := dec.Token()
+				if err != nil {
+					return nil, err
+				}
+				val, err := jsonToYaml(dec)
+				if err != nil {
+					return nil, err
+				}
+				ms = append(ms, yaml.MapItem{Key: key, Value: val})
+			}
+			_, err := dec.Token()
+			return ms, err
+		} else {
+			arr := []interface{}{}
+			for dec.More() {
+				val, err := jsonToYaml(dec)
+				if err != nil {
+					return nil, err
+				}
+				arr = append(arr, val)
+			}
+			_, err := dec.Token()
+			return arr, err
+		}
+
+	case json.Number:
+		if i, err := t.Int64(); err == nil {
+			return i, nil
+		}
+		if u, err := strconv.ParseUint(t.String(), 10, 64); err == nil {
+			return u, nil
+		}
+		return t.Float64()
+
+	default:
+		return t, nil
+	}
+}
+
+// Encodes a value in the selected structured format.  YAML is generated from
+// the JSON encoding, so output types only need json tags and both formats
+// always have the same shape.  If record is set, JSON is encoded on a single
+// line and YAML is preceded by a document separator, so that a sequence of
+// values can be streamed.
+func encodeOutput(v interface{}, record bool) ([]byte, error) {
+	var js bytes.Buffer
+	enc := json.NewEncoder(&js)
+	enc.SetEscapeHTML(false)
+	if !record {
+		enc.SetIndent("", "    ")
+	}
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+
+	if outputFormat != OUTPUT_YAML {
+		return js.Bytes(), nil
+	}
+
+	dec := json.NewDecoder(&js)
+	dec.UseNumber()
+	y, err := jsonToYaml(dec)
+	if err != nil {
+		return nil, err
+	}
+
+	b, err := yaml.Marshal(y)
+	if err != nil {
+		return nil, err
+	}
+
+	if record {
+		b = append([]byte("---\n"), b...)
+	}
+	return b, nil
+}
+
+func writeOutput(w io.Writer, v interface{}) error {
+	b, err := encodeOutput(v, false)
+	if err != nil {
+		return err
+	}
+
+	_, err = w.Write(b)
+	return err
+}
+
+// Prints a command's result.  In text mode, textFn prints it in
+// human-readable form; otherwise v is printed in the selected format.
+func printResult(v interface{}, textFn func()) {
+	if !structuredOutput() {
+		textFn()
+		return
+	}
+
+	if err := writeOutput(os.Stdout, v); err != nil {
+		nmUsage(nil, util.ChildNewtError(err))
+	}
+}
+
+// Prints one of a stream of results, such as the entries of a followed log.
+// JSON records are printed one per line (JSON Lines); YAML records are
+// separate documents.
+func printRecord(v interface{}) {
+	b, err := encodeOutput(v, true)
+	if err != nil {
+		nmUsage(nil, util.ChildNewtError(err))
+	}
+
+	if _, err := os.Stdout.Write(b); err != nil {
+		nmUsage(nil, util.ChildNewtError(err))
+	}
+}
+
+// Prints an informational message in text mode only.
+func printInfo(format string, args ...interface{}) {
+	if !structuredOutput() {
+		fmt.Printf(format, args...)
+	}
+}
+
+// Converts a decoded CBOR value into one that can be encoded as JSON.  Map
+// keys become strings and byte strings become hex strings.  JSON has no
+// NaN or infinity, so those become the strings "NaN", "+Inf", and "-Inf".
+func jsonValue(itf interface{}) interface{} {
+	switch v := itf.(type) {
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for k, e := range v {
+			m[fmt.Sprintf("%v", k)] = jsonValue(e)
+		}
+		return m
+
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for k, e := range v {
+			m[k] = jsonValue(e)
+		}
+		return m
+
+	case []interface{}:
+		a := make([]interface{}, len(v))
+		for i, e := range v {
+			a[i] = jsonValue(e)
+		}
+		return a
+
+	case []byte:
+		return hex.EncodeToString(v)
+
+	case float32:
+		return jsonFloat(float64(v), v)
+
+	case float64:
+		return jsonFloat(v, v)
+
+	default:
+		return v
+	}
+}
+
+func jsonFloat(f float64, itf interface{}) interface{} {
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return strconv.FormatFloat(f, 'g', -1, 64)
+	}
+
+	return itf
+}
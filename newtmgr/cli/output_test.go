@@ -0,0 +1,321 @@
+/**
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package cli
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"math"
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/spf13/cobra"
+
+	"mynewt.apache.org/newt/util"
+	"mynewt.apache.org/newtmgr/nmxact/nmp"
+)
+
+func setOutputFormat(t *testing.T, format string) {
+	prev := outputFormat
+	outputFormat = format
+	t.Cleanup(func() { outputFormat = prev })
+}
+
+// Runs fn and returns what it wrote to stdout.
+func captureStdout(t *testing.T, fn func()) string {
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stdout := os.Stdout
+	os.Stdout = w
+	defer func() { os.Stdout = stdout }()
+
+	fn()
+	w.Close()
+
+	b, err := ioutil.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
+
+type testOutputInner struct {
+	B string `json:"b"`
+	A bool   `json:"a"`
+}
+
+type testOutput struct {
+	Zeta   int             `json:"zeta"`
+	Alpha  string          `json:"alpha"`
+	Big    uint64          `json:"big"`
+	Neg    int64           `json:"neg"`
+	Frac   float64         `json:"frac"`
+	Whole  float64         `json:"whole"`
+	Inner  testOutputInner `json:"inner"`
+	List   []int           `json:"list"`
+	Empty  []string        `json:"empty"`
+	Absent *int            `json:"absent"`
+}
+
+func TestEncodeOutput(t *testing.T) {
+	v := testOutput{
+		Zeta:  3,
+		Alpha: "<a&b>",
+		Big:   18446744073709551615,
+		Neg:   -9007199254740993,
+		Frac:  1.25,
+		Whole: 2,
+		Inner: testOutputInner{B: "x", A: true},
+		List:  []int{1, 2},
+		Empty: []string{},
+	}
+
+	tests := []struct {
+		format string
+		record bool
+		want   string
+	}{
+		{OUTPUT_JSON, false, `{
+    "zeta": 3,
+    "alpha": "<a&b>",
+    "big": 18446744073709551615,
+    "neg": -9007199254740993,
+    "frac": 1.25,
+    "whole": 2,
+    "inner": {
+        "b": "x",
+        "a": true
+    },
+    "list": [
+        1,
+        2
+    ],
+    "empty": [],
+    "absent": null
+}
+`},
+		{OUTPUT_JSON, true, `{"zeta":3,"alpha":"<a&b>",` +
+			`"big":18446744073709551615,"neg":-9007199254740993,` +
+			`"frac":1.25,"whole":2,"inner":{"b":"x","a":true},` +
+			`"list":[1,2],"empty":[],"absent":null}
+`},
+		// Keys stay in struct order, and large integers keep their
+		// precision.
+		{OUTPUT_YAML, false, `zeta: 3
+alpha: <a&b>
+big: 18446744073709551615
+neg: -9007199254740993
+frac: 1.25
+whole: 2
+inner:
+  b: x
+  a: true
+list:
+- 1
+- 2
+empty: []
+absent: null
+`},
+		{OUTPUT_YAML, true, `---
+zeta: 3
+alpha: <a&b>
+big: 18446744073709551615
+neg: -9007199254740993
+frac: 1.25
+whole: 2
+inner:
+  b: x
+  a: true
+list:
+- 1
+- 2
+empty: []
+absent: null
+`},
+	}
+
+	for _, tt := range tests {
+		setOutputFormat(t, tt.format)
+		b, err := encodeOutput(v, tt.record)
+		if err != nil {
+			t.Errorf("%s record=%v: %v", tt.format, tt.record, err)
+			continue
+		}
+		if string(b) != tt.want {
+			t.Errorf("%s record=%v: got\n%s\nwant\n%s", tt.format,
+				tt.record, b, tt.want)
+		}
+	}
+}
+
+func TestPrintRecord(t *testing.T) {
+	recs := []testOutputInner{{B: "1", A: true}, {B: "2"}}
+
+	setOutputFormat(t, OUTPUT_JSON)
+	got := captureStdout(t, func() {
+		for _, r := range recs {
+			printRecord(r)
+		}
+	})
+	want := "{\"b\":\"1\",\"a\":true}\n{\"b\":\"2\",\"a\":false}\n"
+	if got != want {
+		t.Errorf("JSON records: got %q, want %q", got, want)
+	}
+
+	setOutputFormat(t, OUTPUT_YAML)
+	got = captureStdout(t, func() {
+		for _, r := range recs {
+			printRecord(r)
+		}
+	})
+	want = "---\nb: \"1\"\na: true\n---\nb: \"2\"\na: false\n"
+	if got != want {
+		t.Errorf("YAML records: got %q, want %q", got, want)
+	}
+
+	// Text mode leaves the output to the command.
+	setOutputFormat(t, OUTPUT_TEXT)
+	got = captureStdout(t, func() {
+		printResult(recs[0], func() { os.Stdout.WriteString("text\n") })
+	})
+	if got != "text\n" {
+		t.Errorf("text result: got %q", got)
+	}
+}
+
+func TestJsonValue(t *testing.T) {
+	got := jsonValue(map[interface{}]interface{}{
+		1:   []byte{0xab, 0x01},
+		"k": []interface{}{[]byte{}, "s", 2},
+		"m": map[string]interface{}{"h": []byte{0xff}},
+		"f": []interface{}{math.NaN(), float32(math.Inf(1)), 0.5},
+	})
+	want := map[string]interface{}{
+		"1": "ab01",
+		"k": []interface{}{"", "s", 2},
+		"m": map[string]interface{}{"h": "ff"},
+		"f": []interface{}{"NaN", "+Inf", 0.5},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %#v, want %#v", got, want)
+	}
+}
+
+func TestLogFieldsOutputNonFinite(t *testing.T) {
+	fo := logFieldsOutput{
+		{Name: "nan", Value: float32(math.NaN())},
+		{Name: "pos", Value: math.Inf(1)},
+		{Name: "neg", Value: float32(math.Inf(-1))},
+		{Name: "f32", Value: float32(1.5)},
+		{Name: "f64", Value: -0.25},
+	}
+
+	b, err := json.Marshal(fo)
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+
+	want := `{"nan":"NaN","pos":"+Inf","neg":"-Inf","f32":1.5,"f64":-0.25}`
+	if string(b) != want {
+		t.Errorf("got %s, want %s", b, want)
+	}
+}
+
+func TestPrintErrorOutput(t *testing.T) {
+	nmpErr := &nmp.NmpError{
+		Op:    nmp.NMP_OP_READ_RSP,
+		Group: nmp.NMP_GROUP_CONFIG,
+		Id:    0,
+		Rc:    nmp.NMP_ERR_ENOENT,
+	}
+
+	tests := []struct {
+		name string
+		cmd  *cobra.Command
+		err  error
+		want string
+	}{
+		{"device error", nil, &util.NewtError{Text: "read failed",
+			Parent: nmpErr}, `{
+    "error": {
+        "message": "read failed",
+        "exit_status": 15,
+        "device": {
+            "rc": 5,
+            "rc_name": "ENOENT",
+            "op": 1,
+            "group": 3,
+            "group_name": "config",
+            "id": 0
+        }
+    }
+}
+`},
+		{"local error", nil, util.NewNewtError("no such file"), `{
+    "error": {
+        "message": "no such file",
+        "exit_status": 1
+    }
+}
+`},
+		{"usage", &cobra.Command{Use: "thing <arg>"}, nil, `{
+    "error": {
+        "message": "usage: thing <arg>",
+        "exit_status": 1
+    }
+}
+`},
+	}
+
+	setOutputFormat(t, OUTPUT_JSON)
+	for _, tt := range tests {
+		got := captureStdout(t, func() { printErrorOutput(tt.cmd, tt.err) })
+		if got != tt.want {
+			t.Errorf("%s: got\n%s\nwant\n%s", tt.name, got, tt.want)
+		}
+		if !json.Valid([]byte(got)) {
+			t.Errorf("%s: invalid JSON", tt.name)
+		}
+	}
+
+	setOutputFormat(t, OUTPUT_YAML)
+	got := captureStdout(t, func() {
+		printErrorOutput(nil, &util.NewtError{Text: "read failed",
+			Parent: nmpErr})
+	})
+	want := `error:
+  message: read failed
+  exit_status: 15
+  device:
+    rc: 5
+    rc_name: ENOENT
+    op: 1
+    group: 3
+    group_name: config
+    id: 0
+`
+	if got != want {
+		t.Errorf("YAML: got\n%s\nwant\n%s", got, want)
+	}
+}
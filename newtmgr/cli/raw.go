@@ -0,0 +1,146 @@
+/**
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package cli
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"mynewt.apache.org/newt/util"
+	"mynewt.apache.org/newtmgr/newtmgr/nmutil"
+	"mynewt.apache.org/newtmgr/nmxact/nmp"
+	"mynewt.apache.org/newtmgr/nmxact/xact"
+)
+
+func parseRawOp(s string) (uint8, error) {
+	switch strings.ToLower(s) {
+	case "read", "r", "0":
+		return nmp.NMP_OP_READ, nil
+	case "write", "w", "2":
+		return nmp.NMP_OP_WRITE, nil
+	default:
+		return 0, util.FmtNewtError(
+			"invalid op \"%s\"; expected read or write", s)
+	}
+}
+
+func rawRunCmd(cmd *cobra.Command, args []string) {
+	if len(args) < 3 || len(args) > 4 {
+		nmUsage(cmd, nil)
+	}
+
+	op, err := parseRawOp(args[0])
+	if err != nil {
+		nmUsage(cmd, err)
+	}
+
+	group, err := parseNmpGroup(args[1])
+	if err != nil {
+		nmUsage(cmd, util.FmtNewtError("invalid group: %s", args[1]))
+	}
+
+	id, err := strconv.ParseUint(args[2], 0, 8)
+	if err != nil {
+		nmUsage(cmd, util.FmtNewtError("invalid id: %s", args[2]))
+	}
+
+	var body []byte
+	if len(args) == 4 {
+		if args[3] == "-" {
+			body, err = ioutil.ReadAll(os.Stdin)
+			if err != nil {
+				nmUsage(nil, util.ChildNewtError(err))
+			}
+		} else {
+			body = []byte(args[3])
+		}
+	}
+
+	// Catch malformed input before connecting to the device.
+	if _, err := xact.ParseJsonBody(body); err != nil {
+		nmUsage(cmd, util.ChildNewtError(err))
+	}
+
+	s, err := GetSesn()
+	if err != nil {
+		nmUsage(nil, err)
+	}
+
+	c := xact.NewRawCmd()
+	c.SetTxOptions(nmutil.TxOptions())
+	c.Op = op
+	c.Group = group
+	c.Id = uint8(id)
+	c.Body = body
+
+	// An error response may still carry useful information.
+	res, err := c.Run(s)
+	if err != nil && !isNmpError(err) {
+		nmUsage(nil, util.ChildNewtError(err))
+	}
+
+	// Byte strings are shown as hex, as in other commands' output.
+	rres := res.(*xact.RawResult)
+	printResult(jsonValue(rres.Rsp.Body), func() {
+		js, jerr := rres.Json()
+		if jerr != nil {
+			nmUsage(nil, util.ChildNewtError(jerr))
+		}
+		fmt.Println(string(js))
+	})
+
+	if err != nil {
+		// The response body already reports the status.
+		if structuredOutput() {
+			NmExit(exitStatus(err))
+		}
+		nmUsage(nil, err)
+	}
+}
+
+func rawCmd() *cobra.Command {
+	ex := "  " + nmutil.ToolInfo.ExeName +
+		" -c olimex raw write default 0 '{\"d\": \"hello\"}'\n" +
+		"  " + nmutil.ToolInfo.ExeName +
+		" -c olimex raw read 64 1\n" +
+		"  echo '{\"key\": [1, 2, 3]}' | " + nmutil.ToolInfo.ExeName +
+		" -c olimex raw write 64 2 -\n"
+
+	rawCmd := &cobra.Command{
+		Use:   "raw <read|write> <group> <id> [json-body] -c <conn_profile>",
+		Short: "Send a request with an arbitrary group and ID to a device",
+		Long: "Send a request with an arbitrary group and ID to a device " +
+			"and display the response as JSON.\n\n" +
+			"The group is a number or one of the standard group names " +
+			"(default, image, stat, config, log, crash, run, fs, shell).  " +
+			"The body is a JSON object that gets encoded as CBOR; whole " +
+			"numbers are sent as integers.  A body of \"-\" is read from " +
+			"standard input.  Without a body, an empty map is sent.",
+		Example: ex,
+		Run:     rawRunCmd,
+	}
+
+	return rawCmd
+}
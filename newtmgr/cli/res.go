@@ -110,13 +110,16 @@ func extractResKv(params []string) (map[string]interface{}, error) {
 	return m, nil
 }
 
-func printCode(code coap.COAPCode) string {
-	var s string
+// Formats a CoAP code in dotted notation, e.g., "2.05".
+func codeStr(code coap.COAPCode) string {
 	class := (code & 0xE0) >> 5
 	d1 := (code & 0x18) >> 3
 	d2 := code & 0x07
-	s += fmt.Sprintf("CoAP Response Code: %d.%d%d %s\n", class, d1, d2, code)
-	return s
+	return fmt.Sprintf("%d.%d%d", class, d1, d2)
+}
+
+func printCode(code coap.COAPCode) string {
+	return fmt.Sprintf("CoAP Response Code: %s %s\n", codeStr(code), code)
 }
 
 func printDetails(msg coap.Message) string {
@@ -182,6 +185,35 @@ func resResponseStr(path string, cbor []byte) string {
 	return s
 }
 
+// Describes a CoAP response.  The payload is decoded from CBOR; byte strings
+// within it are shown in hex.
+type resOutput struct {
+	Path     string      `json:"path"`
+	Code     string      `json:"code"`
+	CodeName string      `json:"code_name"`
+	Token    string      `json:"token,omitempty"`
+	Payload  interface{} `json:"payload"`
+}
+
+func newResOutput(path string, msg coap.Message) (*resOutput, error) {
+	out := &resOutput{
+		Path:     path,
+		Code:     codeStr(msg.Code()),
+		CodeName: msg.Code().String(),
+		Token:    hex.EncodeToString(msg.Token()),
+	}
+
+	if len(msg.Payload()) > 0 {
+		val, err := nmxutil.DecodeCbor(msg.Payload())
+		if err != nil {
+			return nil, util.ChildNewtError(err)
+		}
+		out.Payload = jsonValue(val)
+	}
+
+	return out, nil
+}
+
 func parsePayloadMap(args []string) (map[string]interface{}, error) {
 	if len(args) == 0 {
 		return nil, nil
@@ -391,6 +423,15 @@ func runResCmd(cmd *cobra.Command, args []string) {
 	}
 
 	sres := res.(*xact.ResResult)
+	if structuredOutput() {
+		out, err := newResOutput(path, sres.Rsp)
+		if err != nil {
+			nmUsage(nil, err)
+		}
+		printResult(out, nil)
+		return
+	}
+
 	if sres.Status() != 0 {
 		fmt.Printf("Error: %s (%d)\n", sres.Rsp.Code(), sres.Rsp.Code())
 		return
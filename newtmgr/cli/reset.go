@@ -42,7 +42,9 @@ func resetRunCmd(cmd *cobra.Command, args []string) {
 		nmUsage(nil, util.ChildNewtError(err))
 	}
 
-	fmt.Printf("Done\n")
+	printResult(doneOutput{}, func() {
+		fmt.Printf("Done\n")
+	})
 }
 
 func resetCmd() *cobra.Command {
@@ -30,6 +30,10 @@ import (
 	"mynewt.apache.org/newtmgr/nmxact/xact"
 )
 
+type runListOutput struct {
+	Tests []string `json:"tests"`
+}
+
 func runTestCmd(cmd *cobra.Command, args []string) {
 	s, err := GetSesn()
 	if err != nil {
@@ -48,18 +52,13 @@ func runTestCmd(cmd *cobra.Command, args []string) {
 		}
 	}
 
-	res, err := c.Run(s)
-	if err != nil {
+	if _, err := c.Run(s); err != nil {
 		nmUsage(nil, util.ChildNewtError(err))
 	}
 
-	sres := res.(*xact.RunTestResult)
-	if sres.Rsp.Rc != 0 {
-		fmt.Printf("Error: %d\n", sres.Rsp.Rc)
-		return
-	}
-
-	fmt.Printf("Done\n")
+	printResult(doneOutput{}, func() {
+		fmt.Printf("Done\n")
+	})
 }
 
 func runListCmd(cmd *cobra.Command, args []string) {
@@ -77,16 +76,18 @@ func runListCmd(cmd *cobra.Command, args []string) {
 	}
 
 	sres := res.(*xact.RunListResult)
-	if sres.Rsp.Rc != 0 {
-		fmt.Printf("Error: %d\n", sres.Rsp.Rc)
-		return
-	}
-
 	sort.Strings(sres.Rsp.List)
-	fmt.Printf("available tests:\n")
-	for _, n := range sres.Rsp.List {
-		fmt.Printf("    %s\n", n)
+
+	out := runListOutput{Tests: sres.Rsp.List}
+	if out.Tests == nil {
+		out.Tests = []string{}
 	}
+	printResult(out, func() {
+		fmt.Printf("available tests:\n")
+		for _, n := range sres.Rsp.List {
+			fmt.Printf("    %s\n", n)
+		}
+	})
 }
 
 func runCmd() *cobra.Command {
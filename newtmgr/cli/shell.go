@@ -28,6 +28,11 @@ import (
 	"mynewt.apache.org/newtmgr/nmxact/xact"
 )
 
+type shellExecOutput struct {
+	Rc     int    `json:"rc"`
+	Output string `json:"output"`
+}
+
 func shellExecCmd(cmd *cobra.Command, args []string) {
 	s, err := GetSesn()
 	if err != nil {
@@ -43,18 +48,27 @@ func shellExecCmd(cmd *cobra.Command, args []string) {
 
 	c.Argv = args
 
+	// A nonzero status is the remote command's exit code; its output is
+	// still worth showing.
 	res, err := c.Run(s)
-	if err != nil {
+	if err != nil && !isNmpError(err) {
 		nmUsage(nil, util.ChildNewtError(err))
 	}
 
 	sres := res.(*xact.ShellExecResult)
-	fmt.Printf("status=%d\n", sres.Rsp.Rc)
-	if len(sres.Rsp.O) > 0 {
-		fmt.Printf("%s", sres.Rsp.O)
-		if sres.Rsp.O[len(sres.Rsp.O)-1] != '\n' {
-			fmt.Printf("\n")
+	out := shellExecOutput{Rc: sres.Rsp.Rc, Output: sres.Rsp.O}
+	printResult(out, func() {
+		fmt.Printf("status=%d\n", sres.Rsp.Rc)
+		if len(sres.Rsp.O) > 0 {
+			fmt.Printf("%s", sres.Rsp.O)
+			if sres.Rsp.O[len(sres.Rsp.O)-1] != '\n' {
+				fmt.Printf("\n")
+			}
 		}
+	})
+
+	if err != nil {
+		NmExit(exitStatus(err))
 	}
 }
 
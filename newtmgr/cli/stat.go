@@ -30,6 +30,15 @@ import (
 	"mynewt.apache.org/newtmgr/nmxact/xact"
 )
 
+type statListOutput struct {
+	Groups []string `json:"groups"`
+}
+
+type statReadOutput struct {
+	Name   string                 `json:"name"`
+	Fields map[string]interface{} `json:"fields"`
+}
+
 func statsListRunCmd(cmd *cobra.Command, args []string) {
 	s, err := GetSesn()
 	if err != nil {
@@ -45,22 +54,22 @@ func statsListRunCmd(cmd *cobra.Command, args []string) {
 	}
 
 	sres := res.(*xact.StatListResult)
-	if sres.Rsp.Rc != 0 {
-		fmt.Printf("Error: %d\n", sres.Rsp.Rc)
-	} else if len(sres.Rsp.List) == 0 {
-		fmt.Printf("stat groups: none\n")
-	} else {
-		groups := make([]string, len(sres.Rsp.List))
-		for i, g := range sres.Rsp.List {
-			groups[i] = g
-		}
-		sort.Strings(groups)
+	groups := make([]string, len(sres.Rsp.List))
+	for i, g := range sres.Rsp.List {
+		groups[i] = g
+	}
+	sort.Strings(groups)
 
-		fmt.Printf("stat groups:\n")
-		for _, g := range groups {
-			fmt.Printf("    %s\n", g)
+	printResult(statListOutput{Groups: groups}, func() {
+		if len(groups) == 0 {
+			fmt.Printf("stat groups: none\n")
+		} else {
+			fmt.Printf("stat groups:\n")
+			for _, g := range groups {
+				fmt.Printf("    %s\n", g)
+			}
 		}
-	}
+	})
 }
 
 func statsRunCmd(cmd *cobra.Command, args []string) {
@@ -83,9 +92,15 @@ func statsRunCmd(cmd *cobra.Command, args []string) {
 	}
 
 	sres := res.(*xact.StatReadResult)
-	if sres.Rsp.Rc != 0 {
-		fmt.Printf("Error: %d\n", sres.Rsp.Rc)
-	} else {
+	out := statReadOutput{
+		Name:   sres.Rsp.Name,
+		Fields: sres.Rsp.Fields,
+	}
+	if out.Fields == nil {
+		out.Fields = map[string]interface{}{}
+	}
+
+	printResult(out, func() {
 		fmt.Printf("stat group: %s\n", sres.Rsp.Name)
 		if len(sres.Rsp.Fields) == 0 {
 			fmt.Printf("    (empty)\n")
@@ -100,7 +115,7 @@ func statsRunCmd(cmd *cobra.Command, args []string) {
 				fmt.Printf("%10d %s\n", sres.Rsp.Fields[n], n)
 			}
 		}
-	}
+	})
 }
 
 func statsCmd() *cobra.Command {
@@ -119,6 +134,7 @@ func statsCmd() *cobra.Command {
 	}
 
 	statsCmd.AddCommand(ListCmd)
+	statsCmd.AddCommand(statWatchCmd())
 
 	return statsCmd
 }
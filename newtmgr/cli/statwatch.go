@@ -0,0 +1,568 @@
+/**
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package cli
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"mynewt.apache.org/newt/util"
+	"mynewt.apache.org/newtmgr/newtmgr/nmutil"
+	"mynewt.apache.org/newtmgr/nmxact/sesn"
+	"mynewt.apache.org/newtmgr/nmxact/xact"
+)
+
+var statWatchInterval time.Duration
+var statWatchCount int
+var statWatchFormat string
+var statWatchOut string
+var statWatchThresholds []string
+
+// A stat field's value at one point in a series.  Delta and rate are
+// relative to the previous sample, so they are absent from the first one.
+type statWatchRecord struct {
+	Time    string   `json:"time"`
+	Elapsed float64  `json:"elapsed"`
+	Group   string   `json:"group"`
+	Field   string   `json:"field"`
+	Value   int64    `json:"value"`
+	Delta   *int64   `json:"delta"`
+	Rate    *float64 `json:"rate"`
+}
+
+// Statistics that a threshold can apply to.
+const (
+	STAT_METRIC_VALUE = "value"
+	STAT_METRIC_DELTA = "delta"
+	STAT_METRIC_RATE  = "rate"
+)
+
+// A limit on a stat field, e.g., "rate(ble_ll.tx_fail) > 5".
+type statThreshold struct {
+	text   string
+	group  string
+	field  string
+	metric string
+	op     string
+	limit  float64
+}
+
+var statThresholdRe = regexp.MustCompile(
+	`^\s*(?:(value|delta|rate)\(\s*([^()\s]+)\s*\)|([^()\s<>=!]+))` +
+		`\s*(>=|<=|>|<|==|!=)\s*(\S+)\s*$`)
+
+func parseStatThreshold(s string) (*statThreshold, error) {
+	m := statThresholdRe.FindStringSubmatch(s)
+	if m == nil {
+		return nil, util.FmtNewtError("invalid threshold \"%s\"; expected "+
+			"[value|delta|rate](<group>.<field>) <op> <number>", s)
+	}
+
+	t := &statThreshold{
+		text:   s,
+		metric: m[1],
+		op:     m[4],
+	}
+
+	name := m[2]
+	if t.metric == "" {
+		t.metric = STAT_METRIC_VALUE
+		name = m[3]
+	}
+
+	dot := strings.LastIndex(name, ".")
+	if dot <= 0 || dot == len(name)-1 {
+		return nil, util.FmtNewtError("invalid threshold \"%s\"; the stat "+
+			"must be specified as <group>.<field>", s)
+	}
+	t.group = name[:dot]
+	t.field = name[dot+1:]
+
+	limit, err := strconv.ParseFloat(m[5], 64)
+	if err != nil {
+		return nil, util.FmtNewtError("invalid threshold \"%s\"; bad "+
+			"number: %s", s, m[5])
+	}
+	t.limit = limit
+
+	return t, nil
+}
+
+// Indicates whether a record crosses the threshold.  Delta and rate
+// thresholds don't apply to the first sample.
+// Verifies that each threshold names a field reported in the first sample.
+// Fields aren't known until the device has been read.
+func checkStatThresholdFields(thresholds []*statThreshold,
+	recs []statWatchRecord) error {
+
+	for _, t := range thresholds {
+		found := false
+		for _, rec := range recs {
+			if rec.Group == t.group && rec.Field == t.field {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return util.FmtNewtError(
+				"threshold \"%s\" refers to a field that isn't in group "+
+					"%s: %s", t.text, t.group, t.field)
+		}
+	}
+
+	return nil
+}
+
+func (t *statThreshold) crossed(rec statWatchRecord) bool {
+	if rec.Group != t.group || rec.Field != t.field {
+		return false
+	}
+
+	var val float64
+	switch t.metric {
+	case STAT_METRIC_VALUE:
+		val = float64(rec.Value)
+	case STAT_METRIC_DELTA:
+		if rec.Delta == nil {
+			return false
+		}
+		val = float64(*rec.Delta)
+	case STAT_METRIC_RATE:
+		if rec.Rate == nil {
+			return false
+		}
+		val = *rec.Rate
+	}
+
+	switch t.op {
+	case ">":
+		return val > t.limit
+	case ">=":
+		return val >= t.limit
+	case "<":
+		return val < t.limit
+	case "<=":
+		return val <= t.limit
+	case "==":
+		return val == t.limit
+	default:
+		return val != t.limit
+	}
+}
+
+// Writes stat records to a file in one of the record formats.
+type statRecordWriter interface {
+	Write(rec statWatchRecord) error
+	Flush() error
+}
+
+type jsonlStatWriter struct {
+	enc *json.Encoder
+}
+
+func (sw *jsonlStatWriter) Write(rec statWatchRecord) error {
+	return sw.enc.Encode(rec)
+}
+
+func (sw *jsonlStatWriter) Flush() error {
+	return nil
+}
+
+var statCsvHeader = []string{
+	"time", "elapsed", "group", "field", "value", "delta", "rate",
+}
+
+// Writes one row per record; the delta and rate of the first sample are
+// empty.
+type csvStatWriter struct {
+	w *csv.Writer
+}
+
+func newCsvStatWriter(w io.Writer) (*csvStatWriter, error) {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(statCsvHeader); err != nil {
+		return nil, err
+	}
+
+	return &csvStatWriter{w: cw}, nil
+}
+
+func (sw *csvStatWriter) Write(rec statWatchRecord) error {
+	delta := ""
+	if rec.Delta != nil {
+		delta = strconv.FormatInt(*rec.Delta, 10)
+	}
+	rate := ""
+	if rec.Rate != nil {
+		rate = strconv.FormatFloat(*rec.Rate, 'f', -1, 64)
+	}
+
+	return sw.w.Write([]string{
+		rec.Time,
+		strconv.FormatFloat(rec.Elapsed, 'f', 3, 64),
+		rec.Group,
+		rec.Field,
+		strconv.FormatInt(rec.Value, 10),
+		delta,
+		rate,
+	})
+}
+
+func (sw *csvStatWriter) Flush() error {
+	sw.w.Flush()
+	return sw.w.Error()
+}
+
+var statRecordWriterCtors = map[string]func(io.Writer) (statRecordWriter,
+	error){
+
+	RECORD_FORMAT_JSONL: func(w io.Writer) (statRecordWriter, error) {
+		enc := json.NewEncoder(w)
+		enc.SetEscapeHTML(false)
+		return &jsonlStatWriter{enc: enc}, nil
+	},
+	RECORD_FORMAT_CSV: func(w io.Writer) (statRecordWriter, error) {
+		return newCsvStatWriter(w)
+	},
+}
+
+// Converts a stat value to an integer.  Stats are unsigned counters, but the
+// decoder's choice of type depends on the value.
+func statInt(itf interface{}) (int64, bool) {
+	switch v := itf.(type) {
+	case int:
+		return int64(v), true
+	case int8:
+		return int64(v), true
+	case int16:
+		return int64(v), true
+	case int32:
+		return int64(v), true
+	case int64:
+		return v, true
+	case uint:
+		return int64(v), true
+	case uint8:
+		return int64(v), true
+	case uint16:
+		return int64(v), true
+	case uint32:
+		return int64(v), true
+	case uint64:
+		return int64(v), true
+	default:
+		return 0, false
+	}
+}
+
+// Polls stat groups and computes the change in each field between samples.
+type statWatcher struct {
+	s      sesn.Sesn
+	groups []string
+	start  time.Time
+
+	prevTime   time.Time
+	prevValues map[string]map[string]int64
+}
+
+func newStatWatcher(s sesn.Sesn, groups []string) *statWatcher {
+	return &statWatcher{
+		s:          s,
+		groups:     groups,
+		prevValues: map[string]map[string]int64{},
+	}
+}
+
+// Reads every group and returns a record for each field, ordered by group
+// and field name.
+func (w *statWatcher) sample() ([]statWatchRecord, error) {
+	now := time.Now()
+	if w.start.IsZero() {
+		w.start = now
+	}
+
+	var recs []statWatchRecord
+	for _, group := range w.groups {
+		c := xact.NewStatReadCmd()
+		c.SetTxOptions(nmutil.TxOptions())
+		c.Name = group
+
+		res, err := c.Run(w.s)
+		if err != nil {
+			return nil, util.ChildNewtError(err)
+		}
+		sres := res.(*xact.StatReadResult)
+
+		recs = append(recs, w.groupRecords(now, group, sres.Rsp.Fields)...)
+	}
+	w.prevTime = now
+
+	return recs, nil
+}
+
+// Converts one group's fields to records, ordered by field name, and
+// remembers their values for the next sample.  A field that decreased is
+// assumed to have been reset, e.g., by a reboot, so its delta is its new
+// value.
+func (w *statWatcher) groupRecords(now time.Time, group string,
+	fields map[string]interface{}) []statWatchRecord {
+
+	names := make([]string, 0, len(fields))
+	for name := range fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var recs []statWatchRecord
+	prev := w.prevValues[group]
+	cur := make(map[string]int64, len(names))
+	for _, name := range names {
+		val, ok := statInt(fields[name])
+		if !ok {
+			continue
+		}
+		cur[name] = val
+
+		rec := statWatchRecord{
+			Time:    now.Format(time.RFC3339Nano),
+			Elapsed: now.Sub(w.start).Seconds(),
+			Group:   group,
+			Field:   name,
+			Value:   val,
+		}
+
+		if pval, ok := prev[name]; ok {
+			delta := val - pval
+			if delta < 0 {
+				delta = val
+			}
+			rate := float64(delta) / now.Sub(w.prevTime).Seconds()
+
+			rec.Delta = &delta
+			rec.Rate = &rate
+		}
+
+		recs = append(recs, rec)
+	}
+	w.prevValues[group] = cur
+
+	return recs
+}
+
+// Prints a sample in text form.  After the first sample, only the fields
+// that changed are listed.
+func printStatSample(recs []statWatchRecord, first bool) {
+	if len(recs) == 0 {
+		return
+	}
+
+	fmt.Printf("%s (%.1fs)\n", recs[0].Time, recs[0].Elapsed)
+	for _, rec := range recs {
+		if rec.Delta == nil {
+			if first {
+				fmt.Printf("    %-16s %-28s %12d\n",
+					rec.Group, rec.Field, rec.Value)
+			}
+		} else if *rec.Delta != 0 {
+			fmt.Printf("    %-16s %-28s %12d %+10d %10.2f/s\n",
+				rec.Group, rec.Field, rec.Value, *rec.Delta, *rec.Rate)
+		}
+	}
+}
+
+func statWatchRunCmd(cmd *cobra.Command, args []string) {
+	if statWatchInterval <= 0 {
+		nmUsage(cmd, util.NewNewtError("poll interval must be positive"))
+	}
+	if statWatchCount < 0 {
+		nmUsage(cmd, util.NewNewtError("sample count can't be negative"))
+	}
+
+	newWriter := statRecordWriterCtors[statWatchFormat]
+	if newWriter == nil {
+		nmUsage(cmd, util.FmtNewtError(
+			"invalid format \"%s\"; expected jsonl or csv", statWatchFormat))
+	}
+
+	var thresholds []*statThreshold
+	for _, s := range statWatchThresholds {
+		t, err := parseStatThreshold(s)
+		if err != nil {
+			nmUsage(cmd, err)
+		}
+		thresholds = append(thresholds, t)
+	}
+
+	s, err := GetSesn()
+	if err != nil {
+		nmUsage(nil, err)
+	}
+
+	groups := args
+	if len(groups) == 0 {
+		c := xact.NewStatListCmd()
+		c.SetTxOptions(nmutil.TxOptions())
+
+		res, err := c.Run(s)
+		if err != nil {
+			nmUsage(nil, util.ChildNewtError(err))
+		}
+		groups = append(groups, res.(*xact.StatListResult).Rsp.List...)
+		sort.Strings(groups)
+	}
+
+	for _, t := range thresholds {
+		found := false
+		for _, g := range groups {
+			if g == t.group {
+				found = true
+				break
+			}
+		}
+		if !found {
+			nmUsage(cmd, util.FmtNewtError(
+				"threshold \"%s\" refers to a group that isn't watched: %s",
+				t.text, t.group))
+		}
+	}
+
+	var sw statRecordWriter
+	if statWatchOut != "" {
+		file, err := os.Create(statWatchOut)
+		if err != nil {
+			nmUsage(nil, util.ChildNewtError(err))
+		}
+		defer file.Close()
+
+		sw, err = newWriter(file)
+		if err != nil {
+			nmUsage(nil, util.ChildNewtError(err))
+		}
+	}
+
+	w := newStatWatcher(s, groups)
+	for i := 0; statWatchCount == 0 || i < statWatchCount; i++ {
+		if i > 0 {
+			time.Sleep(statWatchInterval)
+		}
+
+		recs, err := w.sample()
+		if err != nil {
+			nmUsage(nil, err)
+		}
+		if i == 0 {
+			if err := checkStatThresholdFields(thresholds, recs); err != nil {
+				nmUsage(cmd, err)
+			}
+		}
+
+		if structuredOutput() {
+			for _, rec := range recs {
+				printRecord(rec)
+			}
+		} else {
+			printStatSample(recs, i == 0)
+		}
+
+		// Flush each sample, so the series survives an interrupt.
+		if sw != nil {
+			for _, rec := range recs {
+				if err := sw.Write(rec); err != nil {
+					nmUsage(nil, util.ChildNewtError(err))
+				}
+			}
+			if err := sw.Flush(); err != nil {
+				nmUsage(nil, util.ChildNewtError(err))
+			}
+		}
+
+		crossed := false
+		for _, t := range thresholds {
+			for _, rec := range recs {
+				if t.crossed(rec) {
+					fmt.Fprintf(os.Stderr, "Threshold crossed: %s\n", t.text)
+					crossed = true
+					break
+				}
+			}
+		}
+		if crossed {
+			if sw != nil {
+				// Deferred calls don't run when the process exits.
+				sw.Flush()
+			}
+			NmExit(EXIT_THRESHOLD)
+		}
+	}
+}
+
+func statWatchCmd() *cobra.Command {
+	ex := nmutil.ToolInfo.ExeName +
+		" stat watch ble_ll ble_att --interval 5s -c myserial\n"
+	ex += nmutil.ToolInfo.ExeName +
+		" stat watch --count 60 --format csv --out stats.csv -c myserial\n"
+	ex += nmutil.ToolInfo.ExeName +
+		" stat watch ble_ll --threshold 'rate(ble_ll.tx_fail) > 5' " +
+		"-c myserial\n"
+
+	watchCmd := &cobra.Command{
+		Use:   "watch [stats_name...] -c <conn_profile>",
+		Short: "Poll statistics and show how they change",
+		Long: "Read the specified stats groups, or every group if none " +
+			"are specified, at regular intervals.  For each field, the " +
+			"change since the previous sample (delta) and the change per " +
+			"second (rate) are computed; a field that decreases is " +
+			"assumed to have been reset.  After the first sample, only " +
+			"the fields that changed are displayed.  With --out, every " +
+			"field of every sample is recorded in JSON Lines or CSV " +
+			"format.\n\n" +
+			"A threshold has the form [value|delta|rate](<group>.<field>) " +
+			"<op> <number>, where op is one of >, >=, <, <=, ==, or !=; " +
+			"value is the default.  If a threshold is crossed, the " +
+			"command exits with status 2 after recording the sample.  " +
+			"A threshold must name a field of a watched group.",
+		Example: ex,
+		Run:     statWatchRunCmd,
+	}
+
+	watchCmd.Flags().DurationVar(&statWatchInterval, "interval",
+		time.Second, "time between samples")
+	watchCmd.Flags().IntVar(&statWatchCount, "count", 0,
+		"number of samples to take; 0 means until interrupted")
+	watchCmd.Flags().StringVar(&statWatchFormat, "format",
+		RECORD_FORMAT_JSONL, "output file format: jsonl or csv")
+	watchCmd.Flags().StringVar(&statWatchOut, "out", "",
+		"file to record the samples in")
+	watchCmd.Flags().StringArrayVar(&statWatchThresholds, "threshold", nil,
+		"exit with status 2 if a stat crosses this threshold; may be "+
+			"repeated")
+
+	return watchCmd
+}
@@ -0,0 +1,228 @@
+/**
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package cli
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseStatThreshold(t *testing.T) {
+	tests := []struct {
+		in     string
+		group  string
+		field  string
+		metric string
+		op     string
+		limit  float64
+	}{
+		{"ble_ll.tx_fail > 5", "ble_ll", "tx_fail", STAT_METRIC_VALUE,
+			">", 5},
+		{"rate(ble_ll.tx_fail)>=0.5", "ble_ll", "tx_fail", STAT_METRIC_RATE,
+			">=", 0.5},
+		{" delta( my.grp.errs ) != -1 ", "my.grp", "errs",
+			STAT_METRIC_DELTA, "!=", -1},
+		{"value(a.b) == 1e3", "a", "b", STAT_METRIC_VALUE, "==", 1000},
+		{"a.b<=2", "a", "b", STAT_METRIC_VALUE, "<=", 2},
+	}
+
+	for _, tt := range tests {
+		th, err := parseStatThreshold(tt.in)
+		if err != nil {
+			t.Errorf("%q: %v", tt.in, err)
+			continue
+		}
+		if th.group != tt.group || th.field != tt.field ||
+			th.metric != tt.metric || th.op != tt.op || th.limit != tt.limit {
+
+			t.Errorf("%q: got %+v", tt.in, *th)
+		}
+	}
+
+	bad := []string{
+		"",
+		"a.b",
+		"a.b > ",
+		"a.b > five",
+		"a.b => 5",
+		"ab > 5",
+		".b > 5",
+		"a. > 5",
+		"max(a.b) > 5",
+		"rate(a.b > 5",
+	}
+	for _, in := range bad {
+		if _, err := parseStatThreshold(in); err == nil {
+			t.Errorf("%q: invalid threshold accepted", in)
+		}
+	}
+}
+
+func TestStatThresholdCrossed(t *testing.T) {
+	delta := int64(3)
+	rate := 1.5
+	rec := statWatchRecord{
+		Group: "g",
+		Field: "f",
+		Value: 10,
+		Delta: &delta,
+		Rate:  &rate,
+	}
+	first := rec
+	first.Delta = nil
+	first.Rate = nil
+
+	tests := []struct {
+		in    string
+		rec   statWatchRecord
+		cross bool
+	}{
+		{"g.f > 9", rec, true},
+		{"g.f > 10", rec, false},
+		{"g.f >= 10", rec, true},
+		{"g.f < 10", rec, false},
+		{"g.f <= 10", rec, true},
+		{"g.f == 10", rec, true},
+		{"g.f != 10", rec, false},
+		{"g.other > 0", rec, false},
+		{"h.f > 0", rec, false},
+		{"delta(g.f) == 3", rec, true},
+		{"rate(g.f) > 1", rec, true},
+		{"rate(g.f) < 1", rec, false},
+
+		// Delta and rate thresholds don't apply to the first sample.
+		{"g.f > 9", first, true},
+		{"delta(g.f) >= 0", first, false},
+		{"rate(g.f) != 1", first, false},
+	}
+
+	for _, tt := range tests {
+		th, err := parseStatThreshold(tt.in)
+		if err != nil {
+			t.Fatalf("%q: %v", tt.in, err)
+		}
+		if got := th.crossed(tt.rec); got != tt.cross {
+			t.Errorf("%q on %v: got %v, want %v", tt.in, tt.rec, got,
+				tt.cross)
+		}
+	}
+}
+
+func TestCheckStatThresholdFields(t *testing.T) {
+	recs := []statWatchRecord{
+		{Group: "g", Field: "f"},
+		{Group: "g", Field: "f2"},
+		{Group: "h", Field: "x"},
+	}
+
+	tests := []struct {
+		in []string
+		ok bool
+	}{
+		{nil, true},
+		{[]string{"g.f > 1"}, true},
+		{[]string{"rate(g.f2) > 1", "h.x == 0"}, true},
+		{[]string{"g.f > 1", "g.missing > 1"}, false},
+		// The field exists, but in another group.
+		{[]string{"h.f > 1"}, false},
+	}
+
+	for _, tt := range tests {
+		var thresholds []*statThreshold
+		for _, s := range tt.in {
+			th, err := parseStatThreshold(s)
+			if err != nil {
+				t.Fatalf("%q: %v", s, err)
+			}
+			thresholds = append(thresholds, th)
+		}
+
+		err := checkStatThresholdFields(thresholds, recs)
+		if (err == nil) != tt.ok {
+			t.Errorf("%v: got error %v, want ok %v", tt.in, err, tt.ok)
+		}
+	}
+}
+
+func TestStatWatcherRecords(t *testing.T) {
+	w := newStatWatcher(nil, []string{"g"})
+	start := time.Unix(1000, 0)
+	w.start = start
+
+	sample := func(now time.Time,
+		fields map[string]interface{}) []statWatchRecord {
+
+		recs := w.groupRecords(now, "g", fields)
+		w.prevTime = now
+		return recs
+	}
+
+	// The first sample has no delta or rate; fields that aren't integers are
+	// skipped.
+	recs := sample(start, map[string]interface{}{
+		"b":    uint32(10),
+		"a":    uint64(100),
+		"name": "x",
+	})
+	if len(recs) != 2 || recs[0].Field != "a" || recs[1].Field != "b" {
+		t.Fatalf("first sample: got %v", recs)
+	}
+	for _, rec := range recs {
+		if rec.Delta != nil || rec.Rate != nil {
+			t.Errorf("first sample: %s has delta or rate", rec.Field)
+		}
+	}
+
+	// Two seconds later, "a" has been reset and "b" has grown; "c" is new.
+	now := start.Add(2 * time.Second)
+	recs = sample(now, map[string]interface{}{
+		"a": 4,
+		"b": uint16(16),
+		"c": int8(1),
+	})
+	if len(recs) != 3 {
+		t.Fatalf("second sample: got %v", recs)
+	}
+
+	want := []struct {
+		field string
+		value int64
+		delta int64
+		rate  float64
+	}{
+		{"a", 4, 4, 2},
+		{"b", 16, 6, 3},
+	}
+	for i, w := range want {
+		rec := recs[i]
+		if rec.Field != w.field || rec.Value != w.value ||
+			rec.Delta == nil || *rec.Delta != w.delta ||
+			rec.Rate == nil || *rec.Rate != w.rate {
+
+			t.Errorf("second sample: got %+v, want %+v", rec, w)
+		}
+		if rec.Elapsed != 2 {
+			t.Errorf("second sample: %s elapsed %v", rec.Field, rec.Elapsed)
+		}
+	}
+	if recs[2].Field != "c" || recs[2].Delta != nil || recs[2].Rate != nil {
+		t.Errorf("new field: got %+v", recs[2])
+	}
+}
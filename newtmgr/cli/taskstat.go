@@ -30,6 +30,22 @@ import (
 	"mynewt.apache.org/newtmgr/nmxact/xact"
 )
 
+type taskStatOutput struct {
+	Tasks []taskStatEntry `json:"tasks"`
+}
+
+type taskStatEntry struct {
+	Name        string `json:"name"`
+	Prio        int    `json:"prio"`
+	Tid         int    `json:"tid"`
+	Runtime     int    `json:"runtime"`
+	Cswcnt      int    `json:"cswcnt"`
+	Stksiz      int    `json:"stksiz"`
+	Stkuse      int    `json:"stkuse"`
+	LastCheckin int    `json:"last_checkin"`
+	NextCheckin int    `json:"next_checkin"`
+}
+
 func taskStatRunCmd(cmd *cobra.Command, args []string) {
 	s, err := GetSesn()
 	if err != nil {
@@ -45,33 +61,45 @@ func taskStatRunCmd(cmd *cobra.Command, args []string) {
 	}
 
 	sres := res.(*xact.TaskStatResult)
-	if sres.Rsp.Rc != 0 {
-		fmt.Printf("Error: %d\n", sres.Rsp.Rc)
-		return
-	}
-
 	names := make([]string, 0, len(sres.Rsp.Tasks))
 	for k, _ := range sres.Rsp.Tasks {
 		names = append(names, k)
 	}
 	sort.Strings(names)
 
-	fmt.Printf("  %8s %3s %3s %8s %8s %8s %8s %8s %8s\n",
-		"task", "pri", "tid", "runtime", "csw", "stksz",
-		"stkuse", "last_checkin", "next_checkin")
+	out := taskStatOutput{Tasks: []taskStatEntry{}}
 	for _, n := range names {
 		t := sres.Rsp.Tasks[n]
-		fmt.Printf("  %8s %3d %3d %8d %8d %8d %8d %8d %8d\n",
-			n,
-			t["prio"],
-			t["tid"],
-			t["runtime"],
-			t["cswcnt"],
-			t["stksiz"],
-			t["stkuse"],
-			t["last_checkin"],
-			t["next_checkin"])
+		out.Tasks = append(out.Tasks, taskStatEntry{
+			Name:        n,
+			Prio:        t["prio"],
+			Tid:         t["tid"],
+			Runtime:     t["runtime"],
+			Cswcnt:      t["cswcnt"],
+			Stksiz:      t["stksiz"],
+			Stkuse:      t["stkuse"],
+			LastCheckin: t["last_checkin"],
+			NextCheckin: t["next_checkin"],
+		})
 	}
+
+	printResult(out, func() {
+		fmt.Printf("  %8s %3s %3s %8s %8s %8s %8s %8s %8s\n",
+			"task", "pri", "tid", "runtime", "csw", "stksz",
+			"stkuse", "last_checkin", "next_checkin")
+		for _, t := range out.Tasks {
+			fmt.Printf("  %8s %3d %3d %8d %8d %8d %8d %8d %8d\n",
+				t.Name,
+				t.Prio,
+				t.Tid,
+				t.Runtime,
+				t.Cswcnt,
+				t.Stksiz,
+				t.Stkuse,
+				t.LastCheckin,
+				t.NextCheckin)
+		}
+	})
 }
 
 func taskStatCmd() *cobra.Command {
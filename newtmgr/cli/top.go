@@ -0,0 +1,490 @@
+/**
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package cli
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"mynewt.apache.org/newt/util"
+	"mynewt.apache.org/newtmgr/newtmgr/nmutil"
+	"mynewt.apache.org/newtmgr/nmxact/sesn"
+	"mynewt.apache.org/newtmgr/nmxact/xact"
+)
+
+// Task sort orders.
+const (
+	TOP_SORT_CPU   = "cpu"
+	TOP_SORT_NAME  = "name"
+	TOP_SORT_PRIO  = "prio"
+	TOP_SORT_STACK = "stack"
+	TOP_SORT_CSW   = "csw"
+)
+
+// Mempool sort orders.
+const (
+	TOP_POOL_SORT_NAME = "name"
+	TOP_POOL_SORT_FREE = "free"
+)
+
+var topInterval time.Duration
+var topCount int
+var topSort string
+var topPoolSort string
+var topReverse bool
+
+// Keys that select a sort order in the interactive view.
+var topSortKeys = map[byte]string{
+	'c': TOP_SORT_CPU,
+	'n': TOP_SORT_NAME,
+	'p': TOP_SORT_PRIO,
+	's': TOP_SORT_STACK,
+	'w': TOP_SORT_CSW,
+}
+
+// A task's statistics, with rates computed from the previous sample.  In the
+// first sample, and for a task that wasn't in the previous one, the CPU share
+// is taken over the whole uptime and the context switch rate is unknown.
+type topTask struct {
+	Name     string  `json:"name"`
+	Prio     int     `json:"prio"`
+	Cpu      float64 `json:"cpu_pct"`
+	Runtime  int     `json:"runtime"`
+	Cswcnt   int     `json:"cswcnt"`
+	CswRate  float64 `json:"csw_per_sec"`
+	Stksiz   int     `json:"stksiz"`
+	Stkuse   int     `json:"stkuse"`
+	StackPct float64 `json:"stack_pct"`
+}
+
+// A mempool's statistics.  The free delta is relative to the previous
+// sample; the min-free delta is relative to the first sample, so that a pool
+// that is slowly draining stands out.
+type topPool struct {
+	Name      string `json:"name"`
+	Blksiz    int    `json:"blksiz"`
+	Nblks     int    `json:"nblks"`
+	Nfree     int    `json:"nfree"`
+	FreeDelta int    `json:"free_delta"`
+	Min       int    `json:"min"`
+	MinDelta  int    `json:"min_delta"`
+}
+
+type topOutput struct {
+	Time     string    `json:"time"`
+	Tasks    []topTask `json:"tasks"`
+	Mempools []topPool `json:"mempools"`
+}
+
+// Reads task and mempool statistics and computes the change between
+// samples.
+type topSampler struct {
+	s sesn.Sesn
+
+	prevTime   time.Time
+	prevTasks  map[string]map[string]int
+	prevPools  map[string]map[string]int
+	firstPools map[string]map[string]int
+}
+
+func (t *topSampler) sample() (topOutput, error) {
+	out := topOutput{
+		Tasks:    []topTask{},
+		Mempools: []topPool{},
+	}
+
+	tc := xact.NewTaskStatCmd()
+	tc.SetTxOptions(nmutil.TxOptions())
+	tres, err := tc.Run(t.s)
+	if err != nil {
+		return out, util.ChildNewtError(err)
+	}
+
+	mc := xact.NewMempoolStatCmd()
+	mc.SetTxOptions(nmutil.TxOptions())
+	mres, err := mc.Run(t.s)
+	if err != nil {
+		return out, util.ChildNewtError(err)
+	}
+
+	tasks := tres.(*xact.TaskStatResult).Rsp.Tasks
+	pools := mres.(*xact.MempoolStatResult).Rsp.Mpools
+
+	return t.update(time.Now(), tasks, pools), nil
+}
+
+// Computes a sample from the statistics read at the given time, and keeps
+// them for the next one.
+func (t *topSampler) update(now time.Time, tasks map[string]map[string]int,
+	pools map[string]map[string]int) topOutput {
+
+	out := topOutput{
+		Time:     now.Format(time.RFC3339),
+		Tasks:    []topTask{},
+		Mempools: []topPool{},
+	}
+
+	// The CPU share of a task is its share of the runtime of all tasks.  If
+	// any runtime decreased, the device rebooted, so start over.
+	prevTasks := t.prevTasks
+	for name, ts := range tasks {
+		if prev, ok := prevTasks[name]; ok && ts["runtime"] < prev["runtime"] {
+			prevTasks = nil
+		}
+	}
+
+	// Tasks without a previous sample get a share of the runtime of all
+	// tasks since boot, as every task does in the first sample.
+	total := 0
+	lifetime := 0
+	for name, ts := range tasks {
+		lifetime += ts["runtime"]
+		if prev, ok := prevTasks[name]; ok {
+			total += ts["runtime"] - prev["runtime"]
+		}
+	}
+
+	elapsed := now.Sub(t.prevTime).Seconds()
+	for name, ts := range tasks {
+		tt := topTask{
+			Name:    name,
+			Prio:    ts["prio"],
+			Runtime: ts["runtime"],
+			Cswcnt:  ts["cswcnt"],
+			Stksiz:  ts["stksiz"],
+			Stkuse:  ts["stkuse"],
+		}
+		if prev, ok := prevTasks[name]; ok {
+			if total > 0 {
+				tt.Cpu = 100 * float64(ts["runtime"]-prev["runtime"]) /
+					float64(total)
+			}
+			if elapsed > 0 {
+				tt.CswRate = float64(ts["cswcnt"]-prev["cswcnt"]) / elapsed
+			}
+		} else if lifetime > 0 {
+			tt.Cpu = 100 * float64(ts["runtime"]) / float64(lifetime)
+		}
+		if tt.Stksiz > 0 {
+			tt.StackPct = 100 * float64(tt.Stkuse) / float64(tt.Stksiz)
+		}
+
+		out.Tasks = append(out.Tasks, tt)
+	}
+
+	if t.firstPools == nil {
+		t.firstPools = pools
+	}
+	for name, ps := range pools {
+		tp := topPool{
+			Name:   name,
+			Blksiz: ps["blksiz"],
+			Nblks:  ps["nblks"],
+			Nfree:  ps["nfree"],
+			Min:    ps["min"],
+		}
+		if prev, ok := t.prevPools[name]; ok {
+			tp.FreeDelta = ps["nfree"] - prev["nfree"]
+		}
+		if fp, ok := t.firstPools[name]; ok {
+			tp.MinDelta = ps["min"] - fp["min"]
+		}
+
+		out.Mempools = append(out.Mempools, tp)
+	}
+
+	t.prevTime = now
+	t.prevTasks = tasks
+	t.prevPools = pools
+
+	return out
+}
+
+func sortTopTasks(tasks []topTask, order string, reverse bool) {
+	less := func(a, b topTask) bool {
+		switch order {
+		case TOP_SORT_NAME:
+			return a.Name < b.Name
+		case TOP_SORT_PRIO:
+			return a.Prio < b.Prio
+		case TOP_SORT_STACK:
+			return a.StackPct > b.StackPct
+		case TOP_SORT_CSW:
+			return a.CswRate > b.CswRate
+		default:
+			return a.Cpu > b.Cpu
+		}
+	}
+
+	sort.SliceStable(tasks, func(i, j int) bool {
+		if reverse {
+			return less(tasks[j], tasks[i])
+		}
+		return less(tasks[i], tasks[j])
+	})
+}
+
+// Returns the fraction of a mempool's blocks that are free.  A pool without
+// blocks can't run out, so it counts as entirely free.
+func (p topPool) freeFrac() float64 {
+	if p.Nblks <= 0 {
+		return 1
+	}
+	return float64(p.Nfree) / float64(p.Nblks)
+}
+
+func sortTopPools(pools []topPool, order string) {
+	sort.SliceStable(pools, func(i, j int) bool {
+		if order == TOP_POOL_SORT_FREE {
+			return pools[i].freeFrac() < pools[j].freeFrac()
+		}
+		return pools[i].Name < pools[j].Name
+	})
+}
+
+func formatTopDelta(d int) string {
+	if d == 0 {
+		return "="
+	}
+	return fmt.Sprintf("%+d", d)
+}
+
+// Formats a sample as a table of tasks followed by a table of mempools.
+func formatTop(out topOutput, interactive bool) string {
+	var buf bytes.Buffer
+
+	sortTopTasks(out.Tasks, topSort, topReverse)
+	sortTopPools(out.Mempools, topPoolSort)
+
+	order := topSort
+	if topReverse {
+		order += ", reversed"
+	}
+	fmt.Fprintf(&buf, "%s  every %s  tasks by %s, mempools by %s\n",
+		out.Time, topInterval, order, topPoolSort)
+	if interactive {
+		fmt.Fprintf(&buf, "sort: c=cpu n=name p=prio s=stack w=csw  "+
+			"r=reverse  f=mempool order  q=quit\n")
+	}
+
+	fmt.Fprintf(&buf, "\n%-16s %4s %6s %10s %10s %17s %6s\n",
+		"TASK", "PRI", "CPU%", "RUNTIME", "CSW/S", "STACK", "STK%")
+	for _, t := range out.Tasks {
+		fmt.Fprintf(&buf, "%-16s %4d %6.1f %10d %10.1f %17s %6.1f\n",
+			t.Name, t.Prio, t.Cpu, t.Runtime, t.CswRate,
+			fmt.Sprintf("%d/%d", t.Stkuse, t.Stksiz), t.StackPct)
+	}
+
+	fmt.Fprintf(&buf, "\n%-16s %7s %6s %6s %6s %6s %6s\n",
+		"MEMPOOL", "BLKSIZ", "BLKS", "FREE", "dFREE", "MIN", "dMIN")
+	for _, p := range out.Mempools {
+		fmt.Fprintf(&buf, "%-16s %7d %6d %6d %6s %6d %6s\n",
+			p.Name, p.Blksiz, p.Nblks, p.Nfree, formatTopDelta(p.FreeDelta),
+			p.Min, formatTopDelta(p.MinDelta))
+	}
+
+	return buf.String()
+}
+
+// Redraws the whole screen.  Each line is cleared to the end, so that
+// nothing is left over from a longer previous line.
+func drawTop(text string) {
+	text = strings.Replace(text, "\n", "\033[K\n", -1)
+	fmt.Printf("\033[H%s\033[J", text)
+}
+
+func checkTopSort() error {
+	switch topSort {
+	case TOP_SORT_CPU, TOP_SORT_NAME, TOP_SORT_PRIO, TOP_SORT_STACK,
+		TOP_SORT_CSW:
+	default:
+		return util.FmtNewtError("invalid sort order \"%s\"; expected "+
+			"cpu, name, prio, stack, or csw", topSort)
+	}
+
+	switch topPoolSort {
+	case TOP_POOL_SORT_NAME, TOP_POOL_SORT_FREE:
+	default:
+		return util.FmtNewtError("invalid mempool sort order \"%s\"; "+
+			"expected name or free", topPoolSort)
+	}
+
+	return nil
+}
+
+// Handles a key press in the interactive view.  Returns false if the view
+// should be closed.
+func topKey(key byte) bool {
+	switch key {
+	case 'q', 'Q':
+		return false
+	case 'r':
+		topReverse = !topReverse
+	case 'f':
+		if topPoolSort == TOP_POOL_SORT_NAME {
+			topPoolSort = TOP_POOL_SORT_FREE
+		} else {
+			topPoolSort = TOP_POOL_SORT_NAME
+		}
+	default:
+		if order, ok := topSortKeys[key]; ok {
+			topSort = order
+		}
+	}
+
+	return true
+}
+
+func topRunCmd(cmd *cobra.Command, args []string) {
+	if topInterval <= 0 {
+		nmUsage(cmd, util.NewNewtError("refresh interval must be positive"))
+	}
+	if topCount < 0 {
+		nmUsage(cmd, util.NewNewtError("refresh count can't be negative"))
+	}
+	if err := checkTopSort(); err != nil {
+		nmUsage(cmd, err)
+	}
+
+	s, err := GetSesn()
+	if err != nil {
+		nmUsage(nil, err)
+	}
+
+	// The full-screen view is only used when a person is watching;
+	// otherwise, each sample is printed in turn.
+	interactive := !structuredOutput() &&
+		isTerminal(os.Stdin) && isTerminal(os.Stdout)
+
+	keys := make(chan byte)
+	if interactive {
+		restore, err := makeCbreak(os.Stdin)
+		if err != nil {
+			nmUsage(nil, util.ChildNewtError(err))
+		}
+
+		// Restore the terminal however the command exits.
+		prevOnExit := onExit
+		onExit = func() {
+			restore()
+			fmt.Printf("\n")
+			if prevOnExit != nil {
+				prevOnExit()
+			}
+		}
+
+		fmt.Printf("\033[2J")
+		go func() {
+			b := make([]byte, 1)
+			for {
+				if n, err := os.Stdin.Read(b); err != nil {
+					return
+				} else if n > 0 {
+					keys <- b[0]
+				}
+			}
+		}()
+	}
+
+	sampler := &topSampler{s: s}
+	for i := 0; topCount == 0 || i < topCount; i++ {
+		out, err := sampler.sample()
+		if err != nil {
+			nmUsage(nil, err)
+		}
+
+		if structuredOutput() {
+			sortTopTasks(out.Tasks, topSort, topReverse)
+			sortTopPools(out.Mempools, topPoolSort)
+			printRecord(out)
+		} else if interactive {
+			drawTop(formatTop(out, true))
+		} else {
+			fmt.Printf("%s\n", formatTop(out, false))
+		}
+
+		if topCount != 0 && i == topCount-1 {
+			break
+		}
+
+		// Keys only change the order, so the screen is redrawn with the
+		// same sample.
+		timer := time.After(topInterval)
+	wait:
+		for {
+			select {
+			case <-timer:
+				break wait
+			case key := <-keys:
+				if !topKey(key) {
+					NmExit(0)
+				}
+				drawTop(formatTop(out, true))
+			}
+		}
+	}
+
+	NmExit(0)
+}
+
+func topCmd() *cobra.Command {
+	ex := nmutil.ToolInfo.ExeName + " top -c myserial\n"
+	ex += nmutil.ToolInfo.ExeName +
+		" top --interval 5s --sort stack --pool-sort free -c myserial\n"
+	ex += nmutil.ToolInfo.ExeName + " top --count 10 -c myserial > top.log\n"
+
+	cmd := &cobra.Command{
+		Use:   "top -c <conn_profile>",
+		Short: "Show a live view of the tasks and mempools on a device",
+		Long: "Read task and mempool statistics at regular intervals and " +
+			"display them in a view that is refreshed in place, until " +
+			"q is pressed.\n\n" +
+			"For each task, the view shows its share of the CPU time " +
+			"used since the previous refresh, its context switches per " +
+			"second, and its stack use.  For each mempool, it shows the " +
+			"free blocks and their change since the previous refresh, " +
+			"and the lowest number of free blocks and its change since " +
+			"the view was started.\n\n" +
+			"Keys change the order of the tasks: c (CPU share), n (name), " +
+			"p (priority), s (stack use), and w (context switches); r " +
+			"reverses the order, and f switches the mempools between " +
+			"name order and fullest first.  If the output is not a " +
+			"terminal, each refresh is printed in turn.",
+		Example: ex,
+		Run:     topRunCmd,
+	}
+
+	cmd.Flags().DurationVar(&topInterval, "interval", time.Second,
+		"time between refreshes")
+	cmd.Flags().IntVar(&topCount, "count", 0,
+		"number of refreshes; 0 means until q is pressed")
+	cmd.Flags().StringVar(&topSort, "sort", TOP_SORT_CPU,
+		"task order: cpu, name, prio, stack, or csw")
+	cmd.Flags().StringVar(&topPoolSort, "pool-sort", TOP_POOL_SORT_NAME,
+		"mempool order: name, or free for the fullest first")
+
+	return cmd
+}
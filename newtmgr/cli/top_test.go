@@ -0,0 +1,158 @@
+/**
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package cli
+
+import (
+	"math"
+	"strings"
+	"testing"
+	"time"
+)
+
+func topTaskByName(out topOutput, name string) topTask {
+	for _, tt := range out.Tasks {
+		if tt.Name == name {
+			return tt
+		}
+	}
+	return topTask{}
+}
+
+func topPoolByName(out topOutput, name string) topPool {
+	for _, tp := range out.Mempools {
+		if tp.Name == name {
+			return tp
+		}
+	}
+	return topPool{}
+}
+
+func TestTopSamplerTasks(t *testing.T) {
+	ts := &topSampler{}
+	start := time.Unix(1000, 0)
+
+	// In the first sample, the CPU share covers the whole uptime and the
+	// context switch rate is unknown.
+	out := ts.update(start, map[string]map[string]int{
+		"main": {"runtime": 300, "cswcnt": 10, "stksiz": 200, "stkuse": 50},
+		"idle": {"runtime": 700, "cswcnt": 90},
+	}, nil)
+	if len(out.Tasks) != 2 {
+		t.Fatalf("first sample: got %v", out.Tasks)
+	}
+	main := topTaskByName(out, "main")
+	if main.Cpu != 30 || main.CswRate != 0 || main.StackPct != 25 {
+		t.Errorf("first sample: got %+v", main)
+	}
+	if idle := topTaskByName(out, "idle"); idle.Cpu != 70 {
+		t.Errorf("first sample: got %+v", idle)
+	}
+
+	// Two seconds later, the share and rate are taken over the interval.  A
+	// task that wasn't in the previous sample is treated as in the first
+	// one.
+	out = ts.update(start.Add(2*time.Second), map[string]map[string]int{
+		"main": {"runtime": 400, "cswcnt": 30},
+		"idle": {"runtime": 1000, "cswcnt": 94},
+		"new":  {"runtime": 600, "cswcnt": 1000},
+	}, nil)
+	main = topTaskByName(out, "main")
+	if main.Cpu != 25 || main.CswRate != 10 {
+		t.Errorf("second sample: got %+v", main)
+	}
+	idle := topTaskByName(out, "idle")
+	if idle.Cpu != 75 || idle.CswRate != 2 {
+		t.Errorf("second sample: got %+v", idle)
+	}
+	if nt := topTaskByName(out, "new"); nt.Cpu != 30 || nt.CswRate != 0 {
+		t.Errorf("new task: got %+v", nt)
+	}
+
+	// After a reboot, runtimes go down and every task starts over.
+	out = ts.update(start.Add(4*time.Second), map[string]map[string]int{
+		"main": {"runtime": 10, "cswcnt": 2},
+		"idle": {"runtime": 40, "cswcnt": 3},
+	}, nil)
+	main = topTaskByName(out, "main")
+	if main.Cpu != 20 || main.CswRate != 0 {
+		t.Errorf("after reboot: got %+v", main)
+	}
+
+	// No runtime at all leaves the shares at zero.
+	ts = &topSampler{}
+	out = ts.update(start, map[string]map[string]int{"main": {}}, nil)
+	if main := topTaskByName(out, "main"); main.Cpu != 0 {
+		t.Errorf("no runtime: got %+v", main)
+	}
+}
+
+func TestTopSamplerMempools(t *testing.T) {
+	ts := &topSampler{}
+	start := time.Unix(1000, 0)
+
+	ts.update(start, nil, map[string]map[string]int{
+		"msys": {"blksiz": 292, "nblks": 12, "nfree": 10, "min": 8},
+	})
+	ts.update(start.Add(time.Second), nil, map[string]map[string]int{
+		"msys": {"blksiz": 292, "nblks": 12, "nfree": 7, "min": 6},
+	})
+	out := ts.update(start.Add(2*time.Second), nil, map[string]map[string]int{
+		"msys": {"blksiz": 292, "nblks": 12, "nfree": 9, "min": 5},
+		"new":  {"nblks": 4, "nfree": 4, "min": 4},
+	})
+
+	// The free delta is relative to the previous sample, and the min-free
+	// delta to the first.
+	msys := topPoolByName(out, "msys")
+	if msys.FreeDelta != 2 || msys.MinDelta != -3 {
+		t.Errorf("msys: got %+v", msys)
+	}
+	if nw := topPoolByName(out, "new"); nw.FreeDelta != 0 ||
+		nw.MinDelta != 0 {
+
+		t.Errorf("new pool: got %+v", nw)
+	}
+}
+
+func TestSortTopPools(t *testing.T) {
+	pools := []topPool{
+		{Name: "a", Nblks: 10, Nfree: 5},
+		{Name: "empty", Nblks: 0, Nfree: 0},
+		{Name: "b", Nblks: 4, Nfree: 1},
+		{Name: "c", Nblks: 2, Nfree: 2},
+	}
+
+	sortTopPools(pools, TOP_POOL_SORT_FREE)
+	var names []string
+	for _, p := range pools {
+		if math.IsNaN(p.freeFrac()) {
+			t.Errorf("%s: free fraction is NaN", p.Name)
+		}
+		names = append(names, p.Name)
+	}
+	if got := strings.Join(names, ","); got != "b,a,empty,c" {
+		t.Errorf("by free: got %s", got)
+	}
+
+	sortTopPools(pools, TOP_POOL_SORT_NAME)
+	if pools[0].Name != "a" || pools[3].Name != "empty" {
+		t.Errorf("by name: got %v", pools)
+	}
+}
@@ -20,6 +20,7 @@
 package cli
 
 import (
+	"errors"
 	"fmt"
 	"os"
 	"sync/atomic"
@@ -28,9 +29,27 @@ import (
 	"github.com/spf13/cobra"
 
 	"mynewt.apache.org/newt/util"
+	"mynewt.apache.org/newtmgr/nmxact/nmp"
+)
+
+// Exit statuses.  An error response from the device exits with
+// EXIT_NMP_BASE plus the status code, so scripts can tell failures apart;
+// codes defined by individual groups all map to EXIT_NMP_PERUSER.
+// EXIT_THRESHOLD indicates that a value being watched crossed a threshold;
+// EXIT_DIFFERENT indicates that a comparison found differences.
+const (
+	EXIT_ERR         = 1
+	EXIT_THRESHOLD   = 2
+	EXIT_DIFFERENT   = 3
+	EXIT_NMP_BASE    = 10
+	EXIT_NMP_PERUSER = 99
 )
 
 var onExit func()
+
+// Exit status when newtmgr is interrupted.  Commands that run until they are
+// interrupted set it to 0.
+var interruptStatus = EXIT_ERR
 var exiting int32
 var silenceErrors bool
 
@@ -38,6 +57,10 @@ func SetOnExit(cb func()) {
 	onExit = cb
 }
 
+func InterruptStatus() int {
+	return interruptStatus
+}
+
 func SilenceErrors() {
 	silenceErrors = true
 }
@@ -57,7 +80,9 @@ func NmExit(status int) {
 }
 
 func nmUsage(cmd *cobra.Command, err error) {
-	if !silenceErrors {
+	if !silenceErrors && structuredOutput() {
+		printErrorOutput(cmd, err)
+	} else if !silenceErrors {
 		if err != nil {
 			sErr, ok := err.(*util.NewtError)
 			if !ok {
@@ -75,5 +100,61 @@ func nmUsage(cmd *cobra.Command, err error) {
 		}
 	}
 
-	NmExit(1)
+	NmExit(exitStatus(err))
+}
+
+// Reports an error as a structured object on stdout, in place of the
+// message and usage text.
+func printErrorOutput(cmd *cobra.Command, err error) {
+	var text string
+	if err != nil {
+		sErr, ok := err.(*util.NewtError)
+		if !ok {
+			sErr = util.ChildNewtError(err)
+		}
+
+		log.Debugf("%s", sErr.StackTrace)
+		text = sErr.Text
+	} else if cmd != nil {
+		text = "usage: " + cmd.UseLine()
+	}
+
+	if werr := writeOutput(os.Stdout, newErrorOutput(text, err)); werr != nil {
+		fmt.Fprintf(os.Stderr, "Error: %s\n", text)
+	}
+}
+
+// Extracts the device's error response from an error, if there is one.
+// NewtErrors don't support unwrapping, so they are peeled off by hand.
+func nmpErrorOf(err error) *nmp.NmpError {
+	for {
+		nerr, ok := err.(*util.NewtError)
+		if !ok || nerr.Parent == nil {
+			break
+		}
+		err = nerr.Parent
+	}
+
+	var nmpErr *nmp.NmpError
+	if errors.As(err, &nmpErr) {
+		return nmpErr
+	}
+
+	return nil
+}
+
+func isNmpError(err error) bool {
+	return nmpErrorOf(err) != nil
+}
+
+func exitStatus(err error) int {
+	nmpErr := nmpErrorOf(err)
+	if nmpErr == nil {
+		return EXIT_ERR
+	}
+
+	if nmpErr.Rc >= EXIT_NMP_PERUSER-EXIT_NMP_BASE {
+		return EXIT_NMP_PERUSER
+	}
+	return EXIT_NMP_BASE + nmpErr.Rc
 }
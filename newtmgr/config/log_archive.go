@@ -0,0 +1,223 @@
+/**
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package config
+
+import (
+	"bufio"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"regexp"
+
+	log "github.com/sirupsen/logrus"
+
+	"mynewt.apache.org/newt/util"
+	"mynewt.apache.org/newtmgr/newtmgr/nmutil"
+	"mynewt.apache.org/newtmgr/nmxact/nmp"
+)
+
+// A log entry read from a device and saved in its log archive.  Entries are
+// stored one per line, in the order they were read.
+type LogArchiveEntry struct {
+	Log       string           `json:"log"`
+	LogType   int              `json:"log_type"`
+	Index     uint32           `json:"index"`
+	Timestamp int64            `json:"timestamp"`
+	Module    uint8            `json:"module"`
+	Level     uint8            `json:"level"`
+	Type      nmp.LogEntryType `json:"type"`
+	ImgHash   string           `json:"img_hash,omitempty"`
+	Msg       []byte           `json:"msg"`
+}
+
+func newLogArchiveEntry(l nmp.LogShowLog,
+	entry nmp.LogEntry) *LogArchiveEntry {
+
+	return &LogArchiveEntry{
+		Log:       l.Name,
+		LogType:   l.Type,
+		Index:     entry.Index,
+		Timestamp: entry.Timestamp,
+		Module:    entry.Module,
+		Level:     entry.Level,
+		Type:      entry.Type,
+		ImgHash:   hex.EncodeToString(entry.ImgHash),
+		Msg:       entry.Msg,
+	}
+}
+
+// Converts an archived entry back to the form in which it was read.
+func (e *LogArchiveEntry) LogEntry() nmp.LogEntry {
+	hash, _ := hex.DecodeString(e.ImgHash)
+
+	return nmp.LogEntry{
+		Index:     e.Index,
+		Timestamp: e.Timestamp,
+		Module:    e.Module,
+		Level:     e.Level,
+		Type:      e.Type,
+		ImgHash:   hash,
+		Msg:       e.Msg,
+	}
+}
+
+// Identifies an archived entry.  Indices are only unique within a log, and
+// restart when a device is erased, so the image hash is included to keep
+// entries from different firmware apart.  RAM logs also restart their
+// indices when the device reboots, so the timestamp is included to keep
+// entries from different boots apart.
+type logArchiveKey struct {
+	log       string
+	imgHash   string
+	index     uint32
+	timestamp int64
+}
+
+func (e *LogArchiveEntry) key() logArchiveKey {
+	return logArchiveKey{
+		log:       e.Log,
+		imgHash:   e.ImgHash,
+		index:     e.Index,
+		timestamp: e.Timestamp,
+	}
+}
+
+// Keeps a copy of every log entry read from a device, so that entries remain
+// available after the device's logs wrap.
+type LogArchive struct {
+	filename string
+	entries  []*LogArchiveEntry
+	seen     map[logArchiveKey]bool
+}
+
+var logArchiveUnsafeRe = regexp.MustCompile(`[^A-Za-z0-9._-]`)
+
+// Returns the path of the archive file of the specified device.  Each device
+// gets its own file, named after its key.
+func logArchiveFilename(device string) (string, error) {
+	dir, err := homeFilename(nmutil.ToolInfo.LogArchiveDir)
+	if err != nil {
+		return "", err
+	}
+
+	name := logArchiveUnsafeRe.ReplaceAllString(device, "_") + ".jsonl"
+	return filepath.Join(dir, name), nil
+}
+
+// Reads the log archive of the specified device.  A missing archive is not an
+// error; it is created when entries are first added.
+func ReadLogArchive(device string) (*LogArchive, error) {
+	filename, err := logArchiveFilename(device)
+	if err != nil {
+		return nil, err
+	}
+
+	a := &LogArchive{
+		filename: filename,
+		seen:     map[logArchiveKey]bool{},
+	}
+
+	log.Debugf("Reading log archive from %s", filename)
+	f, err := os.Open(filename)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return a, nil
+		} else {
+			return nil, util.ChildNewtError(err)
+		}
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(nil, 1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		e := &LogArchiveEntry{}
+		if err := json.Unmarshal(scanner.Bytes(), e); err != nil {
+			// Probably a partial line from an interrupted write.
+			log.Warnf("Skipping invalid entry in log archive (%s:%d): %s",
+				filename, line, err.Error())
+			continue
+		}
+
+		if !a.seen[e.key()] {
+			a.seen[e.key()] = true
+			a.entries = append(a.entries, e)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, util.FmtNewtError("error reading log archive (%s): %s",
+			filename, err.Error())
+	}
+
+	return a, nil
+}
+
+// Returns the archived entries in the order they were added.
+func (a *LogArchive) Entries() []*LogArchiveEntry {
+	return a.entries
+}
+
+// Appends the entries in a log show response that aren't in the archive yet.
+// Returns the number of entries added.
+func (a *LogArchive) Add(rsp *nmp.LogShowRsp) (int, error) {
+	var added []*LogArchiveEntry
+	for _, l := range rsp.Logs {
+		for _, entry := range l.Entries {
+			e := newLogArchiveEntry(l, entry)
+			if !a.seen[e.key()] {
+				a.seen[e.key()] = true
+				added = append(added, e)
+			}
+		}
+	}
+
+	if len(added) == 0 {
+		return 0, nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(a.filename), 0755); err != nil {
+		return 0, util.ChildNewtError(err)
+	}
+
+	f, err := os.OpenFile(a.filename,
+		os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return 0, util.ChildNewtError(err)
+	}
+	defer f.Close()
+
+	w := bufio.NewWriter(f)
+	for _, e := range added {
+		b, err := json.Marshal(e)
+		if err != nil {
+			return 0, util.NewNewtError(err.Error())
+		}
+		w.Write(b)
+		w.WriteByte('\n')
+	}
+	if err := w.Flush(); err != nil {
+		return 0, util.ChildNewtError(err)
+	}
+
+	a.entries = append(a.entries, added...)
+
+	return len(added), nil
+}
@@ -0,0 +1,148 @@
+/**
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/mitchellh/go-homedir"
+
+	"mynewt.apache.org/newtmgr/newtmgr/nmutil"
+	"mynewt.apache.org/newtmgr/nmxact/nmp"
+)
+
+// Points the home directory at a temporary one for the duration of a test.
+func setTestHome(t *testing.T) {
+	dir, err := ioutil.TempDir("", "newtmgr-test")
+	if err != nil {
+		t.Fatalf("temp dir: %v", err)
+	}
+
+	prevHome := os.Getenv("HOME")
+	prevCache := homedir.DisableCache
+	prevDir := nmutil.ToolInfo.LogArchiveDir
+	prevNames := nmutil.ToolInfo.LogNamesFilename
+	t.Cleanup(func() {
+		os.Setenv("HOME", prevHome)
+		homedir.DisableCache = prevCache
+		nmutil.ToolInfo.LogArchiveDir = prevDir
+		nmutil.ToolInfo.LogNamesFilename = prevNames
+		os.RemoveAll(dir)
+	})
+
+	os.Setenv("HOME", dir)
+	homedir.DisableCache = true
+	nmutil.ToolInfo.LogArchiveDir = ".newtmgr.logs"
+	nmutil.ToolInfo.LogNamesFilename = ".newtmgr.lognames.json"
+}
+
+func logShowRsp(entries ...nmp.LogEntry) *nmp.LogShowRsp {
+	return &nmp.LogShowRsp{
+		Logs: []nmp.LogShowLog{{Name: "log", Entries: entries}},
+	}
+}
+
+func logEntry(index uint32, ts int64, msg string) nmp.LogEntry {
+	return nmp.LogEntry{
+		Index:     index,
+		Timestamp: ts,
+		ImgHash:   []byte{0x12, 0x34},
+		Msg:       []byte(msg),
+	}
+}
+
+func addLogArchive(t *testing.T, a *LogArchive, rsp *nmp.LogShowRsp,
+	want int) {
+
+	added, err := a.Add(rsp)
+	if err != nil {
+		t.Fatalf("add: %v", err)
+	}
+	if added != want {
+		t.Errorf("added %d entries, want %d", added, want)
+	}
+}
+
+func TestLogArchiveDedup(t *testing.T) {
+	setTestHome(t)
+
+	a, err := ReadLogArchive("udp:127.0.0.1:1337")
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	addLogArchive(t, a, logShowRsp(logEntry(0, 100, "a"),
+		logEntry(1, 200, "b")), 2)
+
+	// Reading the same entries again adds nothing, while new ones are
+	// appended.
+	addLogArchive(t, a, logShowRsp(logEntry(1, 200, "b"),
+		logEntry(2, 300, "c")), 1)
+
+	// The same index in another log, or from another image, is a different
+	// entry.
+	other := logShowRsp(logEntry(0, 100, "a"))
+	other.Logs[0].Name = "other"
+	addLogArchive(t, a, other, 1)
+
+	img := logEntry(0, 100, "a")
+	img.ImgHash = []byte{0x56, 0x78}
+	addLogArchive(t, a, logShowRsp(img), 1)
+
+	// The entries survive a reload, and are still deduplicated.
+	a, err = ReadLogArchive("udp:127.0.0.1:1337")
+	if err != nil {
+		t.Fatalf("reread: %v", err)
+	}
+	if n := len(a.Entries()); n != 5 {
+		t.Fatalf("reloaded %d entries, want 5", n)
+	}
+	addLogArchive(t, a, logShowRsp(logEntry(2, 300, "c")), 0)
+
+	msgs := ""
+	for _, e := range a.Entries() {
+		msgs += string(e.LogEntry().Msg)
+	}
+	if msgs != "abcaa" {
+		t.Errorf("entries: got %q, want \"abcaa\"", msgs)
+	}
+}
+
+func TestLogArchiveReboot(t *testing.T) {
+	setTestHome(t)
+
+	a, err := ReadLogArchive("serial:dev=/dev/ttyUSB0")
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	addLogArchive(t, a, logShowRsp(logEntry(0, 1000, "boot 1"),
+		logEntry(1, 2000, "up 1")), 2)
+
+	// After a reboot, a RAM log starts over at index 0 with the same image,
+	// but the entries have new timestamps.
+	addLogArchive(t, a, logShowRsp(logEntry(0, 5000, "boot 2"),
+		logEntry(1, 6000, "up 2")), 2)
+	addLogArchive(t, a, logShowRsp(logEntry(1, 6000, "up 2")), 0)
+
+	if n := len(a.Entries()); n != 4 {
+		t.Errorf("archived %d entries, want 4", n)
+	}
+}
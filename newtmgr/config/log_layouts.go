@@ -0,0 +1,62 @@
+/**
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package config
+
+import (
+	"io/ioutil"
+	"os"
+
+	log "github.com/sirupsen/logrus"
+
+	"mynewt.apache.org/newt/util"
+	"mynewt.apache.org/newtmgr/newtmgr/nmutil"
+	"mynewt.apache.org/newtmgr/nmxact/nmp"
+)
+
+// Reads the layouts of binary log messages from the specified file.  If no
+// file is specified, the default file in the user's home directory is read;
+// it is not an error for the default file to be missing.
+func ReadLogLayouts(filename string) ([]nmp.LogLayout, error) {
+	if filename == "" {
+		var err error
+		filename, err = homeFilename(nmutil.ToolInfo.LogLayoutsFilename)
+		if err != nil {
+			return nil, err
+		}
+
+		if _, err := os.Stat(filename); os.IsNotExist(err) {
+			return nil, nil
+		}
+	}
+
+	log.Debugf("Reading log layouts from %s", filename)
+	blob, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, util.ChildNewtError(err)
+	}
+
+	layouts, err := nmp.ParseLogLayouts(blob)
+	if err != nil {
+		return nil, util.FmtNewtError("error reading log layouts (%s): %s",
+			filename, err.Error())
+	}
+
+	return layouts, nil
+}
@@ -0,0 +1,134 @@
+/**
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package config
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"sort"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"mynewt.apache.org/newt/util"
+	"mynewt.apache.org/newtmgr/newtmgr/nmutil"
+)
+
+// The log module and level names that a device reported, as name-to-ID maps.
+type LogNamesEntry struct {
+	Device  string         `json:"device"`
+	Modules map[string]int `json:"modules"`
+	Levels  map[string]int `json:"levels"`
+	Updated time.Time      `json:"updated"`
+}
+
+// Remembers the log names of each device, so they don't need to be read
+// from the device every time its logs are displayed.
+type LogNamesCache struct {
+	entries map[string]*LogNamesEntry
+}
+
+func logNamesCacheFilename() (string, error) {
+	return homeFilename(nmutil.ToolInfo.LogNamesFilename)
+}
+
+// Reads the log names cache from disk.  A missing cache is not an error.
+func ReadLogNamesCache() (*LogNamesCache, error) {
+	c := &LogNamesCache{
+		entries: map[string]*LogNamesEntry{},
+	}
+
+	filename, err := logNamesCacheFilename()
+	if err != nil {
+		return nil, err
+	}
+
+	log.Debugf("Reading log names cache from %s", filename)
+	blob, err := ioutil.ReadFile(filename)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return c, nil
+		} else {
+			return nil, util.ChildNewtError(err)
+		}
+	}
+
+	var entries []*LogNamesEntry
+	if err := json.Unmarshal(blob, &entries); err != nil {
+		return nil, util.FmtNewtError("error reading log names cache "+
+			"(%s): %s", filename, err.Error())
+	}
+
+	for _, e := range entries {
+		c.entries[e.Device] = e
+	}
+
+	return c, nil
+}
+
+// Looks up the names of the specified device.  Returns nil if there are
+// none.
+func (c *LogNamesCache) Find(device string) *LogNamesEntry {
+	return c.entries[device]
+}
+
+// Replaces the names of a device and writes the cache to disk.
+func (c *LogNamesCache) Update(device string, modules map[string]int,
+	levels map[string]int) error {
+
+	c.entries[device] = &LogNamesEntry{
+		Device:  device,
+		Modules: modules,
+		Levels:  levels,
+		Updated: time.Now(),
+	}
+
+	return c.save()
+}
+
+func (c *LogNamesCache) save() error {
+	keys := make([]string, 0, len(c.entries))
+	for k := range c.entries {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	list := make([]*LogNamesEntry, 0, len(keys))
+	for _, k := range keys {
+		list = append(list, c.entries[k])
+	}
+
+	b, err := json.MarshalIndent(list, "", "    ")
+	if err != nil {
+		return util.NewNewtError(err.Error())
+	}
+
+	filename, err := logNamesCacheFilename()
+	if err != nil {
+		return err
+	}
+
+	if err := ioutil.WriteFile(filename, b, 0644); err != nil {
+		return util.ChildNewtError(err)
+	}
+
+	return nil
+}
@@ -0,0 +1,86 @@
+/**
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package config
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestLogNamesCache(t *testing.T) {
+	setTestHome(t)
+
+	// A missing cache is empty.
+	c, err := ReadLogNamesCache()
+	if err != nil {
+		t.Fatalf("read empty cache: %v", err)
+	}
+	if e := c.Find("dev1"); e != nil {
+		t.Fatalf("empty cache has entry: %+v", e)
+	}
+
+	mods := map[string]int{"APP": 64, "SENSOR": 65}
+	levels := map[string]int{"TRACE": 5}
+	if err := c.Update("dev1", mods, levels); err != nil {
+		t.Fatalf("update: %v", err)
+	}
+	if err := c.Update("dev2", map[string]int{"OTHER": 70}, nil); err != nil {
+		t.Fatalf("update: %v", err)
+	}
+
+	// Each device's names survive a round trip through the file.
+	c, err = ReadLogNamesCache()
+	if err != nil {
+		t.Fatalf("read cache: %v", err)
+	}
+	e := c.Find("dev1")
+	if e == nil {
+		t.Fatalf("dev1 not cached")
+	}
+	if !reflect.DeepEqual(e.Modules, mods) ||
+		!reflect.DeepEqual(e.Levels, levels) {
+		t.Errorf("dev1 names: got %v %v, want %v %v",
+			e.Modules, e.Levels, mods, levels)
+	}
+	if e.Updated.IsZero() {
+		t.Errorf("dev1 update time not recorded")
+	}
+	if e := c.Find("dev2"); e == nil || e.Modules["OTHER"] != 70 {
+		t.Errorf("dev2 names: got %+v", e)
+	}
+
+	// Updating a device replaces its names and leaves the others alone.
+	newMods := map[string]int{"APP": 66}
+	if err := c.Update("dev1", newMods, nil); err != nil {
+		t.Fatalf("update: %v", err)
+	}
+	c, err = ReadLogNamesCache()
+	if err != nil {
+		t.Fatalf("read cache: %v", err)
+	}
+	if e := c.Find("dev1"); e == nil ||
+		!reflect.DeepEqual(e.Modules, newMods) || len(e.Levels) != 0 {
+
+		t.Errorf("updated dev1 names: got %+v", e)
+	}
+	if e := c.Find("dev2"); e == nil || e.Modules["OTHER"] != 70 {
+		t.Errorf("dev2 names after update: got %+v", e)
+	}
+}
@@ -0,0 +1,179 @@
+/**
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package config
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/mitchellh/go-homedir"
+	log "github.com/sirupsen/logrus"
+
+	"mynewt.apache.org/newt/util"
+	"mynewt.apache.org/newtmgr/newtmgr/nmutil"
+)
+
+// Records the progress of an image upload so that it can be continued by a
+// later invocation if it gets interrupted.
+type UploadJournalEntry struct {
+	Device   string    `json:"device"`
+	ImageSha string    `json:"image_sha256"`
+	ImageNum int       `json:"image_num"`
+	Len      int       `json:"len"`
+	Off      int       `json:"off"`
+	Updated  time.Time `json:"updated"`
+}
+
+type UploadJournal struct {
+	entries map[string]*UploadJournalEntry
+}
+
+func uploadJournalKey(device string, sha []byte, imageNum int) string {
+	return fmt.Sprintf("%s|%x|%d", device, sha, imageNum)
+}
+
+// Returns the path of a state file kept in the user's home directory.
+func homeFilename(name string) (string, error) {
+	dir, err := homedir.Dir()
+	if err != nil {
+		return "", util.NewNewtError(err.Error())
+	}
+
+	return filepath.Join(dir, name), nil
+}
+
+func uploadJournalFilename() (string, error) {
+	return homeFilename(nmutil.ToolInfo.JournalFilename)
+}
+
+// Reads the upload journal from disk.  A missing journal is not an error.
+func ReadUploadJournal() (*UploadJournal, error) {
+	j := &UploadJournal{
+		entries: map[string]*UploadJournalEntry{},
+	}
+
+	filename, err := uploadJournalFilename()
+	if err != nil {
+		return nil, err
+	}
+
+	log.Debugf("Reading upload journal from %s", filename)
+	blob, err := ioutil.ReadFile(filename)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return j, nil
+		} else {
+			return nil, util.ChildNewtError(err)
+		}
+	}
+
+	var entries []*UploadJournalEntry
+	if err := json.Unmarshal(blob, &entries); err != nil {
+		return nil, util.FmtNewtError("error reading upload journal "+
+			"(%s): %s", filename, err.Error())
+	}
+
+	for _, e := range entries {
+		sha, err := hex.DecodeString(e.ImageSha)
+		if err != nil {
+			continue
+		}
+		j.entries[uploadJournalKey(e.Device, sha, e.ImageNum)] = e
+	}
+
+	return j, nil
+}
+
+// Looks up the entry for an upload of the specified image to the specified
+// device.  Returns nil if there is none.
+func (j *UploadJournal) Find(device string, sha []byte,
+	imageNum int) *UploadJournalEntry {
+
+	return j.entries[uploadJournalKey(device, sha, imageNum)]
+}
+
+// Creates or updates the entry for an upload and writes the journal to disk.
+func (j *UploadJournal) Update(device string, sha []byte, imageNum int,
+	imageLen int, off int) error {
+
+	key := uploadJournalKey(device, sha, imageNum)
+	e := j.entries[key]
+	if e == nil {
+		e = &UploadJournalEntry{
+			Device:   device,
+			ImageSha: hex.EncodeToString(sha),
+			ImageNum: imageNum,
+		}
+		j.entries[key] = e
+	}
+	e.Len = imageLen
+	e.Off = off
+	e.Updated = time.Now()
+
+	return j.save()
+}
+
+// Removes the entry for an upload, if any, and writes the journal to disk.
+func (j *UploadJournal) Remove(device string, sha []byte,
+	imageNum int) error {
+
+	key := uploadJournalKey(device, sha, imageNum)
+	if j.entries[key] == nil {
+		return nil
+	}
+	delete(j.entries, key)
+
+	return j.save()
+}
+
+func (j *UploadJournal) save() error {
+	keys := make([]string, 0, len(j.entries))
+	for k := range j.entries {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	list := make([]*UploadJournalEntry, 0, len(keys))
+	for _, k := range keys {
+		list = append(list, j.entries[k])
+	}
+
+	b, err := json.MarshalIndent(list, "", "    ")
+	if err != nil {
+		return util.NewNewtError(err.Error())
+	}
+
+	filename, err := uploadJournalFilename()
+	if err != nil {
+		return err
+	}
+
+	if err := ioutil.WriteFile(filename, b, 0644); err != nil {
+		return util.ChildNewtError(err)
+	}
+
+	return nil
+}
@@ -80,11 +80,15 @@ func main() {
 	// defer trace.Stop()
 
 	nmutil.ToolInfo = nmutil.ToolInfoType{
-		ExeName:       "newtmgr",
-		ShortName:     "Newtmgr",
-		LongName:      "Apache Newtmgr",
-		VersionString: "1.9.0-dev",
-		CfgFilename:   ".newtmgr.cp.json",
+		ExeName:            "newtmgr",
+		ShortName:          "Newtmgr",
+		LongName:           "Apache Newtmgr",
+		VersionString:      "1.9.0-dev",
+		CfgFilename:        ".newtmgr.cp.json",
+		JournalFilename:    ".newtmgr.upload.json",
+		LogNamesFilename:   ".newtmgr.lognames.json",
+		LogArchiveDir:      ".newtmgr.logs",
+		LogLayoutsFilename: ".newtmgr.loglayouts.json",
 	}
 
 	if err := config.InitGlobalConnProfileMgr(); err != nil {
@@ -105,7 +109,7 @@ func main() {
 			case os.Interrupt, syscall.SIGTERM:
 				go func() {
 					cli.SilenceErrors()
-					cli.NmExit(1)
+					cli.NmExit(cli.InterruptStatus())
 				}()
 
 			case syscall.SIGQUIT:
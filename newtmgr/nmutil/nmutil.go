@@ -33,10 +33,24 @@ type ToolInfoType struct {
 	LongName      string
 	VersionString string
 	CfgFilename   string
+
+	// Holds the progress of interrupted image uploads; kept in the same
+	// directory as the connection profiles.
+	JournalFilename string
+
+	// Caches the log module and level names reported by each device.
+	LogNamesFilename string
+
+	// Holds the archived log entries of each device, one file per device.
+	LogArchiveDir string
+
+	// Describes the layouts of binary log messages; see nmp.LogLayout.
+	LogLayoutsFilename string
 }
 
 var Timeout float64
 var Tries int
+var Backoff bool
 var ConnProfile string
 var DeviceName string
 var BleWriteRsp bool
@@ -47,10 +61,15 @@ var ToolInfo ToolInfoType
 var HciIdx int
 
 func TxOptions() sesn.TxOptions {
-	return sesn.TxOptions{
+	opts := sesn.TxOptions{
 		Timeout: time.Duration(Timeout * float64(time.Second)),
 		Tries:   Tries,
 	}
+	if Backoff {
+		opts.Retry = sesn.NewBackoffPolicy()
+	}
+
+	return opts
 }
 
 func ErrorCausedBy(err error, cause error) bool {
@@ -0,0 +1,131 @@
+/**
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package emulator
+
+import (
+	"fmt"
+	"sort"
+
+	"mynewt.apache.org/newtmgr/nmxact/nmp"
+)
+
+// File that saved config settings get appended to, in the same format as
+// Mynewt's sys/config file store.
+const CONF_FS_FILE = "/cfg/run"
+
+func cfgReadReqCtor() nmp.NmpReq  { return &nmp.ConfigReadReq{} }
+func cfgWriteReqCtor() nmp.NmpReq { return &nmp.ConfigWriteReq{} }
+
+func (d *Device) initConfig() {
+	d.config["id/hwid"] = "656d756c61746f72"
+	d.config["id/bsp"] = "emulator"
+	d.config["id/app"] = "emulator"
+	d.config["id/serial"] = ""
+}
+
+// Retrieves the current value of a config setting.
+func (d *Device) Config(name string) (string, bool) {
+	d.mtx.Lock()
+	defer d.mtx.Unlock()
+
+	val, ok := d.config[name]
+	return val, ok
+}
+
+// Creates or updates a config setting.
+func (d *Device) SetConfig(name string, val string) {
+	d.mtx.Lock()
+	defer d.mtx.Unlock()
+
+	d.config[name] = val
+}
+
+// Retrieves the value of a config setting as last saved to persistent
+// storage.
+func (d *Device) SavedConfig(name string) (string, bool) {
+	d.mtx.Lock()
+	defer d.mtx.Unlock()
+
+	val, ok := d.saved[name]
+	return val, ok
+}
+
+// Persists a single setting by appending it to the config file.
+func (d *Device) saveConfigVal(name string) {
+	val := d.config[name]
+	if old, ok := d.saved[name]; ok && old == val {
+		return
+	}
+
+	d.saved[name] = val
+	line := fmt.Sprintf("%s=%s\n", name, val)
+	d.files[CONF_FS_FILE] = append(d.files[CONF_FS_FILE], line...)
+}
+
+func cfgRead(d *Device, req nmp.NmpReq) nmp.NmpRsp {
+	r := req.(*nmp.ConfigReadReq)
+	rsp := nmp.NewConfigReadRsp()
+
+	// Mynewt's config handler reports a setting that doesn't exist as an
+	// invalid argument.
+	val, ok := d.config[r.Name]
+	if !ok {
+		rsp.Rc = nmp.NMP_ERR_EINVAL
+		return rsp
+	}
+
+	rsp.Val = val
+	return rsp
+}
+
+func cfgWrite(d *Device, req nmp.NmpReq) nmp.NmpRsp {
+	r := req.(*nmp.ConfigWriteReq)
+	rsp := nmp.NewConfigWriteRsp()
+
+	if r.Name == "" {
+		// No name: commit and optionally save everything.
+		if !r.Save {
+			rsp.Rc = nmp.NMP_ERR_EINVAL
+			return rsp
+		}
+		names := make([]string, 0, len(d.config))
+		for name, _ := range d.config {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		for _, name := range names {
+			d.saveConfigVal(name)
+		}
+		return rsp
+	}
+
+	if _, ok := d.config[r.Name]; !ok {
+		rsp.Rc = nmp.NMP_ERR_ENOENT
+		return rsp
+	}
+
+	d.config[r.Name] = r.Val
+	if r.Save {
+		d.saveConfigVal(r.Name)
+	}
+
+	return rsp
+}
@@ -0,0 +1,66 @@
+/**
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package emulator
+
+import (
+	"encoding/binary"
+
+	"mynewt.apache.org/newtmgr/nmxact/nmp"
+)
+
+func crashReqCtor() nmp.NmpReq { return &nmp.CrashReq{} }
+
+var crashTypes = map[string]bool{
+	"div0":   true,
+	"jump0":  true,
+	"ref0":   true,
+	"assert": true,
+	"wdog":   true,
+}
+
+// Produces a fake core dump: the core magic followed by some register-sized
+// filler.
+func buildCore(crashType string) []byte {
+	core := make([]byte, 4, 4+DOWNLOAD_CHUNK_SZ+len(crashType))
+	binary.LittleEndian.PutUint32(core, CORE_MAGIC)
+	core = append(core, crashType...)
+	for i := 0; i < DOWNLOAD_CHUNK_SZ; i++ {
+		core = append(core, byte(i))
+	}
+
+	return core
+}
+
+func crash(d *Device, req nmp.NmpReq) nmp.NmpRsp {
+	r := req.(*nmp.CrashReq)
+	rsp := nmp.NewCrashRsp()
+
+	if !crashTypes[r.CrashType] {
+		rsp.Rc = nmp.NMP_ERR_EINVAL
+		return rsp
+	}
+
+	// A real device never gets to respond.  The emulator answers anyway so
+	// that clients don't have to wait for a timeout.
+	d.core = buildCore(r.CrashType)
+	d.reboot("CRASH")
+
+	return rsp
+}
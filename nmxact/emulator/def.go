@@ -0,0 +1,138 @@
+/**
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package emulator
+
+import (
+	"time"
+
+	"mynewt.apache.org/newtmgr/nmxact/nmp"
+)
+
+// Mynewt formats its date-time strings with microsecond precision.
+const DATETIME_FMT = "2006-01-02T15:04:05.000000-07:00"
+
+func echoReqCtor() nmp.NmpReq     { return &nmp.EchoReq{} }
+func taskStatReqCtor() nmp.NmpReq { return &nmp.TaskStatReq{} }
+func mpStatReqCtor() nmp.NmpReq   { return &nmp.MempoolStatReq{} }
+func dtReadReqCtor() nmp.NmpReq   { return &nmp.DateTimeReadReq{} }
+func dtWriteReqCtor() nmp.NmpReq  { return &nmp.DateTimeWriteReq{} }
+func resetReqCtor() nmp.NmpReq    { return &nmp.ResetReq{} }
+
+func (d *Device) initDefault() {
+	d.tasks["idle"] = map[string]int{
+		"prio":   255,
+		"tid":    0,
+		"state":  1,
+		"stksiz": 64,
+	}
+	d.tasks["main"] = map[string]int{
+		"prio":   127,
+		"tid":    1,
+		"state":  1,
+		"stksiz": 1024,
+	}
+
+	d.mpools["msys_1"] = map[string]int{
+		"blksiz": 292,
+		"nblks":  12,
+		"nfree":  12,
+		"min":    12,
+	}
+}
+
+func echo(d *Device, req nmp.NmpReq) nmp.NmpRsp {
+	r := req.(*nmp.EchoReq)
+
+	rsp := nmp.NewEchoRsp()
+	rsp.Payload = r.Payload
+	return rsp
+}
+
+func taskStat(d *Device, req nmp.NmpReq) nmp.NmpRsp {
+	uptime := int(time.Since(d.bootTime) / time.Millisecond)
+
+	rsp := nmp.NewTaskStatRsp()
+	rsp.Tasks = map[string]map[string]int{}
+	for name, t := range d.tasks {
+		// Pretend the main task handles every request and the idle task
+		// gets the rest of the CPU.
+		t["cswcnt"]++
+		if name == "main" {
+			t["runtime"] = uptime / 10
+			t["stkuse"] = t["stksiz"] / 4
+		} else {
+			t["runtime"] = uptime - uptime/10
+			t["stkuse"] = t["stksiz"] / 2
+		}
+		t["last_checkin"] = 0
+		t["next_checkin"] = 0
+
+		cp := map[string]int{}
+		for k, v := range t {
+			cp[k] = v
+		}
+		rsp.Tasks[name] = cp
+	}
+
+	return rsp
+}
+
+func mpStat(d *Device, req nmp.NmpReq) nmp.NmpRsp {
+	rsp := nmp.NewMempoolStatRsp()
+	rsp.Mpools = map[string]map[string]int{}
+	for name, mp := range d.mpools {
+		cp := map[string]int{}
+		for k, v := range mp {
+			cp[k] = v
+		}
+		rsp.Mpools[name] = cp
+	}
+
+	return rsp
+}
+
+func dtRead(d *Device, req nmp.NmpReq) nmp.NmpRsp {
+	rsp := nmp.NewDateTimeReadRsp()
+	rsp.DateTime = d.now().Format(DATETIME_FMT)
+	return rsp
+}
+
+func dtWrite(d *Device, req nmp.NmpReq) nmp.NmpRsp {
+	r := req.(*nmp.DateTimeWriteReq)
+
+	rsp := nmp.NewDateTimeWriteRsp()
+
+	t, err := time.Parse(time.RFC3339, r.DateTime)
+	if err != nil {
+		rsp.Rc = nmp.NMP_ERR_EINVAL
+		return rsp
+	}
+
+	d.dtOffset = time.Until(t)
+	return rsp
+}
+
+func reset(d *Device, req nmp.NmpReq) nmp.NmpRsp {
+	// The response goes out before the device goes down.  Since the
+	// emulator has nothing to flush, just reboot right away.
+	d.reboot("SOFT")
+
+	return nmp.NewResetRsp()
+}
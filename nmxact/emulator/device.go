@@ -0,0 +1,301 @@
+/**
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+// Package emulator implements the device side of the newtmgr protocol.  An
+// emulated device keeps all of its state (image slots, file system, config,
+// logs, stats, ...) in memory and answers NMP requests the way a Mynewt
+// device running the newtmgr server would.  It is reachable through a
+// loopback transport (EmuXport) so that nmxact commands can be exercised
+// without real hardware.
+package emulator
+
+import (
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/ugorji/go/codec"
+
+	"mynewt.apache.org/newtmgr/nmxact/nmp"
+)
+
+type reqCtor func() nmp.NmpReq
+type handlerFn func(d *Device, req nmp.NmpReq) nmp.NmpRsp
+
+type handler struct {
+	ctor reqCtor
+	fn   handlerFn
+}
+
+// These aliases just keep the handler map readable.
+const op_rd = nmp.NMP_OP_READ
+const op_wr = nmp.NMP_OP_WRITE
+const gr_def = nmp.NMP_GROUP_DEFAULT
+const gr_img = nmp.NMP_GROUP_IMAGE
+const gr_sta = nmp.NMP_GROUP_STAT
+const gr_cfg = nmp.NMP_GROUP_CONFIG
+const gr_log = nmp.NMP_GROUP_LOG
+const gr_cra = nmp.NMP_GROUP_CRASH
+const gr_run = nmp.NMP_GROUP_RUN
+const gr_fil = nmp.NMP_GROUP_FS
+const gr_she = nmp.NMP_GROUP_SHELL
+
+type ogi struct {
+	op    uint8
+	group uint16
+	id    uint8
+}
+
+var handlerMap = map[ogi]handler{
+	{op_wr, gr_def, nmp.NMP_ID_DEF_ECHO}:         {echoReqCtor, echo},
+	{op_rd, gr_def, nmp.NMP_ID_DEF_TASKSTAT}:     {taskStatReqCtor, taskStat},
+	{op_rd, gr_def, nmp.NMP_ID_DEF_MPSTAT}:       {mpStatReqCtor, mpStat},
+	{op_rd, gr_def, nmp.NMP_ID_DEF_DATETIME_STR}: {dtReadReqCtor, dtRead},
+	{op_wr, gr_def, nmp.NMP_ID_DEF_DATETIME_STR}: {dtWriteReqCtor, dtWrite},
+	{op_wr, gr_def, nmp.NMP_ID_DEF_RESET}:        {resetReqCtor, reset},
+	{op_wr, gr_img, nmp.NMP_ID_IMAGE_UPLOAD}:     {imgUploadReqCtor, imgUpload},
+	{op_rd, gr_img, nmp.NMP_ID_IMAGE_STATE}:      {imgStateReadReqCtor, imgStateRead},
+	{op_wr, gr_img, nmp.NMP_ID_IMAGE_STATE}:      {imgStateWriteReqCtor, imgStateWrite},
+	{op_rd, gr_img, nmp.NMP_ID_IMAGE_CORELIST}:   {coreListReqCtor, coreList},
+	{op_rd, gr_img, nmp.NMP_ID_IMAGE_CORELOAD}:   {coreLoadReqCtor, coreLoad},
+	{op_wr, gr_img, nmp.NMP_ID_IMAGE_CORELOAD}:   {coreEraseReqCtor, coreErase},
+	{op_wr, gr_img, nmp.NMP_ID_IMAGE_ERASE}:      {imgEraseReqCtor, imgErase},
+	{op_rd, gr_sta, nmp.NMP_ID_STAT_READ}:        {statReadReqCtor, statRead},
+	{op_rd, gr_sta, nmp.NMP_ID_STAT_LIST}:        {statListReqCtor, statList},
+	{op_rd, gr_cfg, nmp.NMP_ID_CONFIG_VAL}:       {cfgReadReqCtor, cfgRead},
+	{op_wr, gr_cfg, nmp.NMP_ID_CONFIG_VAL}:       {cfgWriteReqCtor, cfgWrite},
+	{op_rd, gr_log, nmp.NMP_ID_LOG_SHOW}:         {logShowReqCtor, logShow},
+	{op_rd, gr_log, nmp.NMP_ID_LOG_LIST}:         {logListReqCtor, logList},
+	{op_rd, gr_log, nmp.NMP_ID_LOG_MODULE_LIST}:  {logModListReqCtor, logModList},
+	{op_rd, gr_log, nmp.NMP_ID_LOG_LEVEL_LIST}:   {logLvlListReqCtor, logLvlList},
+	{op_wr, gr_log, nmp.NMP_ID_LOG_CLEAR}:        {logClearReqCtor, logClear},
+	{op_wr, gr_cra, nmp.NMP_ID_CRASH_TRIGGER}:    {crashReqCtor, crash},
+	{op_wr, gr_run, nmp.NMP_ID_RUN_TEST}:         {runTestReqCtor, runTest},
+	{op_rd, gr_run, nmp.NMP_ID_RUN_LIST}:         {runListReqCtor, runList},
+	{op_rd, gr_fil, nmp.NMP_ID_FS_FILE}:          {fsDownloadReqCtor, fsDownload},
+	{op_wr, gr_fil, nmp.NMP_ID_FS_FILE}:          {fsUploadReqCtor, fsUpload},
+	{op_wr, gr_she, nmp.NMP_ID_SHELL_EXEC}:       {shellExecReqCtor, shellExec},
+}
+
+type ShellCmdFn func(argv []string) (string, int)
+
+type Device struct {
+	mtx sync.Mutex
+
+	bootTime   time.Time
+	dtOffset   time.Duration
+	resetCount int
+
+	images []*imageSlot
+	upload *imageUpload
+	core   []byte
+
+	stats   map[string]map[string]int64
+	config  map[string]string
+	saved   map[string]string
+	logs    map[string]*devLog
+	nextIdx uint32
+	files   map[string][]byte
+	tests   []string
+	shell   map[string]ShellCmdFn
+
+	tasks  map[string]map[string]int
+	mpools map[string]map[string]int
+
+	latency time.Duration
+	mtu     int
+	faults  map[faultKey]*fault
+}
+
+// Creates an emulated device.  The device comes up with a confirmed image in
+// the primary slot, a handful of stats, config keys, and logs, and an empty
+// file system.
+func NewDevice() *Device {
+	d := &Device{
+		bootTime: time.Now(),
+		stats:    map[string]map[string]int64{},
+		config:   map[string]string{},
+		saved:    map[string]string{},
+		logs:     map[string]*devLog{},
+		files:    map[string][]byte{},
+		shell:    map[string]ShellCmdFn{},
+		tasks:    map[string]map[string]int{},
+		mpools:   map[string]map[string]int{},
+		faults:   map[faultKey]*fault{},
+	}
+
+	d.initImages()
+	d.initStats()
+	d.initConfig()
+	d.initLogs()
+	d.initTests()
+	d.initShell()
+	d.initDefault()
+
+	return d
+}
+
+// Handles a decoded request and builds the response message.  A nil
+// message indicates that the device does not reply to the request.
+func (d *Device) handleReq(hdr *nmp.NmpHdr, body []byte) *nmp.NmpMsg {
+	d.mtx.Lock()
+	defer d.mtx.Unlock()
+
+	d.incStat("nmgr", "rx_reqs")
+
+	rspHdr := *hdr
+	rspHdr.Op++
+	rspHdr.Flags = 0
+
+	if rc, ok := d.injectedRc(hdr); ok {
+		d.incStat("nmgr", "tx_rsps")
+		return rcRspMsg(rspHdr, rc)
+	}
+
+	h, ok := handlerMap[ogi{hdr.Op, hdr.Group, hdr.Id}]
+	if !ok {
+		d.incStat("nmgr", "rx_errs")
+		return rcRspMsg(rspHdr, nmp.NMP_ERR_ENOTSUP)
+	}
+
+	req := h.ctor()
+	dec := codec.NewDecoderBytes(body, new(codec.CborHandle))
+	if err := dec.Decode(req); err != nil {
+		d.incStat("nmgr", "rx_errs")
+		return rcRspMsg(rspHdr, nmp.NMP_ERR_EINVAL)
+	}
+
+	rsp := h.fn(d, req)
+	if rsp == nil {
+		return nil
+	}
+	rsp.SetHdr(&rspHdr)
+
+	d.incStat("nmgr", "tx_rsps")
+	return rsp.Msg()
+}
+
+// Processes a single, complete NMP request and returns the encoded NMP
+// response.  A nil response with a nil error indicates that the device does
+// not reply to the request.
+func (d *Device) Process(data []byte) ([]byte, error) {
+	hdr, err := nmp.DecodeNmpHdr(data)
+	if err != nil {
+		return nil, err
+	}
+
+	body := data[nmp.NMP_HDR_SIZE:]
+	if len(body) != int(hdr.Len) {
+		return nil, fmt.Errorf("NMP length mismatch; hdr.len=%d actual=%d",
+			hdr.Len, len(body))
+	}
+
+	log.Debugf("Emulator rx request: %s", hex.Dump(data))
+
+	if !d.admit(data) {
+		return nil, nil
+	}
+
+	m := d.handleReq(hdr, body)
+	if m == nil {
+		return nil, nil
+	}
+
+	return nmp.EncodeNmpPlain(m)
+}
+
+// Processes a request in either plain NMP or OMP (CoAP datagram) form.  The
+// form is inferred from the first byte: a CoAP datagram always starts with
+// version 1, while the NMP op field never has its upper bits set.
+func (d *Device) ProcessAny(data []byte) ([]byte, error) {
+	if len(data) > 0 && data[0]>>6 == 1 {
+		return d.ProcessOmp(data)
+	} else {
+		return d.Process(data)
+	}
+}
+
+// Builds a response consisting of nothing but a status code.  Used for
+// requests that the device does not understand and for injected errors.
+func rcRspMsg(hdr nmp.NmpHdr, rc int) *nmp.NmpMsg {
+	return &nmp.NmpMsg{
+		Hdr:  hdr,
+		Body: map[string]int{"rc": rc},
+	}
+}
+
+// Current time according to the device's clock.
+func (d *Device) now() time.Time {
+	return time.Now().Add(d.dtOffset)
+}
+
+// Simulates a device reboot.  Volatile state is lost; a pending image gets
+// swapped into the primary slot.
+func (d *Device) reboot(reason string) {
+	d.resetCount++
+	d.bootTime = time.Now()
+	d.upload = nil
+
+	d.swapImages()
+
+	// As in Mynewt, the log index carries on from the last entry that
+	// survived the reboot, so it starts over if the logs were erased.
+	d.nextIdx = 0
+	for _, l := range d.logs {
+		if l.typ == nmp.MEMORY_LOG {
+			l.entries = nil
+		}
+		if n := len(l.entries); n > 0 && l.entries[n-1].Index >= d.nextIdx {
+			d.nextIdx = l.entries[n-1].Index + 1
+		}
+	}
+	for name, _ := range d.stats {
+		if name != "nmgr" {
+			for field, _ := range d.stats[name] {
+				d.stats[name][field] = 0
+			}
+		}
+	}
+
+	d.appendLog("reboot_log", uint8(nmp.MODULE_REBOOT), uint8(nmp.LEVEL_CRITICAL),
+		nmp.LOG_ENTRY_TYPE_STRING,
+		[]byte(fmt.Sprintf("rsn:%s, cnt:%d, img:%s", reason, d.resetCount,
+			d.activeVersion())))
+}
+
+// Retrieves the number of times the device has rebooted.
+func (d *Device) ResetCount() int {
+	d.mtx.Lock()
+	defer d.mtx.Unlock()
+
+	return d.resetCount
+}
+
+func sortedKeys(m map[string]map[string]int64) []string {
+	names := make([]string, 0, len(m))
+	for k, _ := range m {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+
+	return names
+}
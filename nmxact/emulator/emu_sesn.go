@@ -0,0 +1,176 @@
+/**
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package emulator
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/runtimeco/go-coap"
+
+	"mynewt.apache.org/newtmgr/nmxact/mgmt"
+	"mynewt.apache.org/newtmgr/nmxact/nmcoap"
+	"mynewt.apache.org/newtmgr/nmxact/nmp"
+	"mynewt.apache.org/newtmgr/nmxact/nmxutil"
+	"mynewt.apache.org/newtmgr/nmxact/sesn"
+)
+
+type EmuSesn struct {
+	cfg    sesn.SesnCfg
+	ex     *EmuXport
+	txvr   *mgmt.Transceiver
+	isOpen bool
+
+	// Responses are dispatched one at a time, as they would be by a real
+	// transport's receive loop.
+	rxMtx sync.Mutex
+}
+
+func NewEmuSesn(ex *EmuXport, cfg sesn.SesnCfg) (*EmuSesn, error) {
+	if cfg.MgmtProto != sesn.MGMT_PROTO_NMP {
+		return nil, fmt.Errorf(
+			"Emulator only supports plain NMP; proto=%d", cfg.MgmtProto)
+	}
+
+	s := &EmuSesn{
+		cfg: cfg,
+		ex:  ex,
+	}
+
+	return s, nil
+}
+
+func (s *EmuSesn) Open() error {
+	if s.isOpen {
+		return nmxutil.NewSesnAlreadyOpenError(
+			"Attempt to open an already-open emulator session")
+	}
+
+	txvr, err := mgmt.NewTransceiver(s.cfg.TxFilterCb, s.cfg.RxFilterCb,
+		false, s.cfg.MgmtProto, 3)
+	if err != nil {
+		return err
+	}
+
+	s.txvr = txvr
+	s.isOpen = true
+	return nil
+}
+
+func (s *EmuSesn) Close() error {
+	if !s.isOpen {
+		return nmxutil.NewSesnClosedError(
+			"Attempt to close an unopened emulator session")
+	}
+
+	s.txvr.ErrorAll(fmt.Errorf("closed"))
+	s.txvr.Stop()
+	s.isOpen = false
+	return nil
+}
+
+func (s *EmuSesn) IsOpen() bool {
+	return s.isOpen
+}
+
+func (s *EmuSesn) MtuIn() int {
+	return s.ex.cfg.Mtu
+}
+
+func (s *EmuSesn) MtuOut() int {
+	return s.ex.cfg.Mtu
+}
+
+// Delivers a request to the device and dispatches the device's response.
+func (s *EmuSesn) txRaw(b []byte) error {
+	rsp, err := s.ex.dev.Process(b)
+	if err != nil {
+		return err
+	}
+
+	if rsp != nil {
+		s.rxMtx.Lock()
+		s.txvr.DispatchNmpRsp(rsp)
+		s.rxMtx.Unlock()
+	}
+	return nil
+}
+
+func (s *EmuSesn) TxRxMgmt(m *nmp.NmpMsg,
+	timeout time.Duration) (nmp.NmpRsp, error) {
+
+	return s.TxRxMgmtContext(context.Background(), m, timeout)
+}
+
+func (s *EmuSesn) TxRxMgmtContext(ctx context.Context, m *nmp.NmpMsg,
+	timeout time.Duration) (nmp.NmpRsp, error) {
+
+	if !s.IsOpen() {
+		return nil, nmxutil.NewSesnClosedError(
+			"Attempt to transmit over closed emulator session")
+	}
+
+	return s.txvr.TxRxMgmtContext(ctx, s.txRaw, m, s.MtuOut(), timeout)
+}
+
+func (s *EmuSesn) AbortRx(seq uint8) error {
+	s.txvr.AbortRx(seq)
+	return nil
+}
+
+func (s *EmuSesn) TxCoap(m coap.Message) error {
+	return fmt.Errorf("Op not implemented yet")
+}
+
+func (s *EmuSesn) MgmtProto() sesn.MgmtProto {
+	return s.cfg.MgmtProto
+}
+
+func (s *EmuSesn) ListenCoap(mc nmcoap.MsgCriteria) (*nmcoap.Listener, error) {
+	return nil, fmt.Errorf("Op not implemented yet")
+}
+
+func (s *EmuSesn) StopListenCoap(mc nmcoap.MsgCriteria) {
+}
+
+func (s *EmuSesn) CoapIsTcp() bool {
+	return false
+}
+
+func (s *EmuSesn) RxAccept() (sesn.Sesn, *sesn.SesnCfg, error) {
+	return nil, nil, fmt.Errorf("Op not implemented yet")
+}
+
+func (s *EmuSesn) RxCoap(opt sesn.TxOptions) (coap.Message, error) {
+	return nil, fmt.Errorf("Op not implemented yet")
+}
+
+func (s *EmuSesn) Filters() (nmcoap.MsgFilter, nmcoap.MsgFilter) {
+	return s.cfg.TxFilterCb, s.cfg.RxFilterCb
+}
+
+func (s *EmuSesn) SetFilters(txFilter nmcoap.MsgFilter,
+	rxFilter nmcoap.MsgFilter) {
+
+	s.cfg.TxFilterCb = txFilter
+	s.cfg.RxFilterCb = rxFilter
+}
@@ -0,0 +1,81 @@
+/**
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package emulator
+
+import (
+	"fmt"
+
+	"mynewt.apache.org/newtmgr/nmxact/nmxutil"
+	"mynewt.apache.org/newtmgr/nmxact/sesn"
+)
+
+type XportCfg struct {
+	Mtu int
+}
+
+func NewXportCfg() *XportCfg {
+	return &XportCfg{
+		Mtu: 512,
+	}
+}
+
+// EmuXport is a loopback transport: everything a session transmits is
+// handed directly to an emulated device, and the device's responses are fed
+// straight back to the session.
+type EmuXport struct {
+	cfg     *XportCfg
+	dev     *Device
+	started bool
+}
+
+func NewEmuXport(cfg *XportCfg, dev *Device) *EmuXport {
+	return &EmuXport{
+		cfg: cfg,
+		dev: dev,
+	}
+}
+
+func (ex *EmuXport) Device() *Device {
+	return ex.dev
+}
+
+func (ex *EmuXport) BuildSesn(cfg sesn.SesnCfg) (sesn.Sesn, error) {
+	return NewEmuSesn(ex, cfg)
+}
+
+func (ex *EmuXport) Start() error {
+	if ex.started {
+		return nmxutil.NewXportError("Emulator xport started twice")
+	}
+	ex.started = true
+	return nil
+}
+
+func (ex *EmuXport) Stop() error {
+	if !ex.started {
+		return nmxutil.NewXportError("Emulator xport stopped twice")
+	}
+	ex.started = false
+	return nil
+}
+
+func (ex *EmuXport) Tx(bytes []byte) error {
+	return fmt.Errorf("unsupported")
+}
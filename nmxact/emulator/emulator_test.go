@@ -0,0 +1,971 @@
+/**
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package emulator
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	crand "crypto/rand"
+	"crypto/sha256"
+	"errors"
+	"math/rand"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"mynewt.apache.org/newtmgr/nmxact/mcuboot"
+	"mynewt.apache.org/newtmgr/nmxact/nmp"
+	"mynewt.apache.org/newtmgr/nmxact/nmxutil"
+	"mynewt.apache.org/newtmgr/nmxact/sesn"
+	"mynewt.apache.org/newtmgr/nmxact/xact"
+)
+
+func newTestSesn(t *testing.T) (*Device, sesn.Sesn) {
+	dev := NewDevice()
+	x := NewEmuXport(NewXportCfg(), dev)
+	if err := x.Start(); err != nil {
+		t.Fatalf("xport start: %v", err)
+	}
+
+	sc := sesn.NewSesnCfg()
+	sc.MgmtProto = sesn.MGMT_PROTO_NMP
+
+	s, err := x.BuildSesn(sc)
+	if err != nil {
+		t.Fatalf("build sesn: %v", err)
+	}
+	if err := s.Open(); err != nil {
+		t.Fatalf("open sesn: %v", err)
+	}
+
+	t.Cleanup(func() {
+		s.Close()
+		x.Stop()
+	})
+
+	return dev, s
+}
+
+func runCmd(t *testing.T, s sesn.Sesn, c xact.Cmd) xact.Result {
+	t.Helper()
+
+	res, err := c.Run(s)
+	if err != nil {
+		t.Fatalf("%T failed: %v", c, err)
+	}
+
+	return res
+}
+
+// Runs a command that the device is expected to reject with the specified
+// status code.
+func runCmdRc(t *testing.T, s sesn.Sesn, c xact.Cmd, rc int) xact.Result {
+	t.Helper()
+
+	res, err := c.Run(s)
+	var nerr *nmp.NmpError
+	if !errors.As(err, &nerr) || nerr.Rc != rc {
+		t.Fatalf("%T: want %s, got %v", c, nmp.RcToString(rc), err)
+	}
+	if !errors.Is(err, nmp.Rc(rc)) {
+		t.Fatalf("%T: errors.Is(%v, %s) is false", c, err, nmp.RcToString(rc))
+	}
+	if res == nil || res.Status() != rc {
+		t.Fatalf("%T: result missing or has wrong status: %v", c, res)
+	}
+
+	return res
+}
+
+func imageState(t *testing.T, s sesn.Sesn) *nmp.ImageStateRsp {
+	t.Helper()
+
+	res := runCmd(t, s, xact.NewImageStateReadCmd())
+	return res.(*xact.ImageStateReadResult).Rsp
+}
+
+func TestEcho(t *testing.T) {
+	_, s := newTestSesn(t)
+
+	c := xact.NewEchoCmd()
+	c.Payload = "hello"
+	res := runCmd(t, s, c).(*xact.EchoResult)
+
+	if res.Rsp.Payload != "hello" {
+		t.Errorf("echo: want %q, got %q", "hello", res.Rsp.Payload)
+	}
+}
+
+func TestDefaultGroup(t *testing.T) {
+	_, s := newTestSesn(t)
+
+	tres := runCmd(t, s, xact.NewTaskStatCmd()).(*xact.TaskStatResult)
+	if _, ok := tres.Rsp.Tasks["main"]; !ok {
+		t.Errorf("taskstat: main task missing: %+v", tres.Rsp.Tasks)
+	}
+
+	mres := runCmd(t, s, xact.NewMempoolStatCmd()).(*xact.MempoolStatResult)
+	if len(mres.Rsp.Mpools) == 0 {
+		t.Errorf("mpstat: no pools")
+	}
+
+	wc := xact.NewDateTimeWriteCmd()
+	wc.DateTime = "2020-01-02T03:04:05Z"
+	if st := runCmd(t, s, wc).Status(); st != 0 {
+		t.Errorf("datetime write: rc=%d", st)
+	}
+
+	rres := runCmd(t, s, xact.NewDateTimeReadCmd()).(*xact.DateTimeReadResult)
+	if !strings.HasPrefix(rres.Rsp.DateTime, "2020-01-02T") {
+		t.Errorf("datetime read: got %s", rres.Rsp.DateTime)
+	}
+}
+
+func TestImageUpgrade(t *testing.T) {
+	dev, s := newTestSesn(t)
+
+	img := BuildImage(ImageVersion{Major: 2, Minor: 1},
+		bytes.Repeat([]byte("abcdefgh"), 600))
+	_, hash := parseImage(img)
+	if sum := sha256.Sum256(img[:len(img)-40]); !bytes.Equal(sum[:], hash) {
+		t.Fatalf("image hash mismatch")
+	}
+
+	uc := xact.NewImageUpgradeCmd()
+	uc.Data = img
+	uc.ProgressCb = func(c *xact.ImageUploadCmd, r *nmp.ImageUploadRsp) {}
+	if st := runCmd(t, s, uc).Status(); st != 0 {
+		t.Fatalf("upload: rc=%d", st)
+	}
+
+	if !bytes.Equal(dev.ImageHash(0, 1), hash) {
+		t.Fatalf("uploaded image not in secondary slot")
+	}
+
+	wc := xact.NewImageStateWriteCmd()
+	wc.Hash = hash
+	if st := runCmd(t, s, wc).Status(); st != 0 {
+		t.Fatalf("image test: rc=%d", st)
+	}
+	st := imageState(t, s)
+	if len(st.Images) != 2 || !st.Images[1].Pending {
+		t.Fatalf("image not pending: %+v", st.Images)
+	}
+
+	runCmd(t, s, xact.NewResetCmd())
+
+	st = imageState(t, s)
+	if !bytes.Equal(st.Images[0].Hash, hash) || st.Images[0].Confirmed ||
+		st.Images[0].Version != "2.1.0" {
+
+		t.Fatalf("new image not running in test mode: %+v", st.Images[0])
+	}
+
+	// Not confirmed; the next reset reverts to the original image.
+	runCmd(t, s, xact.NewResetCmd())
+	st = imageState(t, s)
+	if bytes.Equal(st.Images[0].Hash, hash) || !st.Images[0].Confirmed {
+		t.Fatalf("image didn't revert: %+v", st.Images[0])
+	}
+	if dev.ResetCount() != 2 {
+		t.Errorf("reset count: want 2, got %d", dev.ResetCount())
+	}
+
+	// Test again, this time confirming the new image.
+	runCmd(t, s, wc)
+	runCmd(t, s, xact.NewResetCmd())
+	cc := xact.NewImageStateWriteCmd()
+	cc.Confirm = true
+	runCmd(t, s, cc)
+	runCmd(t, s, xact.NewResetCmd())
+
+	st = imageState(t, s)
+	if !bytes.Equal(st.Images[0].Hash, hash) || !st.Images[0].Confirmed {
+		t.Fatalf("new image not confirmed: %+v", st.Images[0])
+	}
+}
+
+func TestImageErase(t *testing.T) {
+	dev, s := newTestSesn(t)
+
+	var hashes [2][]byte
+	for i := range hashes {
+		img := BuildImage(ImageVersion{Major: 2, Minor: uint8(i)},
+			bytes.Repeat([]byte{byte(i)}, 1000))
+		_, hashes[i] = parseImage(img)
+
+		// Each upload erases only its own image's secondary slot first.
+		uc := xact.NewImageUpgradeCmd()
+		uc.Data = img
+		uc.ImageNum = i
+		uc.ProgressCb = func(c *xact.ImageUploadCmd,
+			r *nmp.ImageUploadRsp) {
+		}
+		runCmd(t, s, uc)
+	}
+	for i, hash := range hashes {
+		if !bytes.Equal(dev.ImageHash(i, 1), hash) {
+			t.Fatalf("image %d not in its secondary slot", i)
+		}
+	}
+
+	ec := xact.NewImageEraseCmd()
+	ec.ImageNum = 1
+	runCmd(t, s, ec)
+	if dev.ImageHash(1, 1) != nil {
+		t.Errorf("image 1 not erased")
+	}
+	if !bytes.Equal(dev.ImageHash(0, 1), hashes[0]) {
+		t.Errorf("image 0 erased instead of image 1")
+	}
+
+	runCmd(t, s, xact.NewImageEraseCmd())
+	if dev.ImageHash(0, 1) != nil {
+		t.Errorf("image 0 not erased")
+	}
+
+	// Primary slots can't be erased.
+	r := nmp.NewImageEraseReq()
+	r.Slot = 2
+	rsp, err := s.TxRxMgmt(r.Msg(), time.Second)
+	if err != nil {
+		t.Fatalf("erase primary slot: %v", err)
+	}
+	if rc := rsp.(*nmp.ImageEraseRsp).Rc; rc != nmp.NMP_ERR_EINVAL {
+		t.Errorf("erase primary slot: want EINVAL, got rc=%d", rc)
+	}
+}
+
+func TestImageUpgradeSigCheck(t *testing.T) {
+	dev, s := newTestSesn(t)
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), crand.Reader)
+	if err != nil {
+		t.Fatalf("keygen: %v", err)
+	}
+
+	// The image isn't signed, so nothing gets sent to the device.
+	uc := xact.NewImageUpgradeCmd()
+	uc.Data = BuildImage(ImageVersion{Major: 2}, make([]byte, 1000))
+	uc.SigKey = key.Public()
+	if _, err := uc.Run(s); err == nil ||
+		!strings.Contains(err.Error(), "signature") {
+
+		t.Fatalf("unsigned image: want signature error, got %v", err)
+	}
+	if dev.ImageHash(0, 1) != nil {
+		t.Fatalf("unsigned image was uploaded")
+	}
+}
+
+func TestImageUpgradeSkip(t *testing.T) {
+	dev, s := newTestSesn(t)
+
+	img := BuildImage(ImageVersion{Major: 2}, make([]byte, 1000))
+	_, hash := parseImage(img)
+
+	uc := xact.NewImageUpgradeCmd()
+	uc.Data = img
+	uc.ProgressCb = func(c *xact.ImageUploadCmd, r *nmp.ImageUploadRsp) {}
+	res := runCmd(t, s, uc).(*xact.ImageUpgradeResult)
+	if res.Skipped || res.Status() != 0 {
+		t.Fatalf("first upload: skipped=%v rc=%d", res.Skipped, res.Status())
+	}
+
+	// The secondary slot now holds the image; a second upload is a no-op.
+	res = runCmd(t, s, uc).(*xact.ImageUpgradeResult)
+	if !res.Skipped || res.PresentSlot != 1 || res.UploadRes != nil {
+		t.Fatalf("second upload not skipped: %+v", res)
+	}
+
+	uc.ForceUpload = true
+	res = runCmd(t, s, uc).(*xact.ImageUpgradeResult)
+	if res.Skipped || res.Status() != 0 {
+		t.Fatalf("forced upload: skipped=%v rc=%d", res.Skipped, res.Status())
+	}
+	if !bytes.Equal(dev.ImageHash(0, 1), hash) {
+		t.Fatalf("uploaded image not in secondary slot")
+	}
+}
+
+func TestImageDeploy(t *testing.T) {
+	dev, s := newTestSesn(t)
+	dev.SetStat("app", "ticks", 42)
+
+	img := BuildImage(ImageVersion{Major: 4}, make([]byte, 2000))
+	_, hash := parseImage(img)
+
+	statOk, err := xact.ParseStatHealthCheck("nmgr.rx_errs==0")
+	if err != nil {
+		t.Fatalf("parse stat check: %v", err)
+	}
+
+	var stages []string
+	dc := xact.NewImageDeployCmd()
+	dc.Data = img
+	dc.ResetDelay = 0
+	dc.ReconnectTimeout = time.Second
+	dc.HealthChecks = []xact.HealthCheck{
+		&xact.EchoHealthCheck{Payload: "ok"},
+		statOk,
+	}
+	dc.StageCb = func(c *xact.ImageDeployCmd, stage xact.DeployStage) {
+		stages = append(stages, stage.String())
+	}
+
+	res := runCmd(t, s, dc).(*xact.ImageDeployResult)
+	if !res.Confirmed {
+		t.Fatalf("image not confirmed")
+	}
+	want := "upload test reset reconnect verify health confirm"
+	if got := strings.Join(stages, " "); got != want {
+		t.Errorf("stages: want %q, got %q", want, got)
+	}
+
+	st := imageState(t, s)
+	if !bytes.Equal(st.Images[0].Hash, hash) || !st.Images[0].Confirmed {
+		t.Fatalf("deployed image not confirmed: %+v", st.Images[0])
+	}
+
+	// A failing health check leaves the old image running.  Stats are
+	// cleared by the reset, so app.ticks is 0 when the check runs.
+	statBad, err := xact.ParseStatHealthCheck("app.ticks >= 1")
+	if err != nil {
+		t.Fatalf("parse stat check: %v", err)
+	}
+
+	img2 := BuildImage(ImageVersion{Major: 5}, make([]byte, 2000))
+	dc = xact.NewImageDeployCmd()
+	dc.Data = img2
+	dc.ResetDelay = 0
+	dc.ReconnectTimeout = time.Second
+	dc.HealthChecks = []xact.HealthCheck{statBad}
+	if _, err := dc.Run(s); err == nil ||
+		!strings.Contains(err.Error(), "app.ticks>=1") {
+
+		t.Fatalf("failing health check: want error, got %v", err)
+	}
+
+	st = imageState(t, s)
+	if !bytes.Equal(st.Images[0].Hash, hash) || !st.Images[0].Confirmed {
+		t.Fatalf("device didn't revert: %+v", st.Images[0])
+	}
+}
+
+func TestImageUploadResume(t *testing.T) {
+	dev, s := newTestSesn(t)
+
+	img := BuildImage(ImageVersion{Major: 3}, bytes.Repeat([]byte{1}, 4000))
+
+	// Upload part of the image, then abort.
+	var lastOff uint32
+	uc := xact.NewImageUploadCmd()
+	uc.Data = img
+	uc.ProgressCb = func(c *xact.ImageUploadCmd, r *nmp.ImageUploadRsp) {
+		lastOff = r.Off
+		if r.Off > 1000 {
+			c.Abort()
+		}
+	}
+	if _, err := uc.Run(s); err == nil {
+		t.Fatalf("aborted upload succeeded")
+	}
+
+	// Starting over from offset 0 picks up where the device left off.
+	var offs []uint32
+	uc = xact.NewImageUploadCmd()
+	uc.Data = img
+	uc.ProgressCb = func(c *xact.ImageUploadCmd, r *nmp.ImageUploadRsp) {
+		offs = append(offs, r.Off)
+	}
+	res := runCmd(t, s, uc).(*xact.ImageUploadResult)
+	if res.Status() != 0 || offs[0] != lastOff ||
+		int(offs[len(offs)-1]) != len(img) {
+
+		t.Fatalf("upload didn't resume: last=%d offs=%v", lastOff, offs)
+	}
+
+	_, hash := parseImage(img)
+	if !bytes.Equal(dev.ImageHash(0, 1), hash) {
+		t.Fatalf("uploaded image hash mismatch")
+	}
+}
+
+func TestImageUploadProbe(t *testing.T) {
+	dev, s := newTestSesn(t)
+
+	img := BuildImage(ImageVersion{Major: 3}, bytes.Repeat([]byte{2}, 6000))
+	_, hash := parseImage(img)
+
+	// Nothing to resume; the probe reports offset 0.
+	var offs []uint32
+	uc := xact.NewImageUploadCmd()
+	uc.Data = img
+	uc.Resume = true
+	uc.ProgressCb = func(c *xact.ImageUploadCmd, r *nmp.ImageUploadRsp) {
+		offs = append(offs, r.Off)
+		if r.Off > 2000 {
+			c.Abort()
+		}
+	}
+	if _, err := uc.Run(s); err == nil {
+		t.Fatalf("aborted upload succeeded")
+	}
+	if offs[0] != 0 {
+		t.Fatalf("probe of new upload: want off=0, got %d", offs[0])
+	}
+	lastOff := offs[len(offs)-1]
+
+	// The probe reports how far the interrupted upload got.
+	offs = nil
+	uc = xact.NewImageUploadCmd()
+	uc.Data = img
+	uc.Resume = true
+	uc.Window = 3
+	uc.ProgressCb = func(c *xact.ImageUploadCmd, r *nmp.ImageUploadRsp) {
+		offs = append(offs, r.Off)
+	}
+	res := runCmd(t, s, uc)
+	if res.Status() != 0 || offs[0] != lastOff ||
+		int(offs[len(offs)-1]) != len(img) {
+
+		t.Fatalf("upload didn't resume: last=%d offs=%v", lastOff, offs)
+	}
+	if !bytes.Equal(dev.ImageHash(0, 1), hash) {
+		t.Fatalf("uploaded image hash mismatch")
+	}
+}
+
+// Device that can't resume uploads: like Mynewt's image manager, it rejects a
+// first chunk too short to contain the image header.
+func TestImageUploadProbeRejected(t *testing.T) {
+	dev, s := newTestSesn(t)
+
+	key := ogi{op_wr, gr_img, nmp.NMP_ID_IMAGE_UPLOAD}
+	orig := handlerMap[key]
+	probes := 0
+	handlerMap[key] = handler{orig.ctor,
+		func(d *Device, req nmp.NmpReq) nmp.NmpRsp {
+			r := req.(*nmp.ImageUploadReq)
+			if r.Off == 0 && len(r.Data) < mcuboot.IMAGE_HEADER_SIZE {
+				probes++
+				rsp := nmp.NewImageUploadRsp()
+				rsp.Rc = nmp.NMP_ERR_EINVAL
+				return rsp
+			}
+			return orig.fn(d, req)
+		}}
+	t.Cleanup(func() { handlerMap[key] = orig })
+
+	img := BuildImage(ImageVersion{Major: 3}, bytes.Repeat([]byte{4}, 3000))
+	_, hash := parseImage(img)
+
+	for _, window := range []int{1, 3} {
+		dev.setSlot(0, 1, nil)
+		probes = 0
+
+		var offs []uint32
+		uc := xact.NewImageUploadCmd()
+		uc.Data = img
+		uc.Resume = true
+		uc.Window = window
+		uc.ProgressCb = func(c *xact.ImageUploadCmd,
+			r *nmp.ImageUploadRsp) {
+
+			offs = append(offs, r.Off)
+		}
+		res := runCmd(t, s, uc)
+
+		// The rejected probe is left out of the results.
+		if probes != 1 || res.Status() != 0 || offs[0] == 0 ||
+			int(offs[len(offs)-1]) != len(img) {
+
+			t.Fatalf("window %d: probes=%d status=%d offs=%v", window,
+				probes, res.Status(), offs)
+		}
+		for _, rsp := range res.(*xact.ImageUploadResult).Rsps {
+			if rsp.Rc != 0 {
+				t.Fatalf("window %d: result has rejected probe", window)
+			}
+		}
+		if !bytes.Equal(dev.ImageHash(0, 1), hash) {
+			t.Fatalf("window %d: uploaded image hash mismatch", window)
+		}
+	}
+}
+
+// Delays each request by a random amount before handing it to the device so
+// that requests sent back to back reach it out of order.
+type reorderSesn struct {
+	sesn.Sesn
+
+	mtx         sync.Mutex
+	rnd         *rand.Rand
+	outstanding int
+	maxOut      int
+}
+
+func (s *reorderSesn) TxRxMgmtContext(ctx context.Context, m *nmp.NmpMsg,
+	timeout time.Duration) (nmp.NmpRsp, error) {
+
+	s.mtx.Lock()
+	delay := time.Duration(s.rnd.Intn(3000)) * time.Microsecond
+	s.outstanding++
+	if s.outstanding > s.maxOut {
+		s.maxOut = s.outstanding
+	}
+	s.mtx.Unlock()
+
+	defer func() {
+		s.mtx.Lock()
+		s.outstanding--
+		s.mtx.Unlock()
+	}()
+
+	time.Sleep(delay)
+	return s.Sesn.TxRxMgmtContext(ctx, m, timeout)
+}
+
+func TestImageUploadWindow(t *testing.T) {
+	dev, s := newTestSesn(t)
+	rs := &reorderSesn{Sesn: s, rnd: rand.New(rand.NewSource(1))}
+
+	img := BuildImage(ImageVersion{Major: 4}, bytes.Repeat([]byte{7}, 20000))
+
+	var lastOff uint32
+	uc := xact.NewImageUploadCmd()
+	uc.Data = img
+	uc.Window = 4
+	uc.ProgressCb = func(c *xact.ImageUploadCmd, r *nmp.ImageUploadRsp) {
+		lastOff = r.Off
+	}
+	res := runCmd(t, rs, uc)
+	if res.Status() != 0 || int(lastOff) != len(img) {
+		t.Fatalf("upload failed: rc=%d off=%d", res.Status(), lastOff)
+	}
+	if rs.maxOut < 2 || rs.maxOut > 4 {
+		t.Errorf("outstanding requests: want 2-4, got %d", rs.maxOut)
+	}
+
+	_, hash := parseImage(img)
+	if !bytes.Equal(dev.ImageHash(0, 1), hash) {
+		t.Fatalf("uploaded image hash mismatch")
+	}
+
+	// A device error stops the upload.
+	dev.InjectRc(nmp.NMP_GROUP_IMAGE, nmp.NMP_ID_IMAGE_UPLOAD,
+		nmp.NMP_ERR_ENOMEM, 0)
+	uc = xact.NewImageUploadCmd()
+	uc.Data = BuildImage(ImageVersion{Major: 5}, img)
+	uc.Window = 4
+	runCmdRc(t, rs, uc, nmp.NMP_ERR_ENOMEM)
+}
+
+// Fails the first few requests with the specified error before passing
+// requests on to the device.  Records the timeout of each try.
+type flakySesn struct {
+	sesn.Sesn
+
+	err      error
+	failures int
+	timeouts []time.Duration
+}
+
+func (s *flakySesn) TxRxMgmtContext(ctx context.Context, m *nmp.NmpMsg,
+	timeout time.Duration) (nmp.NmpRsp, error) {
+
+	s.timeouts = append(s.timeouts, timeout)
+	if len(s.timeouts) <= s.failures {
+		if nmxutil.IsSesnClosed(s.err) {
+			s.Sesn.Close()
+		}
+		return nil, s.err
+	}
+
+	return s.Sesn.TxRxMgmtContext(ctx, m, timeout)
+}
+
+func TestRetry(t *testing.T) {
+	_, s := newTestSesn(t)
+
+	policy := sesn.NewBackoffPolicy()
+	policy.InitialDelay = time.Millisecond
+
+	tmoErr := nmxutil.NewRspTimeoutError("NMP timeout")
+	xportErr := nmxutil.NewXportError("write failed")
+	closedErr := nmxutil.NewSesnClosedError("disconnected")
+
+	echo := func(fs *flakySesn, retry sesn.RetryPolicy) error {
+		c := xact.NewEchoCmd()
+		c.Payload = "retry"
+		c.SetTxOptions(sesn.TxOptions{
+			Timeout: 100 * time.Millisecond,
+			Tries:   3,
+			Retry:   retry,
+		})
+		_, err := c.Run(fs)
+		return err
+	}
+
+	// Without a policy, only timeouts are retried.
+	fs := &flakySesn{Sesn: s, err: xportErr, failures: 1}
+	if err := echo(fs, nil); err == nil || len(fs.timeouts) != 1 {
+		t.Fatalf("transport error without policy: err=%v tries=%d",
+			err, len(fs.timeouts))
+	}
+	fs = &flakySesn{Sesn: s, err: tmoErr, failures: 2}
+	if err := echo(fs, nil); err != nil {
+		t.Fatalf("timeouts without policy: %v", err)
+	}
+
+	// The backoff policy retries transport errors and lengthens the timeout
+	// of each try.
+	fs = &flakySesn{Sesn: s, err: xportErr, failures: 2}
+	if err := echo(fs, policy); err != nil {
+		t.Fatalf("transport errors with policy: %v", err)
+	}
+	want := []time.Duration{
+		100 * time.Millisecond, 150 * time.Millisecond, 225 * time.Millisecond,
+	}
+	for i, tmo := range fs.timeouts {
+		if tmo != want[i] {
+			t.Fatalf("try timeouts: want %v, got %v", want, fs.timeouts)
+		}
+	}
+
+	// The session gets reopened if the failure closed it.
+	fs = &flakySesn{Sesn: s, err: closedErr, failures: 1}
+	if err := echo(fs, policy); err != nil || !s.IsOpen() {
+		t.Fatalf("closed session with policy: err=%v open=%v",
+			err, s.IsOpen())
+	}
+
+	// Tries run out.
+	fs = &flakySesn{Sesn: s, err: tmoErr, failures: 3}
+	if err := echo(fs, policy); !nmxutil.IsRspTimeout(err) {
+		t.Fatalf("persistent timeouts: want timeout, got %v", err)
+	}
+
+	// A reset is never re-sent.
+	fs = &flakySesn{Sesn: s, err: tmoErr, failures: 1}
+	c := xact.NewResetCmd()
+	c.SetTxOptions(sesn.TxOptions{
+		Timeout: 100 * time.Millisecond,
+		Tries:   3,
+		Retry:   policy,
+	})
+	if _, err := c.Run(fs); err == nil || len(fs.timeouts) != 1 {
+		t.Fatalf("reset: err=%v tries=%d", err, len(fs.timeouts))
+	}
+}
+
+func TestRaw(t *testing.T) {
+	_, s := newTestSesn(t)
+
+	// A request with a registered response type still yields a map.
+	c := xact.NewRawCmd()
+	c.Op = nmp.NMP_OP_WRITE
+	c.Group = nmp.NMP_GROUP_DEFAULT
+	c.Id = nmp.NMP_ID_DEF_ECHO
+	c.Body = []byte(`{"d": "raw"}`)
+	res := runCmd(t, s, c).(*xact.RawResult)
+	if res.Rsp.Body["r"] != "raw" {
+		t.Fatalf("raw echo: got %v", res.Rsp.Body)
+	}
+
+	// Whole numbers are sent as integers.
+	c = xact.NewRawCmd()
+	c.Group = nmp.NMP_GROUP_LOG
+	c.Id = nmp.NMP_ID_LOG_SHOW
+	c.Body = []byte(`{"log_name": "log", "index": 1}`)
+	res = runCmd(t, s, c).(*xact.RawResult)
+	logs, ok := res.Rsp.Body["logs"].([]interface{})
+	if !ok || len(logs) != 1 {
+		t.Fatalf("raw log show: got %v", res.Rsp.Body)
+	}
+	if _, err := res.Json(); err != nil {
+		t.Fatalf("raw log show: %v", err)
+	}
+
+	// Unknown groups get a generic response too.
+	c = xact.NewRawCmd()
+	c.Group = nmp.NMP_GROUP_PERUSER
+	c.Id = 1
+	runCmdRc(t, s, c, nmp.NMP_ERR_ENOTSUP)
+
+	c = xact.NewRawCmd()
+	c.Body = []byte(`[1, 2]`)
+	if _, err := c.Run(s); err == nil {
+		t.Fatalf("raw request with non-object body succeeded")
+	}
+}
+
+// An application-defined request and response, as a third-party package
+// would define them.
+type peruserReq struct {
+	nmp.NmpBase `codec:"-"`
+	Value       int `codec:"v"`
+}
+
+type peruserRsp struct {
+	nmp.NmpBase
+	Rc int `codec:"rc"`
+}
+
+func newPeruserReq() *peruserReq {
+	r := &peruserReq{}
+	nmp.FillReq(r, nmp.NMP_OP_WRITE, nmp.NMP_GROUP_PERUSER, 7)
+	return r
+}
+
+func (r *peruserReq) Msg() *nmp.NmpMsg { return nmp.MsgFromReq(r) }
+func (r *peruserRsp) Msg() *nmp.NmpMsg { return nmp.MsgFromReq(r) }
+
+func TestRegisterRsp(t *testing.T) {
+	ctor := func() nmp.NmpRsp { return &peruserRsp{} }
+	if err := nmp.RegisterRsp(nmp.NMP_OP_WRITE, nmp.NMP_GROUP_PERUSER, 7,
+		ctor); err != nil {
+
+		t.Fatalf("register: %v", err)
+	}
+	defer nmp.UnregisterRsp(nmp.NMP_OP_WRITE, nmp.NMP_GROUP_PERUSER, 7)
+
+	// Conflicts are rejected, including with built-in types.
+	if err := nmp.RegisterRsp(nmp.NMP_OP_WRITE_RSP, nmp.NMP_GROUP_PERUSER, 7,
+		ctor); err == nil {
+
+		t.Errorf("duplicate registration succeeded")
+	}
+	if err := nmp.RegisterRsp(nmp.NMP_OP_WRITE, nmp.NMP_GROUP_DEFAULT,
+		nmp.NMP_ID_DEF_ECHO, ctor); err == nil {
+
+		t.Errorf("registration over built-in type succeeded")
+	}
+
+	_, s := newTestSesn(t)
+	rsp, err := sesn.TxRxMgmt(s, newPeruserReq().Msg(), sesn.NewTxOptions())
+	if err != nil {
+		t.Fatalf("peruser request: %v", err)
+	}
+	prsp, ok := rsp.(*peruserRsp)
+	if !ok {
+		t.Fatalf("peruser request: wrong response type: %T", rsp)
+	}
+
+	// The emulator doesn't implement the group.
+	if prsp.Rc != nmp.NMP_ERR_ENOTSUP {
+		t.Fatalf("peruser request: want rc=%d, got %d",
+			nmp.NMP_ERR_ENOTSUP, prsp.Rc)
+	}
+}
+
+func TestCoreDump(t *testing.T) {
+	_, s := newTestSesn(t)
+
+	runCmdRc(t, s, xact.NewCoreListCmd(), nmp.NMP_ERR_ENOENT)
+
+	cc := xact.NewCrashCmd()
+	cc.CrashType = xact.CRASH_TYPE_ASSERT
+	runCmd(t, s, cc)
+
+	if st := runCmd(t, s, xact.NewCoreListCmd()).Status(); st != 0 {
+		t.Fatalf("core list after crash: rc=%d", st)
+	}
+
+	res := runCmd(t, s, xact.NewCoreLoadCmd()).(*xact.CoreLoadResult)
+	core := []byte{}
+	for _, r := range res.Rsps {
+		core = append(core, r.Data...)
+	}
+	if len(core) != int(res.Rsps[0].Len) {
+		t.Fatalf("core length: want %d, got %d", res.Rsps[0].Len, len(core))
+	}
+
+	runCmd(t, s, xact.NewCoreEraseCmd())
+	runCmdRc(t, s, xact.NewCoreListCmd(), nmp.NMP_ERR_ENOENT)
+}
+
+func TestStat(t *testing.T) {
+	dev, s := newTestSesn(t)
+	dev.SetStat("app", "ticks", 42)
+
+	lres := runCmd(t, s, xact.NewStatListCmd()).(*xact.StatListResult)
+	found := false
+	for _, name := range lres.Rsp.List {
+		if name == "app" {
+			found = true
+		}
+	}
+	if !found {
+		t.Fatalf("stat list missing group: %v", lres.Rsp.List)
+	}
+
+	rc := xact.NewStatReadCmd()
+	rc.Name = "app"
+	rres := runCmd(t, s, rc).(*xact.StatReadResult)
+	if v, _ := rres.Rsp.Fields["ticks"].(uint64); v != 42 {
+		t.Errorf("stat read: want 42, got %v", rres.Rsp.Fields["ticks"])
+	}
+
+	rc = xact.NewStatReadCmd()
+	rc.Name = "bogus"
+	runCmdRc(t, s, rc, nmp.NMP_ERR_ENOENT)
+}
+
+func TestConfig(t *testing.T) {
+	dev, s := newTestSesn(t)
+	dev.SetConfig("app/mode", "a")
+
+	wc := xact.NewConfigWriteCmd()
+	wc.Name = "app/mode"
+	wc.Val = "b"
+	wc.Save = true
+	if st := runCmd(t, s, wc).Status(); st != 0 {
+		t.Fatalf("config write: rc=%d", st)
+	}
+
+	rc := xact.NewConfigReadCmd()
+	rc.Name = "app/mode"
+	rres := runCmd(t, s, rc).(*xact.ConfigReadResult)
+	if rres.Rsp.Val != "b" {
+		t.Errorf("config read: want b, got %s", rres.Rsp.Val)
+	}
+
+	if data, _ := dev.File(CONF_FS_FILE); string(data) != "app/mode=b\n" {
+		t.Errorf("config store: got %q", data)
+	}
+
+	rc = xact.NewConfigReadCmd()
+	rc.Name = "bogus"
+	runCmdRc(t, s, rc, nmp.NMP_ERR_EINVAL)
+}
+
+func TestLog(t *testing.T) {
+	dev, s := newTestSesn(t)
+
+	for i := 0; i < 100; i++ {
+		dev.AppendLog("app", uint8(nmp.MODULE_DEFAULT),
+			uint8(nmp.LEVEL_INFO), strings.Repeat("x", 20))
+	}
+
+	lc := xact.NewLogShowFullCmd()
+	lc.Name = "app"
+	res := runCmd(t, s, lc).(*xact.LogShowFullResult)
+	if len(res.Rsps) < 2 {
+		t.Errorf("log show: expected multiple responses, got %d",
+			len(res.Rsps))
+	}
+
+	count := 0
+	for _, rsp := range res.Rsps {
+		for _, l := range rsp.Logs {
+			count += len(l.Entries)
+		}
+	}
+	if count != 100 {
+		t.Errorf("log show: want 100 entries, got %d", count)
+	}
+
+	lres := runCmd(t, s, xact.NewLogListCmd()).(*xact.LogListResult)
+	if len(lres.Rsp.List) != 3 {
+		t.Errorf("log list: got %v", lres.Rsp.List)
+	}
+
+	mres := runCmd(t, s, xact.NewLogModuleListCmd()).(*xact.LogModuleListResult)
+	if mres.Rsp.Map["REBOOT"] != nmp.MODULE_REBOOT {
+		t.Errorf("log module list: got %v", mres.Rsp.Map)
+	}
+
+	runCmd(t, s, xact.NewLogClearCmd())
+	sc := xact.NewLogShowCmd()
+	sc.Name = "app"
+	sres := runCmd(t, s, sc).(*xact.LogShowResult)
+	if len(sres.Rsp.Logs[0].Entries) != 0 {
+		t.Errorf("log not cleared")
+	}
+}
+
+func TestRun(t *testing.T) {
+	_, s := newTestSesn(t)
+
+	lres := runCmd(t, s, xact.NewRunListCmd()).(*xact.RunListResult)
+	if len(lres.Rsp.List) == 0 {
+		t.Fatalf("run list: no tests")
+	}
+
+	rc := xact.NewRunTestCmd()
+	rc.Testname = lres.Rsp.List[0]
+	if st := runCmd(t, s, rc).Status(); st != 0 {
+		t.Errorf("run test: rc=%d", st)
+	}
+
+	rc = xact.NewRunTestCmd()
+	rc.Testname = "bogus"
+	runCmdRc(t, s, rc, nmp.NMP_ERR_ENOENT)
+}
+
+func TestFs(t *testing.T) {
+	dev, s := newTestSesn(t)
+
+	data := bytes.Repeat([]byte("0123456789"), 300)
+
+	uc := xact.NewFsUploadCmd()
+	uc.Name = "/data/file"
+	uc.Data = data
+	if st := runCmd(t, s, uc).Status(); st != 0 {
+		t.Fatalf("fs upload: rc=%d", st)
+	}
+	if got, _ := dev.File("/data/file"); !bytes.Equal(got, data) {
+		t.Fatalf("fs upload: contents mismatch")
+	}
+
+	dc := xact.NewFsDownloadCmd()
+	dc.Name = "/data/file"
+	res := runCmd(t, s, dc).(*xact.FsDownloadResult)
+	got := []byte{}
+	for _, r := range res.Rsps {
+		got = append(got, r.Data...)
+	}
+	if !bytes.Equal(got, data) {
+		t.Fatalf("fs download: contents mismatch")
+	}
+
+	dc = xact.NewFsDownloadCmd()
+	dc.Name = "/nonexistent"
+	runCmdRc(t, s, dc, nmp.NMP_ERR_ENOENT)
+}
+
+func TestShell(t *testing.T) {
+	_, s := newTestSesn(t)
+
+	c := xact.NewShellExecCmd()
+	c.Argv = []string{"echo", "a", "b"}
+	res := runCmd(t, s, c).(*xact.ShellExecResult)
+	if res.Rsp.O != "a b\n" {
+		t.Errorf("shell echo: got %q", res.Rsp.O)
+	}
+
+	c = xact.NewShellExecCmd()
+	c.Argv = []string{"bogus"}
+	runCmdRc(t, s, c, nmp.NMP_ERR_ENOENT)
+}
@@ -0,0 +1,117 @@
+/**
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package emulator
+
+import (
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"mynewt.apache.org/newtmgr/nmxact/nmp"
+)
+
+type faultKey struct {
+	group uint16
+	id    uint8
+}
+
+type fault struct {
+	rc        int
+	remaining int
+}
+
+// Delays every response by the specified duration.
+func (d *Device) SetLatency(latency time.Duration) {
+	d.mtx.Lock()
+	defer d.mtx.Unlock()
+
+	d.latency = latency
+}
+
+// Makes the device silently drop requests larger than the specified number
+// of bytes, as a device with a small receive buffer would.  0 means no
+// limit.
+func (d *Device) SetMtu(mtu int) {
+	d.mtx.Lock()
+	defer d.mtx.Unlock()
+
+	d.mtu = mtu
+}
+
+// Makes the device answer requests with the specified group and command ID
+// with the given status code instead of processing them.  If count is
+// greater than 0, only that many requests fail; otherwise the fault stays
+// in place until cleared.
+func (d *Device) InjectRc(group uint16, id uint8, rc int, count int) {
+	d.mtx.Lock()
+	defer d.mtx.Unlock()
+
+	d.faults[faultKey{group, id}] = &fault{
+		rc:        rc,
+		remaining: count,
+	}
+}
+
+// Removes all injected faults.
+func (d *Device) ClearFaults() {
+	d.mtx.Lock()
+	defer d.mtx.Unlock()
+
+	d.faults = map[faultKey]*fault{}
+}
+
+// Applies the configured MTU and latency to an incoming request.  Returns
+// false if the request should be dropped.
+func (d *Device) admit(data []byte) bool {
+	d.mtx.Lock()
+	latency := d.latency
+	mtu := d.mtu
+	d.mtx.Unlock()
+
+	if mtu > 0 && len(data) > mtu {
+		log.Debugf("Emulator dropping request; len=%d mtu=%d", len(data), mtu)
+		return false
+	}
+
+	if latency > 0 {
+		time.Sleep(latency)
+	}
+
+	return true
+}
+
+// Checks whether a fault has been injected for the specified request.
+func (d *Device) injectedRc(hdr *nmp.NmpHdr) (int, bool) {
+	key := faultKey{hdr.Group, hdr.Id}
+
+	f := d.faults[key]
+	if f == nil {
+		return 0, false
+	}
+
+	if f.remaining > 0 {
+		f.remaining--
+		if f.remaining == 0 {
+			delete(d.faults, key)
+		}
+	}
+
+	return f.rc, true
+}
@@ -0,0 +1,103 @@
+/**
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package emulator
+
+import (
+	"mynewt.apache.org/newtmgr/nmxact/nmp"
+)
+
+func fsDownloadReqCtor() nmp.NmpReq { return &nmp.FsDownloadReq{} }
+func fsUploadReqCtor() nmp.NmpReq   { return &nmp.FsUploadReq{} }
+
+// Creates or replaces a file in the device's file system.
+func (d *Device) SetFile(name string, data []byte) {
+	d.mtx.Lock()
+	defer d.mtx.Unlock()
+
+	d.files[name] = append([]byte{}, data...)
+}
+
+// Retrieves the contents of a file in the device's file system.
+func (d *Device) File(name string) ([]byte, bool) {
+	d.mtx.Lock()
+	defer d.mtx.Unlock()
+
+	data, ok := d.files[name]
+	if !ok {
+		return nil, false
+	}
+
+	return append([]byte{}, data...), true
+}
+
+func fsDownload(d *Device, req nmp.NmpReq) nmp.NmpRsp {
+	r := req.(*nmp.FsDownloadReq)
+	rsp := nmp.NewFsDownloadRsp()
+
+	data, ok := d.files[r.Name]
+	if !ok {
+		rsp.Rc = nmp.NMP_ERR_ENOENT
+		return rsp
+	}
+
+	off := int(r.Off)
+	if off > len(data) {
+		rsp.Rc = nmp.NMP_ERR_EINVAL
+		return rsp
+	}
+
+	end := off + DOWNLOAD_CHUNK_SZ
+	if end > len(data) {
+		end = len(data)
+	}
+
+	rsp.Off = r.Off
+	rsp.Data = data[off:end]
+	if off == 0 {
+		rsp.Len = uint32(len(data))
+	}
+
+	return rsp
+}
+
+func fsUpload(d *Device, req nmp.NmpReq) nmp.NmpRsp {
+	r := req.(*nmp.FsUploadReq)
+	rsp := nmp.NewFsUploadRsp()
+
+	if r.Name == "" {
+		rsp.Rc = nmp.NMP_ERR_EINVAL
+		return rsp
+	}
+
+	if r.Off == 0 {
+		d.files[r.Name] = []byte{}
+	}
+
+	data, ok := d.files[r.Name]
+	if !ok || int(r.Off) != len(data) {
+		rsp.Rc = nmp.NMP_ERR_EINVAL
+		return rsp
+	}
+
+	d.files[r.Name] = append(data, r.Data...)
+	rsp.Off = uint32(len(d.files[r.Name]))
+
+	return rsp
+}
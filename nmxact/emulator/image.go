@@ -0,0 +1,416 @@
+/**
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package emulator
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+
+	"mynewt.apache.org/newtmgr/nmxact/mcuboot"
+	"mynewt.apache.org/newtmgr/nmxact/nmp"
+)
+
+const CORE_MAGIC = 0x690c47c3
+
+// Number of bytes of image or core data returned in a single response.
+const DOWNLOAD_CHUNK_SZ = 512
+
+type ImageVersion = mcuboot.ImageVersion
+
+type imageSlot struct {
+	data      []byte
+	hash      []byte
+	version   ImageVersion
+	pending   bool
+	confirmed bool
+	permanent bool
+}
+
+type imageUpload struct {
+	image int
+	data  []byte
+	size  int
+	sha   []byte
+
+	// Set once the last chunk has been written.  Like a real device, the
+	// emulator keeps reporting the final offset to stray chunks until a new
+	// upload starts.
+	done bool
+}
+
+func imgUploadReqCtor() nmp.NmpReq     { return &nmp.ImageUploadReq{} }
+func imgStateReadReqCtor() nmp.NmpReq  { return &nmp.ImageStateReadReq{} }
+func imgStateWriteReqCtor() nmp.NmpReq { return &nmp.ImageStateWriteReq{} }
+func coreListReqCtor() nmp.NmpReq      { return &nmp.CoreListReq{} }
+func coreLoadReqCtor() nmp.NmpReq      { return &nmp.CoreLoadReq{} }
+func coreEraseReqCtor() nmp.NmpReq     { return &nmp.CoreEraseReq{} }
+func imgEraseReqCtor() nmp.NmpReq      { return &nmp.ImageEraseReq{} }
+
+// Builds a minimal MCUboot image: a header, the specified body, and a TLV
+// trailer containing the image's SHA-256 hash.
+func BuildImage(ver ImageVersion, body []byte) []byte {
+	hdr := make([]byte, mcuboot.IMAGE_HEADER_SIZE)
+	binary.LittleEndian.PutUint32(hdr[0:], mcuboot.IMAGE_MAGIC)
+	binary.LittleEndian.PutUint16(hdr[8:], mcuboot.IMAGE_HEADER_SIZE)
+	binary.LittleEndian.PutUint32(hdr[12:], uint32(len(body)))
+	hdr[20] = ver.Major
+	hdr[21] = ver.Minor
+	binary.LittleEndian.PutUint16(hdr[22:], ver.Rev)
+	binary.LittleEndian.PutUint32(hdr[24:], ver.BuildNum)
+
+	img := append(hdr, body...)
+	hash := sha256.Sum256(img)
+
+	tlv := make([]byte, 8, 8+len(hash))
+	binary.LittleEndian.PutUint16(tlv[0:], mcuboot.IMAGE_TLV_INFO_MAGIC)
+	binary.LittleEndian.PutUint16(tlv[2:], uint16(8+len(hash)))
+	tlv[4] = mcuboot.IMAGE_TLV_SHA256
+	binary.LittleEndian.PutUint16(tlv[6:], uint16(len(hash)))
+	tlv = append(tlv, hash[:]...)
+
+	return append(img, tlv...)
+}
+
+// Extracts the version and hash from an image.  Data that doesn't look like
+// an MCUboot image is still accepted; its hash is the SHA-256 of the whole
+// blob.
+func parseImage(data []byte) (ImageVersion, []byte) {
+	img, err := mcuboot.ParseImage(data)
+	if err != nil {
+		sum := sha256.Sum256(data)
+		return ImageVersion{}, sum[:]
+	}
+
+	hash, err := img.Hash()
+	if err != nil {
+		hash = img.CalcHash()
+	}
+
+	return img.Header.Vers, hash
+}
+
+func newImageSlot(data []byte) *imageSlot {
+	ver, hash := parseImage(data)
+
+	return &imageSlot{
+		data:    data,
+		hash:    hash,
+		version: ver,
+	}
+}
+
+func (d *Device) initImages() {
+	body := bytes.Repeat([]byte{0xa5}, 1024)
+	img := BuildImage(ImageVersion{Major: 1}, body)
+
+	slot := newImageSlot(img)
+	slot.confirmed = true
+
+	d.images = []*imageSlot{slot, nil}
+}
+
+func (d *Device) slot(image int, idx int) *imageSlot {
+	i := image*2 + idx
+	if i >= len(d.images) {
+		return nil
+	}
+
+	return d.images[i]
+}
+
+func (d *Device) setSlot(image int, idx int, slot *imageSlot) {
+	for image*2+idx >= len(d.images) {
+		d.images = append(d.images, nil)
+	}
+
+	d.images[image*2+idx] = slot
+}
+
+func (d *Device) activeVersion() string {
+	slot := d.slot(0, 0)
+	if slot == nil {
+		return "none"
+	}
+
+	return slot.version.String()
+}
+
+// Retrieves the hash of the image in the specified slot; nil if the slot is
+// empty.
+func (d *Device) ImageHash(image int, slot int) []byte {
+	d.mtx.Lock()
+	defer d.mtx.Unlock()
+
+	s := d.slot(image, slot)
+	if s == nil {
+		return nil
+	}
+
+	return s.hash
+}
+
+// Performs the image swap that the boot loader would perform on reboot.
+func (d *Device) swapImages() {
+	for image := 0; image*2 < len(d.images); image++ {
+		pri := d.slot(image, 0)
+		sec := d.slot(image, 1)
+
+		if sec != nil && sec.pending {
+			// Swap the pending image in.  It is only confirmed if it was
+			// marked permanent.
+			sec.pending = false
+			sec.confirmed = sec.permanent
+			sec.permanent = false
+			d.setSlot(image, 0, sec)
+			d.setSlot(image, 1, pri)
+		} else if pri != nil && !pri.confirmed && sec != nil {
+			// The test image wasn't confirmed; revert.
+			sec.confirmed = true
+			d.setSlot(image, 0, sec)
+			d.setSlot(image, 1, pri)
+		}
+	}
+}
+
+func (d *Device) findImage(hash []byte) (int, int, *imageSlot) {
+	for i, slot := range d.images {
+		if slot != nil && bytes.Equal(slot.hash, hash) {
+			return i / 2, i % 2, slot
+		}
+	}
+
+	return 0, 0, nil
+}
+
+func (d *Device) buildImageStateRsp() *nmp.ImageStateRsp {
+	rsp := nmp.NewImageStateRsp()
+	rsp.Images = []nmp.ImageStateEntry{}
+
+	for i, slot := range d.images {
+		if slot == nil {
+			continue
+		}
+
+		rsp.Images = append(rsp.Images, nmp.ImageStateEntry{
+			Image:     i / 2,
+			Slot:      i % 2,
+			Version:   slot.version.String(),
+			Hash:      slot.hash,
+			Bootable:  true,
+			Pending:   slot.pending,
+			Confirmed: slot.confirmed,
+			Active:    i%2 == 0,
+			Permanent: slot.permanent,
+		})
+	}
+
+	return rsp
+}
+
+func imgUpload(d *Device, req nmp.NmpReq) nmp.NmpRsp {
+	r := req.(*nmp.ImageUploadReq)
+	rsp := nmp.NewImageUploadRsp()
+
+	if r.Off == 0 {
+		if r.Len == 0 {
+			rsp.Rc = nmp.NMP_ERR_EINVAL
+			return rsp
+		}
+
+		// An upload of the same image that got interrupted can be
+		// resumed where it left off.
+		up := d.upload
+		if up != nil && len(r.DataSha) > 0 && bytes.Equal(up.sha, r.DataSha) &&
+			up.size == int(r.Len) && up.image == int(r.ImageNum) &&
+			len(up.data) > 0 && !up.done {
+
+			rsp.Off = uint32(len(up.data))
+			return rsp
+		}
+
+		sec := d.slot(int(r.ImageNum), 1)
+		if sec != nil && sec.pending {
+			rsp.Rc = nmp.NMP_ERR_EBADSTATE
+			return rsp
+		}
+
+		if r.Upgrade && len(r.Data) >= mcuboot.IMAGE_HEADER_SIZE {
+			ver, _ := parseImage(r.Data)
+			pri := d.slot(int(r.ImageNum), 0)
+			if pri != nil && ver.Cmp(pri.version) <= 0 {
+				rsp.Rc = nmp.NMP_ERR_EBADSTATE
+				return rsp
+			}
+		}
+
+		d.setSlot(int(r.ImageNum), 1, nil)
+		d.upload = &imageUpload{
+			image: int(r.ImageNum),
+			size:  int(r.Len),
+			sha:   r.DataSha,
+		}
+	}
+
+	up := d.upload
+	if up == nil {
+		rsp.Rc = nmp.NMP_ERR_EINVAL
+		return rsp
+	}
+
+	if int(r.Off) != len(up.data) {
+		// Unexpected offset; tell the client where to continue from.
+		rsp.Off = uint32(len(up.data))
+		return rsp
+	}
+
+	if len(up.data)+len(r.Data) > up.size {
+		rsp.Rc = nmp.NMP_ERR_EINVAL
+		return rsp
+	}
+
+	up.data = append(up.data, r.Data...)
+	rsp.Off = uint32(len(up.data))
+
+	if len(up.data) == up.size {
+		d.setSlot(up.image, 1, newImageSlot(up.data))
+		up.done = true
+	}
+
+	return rsp
+}
+
+func imgStateRead(d *Device, req nmp.NmpReq) nmp.NmpRsp {
+	return d.buildImageStateRsp()
+}
+
+func imgStateWrite(d *Device, req nmp.NmpReq) nmp.NmpRsp {
+	r := req.(*nmp.ImageStateWriteReq)
+
+	if len(r.Hash) == 0 {
+		if !r.Confirm {
+			rsp := nmp.NewImageStateRsp()
+			rsp.Rc = nmp.NMP_ERR_EINVAL
+			return rsp
+		}
+
+		// Confirm the running image.
+		if pri := d.slot(0, 0); pri != nil {
+			pri.confirmed = true
+		}
+		return d.buildImageStateRsp()
+	}
+
+	_, idx, slot := d.findImage(r.Hash)
+	if slot == nil {
+		rsp := nmp.NewImageStateRsp()
+		rsp.Rc = nmp.NMP_ERR_EINVAL
+		return rsp
+	}
+
+	if idx == 0 {
+		if !r.Confirm {
+			// Can't test the image that is already running.
+			rsp := nmp.NewImageStateRsp()
+			rsp.Rc = nmp.NMP_ERR_EBADSTATE
+			return rsp
+		}
+		slot.confirmed = true
+	} else {
+		slot.pending = true
+		slot.permanent = r.Confirm
+	}
+
+	return d.buildImageStateRsp()
+}
+
+func imgErase(d *Device, req nmp.NmpReq) nmp.NmpRsp {
+	r := req.(*nmp.ImageEraseReq)
+	rsp := nmp.NewImageEraseRsp()
+
+	// Only secondary slots can be erased; the default is image 0's.
+	slot := r.Slot
+	if slot == 0 {
+		slot = 1
+	}
+	if slot%2 == 0 {
+		rsp.Rc = nmp.NMP_ERR_EINVAL
+		return rsp
+	}
+	image := slot / 2
+
+	// Refuse to erase an image that is about to be booted, or the fallback
+	// of an image that is being tested.
+	pri := d.slot(image, 0)
+	sec := d.slot(image, 1)
+	if sec != nil && (sec.pending || (pri != nil && !pri.confirmed)) {
+		rsp.Rc = nmp.NMP_ERR_EBADSTATE
+		return rsp
+	}
+
+	d.setSlot(image, 1, nil)
+	if d.upload != nil && d.upload.image == image {
+		d.upload = nil
+	}
+	return rsp
+}
+
+func coreList(d *Device, req nmp.NmpReq) nmp.NmpRsp {
+	rsp := nmp.NewCoreListRsp()
+	if d.core == nil {
+		rsp.Rc = nmp.NMP_ERR_ENOENT
+	}
+
+	return rsp
+}
+
+func coreLoad(d *Device, req nmp.NmpReq) nmp.NmpRsp {
+	r := req.(*nmp.CoreLoadReq)
+	rsp := nmp.NewCoreLoadRsp()
+
+	if d.core == nil {
+		rsp.Rc = nmp.NMP_ERR_ENOENT
+		return rsp
+	}
+
+	off := int(r.Off)
+	if off > len(d.core) {
+		rsp.Rc = nmp.NMP_ERR_EINVAL
+		return rsp
+	}
+
+	end := off + DOWNLOAD_CHUNK_SZ
+	if end > len(d.core) {
+		end = len(d.core)
+	}
+
+	rsp.Off = r.Off
+	rsp.Data = d.core[off:end]
+	if off == 0 {
+		rsp.Len = uint32(len(d.core))
+	}
+
+	return rsp
+}
+
+func coreErase(d *Device, req nmp.NmpReq) nmp.NmpRsp {
+	d.core = nil
+	return nmp.NewCoreEraseRsp()
+}
@@ -0,0 +1,235 @@
+/**
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package emulator
+
+import (
+	"sort"
+	"time"
+
+	"mynewt.apache.org/newtmgr/nmxact/nmp"
+)
+
+// Maximum number of message bytes returned in a single log show response.
+// If more entries match, the response carries a status of 1 so that the
+// client knows to ask for the rest.
+const LOG_SHOW_MAX_BYTES = 400
+
+// Number of image hash bytes included in each log entry.
+const LOG_IMG_HASH_SZ = 4
+
+// A log.  If max is set, the log is circular; the oldest entries are
+// dropped to keep it to max entries.
+type devLog struct {
+	typ     int
+	max     int
+	entries []nmp.LogEntry
+}
+
+func logShowReqCtor() nmp.NmpReq    { return &nmp.LogShowReq{} }
+func logListReqCtor() nmp.NmpReq    { return &nmp.LogListReq{} }
+func logModListReqCtor() nmp.NmpReq { return &nmp.LogModuleListReq{} }
+func logLvlListReqCtor() nmp.NmpReq { return &nmp.LogLevelListReq{} }
+func logClearReqCtor() nmp.NmpReq   { return &nmp.LogClearReq{} }
+
+func (d *Device) initLogs() {
+	d.logs["log"] = &devLog{typ: nmp.MEMORY_LOG}
+	d.logs["reboot_log"] = &devLog{typ: nmp.STORAGE_LOG}
+
+	d.appendLog("reboot_log", uint8(nmp.MODULE_REBOOT),
+		uint8(nmp.LEVEL_CRITICAL), nmp.LOG_ENTRY_TYPE_STRING,
+		[]byte("rsn:HARD, cnt:0, img:"+d.activeVersion()))
+	d.appendLog("log", uint8(nmp.MODULE_DEFAULT), uint8(nmp.LEVEL_INFO),
+		nmp.LOG_ENTRY_TYPE_STRING, []byte("emulator started"))
+}
+
+func (d *Device) appendLog(name string, module uint8, level uint8,
+	typ nmp.LogEntryType, msg []byte) {
+
+	l := d.logs[name]
+	if l == nil {
+		l = &devLog{typ: nmp.MEMORY_LOG}
+		d.logs[name] = l
+	}
+
+	var imgHash []byte
+	if pri := d.slot(0, 0); pri != nil && len(pri.hash) >= LOG_IMG_HASH_SZ {
+		imgHash = pri.hash[:LOG_IMG_HASH_SZ]
+	}
+
+	l.entries = append(l.entries, nmp.LogEntry{
+		Index:     d.nextIdx,
+		Timestamp: d.now().UnixNano() / int64(time.Microsecond),
+		Module:    module,
+		Level:     level,
+		Type:      typ,
+		ImgHash:   imgHash,
+		Msg:       msg,
+	})
+	d.nextIdx++
+
+	if l.max > 0 && len(l.entries) > l.max {
+		l.entries = l.entries[len(l.entries)-l.max:]
+	}
+}
+
+// Adds a string entry to the named log.  The log is created if it doesn't
+// exist.
+func (d *Device) AppendLog(name string, module uint8, level uint8,
+	msg string) {
+
+	d.AppendLogEntry(name, module, level, nmp.LOG_ENTRY_TYPE_STRING,
+		[]byte(msg))
+}
+
+// Adds an entry of an arbitrary type (string, CBOR, or binary) to the named
+// log.
+func (d *Device) AppendLogEntry(name string, module uint8, level uint8,
+	typ nmp.LogEntryType, msg []byte) {
+
+	d.mtx.Lock()
+	defer d.mtx.Unlock()
+
+	d.appendLog(name, module, level, typ, msg)
+}
+
+// Limits the named log to the specified number of entries, as a circular
+// log would.  The log is created if it doesn't exist.  Zero removes the
+// limit.
+func (d *Device) SetLogCapacity(name string, entries int) {
+	d.mtx.Lock()
+	defer d.mtx.Unlock()
+
+	l := d.logs[name]
+	if l == nil {
+		l = &devLog{typ: nmp.MEMORY_LOG}
+		d.logs[name] = l
+	}
+
+	l.max = entries
+	if l.max > 0 && len(l.entries) > l.max {
+		l.entries = l.entries[len(l.entries)-l.max:]
+	}
+}
+
+func (d *Device) logNames() []string {
+	names := make([]string, 0, len(d.logs))
+	for name, _ := range d.logs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+func logShow(d *Device, req nmp.NmpReq) nmp.NmpRsp {
+	r := req.(*nmp.LogShowReq)
+	rsp := nmp.NewLogShowRsp()
+	rsp.Logs = []nmp.LogShowLog{}
+	rsp.NextIndex = d.nextIdx
+
+	var names []string
+	if r.Name == "" {
+		names = d.logNames()
+	} else {
+		if d.logs[r.Name] == nil {
+			rsp.Rc = nmp.NMP_ERR_ENOENT
+			return rsp
+		}
+		names = []string{r.Name}
+	}
+
+	budget := LOG_SHOW_MAX_BYTES
+	for _, name := range names {
+		l := d.logs[name]
+		sl := nmp.LogShowLog{
+			Name:    name,
+			Type:    l.typ,
+			Entries: []nmp.LogEntry{},
+		}
+
+		entries := l.entries
+		if r.Timestamp == -1 {
+			// Only the most recent entry was requested.
+			if len(entries) > 0 {
+				entries = entries[len(entries)-1:]
+			}
+		}
+
+		for _, e := range entries {
+			if e.Index < r.Index {
+				continue
+			}
+			if r.Timestamp > 0 && e.Timestamp <= r.Timestamp {
+				continue
+			}
+
+			if len(e.Msg) > budget && len(sl.Entries) > 0 {
+				// Out of room; the client has to ask for the rest.
+				rsp.Rc = 1
+				break
+			}
+
+			sl.Entries = append(sl.Entries, e)
+			budget -= len(e.Msg)
+		}
+
+		rsp.Logs = append(rsp.Logs, sl)
+		if rsp.Rc != 0 {
+			break
+		}
+	}
+
+	return rsp
+}
+
+func logList(d *Device, req nmp.NmpReq) nmp.NmpRsp {
+	rsp := nmp.NewLogListRsp()
+	rsp.List = d.logNames()
+
+	return rsp
+}
+
+func logModList(d *Device, req nmp.NmpReq) nmp.NmpRsp {
+	rsp := nmp.NewLogModuleListRsp()
+	rsp.Map = map[string]int{}
+	for id, name := range nmp.LogModuleNameMap {
+		rsp.Map[name] = id
+	}
+
+	return rsp
+}
+
+func logLvlList(d *Device, req nmp.NmpReq) nmp.NmpRsp {
+	rsp := nmp.NewLogLevelListRsp()
+	rsp.Map = map[string]int{}
+	for id, name := range nmp.LogLevelNameMap {
+		rsp.Map[name] = id
+	}
+
+	return rsp
+}
+
+func logClear(d *Device, req nmp.NmpReq) nmp.NmpRsp {
+	for _, l := range d.logs {
+		l.entries = nil
+	}
+
+	return nmp.NewLogClearRsp()
+}
@@ -0,0 +1,120 @@
+/**
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package emulator
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/runtimeco/go-coap"
+	"github.com/ugorji/go/codec"
+
+	"mynewt.apache.org/newtmgr/nmxact/nmcoap"
+	"mynewt.apache.org/newtmgr/nmxact/nmp"
+	"mynewt.apache.org/newtmgr/nmxact/nmxutil"
+	"mynewt.apache.org/newtmgr/nmxact/omp"
+)
+
+// Encodes an OMP response payload: the response fields plus the NMP header
+// under the "_h" key.
+func encodeOmpPayload(m *nmp.NmpMsg) ([]byte, error) {
+	b, err := nmp.BodyBytes(m.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	fields := map[string]interface{}{}
+	dec := codec.NewDecoderBytes(b, new(codec.CborHandle))
+	if err := dec.Decode(&fields); err != nil {
+		return nil, err
+	}
+
+	hdr := m.Hdr
+	hdr.Len = uint16(len(b))
+	fields["_h"] = hdr.Bytes()
+
+	payload := []byte{}
+	enc := codec.NewEncoderBytes(&payload, new(codec.CborHandle))
+	if err := enc.Encode(fields); err != nil {
+		return nil, err
+	}
+
+	return payload, nil
+}
+
+func buildOmpRsp(req coap.Message, code coap.COAPCode,
+	payload []byte) coap.Message {
+
+	mp := coap.MessageParams{
+		Code:    code,
+		Token:   req.Token(),
+		Payload: payload,
+	}
+	if req.IsConfirmable() {
+		mp.Type = coap.Acknowledgement
+		mp.MessageID = req.MessageID()
+	} else {
+		mp.Type = coap.NonConfirmable
+		mp.MessageID = nmcoap.NextMessageId()
+	}
+
+	return coap.NewDgramMessage(mp)
+}
+
+// Processes a single OMP request in CoAP datagram form and returns the
+// encoded response.  A nil response with a nil error indicates that the
+// device does not reply to the request.
+func (d *Device) ProcessOmp(data []byte) ([]byte, error) {
+	m, err := coap.ParseDgramMessage(data)
+	if err != nil {
+		return nil, fmt.Errorf("invalid CoAP message: %s", err.Error())
+	}
+
+	if m.PathString() != strings.TrimPrefix(nmxutil.OmpRes, "/") {
+		return buildOmpRsp(m, coap.NotFound, nil).MarshalBinary()
+	}
+
+	var om omp.OicMsg
+	dec := codec.NewDecoderBytes(m.Payload(), new(codec.CborHandle))
+	if err := dec.Decode(&om); err != nil {
+		return buildOmpRsp(m, coap.BadRequest, nil).MarshalBinary()
+	}
+
+	hdr, err := nmp.DecodeNmpHdr(om.Hdr)
+	if err != nil {
+		return buildOmpRsp(m, coap.BadRequest, nil).MarshalBinary()
+	}
+
+	if !d.admit(data) {
+		return nil, nil
+	}
+
+	rsp := d.handleReq(hdr, m.Payload())
+	if rsp == nil {
+		return nil, nil
+	}
+
+	payload, err := encodeOmpPayload(rsp)
+	if err != nil {
+		return nil, err
+	}
+
+	return buildOmpRsp(m, coap.Changed, payload).MarshalBinary()
+}
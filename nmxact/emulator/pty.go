@@ -0,0 +1,67 @@
+/**
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package emulator
+
+import (
+	"os"
+)
+
+// A pseudo-terminal pair.  The emulator serves the master side; newtmgr
+// opens the slave device (Name) as if it were a serial port.
+type Pty struct {
+	Master *os.File
+	Name   string
+
+	// Kept open so that reads from the master don't fail while no client
+	// has the slave open.
+	slave *os.File
+}
+
+func OpenPty() (*Pty, error) {
+	master, name, err := openPtyMaster()
+	if err != nil {
+		return nil, err
+	}
+
+	slave, err := os.OpenFile(name, os.O_RDWR, 0)
+	if err != nil {
+		master.Close()
+		return nil, err
+	}
+
+	// Turn off echo and line processing; otherwise the emulator would read
+	// its own responses back.
+	if err := makeRaw(slave); err != nil {
+		slave.Close()
+		master.Close()
+		return nil, err
+	}
+
+	return &Pty{
+		Master: master,
+		Name:   name,
+		slave:  slave,
+	}, nil
+}
+
+func (p *Pty) Close() error {
+	p.slave.Close()
+	return p.Master.Close()
+}
@@ -0,0 +1,35 @@
+// +build !linux,!darwin
+
+/**
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package emulator
+
+import (
+	"fmt"
+	"os"
+)
+
+func openPtyMaster() (*os.File, string, error) {
+	return nil, "", fmt.Errorf("pseudo-terminals not supported on this OS")
+}
+
+func makeRaw(f *os.File) error {
+	return fmt.Errorf("pseudo-terminals not supported on this OS")
+}
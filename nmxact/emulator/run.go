@@ -0,0 +1,77 @@
+/**
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package emulator
+
+import (
+	"fmt"
+
+	"mynewt.apache.org/newtmgr/nmxact/nmp"
+)
+
+func runTestReqCtor() nmp.NmpReq { return &nmp.RunTestReq{} }
+func runListReqCtor() nmp.NmpReq { return &nmp.RunListReq{} }
+
+func (d *Device) initTests() {
+	d.tests = []string{"os_test", "cbmem_test"}
+}
+
+// Registers a test suite that can be listed and run remotely.  Emulated
+// tests always pass.
+func (d *Device) AddTest(name string) {
+	d.mtx.Lock()
+	defer d.mtx.Unlock()
+
+	d.tests = append(d.tests, name)
+}
+
+func (d *Device) runOneTest(name string, token string) {
+	d.appendLog("log", uint8(nmp.MODULE_TEST), uint8(nmp.LEVEL_INFO),
+		nmp.LOG_ENTRY_TYPE_STRING,
+		[]byte(fmt.Sprintf("%s: PASS %s", name, token)))
+}
+
+func runTest(d *Device, req nmp.NmpReq) nmp.NmpRsp {
+	r := req.(*nmp.RunTestReq)
+	rsp := nmp.NewRunTestRsp()
+
+	if r.Testname == "" || r.Testname == "all" {
+		for _, name := range d.tests {
+			d.runOneTest(name, r.Token)
+		}
+		return rsp
+	}
+
+	for _, name := range d.tests {
+		if name == r.Testname {
+			d.runOneTest(name, r.Token)
+			return rsp
+		}
+	}
+
+	rsp.Rc = nmp.NMP_ERR_ENOENT
+	return rsp
+}
+
+func runList(d *Device, req nmp.NmpReq) nmp.NmpRsp {
+	rsp := nmp.NewRunListRsp()
+	rsp.List = append([]string{}, d.tests...)
+
+	return rsp
+}
@@ -0,0 +1,168 @@
+/**
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package emulator
+
+import (
+	"bufio"
+	"encoding/base64"
+	"encoding/binary"
+	"io"
+	"net"
+
+	"github.com/joaojeronimo/go-crc16"
+	log "github.com/sirupsen/logrus"
+
+	"mynewt.apache.org/newtmgr/nmxact/nmserial"
+	"mynewt.apache.org/newtmgr/nmxact/udp"
+)
+
+// Maximum number of base64 characters in a single serial frame.
+const SERIAL_FRAME_SZ = 124
+
+// Writes a packet using the newtmgr serial framing: length, data, and CRC,
+// base64-encoded and split into newline-terminated frames.
+func writeSerialPkt(w io.Writer, data []byte) error {
+	pkt := make([]byte, 2, 2+len(data)+2)
+	binary.BigEndian.PutUint16(pkt, uint16(len(data)+2))
+	pkt = append(pkt, data...)
+
+	crc := make([]byte, 2)
+	binary.BigEndian.PutUint16(crc, crc16.Crc16(data))
+	pkt = append(pkt, crc...)
+
+	enc := base64.StdEncoding.EncodeToString(pkt)
+
+	buf := []byte{}
+	for off := 0; off < len(enc); off += SERIAL_FRAME_SZ {
+		if off == 0 {
+			buf = append(buf, 6, 9)
+		} else {
+			buf = append(buf, 4, 20)
+		}
+
+		end := off + SERIAL_FRAME_SZ
+		if end > len(enc) {
+			end = len(enc)
+		}
+		buf = append(buf, enc[off:end]...)
+		buf = append(buf, '\n')
+	}
+
+	_, err := w.Write(buf)
+	return err
+}
+
+// Serves the device over a byte stream (e.g., a pseudo-terminal) using the
+// newtmgr serial framing.  Requests may be plain NMP or OMP.  Lines that
+// aren't newtmgr frames are treated as console input and ignored.  Returns
+// when the stream reports an error.
+func ServeSerial(d *Device, rw io.ReadWriter) error {
+	var pkt *nmserial.Packet
+
+	scanner := bufio.NewScanner(rw)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		for len(line) > 1 && line[0] == '\r' {
+			line = line[1:]
+		}
+
+		if len(line) < 2 {
+			continue
+		}
+		start := line[0] == 6 && line[1] == 9
+		cont := line[0] == 4 && line[1] == 20
+		if !start && !cont {
+			continue
+		}
+
+		data, err := base64.StdEncoding.DecodeString(string(line[2:]))
+		if err != nil {
+			log.Debugf("Emulator discarding bad serial frame: %s",
+				err.Error())
+			pkt = nil
+			continue
+		}
+
+		if start {
+			if len(data) < 2 {
+				continue
+			}
+			pkt, _ = nmserial.NewPacket(binary.BigEndian.Uint16(data[0:2]))
+			data = data[2:]
+		}
+
+		if pkt == nil || !pkt.AddBytes(data) {
+			continue
+		}
+
+		if crc16.Crc16(pkt.GetBytes()) != 0 {
+			log.Debugf("Emulator discarding serial packet: CRC error")
+			pkt = nil
+			continue
+		}
+		pkt.TrimEnd(2)
+		req := pkt.GetBytes()
+		pkt = nil
+
+		rsp, err := d.ProcessAny(req)
+		if err != nil {
+			log.Debugf("Emulator failed to process request: %s",
+				err.Error())
+			continue
+		}
+		if rsp == nil {
+			continue
+		}
+
+		if err := writeSerialPkt(rw, rsp); err != nil {
+			return err
+		}
+	}
+
+	return scanner.Err()
+}
+
+// Serves the device over UDP.  Requests may be plain NMP or OMP; each
+// response is sent to the address the request came from.  Returns when the
+// connection is closed.
+func ServeUdp(d *Device, conn *net.UDPConn) error {
+	buf := make([]byte, udp.MAX_PACKET_SIZE)
+
+	for {
+		nr, addr, err := conn.ReadFromUDP(buf)
+		if err != nil {
+			return err
+		}
+
+		rsp, err := d.ProcessAny(buf[:nr])
+		if err != nil {
+			log.Debugf("Emulator failed to process request from %s: %s",
+				addr, err.Error())
+			continue
+		}
+		if rsp == nil {
+			continue
+		}
+
+		if _, err := conn.WriteToUDP(rsp, addr); err != nil {
+			return err
+		}
+	}
+}
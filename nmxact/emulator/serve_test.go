@@ -0,0 +1,181 @@
+/**
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package emulator
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"mynewt.apache.org/newtmgr/nmxact/nmp"
+	"mynewt.apache.org/newtmgr/nmxact/nmserial"
+	"mynewt.apache.org/newtmgr/nmxact/sesn"
+	"mynewt.apache.org/newtmgr/nmxact/udp"
+	"mynewt.apache.org/newtmgr/nmxact/xact"
+	"mynewt.apache.org/newtmgr/nmxact/xport"
+)
+
+func openXportSesn(t *testing.T, x xport.Xport, sc sesn.SesnCfg) sesn.Sesn {
+	if err := x.Start(); err != nil {
+		t.Fatalf("xport start: %v", err)
+	}
+
+	s, err := x.BuildSesn(sc)
+	if err != nil {
+		t.Fatalf("build sesn: %v", err)
+	}
+	if err := s.Open(); err != nil {
+		t.Fatalf("open sesn: %v", err)
+	}
+
+	t.Cleanup(func() {
+		s.Close()
+		x.Stop()
+	})
+
+	return s
+}
+
+func TestServeSerial(t *testing.T) {
+	pty, err := OpenPty()
+	if err != nil {
+		t.Skipf("no pseudo-terminal: %v", err)
+	}
+	defer pty.Close()
+
+	dev := NewDevice()
+	go ServeSerial(dev, pty.Master)
+
+	xc := nmserial.NewXportCfg()
+	xc.DevPath = pty.Name
+	xc.Baud = 115200
+	xc.ReadTimeout = time.Second
+
+	sc := sesn.NewSesnCfg()
+	sc.MgmtProto = sesn.MGMT_PROTO_NMP
+	s := openXportSesn(t, nmserial.NewSerialXport(xc), sc)
+
+	// Big enough to span several serial frames in each direction.
+	c := xact.NewEchoCmd()
+	c.Payload = string(make([]byte, 300))
+	res := runCmd(t, s, c).(*xact.EchoResult)
+	if res.Rsp.Payload != c.Payload {
+		t.Errorf("echo over serial: payload mismatch")
+	}
+}
+
+func TestServeUdp(t *testing.T) {
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer conn.Close()
+
+	dev := NewDevice()
+	dev.InjectRc(nmp.NMP_GROUP_STAT, nmp.NMP_ID_STAT_LIST,
+		nmp.NMP_ERR_ENOMEM, 1)
+	go ServeUdp(dev, conn)
+
+	sc := sesn.NewSesnCfg()
+	sc.MgmtProto = sesn.MGMT_PROTO_NMP
+	sc.PeerSpec.Udp = conn.LocalAddr().String()
+	s := openXportSesn(t, udp.NewUdpXport(), sc)
+
+	// The first stat list fails with the injected error; the second one
+	// succeeds.
+	runCmdRc(t, s, xact.NewStatListCmd(), nmp.NMP_ERR_ENOMEM)
+	if st := runCmd(t, s, xact.NewStatListCmd()).Status(); st != 0 {
+		t.Errorf("stat list after fault: rc=%d", st)
+	}
+
+	// Requests larger than the device's MTU get dropped.
+	dev.SetMtu(64)
+	c := xact.NewEchoCmd()
+	c.Payload = string(make([]byte, 100))
+	c.SetTxOptions(sesn.TxOptions{Timeout: 200 * time.Millisecond, Tries: 1})
+	if _, err := c.Run(s); err == nil {
+		t.Errorf("oversized request got a response")
+	}
+}
+
+func TestCancel(t *testing.T) {
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer conn.Close()
+
+	dev := NewDevice()
+	dev.SetLatency(500 * time.Millisecond)
+	go ServeUdp(dev, conn)
+
+	sc := sesn.NewSesnCfg()
+	sc.MgmtProto = sesn.MGMT_PROTO_NMP
+	sc.PeerSpec.Udp = conn.LocalAddr().String()
+	s := openXportSesn(t, udp.NewUdpXport(), sc)
+
+	// A deadline caps the wait for a slow response.
+	c := xact.NewEchoCmd()
+	c.Payload = "slow"
+	ctx, cancel := context.WithTimeout(context.Background(),
+		100*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	_, err = c.RunContext(ctx, s)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("echo past deadline: want DeadlineExceeded, got %v", err)
+	}
+	if time.Since(start) > 400*time.Millisecond {
+		t.Fatalf("echo past deadline took %s", time.Since(start))
+	}
+
+	// Cancelling the context abandons the request right away.
+	c = xact.NewEchoCmd()
+	c.Payload = "slow"
+	ctx, cancel = context.WithCancel(context.Background())
+	time.AfterFunc(100*time.Millisecond, cancel)
+	start = time.Now()
+	if _, err := c.RunContext(ctx, s); !errors.Is(err, context.Canceled) {
+		t.Fatalf("cancelled echo: want Canceled, got %v", err)
+	}
+	if time.Since(start) > 400*time.Millisecond {
+		t.Fatalf("cancelled echo took %s", time.Since(start))
+	}
+
+	// So does aborting the command.
+	c = xact.NewEchoCmd()
+	c.Payload = "slow"
+	time.AfterFunc(100*time.Millisecond, func() { c.Abort() })
+	start = time.Now()
+	if _, err := c.Run(s); err == nil || time.Since(start) > 400*time.Millisecond {
+		t.Fatalf("aborted echo: err=%v elapsed=%s", err, time.Since(start))
+	}
+
+	// The session is still usable afterwards.
+	dev.SetLatency(0)
+	c = xact.NewEchoCmd()
+	c.Payload = "fast"
+	if res := runCmd(t, s, c).(*xact.EchoResult); res.Rsp.Payload != "fast" {
+		t.Fatalf("echo after cancel: got %q", res.Rsp.Payload)
+	}
+}
@@ -0,0 +1,80 @@
+/**
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package emulator
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"mynewt.apache.org/newtmgr/nmxact/nmp"
+)
+
+func shellExecReqCtor() nmp.NmpReq { return &nmp.ShellExecReq{} }
+
+func (d *Device) initShell() {
+	d.shell["echo"] = func(argv []string) (string, int) {
+		return strings.Join(argv[1:], " ") + "\n", 0
+	}
+	d.shell["help"] = func(argv []string) (string, int) {
+		return strings.Join(d.shellCmdNames(), "\n") + "\n", 0
+	}
+}
+
+// Registers a shell command that can be executed remotely.  The callback
+// receives the full argument vector, including the command name, and
+// returns the command's output and status code.  It runs with the device
+// locked, so it must not call back into the device.
+func (d *Device) AddShellCmd(name string, cb ShellCmdFn) {
+	d.mtx.Lock()
+	defer d.mtx.Unlock()
+
+	d.shell[name] = cb
+}
+
+func (d *Device) shellCmdNames() []string {
+	names := make([]string, 0, len(d.shell))
+	for name, _ := range d.shell {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+func shellExec(d *Device, req nmp.NmpReq) nmp.NmpRsp {
+	r := req.(*nmp.ShellExecReq)
+	rsp := nmp.NewShellExecRsp()
+
+	if len(r.Argv) == 0 {
+		rsp.Rc = nmp.NMP_ERR_EINVAL
+		return rsp
+	}
+
+	cb := d.shell[r.Argv[0]]
+	if cb == nil {
+		rsp.O = fmt.Sprintf("Unrecognized command: %s\n", r.Argv[0])
+		rsp.Rc = nmp.NMP_ERR_ENOENT
+		return rsp
+	}
+
+	rsp.O, rsp.Rc = cb(r.Argv)
+	return rsp
+}
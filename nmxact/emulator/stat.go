@@ -0,0 +1,87 @@
+/**
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package emulator
+
+import (
+	"mynewt.apache.org/newtmgr/nmxact/nmp"
+)
+
+func statReadReqCtor() nmp.NmpReq { return &nmp.StatReadReq{} }
+func statListReqCtor() nmp.NmpReq { return &nmp.StatListReq{} }
+
+func (d *Device) initStats() {
+	d.stats["nmgr"] = map[string]int64{
+		"rx_reqs": 0,
+		"tx_rsps": 0,
+		"rx_errs": 0,
+	}
+	d.stats["ble_ll"] = map[string]int64{
+		"rx_adv_pdu_crc_ok":  0,
+		"rx_adv_pdu_crc_err": 0,
+		"tx_adv_pdus":        0,
+	}
+}
+
+func (d *Device) incStat(group string, field string) {
+	if g := d.stats[group]; g != nil {
+		g[field]++
+	}
+}
+
+// Sets the value of a stat; creates the group and field if they don't
+// exist.
+func (d *Device) SetStat(group string, field string, val int64) {
+	d.mtx.Lock()
+	defer d.mtx.Unlock()
+
+	g := d.stats[group]
+	if g == nil {
+		g = map[string]int64{}
+		d.stats[group] = g
+	}
+	g[field] = val
+}
+
+func statRead(d *Device, req nmp.NmpReq) nmp.NmpRsp {
+	r := req.(*nmp.StatReadReq)
+	rsp := nmp.NewStatReadRsp()
+
+	g := d.stats[r.Name]
+	if g == nil {
+		rsp.Rc = nmp.NMP_ERR_ENOENT
+		return rsp
+	}
+
+	rsp.Name = r.Name
+	rsp.Group = r.Name
+	rsp.Fields = map[string]interface{}{}
+	for k, v := range g {
+		rsp.Fields[k] = v
+	}
+
+	return rsp
+}
+
+func statList(d *Device, req nmp.NmpReq) nmp.NmpRsp {
+	rsp := nmp.NewStatListRsp()
+	rsp.List = sortedKeys(d.stats)
+
+	return rsp
+}
@@ -0,0 +1,375 @@
+/**
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+// Package mcuboot parses and validates MCUboot image files.
+package mcuboot
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+)
+
+const IMAGE_MAGIC = 0x96f3b83d
+const IMAGE_MAGIC_V1 = 0x96f3b83c
+const IMAGE_HEADER_SIZE = 32
+
+const IMAGE_TLV_INFO_MAGIC = 0x6907
+const IMAGE_TLV_PROT_INFO_MAGIC = 0x6908
+const IMAGE_TLV_INFO_SIZE = 4
+const IMAGE_TLV_HDR_SIZE = 4
+
+const (
+	IMAGE_TLV_KEYHASH     = 0x01
+	IMAGE_TLV_PUBKEY      = 0x02
+	IMAGE_TLV_SHA256      = 0x10
+	IMAGE_TLV_RSA2048_PSS = 0x20
+	IMAGE_TLV_ECDSA224    = 0x21
+	IMAGE_TLV_ECDSA256    = 0x22
+	IMAGE_TLV_RSA3072_PSS = 0x23
+	IMAGE_TLV_ED25519     = 0x24
+	IMAGE_TLV_ENC_RSA2048 = 0x30
+	IMAGE_TLV_ENC_KW      = 0x31
+	IMAGE_TLV_ENC_EC256   = 0x32
+	IMAGE_TLV_ENC_X25519  = 0x33
+	IMAGE_TLV_DEPENDENCY  = 0x40
+	IMAGE_TLV_SEC_CNT     = 0x50
+	IMAGE_TLV_BOOT_RECORD = 0x60
+)
+
+const (
+	IMAGE_F_PIC              = 0x00000001
+	IMAGE_F_ENCRYPTED_AES128 = 0x00000004
+	IMAGE_F_ENCRYPTED_AES256 = 0x00000008
+	IMAGE_F_NON_BOOTABLE     = 0x00000010
+	IMAGE_F_RAM_LOAD         = 0x00000020
+	IMAGE_F_ROM_FIXED        = 0x00000100
+)
+
+var tlvTypeNameMap = map[uint8]string{
+	IMAGE_TLV_KEYHASH:     "KEYHASH",
+	IMAGE_TLV_PUBKEY:      "PUBKEY",
+	IMAGE_TLV_SHA256:      "SHA256",
+	IMAGE_TLV_RSA2048_PSS: "RSA2048_PSS",
+	IMAGE_TLV_ECDSA224:    "ECDSA224",
+	IMAGE_TLV_ECDSA256:    "ECDSA256",
+	IMAGE_TLV_RSA3072_PSS: "RSA3072_PSS",
+	IMAGE_TLV_ED25519:     "ED25519",
+	IMAGE_TLV_ENC_RSA2048: "ENC_RSA2048",
+	IMAGE_TLV_ENC_KW:      "ENC_KW",
+	IMAGE_TLV_ENC_EC256:   "ENC_EC256",
+	IMAGE_TLV_ENC_X25519:  "ENC_X25519",
+	IMAGE_TLV_DEPENDENCY:  "DEPENDENCY",
+	IMAGE_TLV_SEC_CNT:     "SEC_CNT",
+	IMAGE_TLV_BOOT_RECORD: "BOOT_RECORD",
+}
+
+var imageFlagNameMap = map[uint32]string{
+	IMAGE_F_PIC:              "PIC",
+	IMAGE_F_ENCRYPTED_AES128: "ENCRYPTED_AES128",
+	IMAGE_F_ENCRYPTED_AES256: "ENCRYPTED_AES256",
+	IMAGE_F_NON_BOOTABLE:     "NON_BOOTABLE",
+	IMAGE_F_RAM_LOAD:         "RAM_LOAD",
+	IMAGE_F_ROM_FIXED:        "ROM_FIXED",
+}
+
+func TlvTypeToString(t uint8) string {
+	s := tlvTypeNameMap[t]
+	if s == "" {
+		return fmt.Sprintf("UNKNOWN(0x%02x)", t)
+	}
+	return s
+}
+
+// Returns the names of the flags that are set, in ascending order of value.
+// Unrecognized bits are reported in hex.
+func ImageFlagsToStrings(flags uint32) []string {
+	strs := []string{}
+
+	for bit := uint32(1); bit != 0; bit <<= 1 {
+		if flags&bit == 0 {
+			continue
+		}
+
+		if s := imageFlagNameMap[bit]; s != "" {
+			strs = append(strs, s)
+		} else {
+			strs = append(strs, fmt.Sprintf("0x%08x", bit))
+		}
+	}
+
+	return strs
+}
+
+type ImageVersion struct {
+	Major    uint8
+	Minor    uint8
+	Rev      uint16
+	BuildNum uint32
+}
+
+// Formats the version the same way the device does in image state
+// responses.
+func (v ImageVersion) String() string {
+	s := fmt.Sprintf("%d.%d.%d", v.Major, v.Minor, v.Rev)
+	if v.BuildNum != 0 {
+		s += fmt.Sprintf(".%d", v.BuildNum)
+	}
+	return s
+}
+
+// Returns -1, 0, or 1 if v is less than, equal to, or greater than o,
+// respectively.
+func (v ImageVersion) Cmp(o ImageVersion) int {
+	a := []uint64{uint64(v.Major), uint64(v.Minor), uint64(v.Rev),
+		uint64(v.BuildNum)}
+	b := []uint64{uint64(o.Major), uint64(o.Minor), uint64(o.Rev),
+		uint64(o.BuildNum)}
+
+	for i := range a {
+		if a[i] < b[i] {
+			return -1
+		} else if a[i] > b[i] {
+			return 1
+		}
+	}
+
+	return 0
+}
+
+type ImageHeader struct {
+	Magic     uint32
+	LoadAddr  uint32
+	HdrSz     uint16
+	ProtTlvSz uint16
+	ImgSz     uint32
+	Flags     uint32
+	Vers      ImageVersion
+}
+
+type ImageTlv struct {
+	Type      uint8
+	Data      []byte
+	Protected bool
+}
+
+type Image struct {
+	Header ImageHeader
+	Body   []byte
+
+	// TLVs in the protected area are covered by the image hash and
+	// signatures; the others are not.
+	ProtTlvs []ImageTlv
+	Tlvs     []ImageTlv
+
+	// The header, body, and protected TLV area; the part of the image that
+	// is hashed and signed.
+	Hashed []byte
+}
+
+func parseTlvArea(data []byte, off int, magic uint16,
+	protected bool) ([]ImageTlv, int, error) {
+
+	what := "TLV"
+	if protected {
+		what = "protected TLV"
+	}
+
+	if off+IMAGE_TLV_INFO_SIZE > len(data) {
+		return nil, 0, fmt.Errorf("image truncated; missing %s info", what)
+	}
+
+	if m := binary.LittleEndian.Uint16(data[off:]); m != magic {
+		return nil, 0, fmt.Errorf("bad %s info magic: 0x%04x", what, m)
+	}
+
+	end := off + int(binary.LittleEndian.Uint16(data[off+2:]))
+	if end > len(data) {
+		return nil, 0, fmt.Errorf("image truncated; %s area extends past "+
+			"end of file", what)
+	}
+
+	tlvs := []ImageTlv{}
+	for off += IMAGE_TLV_INFO_SIZE; off < end; {
+		if off+IMAGE_TLV_HDR_SIZE > end {
+			return nil, 0, fmt.Errorf("malformed %s at offset %d", what, off)
+		}
+
+		tlv := ImageTlv{
+			Type:      data[off],
+			Protected: protected,
+		}
+		tlvLen := int(binary.LittleEndian.Uint16(data[off+2:]))
+		off += IMAGE_TLV_HDR_SIZE
+
+		if off+tlvLen > end {
+			return nil, 0, fmt.Errorf("%s %s at offset %d extends past end "+
+				"of TLV area", what, TlvTypeToString(tlv.Type),
+				off-IMAGE_TLV_HDR_SIZE)
+		}
+		tlv.Data = data[off : off+tlvLen]
+		off += tlvLen
+
+		tlvs = append(tlvs, tlv)
+	}
+
+	return tlvs, end, nil
+}
+
+// Parses an MCUboot image.  The image's hash is not checked; see Verify().
+func ParseImage(data []byte) (*Image, error) {
+	if len(data) < IMAGE_HEADER_SIZE {
+		return nil, fmt.Errorf("image too short (%d bytes); not an MCUboot "+
+			"image", len(data))
+	}
+
+	hdr := ImageHeader{
+		Magic:     binary.LittleEndian.Uint32(data[0:]),
+		LoadAddr:  binary.LittleEndian.Uint32(data[4:]),
+		HdrSz:     binary.LittleEndian.Uint16(data[8:]),
+		ProtTlvSz: binary.LittleEndian.Uint16(data[10:]),
+		ImgSz:     binary.LittleEndian.Uint32(data[12:]),
+		Flags:     binary.LittleEndian.Uint32(data[16:]),
+		Vers: ImageVersion{
+			Major:    data[20],
+			Minor:    data[21],
+			Rev:      binary.LittleEndian.Uint16(data[22:]),
+			BuildNum: binary.LittleEndian.Uint32(data[24:]),
+		},
+	}
+
+	switch hdr.Magic {
+	case IMAGE_MAGIC:
+	case IMAGE_MAGIC_V1:
+		return nil, fmt.Errorf("unsupported legacy image format "+
+			"(magic=0x%08x)", hdr.Magic)
+	default:
+		return nil, fmt.Errorf("bad image magic: 0x%08x (expected 0x%08x)",
+			hdr.Magic, IMAGE_MAGIC)
+	}
+
+	if int(hdr.HdrSz) < IMAGE_HEADER_SIZE {
+		return nil, fmt.Errorf("invalid header size: %d", hdr.HdrSz)
+	}
+
+	bodyEnd := int(hdr.HdrSz) + int(hdr.ImgSz)
+	if bodyEnd > len(data) {
+		return nil, fmt.Errorf("image truncated; header specifies %d bytes "+
+			"of image data, file contains %d", hdr.ImgSz,
+			len(data)-int(hdr.HdrSz))
+	}
+
+	img := &Image{
+		Header: hdr,
+		Body:   data[hdr.HdrSz:bodyEnd],
+	}
+
+	off := bodyEnd
+	if hdr.ProtTlvSz > 0 {
+		tlvs, end, err := parseTlvArea(data, off, IMAGE_TLV_PROT_INFO_MAGIC,
+			true)
+		if err != nil {
+			return nil, err
+		}
+		if end-off != int(hdr.ProtTlvSz) {
+			return nil, fmt.Errorf("protected TLV area size mismatch: "+
+				"header=%d actual=%d", hdr.ProtTlvSz, end-off)
+		}
+		img.ProtTlvs = tlvs
+		off = end
+	}
+	img.Hashed = data[:off]
+
+	tlvs, _, err := parseTlvArea(data, off, IMAGE_TLV_INFO_MAGIC, false)
+	if err != nil {
+		return nil, err
+	}
+	img.Tlvs = tlvs
+
+	return img, nil
+}
+
+// Returns all TLVs of the specified type, protected ones first.
+func (img *Image) FindTlvs(typ uint8) []ImageTlv {
+	var tlvs []ImageTlv
+
+	for _, list := range [][]ImageTlv{img.ProtTlvs, img.Tlvs} {
+		for _, tlv := range list {
+			if tlv.Type == typ {
+				tlvs = append(tlvs, tlv)
+			}
+		}
+	}
+
+	return tlvs
+}
+
+// Returns the hash stored in the image's SHA256 TLV.
+func (img *Image) Hash() ([]byte, error) {
+	tlvs := img.FindTlvs(IMAGE_TLV_SHA256)
+	if len(tlvs) == 0 {
+		return nil, fmt.Errorf("image has no SHA256 TLV")
+	}
+	if len(tlvs) > 1 {
+		return nil, fmt.Errorf("image has %d SHA256 TLVs", len(tlvs))
+	}
+	if len(tlvs[0].Data) != sha256.Size {
+		return nil, fmt.Errorf("invalid SHA256 TLV length: %d",
+			len(tlvs[0].Data))
+	}
+
+	return tlvs[0].Data, nil
+}
+
+// Computes the image's hash from its contents.
+func (img *Image) CalcHash() []byte {
+	sum := sha256.Sum256(img.Hashed)
+	return sum[:]
+}
+
+// Checks that the image's SHA256 TLV matches its contents.
+func (img *Image) Verify() error {
+	hash, err := img.Hash()
+	if err != nil {
+		return err
+	}
+
+	calc := img.CalcHash()
+	if !bytes.Equal(hash, calc) {
+		return fmt.Errorf("image hash mismatch; TLV=%x computed=%x",
+			hash, calc)
+	}
+
+	return nil
+}
+
+// Parses an image and verifies its hash.
+func ParseAndVerify(data []byte) (*Image, error) {
+	img, err := ParseImage(data)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := img.Verify(); err != nil {
+		return nil, err
+	}
+
+	return img, nil
+}
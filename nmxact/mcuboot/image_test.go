@@ -0,0 +1,79 @@
+/**
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package mcuboot_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"mynewt.apache.org/newtmgr/nmxact/emulator"
+	"mynewt.apache.org/newtmgr/nmxact/mcuboot"
+)
+
+func TestParseImage(t *testing.T) {
+	ver := mcuboot.ImageVersion{Major: 1, Minor: 2, Rev: 3, BuildNum: 4}
+	data := emulator.BuildImage(ver, bytes.Repeat([]byte{0xaa}, 1000))
+
+	img, err := mcuboot.ParseAndVerify(data)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if img.Header.Vers != ver || img.Header.Vers.String() != "1.2.3.4" {
+		t.Errorf("version: got %s", img.Header.Vers.String())
+	}
+	if len(img.Body) != 1000 || len(img.Tlvs) != 1 ||
+		img.Tlvs[0].Type != mcuboot.IMAGE_TLV_SHA256 {
+
+		t.Errorf("unexpected layout: body=%d tlvs=%+v", len(img.Body),
+			img.Tlvs)
+	}
+}
+
+func TestParseImageErrors(t *testing.T) {
+	good := emulator.BuildImage(mcuboot.ImageVersion{Major: 1},
+		bytes.Repeat([]byte{0x55}, 100))
+
+	corrupt := func(fn func(b []byte) []byte) []byte {
+		b := append([]byte{}, good...)
+		return fn(b)
+	}
+
+	tests := []struct {
+		name string
+		data []byte
+		err  string
+	}{
+		{"magic", corrupt(func(b []byte) []byte { b[3] ^= 0xff; return b }),
+			"bad image magic"},
+		{"truncated", good[:len(good)-10], "truncated"},
+		{"hash", corrupt(func(b []byte) []byte { b[40] ^= 1; return b }),
+			"hash mismatch"},
+		{"no-tlvs", good[:mcuboot.IMAGE_HEADER_SIZE+100], "missing TLV info"},
+	}
+
+	for _, test := range tests {
+		_, err := mcuboot.ParseAndVerify(test.data)
+		if err == nil || !strings.Contains(err.Error(), test.err) {
+			t.Errorf("%s: want error containing %q, got %v", test.name,
+				test.err, err)
+		}
+	}
+}
@@ -0,0 +1,231 @@
+/**
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package mcuboot
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+)
+
+// Extracts a public key from a PEM file.  Public keys (PKIX or PKCS #1) and
+// the private keys that imgtool generates (SEC 1, PKCS #1, or PKCS #8) are
+// accepted; in the latter case, only the public part is used.
+func ParsePubKeyPem(data []byte) (crypto.PublicKey, error) {
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			return nil, fmt.Errorf(
+				"no public or private key found in PEM data")
+		}
+
+		switch block.Type {
+		case "PUBLIC KEY":
+			return x509.ParsePKIXPublicKey(block.Bytes)
+
+		case "RSA PUBLIC KEY":
+			return x509.ParsePKCS1PublicKey(block.Bytes)
+
+		case "EC PRIVATE KEY":
+			key, err := x509.ParseECPrivateKey(block.Bytes)
+			if err != nil {
+				return nil, err
+			}
+			return key.Public(), nil
+
+		case "RSA PRIVATE KEY":
+			key, err := x509.ParsePKCS1PrivateKey(block.Bytes)
+			if err != nil {
+				return nil, err
+			}
+			return key.Public(), nil
+
+		case "PRIVATE KEY":
+			key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+			if err != nil {
+				return nil, err
+			}
+			signer, ok := key.(crypto.Signer)
+			if !ok {
+				return nil, fmt.Errorf("unsupported private key type: %T",
+					key)
+			}
+			return signer.Public(), nil
+		}
+	}
+}
+
+// Returns the signature TLV type that corresponds to a public key, along with
+// the key encoding that MCUboot hashes to produce the KEYHASH TLV.
+func sigTlvType(key crypto.PublicKey) (uint8, []byte, error) {
+	switch k := key.(type) {
+	case *ecdsa.PublicKey:
+		if k.Curve != elliptic.P256() {
+			return 0, nil, fmt.Errorf("unsupported ECDSA curve: %s",
+				k.Curve.Params().Name)
+		}
+		der, err := x509.MarshalPKIXPublicKey(k)
+		if err != nil {
+			return 0, nil, err
+		}
+		return IMAGE_TLV_ECDSA256, der, nil
+
+	case ed25519.PublicKey:
+		der, err := x509.MarshalPKIXPublicKey(k)
+		if err != nil {
+			return 0, nil, err
+		}
+		return IMAGE_TLV_ED25519, der, nil
+
+	case *rsa.PublicKey:
+		der := x509.MarshalPKCS1PublicKey(k)
+		switch k.N.BitLen() {
+		case 2048:
+			return IMAGE_TLV_RSA2048_PSS, der, nil
+		case 3072:
+			return IMAGE_TLV_RSA3072_PSS, der, nil
+		default:
+			return 0, nil, fmt.Errorf("unsupported RSA key size: %d",
+				k.N.BitLen())
+		}
+
+	default:
+		return 0, nil, fmt.Errorf("unsupported key type: %T", key)
+	}
+}
+
+// Returns the short name of the signature algorithm used with a key, e.g.,
+// "ECDSA256".
+func KeyAlgorithm(key crypto.PublicKey) (string, error) {
+	typ, _, err := sigTlvType(key)
+	if err != nil {
+		return "", err
+	}
+
+	return TlvTypeToString(typ), nil
+}
+
+// Returns the SHA-256 hash of a public key, as stored in an image's KEYHASH
+// TLV.
+func KeyHash(key crypto.PublicKey) ([]byte, error) {
+	_, der, err := sigTlvType(key)
+	if err != nil {
+		return nil, err
+	}
+
+	sum := sha256.Sum256(der)
+	return sum[:], nil
+}
+
+func verifySig(key crypto.PublicKey, hash []byte, sig []byte) bool {
+	switch k := key.(type) {
+	case *ecdsa.PublicKey:
+		// MCUboot pads ECDSA signatures to a fixed size, so anything after
+		// the DER-encoded signature is ignored, as it is by the bootloader.
+		var esig struct {
+			R, S *big.Int
+		}
+		if _, err := asn1.Unmarshal(sig, &esig); err != nil {
+			return false
+		}
+		return ecdsa.Verify(k, hash, esig.R, esig.S)
+
+	case ed25519.PublicKey:
+		return ed25519.Verify(k, hash, sig)
+
+	case *rsa.PublicKey:
+		opts := &rsa.PSSOptions{
+			SaltLength: rsa.PSSSaltLengthEqualsHash,
+			Hash:       crypto.SHA256,
+		}
+		return rsa.VerifyPSS(k, crypto.SHA256, hash, sig, opts) == nil
+
+	default:
+		return false
+	}
+}
+
+// Checks that the image is intact and carries a valid signature made with
+// the private key corresponding to the specified public key.  An image may
+// contain several signatures; each is preceded by a KEYHASH TLV that
+// identifies the key that made it.
+func (img *Image) VerifySig(key crypto.PublicKey) error {
+	if err := img.Verify(); err != nil {
+		return err
+	}
+	hash, _ := img.Hash()
+
+	typ, _, err := sigTlvType(key)
+	if err != nil {
+		return err
+	}
+	keyHash, err := KeyHash(key)
+	if err != nil {
+		return err
+	}
+
+	var sigs [][]byte
+	var curKeyHash []byte
+	numSigs := 0
+	for _, tlv := range img.Tlvs {
+		switch tlv.Type {
+		case IMAGE_TLV_KEYHASH:
+			curKeyHash = tlv.Data
+
+		case IMAGE_TLV_RSA2048_PSS, IMAGE_TLV_ECDSA224, IMAGE_TLV_ECDSA256,
+			IMAGE_TLV_RSA3072_PSS, IMAGE_TLV_ED25519:
+
+			if tlv.Type == typ {
+				numSigs++
+				if curKeyHash == nil || bytes.Equal(curKeyHash, keyHash) {
+					sigs = append(sigs, tlv.Data)
+				}
+			}
+			curKeyHash = nil
+		}
+	}
+
+	if numSigs == 0 {
+		return fmt.Errorf("image has no %s signature", TlvTypeToString(typ))
+	}
+	if len(sigs) == 0 {
+		return fmt.Errorf("image not signed with this key; key hash %x "+
+			"not found", keyHash)
+	}
+
+	for _, sig := range sigs {
+		if verifySig(key, hash, sig) {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("%s signature verification failed",
+		TlvTypeToString(typ))
+}
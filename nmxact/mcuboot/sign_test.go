@@ -0,0 +1,202 @@
+/**
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package mcuboot_test
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/binary"
+	"encoding/pem"
+	"strings"
+	"testing"
+
+	"mynewt.apache.org/newtmgr/nmxact/emulator"
+	"mynewt.apache.org/newtmgr/nmxact/mcuboot"
+)
+
+func appendTlv(b []byte, typ uint8, data []byte) []byte {
+	hdr := make([]byte, mcuboot.IMAGE_TLV_HDR_SIZE)
+	hdr[0] = typ
+	binary.LittleEndian.PutUint16(hdr[2:], uint16(len(data)))
+	return append(append(b, hdr...), data...)
+}
+
+// Builds an image signed with the specified private key, the way imgtool
+// does: SHA256, KEYHASH, and signature TLVs.
+func signedImage(t *testing.T, priv crypto.Signer) []byte {
+	return paddedSignedImage(t, priv, 0)
+}
+
+// Builds a signed image whose signature is padded with zeros to the
+// specified length, as imgtool does with ECDSA signatures.
+func paddedSignedImage(t *testing.T, priv crypto.Signer, padTo int) []byte {
+	img, err := mcuboot.ParseImage(emulator.BuildImage(
+		mcuboot.ImageVersion{Major: 1}, make([]byte, 500)))
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	hash := img.CalcHash()
+
+	var sig []byte
+	var opts crypto.SignerOpts = crypto.SHA256
+	switch priv.(type) {
+	case ed25519.PrivateKey:
+		opts = crypto.Hash(0)
+	case *rsa.PrivateKey:
+		opts = &rsa.PSSOptions{
+			SaltLength: rsa.PSSSaltLengthEqualsHash,
+			Hash:       crypto.SHA256,
+		}
+	}
+	sig, err = priv.Sign(rand.Reader, hash, opts)
+	if err != nil {
+		t.Fatalf("sign: %v", err)
+	}
+	for len(sig) < padTo {
+		sig = append(sig, 0)
+	}
+
+	keyHash, err := mcuboot.KeyHash(priv.Public())
+	if err != nil {
+		t.Fatalf("key hash: %v", err)
+	}
+	alg, _ := mcuboot.KeyAlgorithm(priv.Public())
+	sigType := map[string]uint8{
+		"ECDSA256":    mcuboot.IMAGE_TLV_ECDSA256,
+		"ED25519":     mcuboot.IMAGE_TLV_ED25519,
+		"RSA2048_PSS": mcuboot.IMAGE_TLV_RSA2048_PSS,
+	}[alg]
+
+	var tlvs []byte
+	tlvs = appendTlv(tlvs, mcuboot.IMAGE_TLV_SHA256, hash)
+	tlvs = appendTlv(tlvs, mcuboot.IMAGE_TLV_KEYHASH, keyHash)
+	tlvs = appendTlv(tlvs, sigType, sig)
+
+	info := make([]byte, mcuboot.IMAGE_TLV_INFO_SIZE)
+	binary.LittleEndian.PutUint16(info[0:], mcuboot.IMAGE_TLV_INFO_MAGIC)
+	binary.LittleEndian.PutUint16(info[2:], uint16(len(info)+len(tlvs)))
+
+	data := append([]byte{}, img.Hashed...)
+	return append(append(data, info...), tlvs...)
+}
+
+func TestVerifySig(t *testing.T) {
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	ecKey2, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	_, edKey, _ := ed25519.GenerateKey(rand.Reader)
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("rsa keygen: %v", err)
+	}
+
+	for _, key := range []crypto.Signer{ecKey, edKey, rsaKey} {
+		data := signedImage(t, key)
+		img, err := mcuboot.ParseImage(data)
+		if err != nil {
+			t.Fatalf("parse: %v", err)
+		}
+		if err := img.VerifySig(key.Public()); err != nil {
+			t.Errorf("%T: %v", key, err)
+		}
+
+		// Corrupt the last byte of the signature.
+		data[len(data)-1] ^= 0xff
+		img, _ = mcuboot.ParseImage(data)
+		err = img.VerifySig(key.Public())
+		if err == nil || !strings.Contains(err.Error(), "failed") {
+			t.Errorf("%T: corrupt signature: got %v", key, err)
+		}
+	}
+
+	img, _ := mcuboot.ParseImage(signedImage(t, ecKey))
+	err = img.VerifySig(ecKey2.Public())
+	if err == nil || !strings.Contains(err.Error(), "not signed with this key") {
+		t.Errorf("wrong key: got %v", err)
+	}
+	err = img.VerifySig(edKey.Public())
+	if err == nil || !strings.Contains(err.Error(), "no ED25519 signature") {
+		t.Errorf("wrong key type: got %v", err)
+	}
+}
+
+func TestVerifySigPadded(t *testing.T) {
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+
+	// A DER-encoded P-256 signature is at most 72 bytes long, and is often
+	// shorter; sign until padding is needed.
+	var data []byte
+	for i := 0; ; i++ {
+		data = paddedSignedImage(t, ecKey, 72)
+		if data[len(data)-1] == 0 {
+			break
+		}
+		if i == 100 {
+			t.Fatalf("no signature needed padding")
+		}
+	}
+
+	img, err := mcuboot.ParseImage(data)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if err := img.VerifySig(ecKey.Public()); err != nil {
+		t.Errorf("padded signature: %v", err)
+	}
+
+	// Corrupt the start of the signature, which is still checked.
+	data[len(data)-72+10] ^= 0xff
+	img, _ = mcuboot.ParseImage(data)
+	err = img.VerifySig(ecKey.Public())
+	if err == nil || !strings.Contains(err.Error(), "failed") {
+		t.Errorf("corrupt padded signature: got %v", err)
+	}
+}
+
+func TestParsePubKeyPem(t *testing.T) {
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+
+	sec1, _ := x509.MarshalECPrivateKey(ecKey)
+	pkcs8, _ := x509.MarshalPKCS8PrivateKey(ecKey)
+	pkix, _ := x509.MarshalPKIXPublicKey(ecKey.Public())
+
+	blocks := []*pem.Block{
+		{Type: "EC PRIVATE KEY", Bytes: sec1},
+		{Type: "PRIVATE KEY", Bytes: pkcs8},
+		{Type: "PUBLIC KEY", Bytes: pkix},
+	}
+	for _, b := range blocks {
+		key, err := mcuboot.ParsePubKeyPem(pem.EncodeToMemory(b))
+		if err != nil {
+			t.Fatalf("%s: %v", b.Type, err)
+		}
+		if !ecKey.PublicKey.Equal(key) {
+			t.Errorf("%s: wrong key", b.Type)
+		}
+	}
+
+	if _, err := mcuboot.ParsePubKeyPem([]byte("junk")); err == nil {
+		t.Errorf("junk PEM accepted")
+	}
+}
@@ -20,6 +20,7 @@
 package mgmt
 
 import (
+	"context"
 	"encoding/hex"
 	"fmt"
 	"strings"
@@ -75,8 +76,8 @@ func NewTransceiver(txFilterCb, rxFilterCb nmcoap.MsgFilter, isTcp bool,
 	return t, nil
 }
 
-func (t *Transceiver) txRxNmp(txCb TxFn, req *nmp.NmpMsg, mtu int,
-	timeout time.Duration) (nmp.NmpRsp, error) {
+func (t *Transceiver) txRxNmp(ctx context.Context, txCb TxFn,
+	req *nmp.NmpMsg, mtu int, timeout time.Duration) (nmp.NmpRsp, error) {
 	//// time.Sleep(100 * time.Millisecond) ////
 
 	nl, err := t.nd.AddListener(req.Hdr.Seq)
@@ -108,6 +109,8 @@ func (t *Transceiver) txRxNmp(txCb TxFn, req *nmp.NmpMsg, mtu int,
 			return nil, err
 		case rsp := <-nl.RspChan:
 			return rsp, nil
+		case <-ctx.Done():
+			return nil, ctx.Err()
 		case _, ok := <-nl.AfterTimeout(timeout):
 			if ok {
 				return nil, nmxutil.NewRspTimeoutError("NMP timeout")
@@ -116,8 +119,8 @@ func (t *Transceiver) txRxNmp(txCb TxFn, req *nmp.NmpMsg, mtu int,
 	}
 }
 
-func (t *Transceiver) txRxOmp(txCb TxFn, req *nmp.NmpMsg, mtu int,
-	timeout time.Duration) (nmp.NmpRsp, error) {
+func (t *Transceiver) txRxOmp(ctx context.Context, txCb TxFn,
+	req *nmp.NmpMsg, mtu int, timeout time.Duration) (nmp.NmpRsp, error) {
 	//// time.Sleep(100 * time.Millisecond) ////
 
 	nl, err := t.od.AddNmpListener(req.Hdr.Seq)
@@ -155,6 +158,8 @@ func (t *Transceiver) txRxOmp(txCb TxFn, req *nmp.NmpMsg, mtu int,
 			return nil, err
 		case rsp := <-nl.RspChan:
 			return rsp, nil
+		case <-ctx.Done():
+			return nil, ctx.Err()
 		case _, ok := <-nl.AfterTimeout(timeout):
 			if ok {
 				return nil, nmxutil.NewRspTimeoutError("NMP timeout")
@@ -165,12 +170,20 @@ func (t *Transceiver) txRxOmp(txCb TxFn, req *nmp.NmpMsg, mtu int,
 
 func (t *Transceiver) TxRxMgmt(txCb TxFn, req *nmp.NmpMsg, mtu int,
 	timeout time.Duration) (nmp.NmpRsp, error) {
+
+	return t.TxRxMgmtContext(context.Background(), txCb, req, mtu, timeout)
+}
+
+// TxRxMgmtContext transmits a management request and waits for the
+// response, giving up with the context's error when the context is done.
+func (t *Transceiver) TxRxMgmtContext(ctx context.Context, txCb TxFn,
+	req *nmp.NmpMsg, mtu int, timeout time.Duration) (nmp.NmpRsp, error) {
 	//// time.Sleep(100 * time.Millisecond) ////
 
 	if t.nd != nil {
-		return t.txRxNmp(txCb, req, mtu, timeout)
+		return t.txRxNmp(ctx, txCb, req, mtu, timeout)
 	} else {
-		return t.txRxOmp(txCb, req, mtu, timeout)
+		return t.txRxOmp(ctx, txCb, req, mtu, timeout)
 	}
 }
 
@@ -21,6 +21,7 @@ package mtech_lora
 
 import (
 	"bytes"
+	"context"
 	"encoding/base64"
 	"encoding/binary"
 	"fmt"
@@ -305,6 +306,12 @@ func (s *LoraSesn) sendFragments(b []byte) error {
 func (s *LoraSesn) TxRxMgmt(m *nmp.NmpMsg,
 	timeout time.Duration) (nmp.NmpRsp, error) {
 
+	return s.TxRxMgmtContext(context.Background(), m, timeout)
+}
+
+func (s *LoraSesn) TxRxMgmtContext(ctx context.Context, m *nmp.NmpMsg,
+	timeout time.Duration) (nmp.NmpRsp, error) {
+
 	if !s.IsOpen() {
 		return nil, nmxutil.NewSesnClosedError(
 			"Attempt to transmit over closed Lora session")
@@ -313,11 +320,11 @@ func (s *LoraSesn) TxRxMgmt(m *nmp.NmpMsg,
 	txFunc := func(b []byte) error {
 		return s.sendFragments(b)
 	}
-	return s.txvr.TxRxMgmt(txFunc, m, s.MtuOut(), timeout)
+	return s.txvr.TxRxMgmtContext(ctx, txFunc, m, s.MtuOut(), timeout)
 }
 
 func (s *LoraSesn) AbortRx(seq uint8) error {
-	s.txvr.ErrorAll(fmt.Errorf("Rx aborted"))
+	s.txvr.AbortRx(seq)
 	return nil
 }
 
@@ -20,6 +20,7 @@
 package nmble
 
 import (
+	"context"
 	"time"
 
 	"github.com/runtimeco/go-coap"
@@ -110,6 +111,12 @@ func (s *BleSesn) TxRxMgmt(m *nmp.NmpMsg,
 	return s.Ns.TxRxMgmt(m, timeout)
 }
 
+func (s *BleSesn) TxRxMgmtContext(ctx context.Context, m *nmp.NmpMsg,
+	timeout time.Duration) (nmp.NmpRsp, error) {
+
+	return s.Ns.TxRxMgmtContext(ctx, m, timeout)
+}
+
 func (s *BleSesn) TxCoap(m coap.Message) error {
 	return s.Ns.TxCoap(m)
 }
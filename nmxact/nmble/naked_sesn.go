@@ -20,6 +20,7 @@
 package nmble
 
 import (
+	"context"
 	"fmt"
 	"sync"
 	"time"
@@ -320,35 +321,36 @@ func (s *NakedSesn) failIfNotOpen() error {
 func (s *NakedSesn) TxRxMgmt(m *nmp.NmpMsg,
 	timeout time.Duration) (nmp.NmpRsp, error) {
 
+	return s.TxRxMgmtContext(context.Background(), m, timeout)
+}
+
+func (s *NakedSesn) TxRxMgmtContext(ctx context.Context, m *nmp.NmpMsg,
+	timeout time.Duration) (nmp.NmpRsp, error) {
+
 	if err := s.failIfNotOpen(); err != nil {
 		return nil, err
 	}
 
-	var rsp nmp.NmpRsp
-
-	fn := func() error {
-		chr, err := s.getChr(s.mgmtChrs.NmpReqChr)
-		if err != nil {
-			return err
-		}
+	// Only the writes go through the task queue.  Waiting for the response
+	// happens outside of it so that several requests can be outstanding at
+	// once; the transceiver matches responses to requests by sequence
+	// number.
+	txRaw := func(b []byte) error {
+		return s.runTask(func() error {
+			chr, err := s.getChr(s.mgmtChrs.NmpReqChr)
+			if err != nil {
+				return err
+			}
 
-		txRaw := func(b []byte) error {
 			if s.cfg.Ble.WriteRsp {
 				return s.conn.WriteChr(chr, b, "nmp")
 			} else {
 				return s.conn.WriteChrNoRsp(chr, b, "nmp")
 			}
-		}
-
-		rsp, err = s.txvr.TxRxMgmt(txRaw, m, s.MtuOut(), timeout)
-		return err
-	}
-
-	if err := s.runTask(fn); err != nil {
-		return nil, err
+		})
 	}
 
-	return rsp, nil
+	return s.txvr.TxRxMgmtContext(ctx, txRaw, m, s.MtuOut(), timeout)
 }
 
 func (s *NakedSesn) ListenCoap(
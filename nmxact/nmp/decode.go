@@ -21,6 +21,7 @@ package nmp
 
 import (
 	"fmt"
+	"sync"
 
 	"github.com/ugorji/go/codec"
 )
@@ -45,7 +46,8 @@ type Ogi struct {
 	Id    uint8
 }
 
-type rspCtor func() NmpRsp
+// Creates an empty response of a specific type for the decoder to fill in.
+type RspCtor func() NmpRsp
 
 func echoRspCtor() NmpRsp          { return NewEchoRsp() }
 func taskStatRspCtor() NmpRsp      { return NewTaskStatRsp() }
@@ -75,7 +77,11 @@ func configReadRspCtor() NmpRsp    { return NewConfigReadRsp() }
 func configWriteRspCtor() NmpRsp   { return NewConfigWriteRsp() }
 func shellExecRspCtor() NmpRsp     { return NewShellExecRsp() }
 
-var rspCtorMap = map[Ogi]rspCtor{
+// Protects rspCtorMap; responses can be decoded while a package registers
+// its types.
+var rspCtorMtx sync.RWMutex
+
+var rspCtorMap = map[Ogi]RspCtor{
 	{op_wr, gr_def, NMP_ID_DEF_ECHO}:         echoRspCtor,
 	{op_rr, gr_def, NMP_ID_DEF_TASKSTAT}:     taskStatRspCtor,
 	{op_rr, gr_def, NMP_ID_DEF_MPSTAT}:       mpStatRspCtor,
@@ -106,11 +112,65 @@ var rspCtorMap = map[Ogi]rspCtor{
 	{op_wr, gr_she, NMP_ID_SHELL_EXEC}:       shellExecRspCtor,
 }
 
+// Registers the type of response to decode for the specified op, group, and
+// ID.  Allows packages to define their own request and response types, e.g.,
+// for application-defined groups at or above NMP_GROUP_PERUSER.  The op may
+// be given as either a request op (NMP_OP_READ / NMP_OP_WRITE) or the
+// corresponding response op.  Fails if a type is already registered for the
+// op, group, and ID, including the types that nmxact defines itself.
+//
+// The device's status code is taken from the response's int field named Rc,
+// if it has one; see RspError.
+func RegisterRsp(op uint8, group uint16, id uint8, ctor RspCtor) error {
+	switch op {
+	case NMP_OP_READ, NMP_OP_WRITE:
+		op++
+	case NMP_OP_READ_RSP, NMP_OP_WRITE_RSP:
+	default:
+		return fmt.Errorf("Invalid NMP op: %d", op)
+	}
+
+	if ctor == nil {
+		return fmt.Errorf("Nil NMP response constructor")
+	}
+
+	rspCtorMtx.Lock()
+	defer rspCtorMtx.Unlock()
+
+	ogi := Ogi{op, group, id}
+	if rspCtorMap[ogi] != nil {
+		return fmt.Errorf(
+			"NMP response type already registered: op=%d group=%d id=%d",
+			op, group, id)
+	}
+
+	rspCtorMap[ogi] = ctor
+	return nil
+}
+
+// Removes a response type registered with RegisterRsp.  Responses with the
+// specified op, group, and ID are then decoded into a *RawRsp.
+func UnregisterRsp(op uint8, group uint16, id uint8) {
+	if op == NMP_OP_READ || op == NMP_OP_WRITE {
+		op++
+	}
+
+	rspCtorMtx.Lock()
+	defer rspCtorMtx.Unlock()
+
+	delete(rspCtorMap, Ogi{op, group, id})
+}
+
+// Decodes a response body into the type registered for the response's op,
+// group, and ID.  Responses without a registered type are decoded into a
+// *RawRsp.
 func DecodeRspBody(hdr *NmpHdr, body []byte) (NmpRsp, error) {
+	rspCtorMtx.RLock()
 	cb := rspCtorMap[Ogi{hdr.Op, hdr.Group, hdr.Id}]
+	rspCtorMtx.RUnlock()
+
 	if cb == nil {
-		return nil, fmt.Errorf("Unrecognized NMP op+group+id: %d, %d, %d",
-			hdr.Op, hdr.Group, hdr.Id)
+		return DecodeRawRspBody(hdr, body)
 	}
 
 	r := cb()
@@ -26,13 +26,25 @@ const (
 	NMP_OP_WRITE_RSP = 3
 )
 
+// Status codes; see error.go for names and descriptions.
 const (
-	NMP_ERR_OK       = 0
-	NMP_ERR_EUNKNOWN = 1
-	NMP_ERR_ENOMEM   = 2
-	NMP_ERR_EINVAL   = 3
-	NMP_ERR_ETIMEOUT = 4
-	NMP_ERR_ENOENT   = 5
+	NMP_ERR_OK                  = 0
+	NMP_ERR_EUNKNOWN            = 1
+	NMP_ERR_ENOMEM              = 2
+	NMP_ERR_EINVAL              = 3
+	NMP_ERR_ETIMEOUT            = 4
+	NMP_ERR_ENOENT              = 5
+	NMP_ERR_EBADSTATE           = 6
+	NMP_ERR_EMSGSIZE            = 7
+	NMP_ERR_ENOTSUP             = 8
+	NMP_ERR_ECORRUPT            = 9
+	NMP_ERR_EBUSY               = 10
+	NMP_ERR_EACCESSDENIED       = 11
+	NMP_ERR_UNSUPPORTED_TOO_OLD = 12
+	NMP_ERR_UNSUPPORTED_TOO_NEW = 13
+
+	// Codes starting here are defined by individual groups.
+	NMP_ERR_EPERUSER = 256
 )
 
 // First 64 groups are reserved for system level newtmgr commands.
@@ -112,3 +124,31 @@ const (
 const (
 	NMP_ID_SHELL_EXEC = 0
 )
+
+type cmdKey struct {
+	group uint16
+	id    uint8
+}
+
+// Write requests whose effect changes if the device receives them more than
+// once.  These are never re-sent after a missing response, since the device
+// may have acted on the first copy.
+var nonIdempotentWrites = map[cmdKey]bool{
+	{NMP_GROUP_DEFAULT, NMP_ID_DEF_RESET}:    true,
+	{NMP_GROUP_IMAGE, NMP_ID_IMAGE_ERASE}:    true,
+	{NMP_GROUP_IMAGE, NMP_ID_IMAGE_CORELOAD}: true, // Core erase.
+	{NMP_GROUP_LOG, NMP_ID_LOG_APPEND}:       true,
+	{NMP_GROUP_CRASH, NMP_ID_CRASH_TRIGGER}:  true,
+	{NMP_GROUP_RUN, NMP_ID_RUN_TEST}:         true,
+	{NMP_GROUP_SHELL, NMP_ID_SHELL_EXEC}:     true,
+}
+
+// Indicates whether a request can safely be re-sent when its response fails
+// to arrive.
+func IsIdempotent(hdr *NmpHdr) bool {
+	if hdr.Op != NMP_OP_WRITE {
+		return true
+	}
+
+	return !nonIdempotentWrites[cmdKey{hdr.Group, hdr.Id}]
+}
@@ -35,6 +35,10 @@ type Listener struct {
 	ErrChan chan error
 	tmoChan chan time.Time
 	timer   *time.Timer
+
+	// Protects tmoChan from being closed while the timer is sending to it.
+	tmoMtx sync.Mutex
+	closed bool
 }
 
 func NewListener() *Listener {
@@ -47,7 +51,15 @@ func NewListener() *Listener {
 
 func (nl *Listener) AfterTimeout(tmo time.Duration) <-chan time.Time {
 	fn := func() {
-		nl.tmoChan <- time.Now()
+		nl.tmoMtx.Lock()
+		defer nl.tmoMtx.Unlock()
+
+		if !nl.closed {
+			select {
+			case nl.tmoChan <- time.Now():
+			default:
+			}
+		}
 	}
 	nl.timer = time.AfterFunc(tmo, fn)
 	return nl.tmoChan
@@ -58,6 +70,10 @@ func (nl *Listener) Close() {
 		nl.timer.Stop()
 	}
 
+	nl.tmoMtx.Lock()
+	nl.closed = true
+	nl.tmoMtx.Unlock()
+
 	close(nl.RspChan)
 	close(nl.ErrChan)
 	close(nl.tmoChan)
@@ -174,7 +190,12 @@ func (d *Dispatcher) ErrorOne(seq uint8, err error) error {
 		return fmt.Errorf("No NMP listener for seq %d", seq)
 	}
 
-	nl.ErrChan <- err
+	// Don't block if an error is already pending; the listener only needs
+	// one.
+	select {
+	case nl.ErrChan <- err:
+	default:
+	}
 
 	return nil
 }
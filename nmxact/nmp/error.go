@@ -0,0 +1,180 @@
+/**
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package nmp
+
+import (
+	"fmt"
+	"reflect"
+)
+
+type rcInfo struct {
+	name string
+	desc string
+}
+
+var rcInfoMap = map[int]rcInfo{
+	NMP_ERR_OK:                  {"EOK", "No error"},
+	NMP_ERR_EUNKNOWN:            {"EUNKNOWN", "Unknown error"},
+	NMP_ERR_ENOMEM:              {"ENOMEM", "Insufficient memory"},
+	NMP_ERR_EINVAL:              {"EINVAL", "Invalid argument"},
+	NMP_ERR_ETIMEOUT:            {"ETIMEOUT", "Operation timed out"},
+	NMP_ERR_ENOENT:              {"ENOENT", "No such file or entry"},
+	NMP_ERR_EBADSTATE:           {"EBADSTATE", "Current state disallows command"},
+	NMP_ERR_EMSGSIZE:            {"EMSGSIZE", "Response too large"},
+	NMP_ERR_ENOTSUP:             {"ENOTSUP", "Command not supported"},
+	NMP_ERR_ECORRUPT:            {"ECORRUPT", "Corrupt data"},
+	NMP_ERR_EBUSY:               {"EBUSY", "Device busy with another command"},
+	NMP_ERR_EACCESSDENIED:       {"EACCESSDENIED", "Access denied"},
+	NMP_ERR_UNSUPPORTED_TOO_OLD: {"UNSUPPORTED_TOO_OLD", "Protocol version too old"},
+	NMP_ERR_UNSUPPORTED_TOO_NEW: {"UNSUPPORTED_TOO_NEW", "Protocol version too new"},
+}
+
+// Returns the symbolic name of a status code, e.g., "ENOENT".
+func RcToString(rc int) string {
+	if info, ok := rcInfoMap[rc]; ok {
+		return info.name
+	}
+	if rc >= NMP_ERR_EPERUSER {
+		return fmt.Sprintf("EPERUSER+%d", rc-NMP_ERR_EPERUSER)
+	}
+
+	return fmt.Sprintf("%d", rc)
+}
+
+// Returns a short human-readable description of a status code.
+func RcDescription(rc int) string {
+	if info, ok := rcInfoMap[rc]; ok {
+		return info.desc
+	}
+	if rc >= NMP_ERR_EPERUSER {
+		return "Group-specific error"
+	}
+
+	return "Unrecognized error"
+}
+
+var groupNameMap = map[uint16]string{
+	NMP_GROUP_DEFAULT: "default",
+	NMP_GROUP_IMAGE:   "image",
+	NMP_GROUP_STAT:    "stat",
+	NMP_GROUP_CONFIG:  "config",
+	NMP_GROUP_LOG:     "log",
+	NMP_GROUP_CRASH:   "crash",
+	NMP_GROUP_SPLIT:   "split",
+	NMP_GROUP_RUN:     "run",
+	NMP_GROUP_FS:      "fs",
+	NMP_GROUP_SHELL:   "shell",
+}
+
+// Returns the name of a command group, or its number if it isn't one of the
+// standard groups.
+func GroupToString(group uint16) string {
+	if name, ok := groupNameMap[group]; ok {
+		return name
+	}
+
+	return fmt.Sprintf("%d", group)
+}
+
+// A status code reported by a device.  Rc values are comparable with
+// errors.Is, so callers can check for a particular failure without caring
+// which command produced it:
+//
+//	if errors.Is(err, nmp.ErrNoEnt) { ... }
+type Rc int
+
+func (rc Rc) Error() string {
+	return fmt.Sprintf("%s (%d): %s",
+		RcToString(int(rc)), int(rc), RcDescription(int(rc)))
+}
+
+var (
+	ErrUnknown      error = Rc(NMP_ERR_EUNKNOWN)
+	ErrNoMem        error = Rc(NMP_ERR_ENOMEM)
+	ErrInval        error = Rc(NMP_ERR_EINVAL)
+	ErrTimeout      error = Rc(NMP_ERR_ETIMEOUT)
+	ErrNoEnt        error = Rc(NMP_ERR_ENOENT)
+	ErrBadState     error = Rc(NMP_ERR_EBADSTATE)
+	ErrMsgSize      error = Rc(NMP_ERR_EMSGSIZE)
+	ErrNotSup       error = Rc(NMP_ERR_ENOTSUP)
+	ErrCorrupt      error = Rc(NMP_ERR_ECORRUPT)
+	ErrBusy         error = Rc(NMP_ERR_EBUSY)
+	ErrAccessDenied error = Rc(NMP_ERR_EACCESSDENIED)
+	ErrTooOld       error = Rc(NMP_ERR_UNSUPPORTED_TOO_OLD)
+	ErrTooNew       error = Rc(NMP_ERR_UNSUPPORTED_TOO_NEW)
+)
+
+// The error returned when a device responds to a request with a nonzero
+// status code.  It identifies the command that failed as well as the
+// status; use errors.As to get at the details.
+type NmpError struct {
+	Op    uint8
+	Group uint16
+	Id    uint8
+	Rc    int
+}
+
+func NewNmpError(hdr *NmpHdr, rc int) *NmpError {
+	return &NmpError{
+		Op:    hdr.Op,
+		Group: hdr.Group,
+		Id:    hdr.Id,
+		Rc:    rc,
+	}
+}
+
+func (e *NmpError) Error() string {
+	return fmt.Sprintf("device responded with %s (group=%s id=%d)",
+		Rc(e.Rc).Error(), GroupToString(e.Group), e.Id)
+}
+
+func (e *NmpError) Unwrap() error {
+	return Rc(e.Rc)
+}
+
+// Returns the status code in a response, or 0 if the response type doesn't
+// carry one.
+func RspRc(rsp NmpRsp) int {
+	v := reflect.ValueOf(rsp)
+	if v.Kind() == reflect.Ptr {
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		return 0
+	}
+
+	f := v.FieldByName("Rc")
+	if !f.IsValid() || f.Kind() != reflect.Int {
+		return 0
+	}
+
+	return int(f.Int())
+}
+
+// Returns an *NmpError if the response carries a nonzero status code, nil
+// otherwise.
+func RspError(rsp NmpRsp) error {
+	rc := RspRc(rsp)
+	if rc == 0 {
+		return nil
+	}
+
+	return NewNmpError(rsp.Hdr(), rc)
+}
@@ -242,8 +242,11 @@ func (r *CoreEraseRsp) Msg() *NmpMsg { return MsgFromReq(r) }
 // $erase                                                                   //
 //////////////////////////////////////////////////////////////////////////////
 
+// Slot is the flash slot to erase; image n's secondary slot is 2n+1.  If it
+// is zero, the device erases its default slot, image 0's secondary slot.
 type ImageEraseReq struct {
 	NmpBase `codec:"-"`
+	Slot    int `codec:"slot,omitempty"`
 }
 
 type ImageEraseRsp struct {
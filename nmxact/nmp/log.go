@@ -21,6 +21,8 @@ package nmp
 
 import (
 	"fmt"
+	"strconv"
+	"strings"
 )
 
 //////////////////////////////////////////////////////////////////////////////
@@ -96,6 +98,116 @@ func LogLevelToString(lm int) string {
 	return name
 }
 
+// Parses a log module given as a name from LogModuleNameMap (in any case)
+// or as a number.
+func LogModuleFromString(s string) (int, error) {
+	return logIdFromString(s, LogModuleNameMap, "module")
+}
+
+// Parses a log level given as a name from LogLevelNameMap (in any case) or
+// as a number.
+func LogLevelFromString(s string) (int, error) {
+	return logIdFromString(s, LogLevelNameMap, "level")
+}
+
+func logIdFromString(s string, names map[int]string,
+	what string) (int, error) {
+
+	for id, name := range names {
+		if strings.EqualFold(s, name) {
+			return id, nil
+		}
+	}
+
+	// Modules and levels are both one byte.
+	id, err := strconv.ParseUint(s, 0, 8)
+	if err != nil {
+		return 0, fmt.Errorf("invalid log %s: %s", what, s)
+	}
+
+	return int(id), nil
+}
+
+// Maps the log module and level IDs of a particular device to names.
+// Devices report the modules their applications register (see
+// LogModuleListRsp); IDs the device doesn't name fall back to the standard
+// tables.  A nil *LogNames uses the standard tables only.
+type LogNames struct {
+	Modules map[int]string
+	Levels  map[int]string
+}
+
+// Builds a LogNames from the name-to-ID maps in module list and level list
+// responses.  Either map may be nil.
+func NewLogNames(modules map[string]int, levels map[string]int) *LogNames {
+	n := &LogNames{
+		Modules: make(map[int]string, len(modules)),
+		Levels:  make(map[int]string, len(levels)),
+	}
+	for name, id := range modules {
+		n.Modules[id] = name
+	}
+	for name, id := range levels {
+		n.Levels[id] = name
+	}
+
+	return n
+}
+
+// Indicates whether the device named a module.
+func (n *LogNames) HasModule(id int) bool {
+	return n != nil && n.Modules[id] != ""
+}
+
+// Indicates whether the device named a level.
+func (n *LogNames) HasLevel(id int) bool {
+	return n != nil && n.Levels[id] != ""
+}
+
+func (n *LogNames) ModuleString(id int) string {
+	if n.HasModule(id) {
+		return n.Modules[id]
+	}
+
+	return LogModuleToString(id)
+}
+
+func (n *LogNames) LevelString(id int) string {
+	if n.HasLevel(id) {
+		return n.Levels[id]
+	}
+
+	return LogLevelToString(id)
+}
+
+// Parses a log module name or number, trying the device's names before the
+// standard ones.
+func (n *LogNames) ModuleFromString(s string) (int, error) {
+	if n != nil {
+		for id, name := range n.Modules {
+			if strings.EqualFold(s, name) {
+				return id, nil
+			}
+		}
+	}
+
+	return LogModuleFromString(s)
+}
+
+// Parses a log level name or number, trying the device's names before the
+// standard ones.
+func (n *LogNames) LevelFromString(s string) (int, error) {
+	if n != nil {
+		for id, name := range n.Levels {
+			if strings.EqualFold(s, name) {
+				return id, nil
+			}
+		}
+	}
+
+	return LogLevelFromString(s)
+}
+
 func LogTypeToString(lm int) string {
 	name := LogTypeNameMap[lm]
 	if name == "" {
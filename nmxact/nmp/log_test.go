@@ -0,0 +1,112 @@
+/**
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package nmp
+
+import (
+	"testing"
+)
+
+func TestLogNames(t *testing.T) {
+	dev := NewLogNames(
+		map[string]int{"APP": 64, "Sensor": 65},
+		map[string]int{"TRACE": 5})
+
+	tests := []struct {
+		name  string
+		names *LogNames
+		mod   int
+		level int
+		mstr  string
+		lstr  string
+	}{
+		// Device names.
+		{"device", dev, 64, 5, "APP", "TRACE"},
+		// IDs the device doesn't name fall back to the standard tables.
+		{"fallback", dev, MODULE_OS, LEVEL_WARN, "OS", "WARN"},
+		{"unknown", dev, 100, 7, "CUSTOM", "CUSTOM"},
+		// A nil receiver only knows the standard tables.
+		{"nil", nil, MODULE_NEWTMGR, LEVEL_ERROR, "NEWTMGR", "ERROR"},
+		{"nil unknown", nil, 64, 5, "CUSTOM", "CUSTOM"},
+	}
+
+	for _, tt := range tests {
+		if s := tt.names.ModuleString(tt.mod); s != tt.mstr {
+			t.Errorf("%s: ModuleString(%d) = %q, want %q",
+				tt.name, tt.mod, s, tt.mstr)
+		}
+		if s := tt.names.LevelString(tt.level); s != tt.lstr {
+			t.Errorf("%s: LevelString(%d) = %q, want %q",
+				tt.name, tt.level, s, tt.lstr)
+		}
+	}
+
+	var nilNames *LogNames
+	if nilNames.HasModule(MODULE_OS) || nilNames.HasLevel(LEVEL_INFO) {
+		t.Errorf("nil LogNames names an ID")
+	}
+	if !dev.HasModule(64) || dev.HasModule(MODULE_OS) {
+		t.Errorf("HasModule: wrong result")
+	}
+}
+
+func TestLogNamesFromString(t *testing.T) {
+	dev := NewLogNames(
+		map[string]int{"APP": 64, "Sensor": 65},
+		map[string]int{"TRACE": 5})
+
+	tests := []struct {
+		names *LogNames
+		s     string
+		mod   int
+		merr  bool
+		level int
+		lerr  bool
+	}{
+		// Names match in any case.
+		{dev, "app", 64, false, 0, true},
+		{dev, "SENSOR", 65, false, 0, true},
+		{dev, "trace", 0, true, 5, false},
+		// Standard names and numbers work with or without device names.
+		{dev, "newtmgr", MODULE_NEWTMGR, false, 0, true},
+		{dev, "Warn", 0, true, LEVEL_WARN, false},
+		{dev, "0x42", 0x42, false, 0x42, false},
+		{nil, "os", MODULE_OS, false, 0, true},
+		{nil, "critical", 0, true, LEVEL_CRITICAL, false},
+		{nil, "7", 7, false, 7, false},
+		// Device names are unknown without a device.
+		{nil, "app", 0, true, 0, true},
+		// IDs are one byte.
+		{dev, "256", 0, true, 0, true},
+	}
+
+	for _, tt := range tests {
+		mod, err := tt.names.ModuleFromString(tt.s)
+		if (err != nil) != tt.merr || (err == nil && mod != tt.mod) {
+			t.Errorf("ModuleFromString(%q) = %d, %v; want %d, error %v",
+				tt.s, mod, err, tt.mod, tt.merr)
+		}
+
+		level, err := tt.names.LevelFromString(tt.s)
+		if (err != nil) != tt.lerr || (err == nil && level != tt.level) {
+			t.Errorf("LevelFromString(%q) = %d, %v; want %d, error %v",
+				tt.s, level, err, tt.level, tt.lerr)
+		}
+	}
+}
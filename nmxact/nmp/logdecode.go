@@ -0,0 +1,366 @@
+/**
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package nmp
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sync"
+)
+
+// A named value decoded from the message of a binary log entry.
+type LogField struct {
+	Name  string
+	Value interface{}
+}
+
+// Decodes the messages of binary log entries into fields.  Firmware writes
+// binary entries as packed structs, so the layout of a message depends on the
+// module that wrote it and, possibly, the image.
+type LogDecoder interface {
+	DecodeLogMsg(msg []byte) ([]LogField, error)
+}
+
+// Adapts an ordinary function to the LogDecoder interface.
+type LogDecoderFunc func(msg []byte) ([]LogField, error)
+
+func (f LogDecoderFunc) DecodeLogMsg(msg []byte) ([]LogField, error) {
+	return f(msg)
+}
+
+type logDecoderReg struct {
+	module  uint8
+	imgHash []byte
+	dec     LogDecoder
+}
+
+// Protects logDecoders; entries can be decoded while a package registers its
+// decoders.
+var logDecoderMtx sync.RWMutex
+var logDecoders []logDecoderReg
+
+// Indicates whether a registered image hash applies to an entry.  Devices
+// only report the first few bytes of the hash, so the shorter of the two is
+// compared against the start of the other.
+func logImgHashMatch(regHash []byte, entryHash []byte) bool {
+	n := len(regHash)
+	if len(entryHash) < n {
+		n = len(entryHash)
+	}
+
+	return n > 0 && bytes.Equal(regHash[:n], entryHash[:n])
+}
+
+// Registers a decoder for the binary log entries written by the specified
+// module.  If imgHash is nil, the decoder applies to entries from any image;
+// otherwise, it only applies to entries from the image with that hash, and it
+// takes precedence over a decoder for any image.  Fails if a decoder is
+// already registered for the module and hash.
+func RegisterLogDecoder(module uint8, imgHash []byte, dec LogDecoder) error {
+	if dec == nil {
+		return fmt.Errorf("Nil log decoder")
+	}
+
+	logDecoderMtx.Lock()
+	defer logDecoderMtx.Unlock()
+
+	for _, r := range logDecoders {
+		if r.module == module && bytes.Equal(r.imgHash, imgHash) {
+			return fmt.Errorf(
+				"Log decoder already registered: module=%d img_hash=%x",
+				module, imgHash)
+		}
+	}
+
+	logDecoders = append(logDecoders, logDecoderReg{
+		module:  module,
+		imgHash: imgHash,
+		dec:     dec,
+	})
+	return nil
+}
+
+// Removes the decoder registered for the specified module and hash, if any.
+func UnregisterLogDecoder(module uint8, imgHash []byte) {
+	logDecoderMtx.Lock()
+	defer logDecoderMtx.Unlock()
+
+	for i, r := range logDecoders {
+		if r.module == module && bytes.Equal(r.imgHash, imgHash) {
+			logDecoders = append(logDecoders[:i], logDecoders[i+1:]...)
+			return
+		}
+	}
+}
+
+// Retrieves the decoder that applies to a log entry.  Returns nil if there is
+// none.
+func FindLogDecoder(entry LogEntry) LogDecoder {
+	logDecoderMtx.RLock()
+	defer logDecoderMtx.RUnlock()
+
+	var dec LogDecoder
+	for _, r := range logDecoders {
+		if r.module != entry.Module {
+			continue
+		}
+
+		if r.imgHash == nil {
+			if dec == nil {
+				dec = r.dec
+			}
+		} else if logImgHashMatch(r.imgHash, entry.ImgHash) {
+			return r.dec
+		}
+	}
+
+	return dec
+}
+
+// Decodes the message of a binary log entry with the decoder that applies to
+// it.  Returns nil fields and a nil error if the entry isn't binary or no
+// decoder applies.
+func DecodeLogEntry(entry LogEntry) ([]LogField, error) {
+	if entry.Type != LOG_ENTRY_TYPE_BINARY {
+		return nil, nil
+	}
+
+	dec := FindLogDecoder(entry)
+	if dec == nil {
+		return nil, nil
+	}
+
+	return dec.DecodeLogMsg(entry.Msg)
+}
+
+// Field types in a log layout.
+const (
+	LOG_FIELD_U8     = "u8"
+	LOG_FIELD_U16    = "u16"
+	LOG_FIELD_U32    = "u32"
+	LOG_FIELD_U64    = "u64"
+	LOG_FIELD_I8     = "i8"
+	LOG_FIELD_I16    = "i16"
+	LOG_FIELD_I32    = "i32"
+	LOG_FIELD_I64    = "i64"
+	LOG_FIELD_F32    = "f32"
+	LOG_FIELD_F64    = "f64"
+	LOG_FIELD_BOOL   = "bool"
+	LOG_FIELD_BYTES  = "bytes"
+	LOG_FIELD_STRING = "string"
+	LOG_FIELD_PAD    = "pad"
+)
+
+var logFieldSizes = map[string]int{
+	LOG_FIELD_U8:   1,
+	LOG_FIELD_U16:  2,
+	LOG_FIELD_U32:  4,
+	LOG_FIELD_U64:  8,
+	LOG_FIELD_I8:   1,
+	LOG_FIELD_I16:  2,
+	LOG_FIELD_I32:  4,
+	LOG_FIELD_I64:  8,
+	LOG_FIELD_F32:  4,
+	LOG_FIELD_F64:  8,
+	LOG_FIELD_BOOL: 1,
+}
+
+// Describes a field in a packed struct.  Len is the size of a bytes, string,
+// or pad field; a bytes or string field with a Len of 0 extends to the end of
+// the message, so it must be the last field.  Bytes are decoded as a hex
+// string and strings end at the first NUL; pad fields are skipped.
+type LogLayoutField struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+	Len  int    `json:"len,omitempty"`
+}
+
+// Describes the packed struct that a module writes as the message of its
+// binary log entries.  ImgHash optionally restricts the layout to a single
+// image; it is a hex string with an even number of digits, and may be a
+// prefix of the hash.  Endian is "little" (the default) or "big".
+type LogLayout struct {
+	Module  uint8            `json:"module"`
+	ImgHash string           `json:"img_hash,omitempty"`
+	Endian  string           `json:"endian,omitempty"`
+	Fields  []LogLayoutField `json:"fields"`
+}
+
+type logLayoutDecoder struct {
+	order  binary.ByteOrder
+	fields []LogLayoutField
+}
+
+// Creates a decoder for messages with the specified layout.
+func NewLogLayoutDecoder(layout LogLayout) (LogDecoder, error) {
+	d := &logLayoutDecoder{
+		fields: layout.Fields,
+	}
+
+	switch layout.Endian {
+	case "", "little":
+		d.order = binary.LittleEndian
+	case "big":
+		d.order = binary.BigEndian
+	default:
+		return nil, fmt.Errorf("Invalid log layout endianness: %s",
+			layout.Endian)
+	}
+
+	for i, f := range layout.Fields {
+		switch f.Type {
+		case LOG_FIELD_BYTES, LOG_FIELD_STRING:
+			if f.Len < 0 || (f.Len == 0 && i != len(layout.Fields)-1) {
+				return nil, fmt.Errorf(
+					"Invalid length for log layout field \"%s\": %d",
+					f.Name, f.Len)
+			}
+
+		case LOG_FIELD_PAD:
+			if f.Len <= 0 {
+				return nil, fmt.Errorf(
+					"Invalid length for log layout field \"%s\": %d",
+					f.Name, f.Len)
+			}
+
+		default:
+			if logFieldSizes[f.Type] == 0 {
+				return nil, fmt.Errorf(
+					"Invalid type for log layout field \"%s\": %s",
+					f.Name, f.Type)
+			}
+		}
+
+		if f.Name == "" && f.Type != LOG_FIELD_PAD {
+			return nil, fmt.Errorf("Log layout field %d has no name", i)
+		}
+	}
+
+	return d, nil
+}
+
+// Decodes a message.  Bytes that follow the last field are ignored.
+func (d *logLayoutDecoder) DecodeLogMsg(msg []byte) ([]LogField, error) {
+	var fields []LogField
+
+	off := 0
+	for _, f := range d.fields {
+		size := logFieldSizes[f.Type]
+		if size == 0 {
+			size = f.Len
+			if size == 0 {
+				size = len(msg) - off
+			}
+		}
+
+		if off+size > len(msg) {
+			return nil, fmt.Errorf(
+				"Log message too short: field \"%s\" needs %d bytes at "+
+					"offset %d; message is %d bytes",
+				f.Name, size, off, len(msg))
+		}
+		b := msg[off : off+size]
+		off += size
+
+		var val interface{}
+		switch f.Type {
+		case LOG_FIELD_U8:
+			val = uint64(b[0])
+		case LOG_FIELD_U16:
+			val = uint64(d.order.Uint16(b))
+		case LOG_FIELD_U32:
+			val = uint64(d.order.Uint32(b))
+		case LOG_FIELD_U64:
+			val = d.order.Uint64(b)
+		case LOG_FIELD_I8:
+			val = int64(int8(b[0]))
+		case LOG_FIELD_I16:
+			val = int64(int16(d.order.Uint16(b)))
+		case LOG_FIELD_I32:
+			val = int64(int32(d.order.Uint32(b)))
+		case LOG_FIELD_I64:
+			val = int64(d.order.Uint64(b))
+		case LOG_FIELD_F32:
+			val = math.Float32frombits(d.order.Uint32(b))
+		case LOG_FIELD_F64:
+			val = math.Float64frombits(d.order.Uint64(b))
+		case LOG_FIELD_BOOL:
+			val = b[0] != 0
+		case LOG_FIELD_BYTES:
+			val = hex.EncodeToString(b)
+		case LOG_FIELD_STRING:
+			if i := bytes.IndexByte(b, 0); i >= 0 {
+				b = b[:i]
+			}
+			val = string(b)
+		case LOG_FIELD_PAD:
+			continue
+		}
+
+		fields = append(fields, LogField{Name: f.Name, Value: val})
+	}
+
+	return fields, nil
+}
+
+// Parses a JSON array of log layouts, e.g.:
+//
+//	[{"module": 64, "endian": "little", "fields": [
+//	    {"name": "sensor", "type": "u8"},
+//	    {"type": "pad", "len": 1},
+//	    {"name": "temp", "type": "i16"}]}]
+func ParseLogLayouts(data []byte) ([]LogLayout, error) {
+	var layouts []LogLayout
+	if err := json.Unmarshal(data, &layouts); err != nil {
+		return nil, fmt.Errorf("Invalid log layouts: %s", err.Error())
+	}
+
+	return layouts, nil
+}
+
+// Creates a decoder for each layout and registers it.
+func RegisterLogLayouts(layouts []LogLayout) error {
+	for _, l := range layouts {
+		var hash []byte
+		if l.ImgHash != "" {
+			var err error
+			hash, err = hex.DecodeString(l.ImgHash)
+			if err != nil {
+				return fmt.Errorf("Invalid log layout image hash: %s; "+
+					"expected an even number of hex digits", l.ImgHash)
+			}
+		}
+
+		dec, err := NewLogLayoutDecoder(l)
+		if err != nil {
+			return err
+		}
+
+		if err := RegisterLogDecoder(l.Module, hash, dec); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,262 @@
+/**
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package nmp
+
+import (
+	"reflect"
+	"testing"
+)
+
+func mustRegisterLogLayouts(t *testing.T, js string) {
+	layouts, err := ParseLogLayouts([]byte(js))
+	if err != nil {
+		t.Fatalf("parse layouts: %v", err)
+	}
+	if err := RegisterLogLayouts(layouts); err != nil {
+		t.Fatalf("register layouts: %v", err)
+	}
+	t.Cleanup(func() {
+		for _, l := range layouts {
+			var hash []byte
+			if l.ImgHash != "" {
+				hash = []byte{0x12, 0x34}
+			}
+			UnregisterLogDecoder(l.Module, hash)
+		}
+	})
+}
+
+func decodeLayout(t *testing.T, layout LogLayout,
+	msg []byte) ([]LogField, error) {
+
+	dec, err := NewLogLayoutDecoder(layout)
+	if err != nil {
+		t.Fatalf("new decoder: %v", err)
+	}
+	return dec.DecodeLogMsg(msg)
+}
+
+func TestLogDecoder(t *testing.T) {
+	mustRegisterLogLayouts(t, `[{
+		"module": 64,
+		"fields": [
+			{"name": "sensor", "type": "u8"},
+			{"type": "pad", "len": 1},
+			{"name": "temp", "type": "i16"},
+			{"name": "tag", "type": "string", "len": 4}
+		]
+	}]`)
+
+	entry := LogEntry{
+		Module:  64,
+		Type:    LOG_ENTRY_TYPE_BINARY,
+		ImgHash: []byte{0x12, 0x34, 0x56, 0x78},
+		Msg:     []byte{0x07, 0x00, 0x2c, 0xff, 'a', 'b', 0, 0},
+	}
+
+	fields, err := DecodeLogEntry(entry)
+	if err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	want := []LogField{
+		{Name: "sensor", Value: uint64(7)},
+		{Name: "temp", Value: int64(-212)},
+		{Name: "tag", Value: "ab"},
+	}
+	if !reflect.DeepEqual(fields, want) {
+		t.Errorf("decode: got %v, want %v", fields, want)
+	}
+
+	// A decoder for the entry's image takes precedence; the image hash only
+	// needs to match the start of the entry's.
+	img := func(msg []byte) ([]LogField, error) {
+		return []LogField{{Name: "len", Value: len(msg)}}, nil
+	}
+	if err := RegisterLogDecoder(64, []byte{0x12, 0x34},
+		LogDecoderFunc(img)); err != nil {
+
+		t.Fatalf("register decoder: %v", err)
+	}
+	defer UnregisterLogDecoder(64, []byte{0x12, 0x34})
+
+	fields, err = DecodeLogEntry(entry)
+	if err != nil || len(fields) != 1 || fields[0].Value != 8 {
+		t.Errorf("decode with image decoder: got %v, %v", fields, err)
+	}
+
+	// Other images still get the general decoder.
+	entry.ImgHash = []byte{0x99}
+	if fields, err := DecodeLogEntry(entry); err != nil || len(fields) != 3 {
+		t.Errorf("decode for other image: got %v, %v", fields, err)
+	}
+
+	// Registering the same module and image twice fails.
+	if err := RegisterLogDecoder(64, []byte{0x12, 0x34},
+		LogDecoderFunc(img)); err == nil {
+
+		t.Errorf("duplicate decoder registered")
+	}
+
+	// Messages shorter than the layout are rejected.
+	entry.Msg = entry.Msg[:3]
+	if _, err := DecodeLogEntry(entry); err == nil {
+		t.Errorf("decode of short message succeeded")
+	}
+
+	// Entries that aren't binary, or that no decoder applies to, are left
+	// alone.
+	entry.Type = LOG_ENTRY_TYPE_STRING
+	if fields, err := DecodeLogEntry(entry); fields != nil || err != nil {
+		t.Errorf("decode of string entry: got %v, %v", fields, err)
+	}
+	entry.Type = LOG_ENTRY_TYPE_BINARY
+	entry.Module = 65
+	if fields, err := DecodeLogEntry(entry); fields != nil || err != nil {
+		t.Errorf("decode without decoder: got %v, %v", fields, err)
+	}
+}
+
+func TestLogLayoutEndian(t *testing.T) {
+	fields := []LogLayoutField{
+		{Name: "u16", Type: LOG_FIELD_U16},
+		{Name: "i32", Type: LOG_FIELD_I32},
+		{Name: "u64", Type: LOG_FIELD_U64},
+		{Name: "f32", Type: LOG_FIELD_F32},
+		{Name: "ok", Type: LOG_FIELD_BOOL},
+	}
+	msg := []byte{
+		0x01, 0x02,
+		0xff, 0xff, 0xff, 0xfe,
+		0, 0, 0, 0, 0, 0, 0x01, 0x00,
+		0x3f, 0xc0, 0x00, 0x00,
+		0x01,
+	}
+
+	got, err := decodeLayout(t, LogLayout{Endian: "big", Fields: fields}, msg)
+	if err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	want := []LogField{
+		{Name: "u16", Value: uint64(0x0102)},
+		{Name: "i32", Value: int64(-2)},
+		{Name: "u64", Value: uint64(0x100)},
+		{Name: "f32", Value: float32(1.5)},
+		{Name: "ok", Value: true},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("big endian: got %v, want %v", got, want)
+	}
+
+	got, err = decodeLayout(t, LogLayout{Fields: fields[:1]}, msg)
+	if err != nil || got[0].Value != uint64(0x0201) {
+		t.Errorf("little endian: got %v, %v", got, err)
+	}
+}
+
+func TestLogLayoutVarLen(t *testing.T) {
+	msg := []byte{0x01, 0xaa, 0xbb, 0x02, 'h', 'i', 0, 'x'}
+
+	// Pad fields are skipped, and a final field without a length takes the
+	// rest of the message.
+	got, err := decodeLayout(t, LogLayout{Fields: []LogLayoutField{
+		{Name: "a", Type: LOG_FIELD_U8},
+		{Type: LOG_FIELD_PAD, Len: 2},
+		{Name: "b", Type: LOG_FIELD_U8},
+		{Name: "s", Type: LOG_FIELD_STRING},
+	}}, msg)
+	want := []LogField{
+		{Name: "a", Value: uint64(1)},
+		{Name: "b", Value: uint64(2)},
+		{Name: "s", Value: "hi"},
+	}
+	if err != nil || !reflect.DeepEqual(got, want) {
+		t.Errorf("trailing string: got %v, %v; want %v", got, err, want)
+	}
+
+	got, err = decodeLayout(t, LogLayout{Fields: []LogLayoutField{
+		{Name: "a", Type: LOG_FIELD_U8},
+		{Name: "rest", Type: LOG_FIELD_BYTES},
+	}}, msg)
+	want = []LogField{
+		{Name: "a", Value: uint64(1)},
+		{Name: "rest", Value: "aabb0268690078"},
+	}
+	if err != nil || !reflect.DeepEqual(got, want) {
+		t.Errorf("trailing bytes: got %v, %v; want %v", got, err, want)
+	}
+
+	// A trailing field can be empty.
+	got, err = decodeLayout(t, LogLayout{Fields: []LogLayoutField{
+		{Name: "all", Type: LOG_FIELD_BYTES, Len: 8},
+		{Name: "rest", Type: LOG_FIELD_STRING},
+	}}, msg)
+	if err != nil || len(got) != 2 || got[1].Value != "" {
+		t.Errorf("empty trailing string: got %v, %v", got, err)
+	}
+}
+
+func TestLogLayoutInvalid(t *testing.T) {
+	u8 := LogLayoutField{Name: "a", Type: LOG_FIELD_U8}
+
+	tests := []struct {
+		name   string
+		layout LogLayout
+	}{
+		{"endian", LogLayout{Endian: "middle",
+			Fields: []LogLayoutField{u8}}},
+		{"type", LogLayout{Fields: []LogLayoutField{
+			{Name: "a", Type: "u24"}}}},
+		{"no name", LogLayout{Fields: []LogLayoutField{
+			{Type: LOG_FIELD_U8}}}},
+		{"pad length", LogLayout{Fields: []LogLayoutField{
+			{Type: LOG_FIELD_PAD}, u8}}},
+		{"negative length", LogLayout{Fields: []LogLayoutField{
+			{Name: "s", Type: LOG_FIELD_STRING, Len: -1}}}},
+		{"open field not last", LogLayout{Fields: []LogLayoutField{
+			{Name: "b", Type: LOG_FIELD_BYTES}, u8}}},
+	}
+
+	for _, tt := range tests {
+		if _, err := NewLogLayoutDecoder(tt.layout); err == nil {
+			t.Errorf("%s: invalid layout accepted", tt.name)
+		}
+	}
+
+	bad := []string{
+		`{"module": 1}`,
+		`[{"module": 300, "fields": []}]`,
+		`[{"module": 1, "fields": [{"name": "a", "type": 5}]}]`,
+	}
+	for _, js := range bad {
+		if _, err := ParseLogLayouts([]byte(js)); err == nil {
+			t.Errorf("invalid layouts accepted: %s", js)
+		}
+	}
+
+	// Image hashes must be whole bytes of hex.
+	for _, hash := range []string{"123", "xyz1"} {
+		err := RegisterLogLayouts([]LogLayout{{Module: 1, ImgHash: hash,
+			Fields: []LogLayoutField{u8}}})
+		if err == nil {
+			UnregisterLogDecoder(1, nil)
+			t.Errorf("image hash %q accepted", hash)
+		}
+	}
+}
@@ -177,3 +177,10 @@ func fillNmpReq(req NmpReq, op uint8, group uint16, id uint8) {
 	//// time.Sleep(100 * time.Millisecond) ////
 	fillNmpReqWithSeq(req, op, group, id, nmxutil.NextNmpSeq())
 }
+
+// Fills in the header of a request with the specified op, group, and ID and
+// the next sequence number.  For use by request types defined outside of
+// this package; see RegisterRsp.
+func FillReq(req NmpReq, op uint8, group uint16, id uint8) {
+	fillNmpReq(req, op, group, id)
+}
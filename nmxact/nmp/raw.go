@@ -0,0 +1,114 @@
+/**
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package nmp
+
+import (
+	"fmt"
+	"reflect"
+
+	"github.com/ugorji/go/codec"
+)
+
+// A request with an arbitrary op, group, and ID.  Used for management groups
+// that nmxact has no specific request type for, e.g., application-defined
+// groups at or above NMP_GROUP_PERUSER.  The body is encoded as a CBOR map.
+type RawReq struct {
+	NmpBase
+	Body map[string]interface{}
+}
+
+// A response that is decoded into a generic map rather than a specific type.
+type RawRsp struct {
+	NmpBase
+	Body map[string]interface{}
+
+	// Copied from the body's "rc" entry; 0 if the response has none.
+	Rc int
+}
+
+func NewRawReq(op uint8, group uint16, id uint8) *RawReq {
+	r := &RawReq{
+		Body: map[string]interface{}{},
+	}
+	fillNmpReq(r, op, group, id)
+	return r
+}
+
+func (r *RawReq) Msg() *NmpMsg {
+	return &NmpMsg{
+		Hdr:  *r.Hdr(),
+		Body: r.Body,
+	}
+}
+
+func NewRawRsp() *RawRsp {
+	return &RawRsp{
+		Body: map[string]interface{}{},
+	}
+}
+
+func (r *RawRsp) Msg() *NmpMsg {
+	return &NmpMsg{
+		Hdr:  *r.Hdr(),
+		Body: r.Body,
+	}
+}
+
+// Decodes a response body into a generic map, regardless of the response's
+// op, group, and ID.
+func DecodeRawRspBody(hdr *NmpHdr, body []byte) (*RawRsp, error) {
+	r := NewRawRsp()
+
+	// Decode nested maps with string keys so that the result can be
+	// converted to JSON.
+	cborCodec := new(codec.CborHandle)
+	cborCodec.MapType = reflect.TypeOf(map[string]interface{}(nil))
+
+	if len(body) > 0 {
+		dec := codec.NewDecoderBytes(body, cborCodec)
+		if err := dec.Decode(&r.Body); err != nil {
+			return nil, fmt.Errorf("Invalid response: %s", err.Error())
+		}
+	}
+
+	switch rc := r.Body["rc"].(type) {
+	case int64:
+		r.Rc = int(rc)
+	case uint64:
+		r.Rc = int(rc)
+	}
+
+	r.SetHdr(hdr)
+	return r, nil
+}
+
+// Converts a response of any type into a raw response.
+func ToRawRsp(rsp NmpRsp) (*RawRsp, error) {
+	if r, ok := rsp.(*RawRsp); ok {
+		return r, nil
+	}
+
+	body, err := BodyBytes(rsp)
+	if err != nil {
+		return nil, err
+	}
+
+	return DecodeRawRspBody(rsp.Hdr(), body)
+}
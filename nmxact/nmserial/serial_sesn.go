@@ -20,6 +20,7 @@
 package nmserial
 
 import (
+	"context"
 	"fmt"
 	"sync"
 	"time"
@@ -42,9 +43,14 @@ type SerialSesn struct {
 
 	// This mutex ensures:
 	//     * accesses to isOpen are protected.
+	//     * accesses to rspRefs are protected.
 	m  sync.Mutex
 	wg sync.WaitGroup
 
+	// Number of transactions waiting for a response.  The session stays
+	// registered as the transport's response session while this is nonzero.
+	rspRefs int
+
 	errChan  chan error
 	msgChan  chan []byte
 	connChan chan *SerialSesn
@@ -188,13 +194,19 @@ func (s *SerialSesn) MtuOut() int {
 }
 
 func (s *SerialSesn) AbortRx(seq uint8) error {
-	s.txvr.ErrorAll(fmt.Errorf("Rx aborted"))
+	s.txvr.AbortRx(seq)
 	return nil
 }
 
 func (s *SerialSesn) TxRxMgmt(m *nmp.NmpMsg,
 	timeout time.Duration) (nmp.NmpRsp, error) {
 
+	return s.TxRxMgmtContext(context.Background(), m, timeout)
+}
+
+func (s *SerialSesn) TxRxMgmtContext(ctx context.Context, m *nmp.NmpMsg,
+	timeout time.Duration) (nmp.NmpRsp, error) {
+
 	if !s.isOpen {
 		return nil, nmxutil.NewSesnClosedError(
 			"Attempt to transmit over closed serial session")
@@ -204,13 +216,36 @@ func (s *SerialSesn) TxRxMgmt(m *nmp.NmpMsg,
 		return s.sx.Tx(b)
 	}
 
-	err := s.sx.setRspSesn(s)
-	if err != nil {
+	if err := s.acquireRsp(); err != nil {
 		return nil, err
 	}
-	defer s.sx.setRspSesn(nil)
+	defer s.releaseRsp()
+
+	return s.txvr.TxRxMgmtContext(ctx, txFn, m, s.MtuOut(), timeout)
+}
+
+func (s *SerialSesn) acquireRsp() error {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	if s.rspRefs == 0 {
+		if err := s.sx.setRspSesn(s); err != nil {
+			return err
+		}
+	}
+	s.rspRefs++
 
-	return s.txvr.TxRxMgmt(txFn, m, s.MtuOut(), timeout)
+	return nil
+}
+
+func (s *SerialSesn) releaseRsp() {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	s.rspRefs--
+	if s.rspRefs == 0 {
+		s.sx.setRspSesn(nil)
+	}
 }
 
 func (s *SerialSesn) TxCoap(m coap.Message) error {
@@ -63,6 +63,10 @@ type SerialXport struct {
 	sync.Mutex
 	closing bool
 
+	// Keeps the frames of concurrently transmitted packets from being
+	// interleaved.
+	txMtx sync.Mutex
+
 	reqSesn    *SerialSesn
 	acceptSesn *SerialSesn
 	rspSesn    *SerialSesn
@@ -248,6 +252,9 @@ func (sx *SerialXport) txRaw(bytes []byte) error {
 }
 
 func (sx *SerialXport) Tx(bytes []byte) error {
+	sx.txMtx.Lock()
+	defer sx.txMtx.Unlock()
+
 	log.Debugf("Base64 encoding request:\n%s", hex.Dump(bytes))
 
 	pktData := make([]byte, 2)
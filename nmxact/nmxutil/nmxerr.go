@@ -236,3 +236,9 @@ func ToBleSecurity(err error) *BleSecurityError {
 		return nil
 	}
 }
+
+// Indicates whether an error represents a transport failure that may clear
+// up if the request is retried, possibly after reopening the session.
+func IsTransient(err error) bool {
+	return IsBleSesnDisconnect(err) || IsSesnClosed(err) || IsXport(err)
+}
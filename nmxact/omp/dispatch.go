@@ -191,7 +191,12 @@ func (d *Dispatcher) ErrorOneNmp(seq uint8, err error) error {
 		return fmt.Errorf("no nmp listener for seq %d", seq)
 	}
 
-	ompl.nmpl.ErrChan <- err
+	// Don't block if an error is already pending; the listener only needs
+	// one.
+	select {
+	case ompl.nmpl.ErrChan <- err:
+	default:
+	}
 	return nil
 }
 
@@ -90,6 +90,11 @@ func DecodeOmp(m coap.Message, rxFilterCb nmcoap.MsgFilter) (nmp.NmpRsp, error)
 		return nil, nil
 	}
 
+	// The NMP header is embedded in the payload; it is not part of the body.
+	if raw, ok := rsp.(*nmp.RawRsp); ok {
+		delete(raw.Body, "_h")
+	}
+
 	return rsp, nil
 }
 
@@ -119,10 +124,18 @@ func encodeOmpBase(txFilterCb nmcoap.MsgFilter, isTcp bool, nmr *nmp.NmpMsg) (en
 	payload := []byte{}
 	enc := codec.NewEncoderBytes(&payload, new(codec.CborHandle))
 
-	// Convert request struct to map, use "codec" tag which is compatible with "structs"
-	s := structs.New(nmr.Body)
-	s.TagName = "codec"
-	er.fieldMap = s.Map()
+	if body, ok := nmr.Body.(map[string]interface{}); ok {
+		// Raw request; copy the map so that the caller's stays intact.
+		er.fieldMap = make(map[string]interface{}, len(body)+1)
+		for k, v := range body {
+			er.fieldMap[k] = v
+		}
+	} else {
+		// Convert request struct to map, use "codec" tag which is compatible with "structs"
+		s := structs.New(nmr.Body)
+		s.TagName = "codec"
+		er.fieldMap = s.Map()
+	}
 
 	// Add the NMP header to the OMP response map.
 	er.hdrBytes = nmr.Hdr.Bytes()
@@ -0,0 +1,125 @@
+/**
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package sesn
+
+import (
+	"math/rand"
+	"sync"
+	"time"
+
+	"mynewt.apache.org/newtmgr/nmxact/nmxutil"
+)
+
+// Decides whether and when a failed request gets re-sent.  A policy is
+// consulted only while the number of tries specified in TxOptions has not
+// been used up, and never for requests that aren't safe to repeat.
+type RetryPolicy interface {
+	// Called after try number `try` (counting from 1) fails with the
+	// specified error.  timeout is the timeout the failed try used.  Returns
+	// false if the request should not be retried.  Otherwise, returns how
+	// long to wait before the next try and the timeout to use for it.
+	Retry(try int, err error, timeout time.Duration) (
+		delay time.Duration, nextTimeout time.Duration, retry bool)
+}
+
+// A retry policy that waits an exponentially growing, randomized delay
+// between tries and lengthens the timeout of each successive try.
+type BackoffPolicy struct {
+	// Delay before the first retry.
+	InitialDelay time.Duration
+
+	// Upper bound on the delay between tries.
+	MaxDelay time.Duration
+
+	// Factor the delay grows by after each retry.
+	Multiplier float64
+
+	// Fraction of each delay to randomize, in [0, 1].  A jitter of 0.2 means
+	// the actual delay is within 20% of the nominal value.  Keeps devices
+	// that failed together from retrying in lock step.
+	Jitter float64
+
+	// Factor the per-try timeout grows by after each retry.  Values <= 1
+	// keep the timeout fixed.
+	TimeoutMultiplier float64
+
+	// Upper bound on the per-try timeout; 0 means no limit.
+	MaxTimeout time.Duration
+
+	// Also retry after transient transport failures, e.g., a dropped BLE
+	// connection.  The session gets reopened before the next try.  Response
+	// timeouts are always retried.
+	RetryTransient bool
+
+	mtx sync.Mutex
+	rnd *rand.Rand
+}
+
+func NewBackoffPolicy() *BackoffPolicy {
+	return &BackoffPolicy{
+		InitialDelay:      250 * time.Millisecond,
+		MaxDelay:          5 * time.Second,
+		Multiplier:        2,
+		Jitter:            0.2,
+		TimeoutMultiplier: 1.5,
+		MaxTimeout:        60 * time.Second,
+		RetryTransient:    true,
+	}
+}
+
+func (p *BackoffPolicy) Retry(try int, err error, timeout time.Duration) (
+	time.Duration, time.Duration, bool) {
+
+	if !nmxutil.IsRspTimeout(err) &&
+		!(p.RetryTransient && nmxutil.IsTransient(err)) {
+
+		return 0, 0, false
+	}
+
+	delay := float64(p.InitialDelay)
+	for i := 1; i < try; i++ {
+		delay *= p.Multiplier
+		if p.MaxDelay > 0 && delay >= float64(p.MaxDelay) {
+			delay = float64(p.MaxDelay)
+			break
+		}
+	}
+	delay += delay * p.Jitter * (2*p.random() - 1)
+
+	if timeout != 0 && p.TimeoutMultiplier > 1 {
+		timeout = time.Duration(float64(timeout) * p.TimeoutMultiplier)
+		if p.MaxTimeout > 0 && timeout > p.MaxTimeout {
+			timeout = p.MaxTimeout
+		}
+	}
+
+	return time.Duration(delay), timeout, true
+}
+
+// Returns a random number in [0, 1).
+func (p *BackoffPolicy) random() float64 {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+
+	if p.rnd == nil {
+		p.rnd = rand.New(rand.NewSource(time.Now().UnixNano()))
+	}
+	return p.rnd.Float64()
+}
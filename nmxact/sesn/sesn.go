@@ -20,6 +20,7 @@
 package sesn
 
 import (
+	"context"
 	"time"
 
 	"github.com/runtimeco/go-coap"
@@ -38,6 +39,11 @@ type NotifyCb func(msg coap.Message, err error)
 type TxOptions struct {
 	Timeout time.Duration
 	Tries   int
+
+	// Decides how failed tries are repeated.  If nil, a try that times out
+	// is repeated immediately with the same timeout and other failures are
+	// not retried.
+	Retry RetryPolicy
 }
 
 func NewTxOptions() TxOptions {
@@ -103,6 +109,11 @@ type Sesn interface {
 	//     * other error
 	TxRxMgmt(m *nmp.NmpMsg, timeout time.Duration) (nmp.NmpRsp, error)
 
+	// Like TxRxMgmt, but stops waiting for the response when the context is
+	// done and returns the context's error.
+	TxRxMgmtContext(ctx context.Context, m *nmp.NmpMsg,
+		timeout time.Duration) (nmp.NmpRsp, error)
+
 	// Creates a listener for incoming CoAP messages matching the specified
 	// criteria.
 	ListenCoap(mc nmcoap.MsgCriteria) (*nmcoap.Listener, error)
@@ -25,6 +25,7 @@ import (
 	"time"
 
 	"github.com/runtimeco/go-coap"
+	log "github.com/sirupsen/logrus"
 
 	"mynewt.apache.org/newtmgr/nmxact/nmcoap"
 	"mynewt.apache.org/newtmgr/nmxact/nmp"
@@ -34,23 +35,117 @@ import (
 // TxRxMgmt sends a management command (NMP / OMP) and listens for the
 // response.
 func TxRxMgmt(s Sesn, m *nmp.NmpMsg, o TxOptions) (nmp.NmpRsp, error) {
-	_, task := trace.NewTask(context.Background(), "nmxact/sesn/sesn_util.go/TxRxMgmt")
+	return TxRxMgmtContext(context.Background(), s, m, o)
+}
+
+// Returns the timeout to use for a single try given the context's deadline,
+// or the context's error if it is already done.  The boolean indicates
+// whether the deadline, rather than the specified timeout, limits the try.
+func ctxTimeout(ctx context.Context, timeout time.Duration) (
+	time.Duration, bool, error) {
+
+	if err := ctx.Err(); err != nil {
+		return 0, false, err
+	}
+
+	if dl, ok := ctx.Deadline(); ok {
+		rem := time.Until(dl)
+		if rem <= 0 {
+			return 0, false, context.DeadlineExceeded
+		}
+		if timeout == 0 || rem < timeout {
+			return rem, true, nil
+		}
+	}
+
+	return timeout, false, nil
+}
+
+// TxRxMgmtContext sends a management command (NMP / OMP) and listens for the
+// response.  If the context is cancelled, the session stops waiting for the
+// response and the context's error is returned.  A context deadline caps the
+// timeout of each try, and no try starts after the deadline has passed.
+// Requests that are not idempotent are sent only once.
+func TxRxMgmtContext(ctx context.Context, s Sesn, m *nmp.NmpMsg,
+	o TxOptions) (nmp.NmpRsp, error) {
+
+	_, task := trace.NewTask(ctx, "nmxact/sesn/sesn_util.go/TxRxMgmt")
 	//// time.Sleep(100 * time.Millisecond) ////
 	defer task.End()
 
-	retries := o.Tries - 1
-	for i := 0; ; i++ {
-		r, err := s.TxRxMgmt(m, o.Timeout)
+	if !nmp.IsIdempotent(&m.Hdr) {
+		o.Tries = 1
+	}
+
+	timeout := o.Timeout
+	for try := 1; ; try++ {
+		tryTimeout, capped, err := ctxTimeout(ctx, timeout)
+		if err != nil {
+			return nil, err
+		}
+
+		r, err := s.TxRxMgmtContext(ctx, m, tryTimeout)
 		if err == nil {
 			return r, nil
 		}
 
-		if !nmxutil.IsRspTimeout(err) || i >= retries {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		if capped && nmxutil.IsRspTimeout(err) {
+			return nil, context.DeadlineExceeded
+		}
+
+		var retry bool
+		if timeout, retry = prepareRetry(ctx, s, o, try, err,
+			timeout); !retry {
+
 			return nil, err
 		}
 	}
 }
 
+// Decides whether to repeat a failed try.  If so, waits for the delay
+// specified by the retry policy and reopens the session if the failure
+// closed it.  Returns the timeout to use for the next try.
+func prepareRetry(ctx context.Context, s Sesn, o TxOptions, try int,
+	err error, timeout time.Duration) (time.Duration, bool) {
+
+	if try >= o.Tries {
+		return 0, false
+	}
+
+	if o.Retry == nil {
+		return timeout, nmxutil.IsRspTimeout(err)
+	}
+
+	delay, timeout, ok := o.Retry.Retry(try, err, timeout)
+	if !ok {
+		return 0, false
+	}
+
+	log.Debugf("Retrying request in %s (try %d of %d): %s",
+		delay, try+1, o.Tries, err.Error())
+
+	t := time.NewTimer(delay)
+	defer t.Stop()
+	select {
+	case <-t.C:
+	case <-ctx.Done():
+		// Report the cancellation on the next try.
+		return timeout, true
+	}
+
+	if !s.IsOpen() {
+		if err := s.Open(); err != nil && !nmxutil.IsSesnAlreadyOpen(err) {
+			// Let the next try fail and consult the policy again.
+			log.Debugf("Failed to reopen session: %s", err.Error())
+		}
+	}
+
+	return timeout, true
+}
+
 // TxCoap transmits a single CoAP message over the provided session.
 func TxCoap(s Sesn, mp nmcoap.MsgParams) error {
 	//// time.Sleep(100 * time.Millisecond) ////
@@ -65,7 +160,15 @@ func TxCoap(s Sesn, mp nmcoap.MsgParams) error {
 // RxCoap performs a blocking receive of a CoAP message.  It returns a nil
 // message if the specified listener is closed while the function is running.
 func RxCoap(cl *nmcoap.Listener, timeout time.Duration) (coap.Message, error) {
+	return RxCoapContext(context.Background(), cl, timeout)
+}
+
+// RxCoapContext is like RxCoap, but stops waiting and returns the context's
+// error when the context is done.
+func RxCoapContext(ctx context.Context, cl *nmcoap.Listener,
+	timeout time.Duration) (coap.Message, error) {
 	//// time.Sleep(100 * time.Millisecond) ////
+
 	if timeout != 0 {
 		for {
 			select {
@@ -73,6 +176,8 @@ func RxCoap(cl *nmcoap.Listener, timeout time.Duration) (coap.Message, error) {
 				return nil, err
 			case rsp := <-cl.RspChan:
 				return rsp, nil
+			case <-ctx.Done():
+				return nil, ctx.Err()
 			case _, ok := <-cl.AfterTimeout(timeout):
 				if ok {
 					return nil, nmxutil.NewRspTimeoutError("CoAP timeout")
@@ -85,6 +190,8 @@ func RxCoap(cl *nmcoap.Listener, timeout time.Duration) (coap.Message, error) {
 			return nil, err
 		case rsp := <-cl.RspChan:
 			return rsp, nil
+		case <-ctx.Done():
+			return nil, ctx.Err()
 		}
 	}
 }
@@ -92,6 +199,14 @@ func RxCoap(cl *nmcoap.Listener, timeout time.Duration) (coap.Message, error) {
 // TxRxMgmt sends a CoAP request and listens for the response.
 func TxRxCoap(s Sesn, mp nmcoap.MsgParams,
 	opts TxOptions) (coap.Message, error) {
+
+	return TxRxCoapContext(context.Background(), s, mp, opts)
+}
+
+// TxRxCoapContext sends a CoAP request and listens for the response, giving
+// up when the context is done.  See TxRxMgmtContext.
+func TxRxCoapContext(ctx context.Context, s Sesn, mp nmcoap.MsgParams,
+	opts TxOptions) (coap.Message, error) {
 	//// time.Sleep(100 * time.Millisecond) ////
 
 	mc := nmcoap.MsgCriteria{Token: mp.Token}
@@ -99,24 +214,34 @@ func TxRxCoap(s Sesn, mp nmcoap.MsgParams,
 	if err != nil {
 		return nil, err
 	}
-	defer s.StopListenCoap(mc)
 
-	listenOnce := func() (coap.Message, error) {
-		return RxCoap(cl, opts.Timeout)
-	}
+	defer s.StopListenCoap(mc)
 
-	retries := opts.Tries - 1
-	for i := 0; ; i++ {
-		if err := TxCoap(s, mp); err != nil {
+	timeout := opts.Timeout
+	for try := 1; ; try++ {
+		tryTimeout, capped, err := ctxTimeout(ctx, timeout)
+		if err != nil {
 			return nil, err
 		}
 
-		rsp, err := listenOnce()
-		if err == nil {
-			return rsp, nil
+		if err = TxCoap(s, mp); err == nil {
+			var rsp coap.Message
+			rsp, err = RxCoapContext(ctx, cl, tryTimeout)
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			if err == nil {
+				return rsp, nil
+			}
+			if capped && nmxutil.IsRspTimeout(err) {
+				return nil, context.DeadlineExceeded
+			}
 		}
 
-		if !nmxutil.IsRspTimeout(err) || i >= retries {
+		var retry bool
+		if timeout, retry = prepareRetry(ctx, s, opts, try, err,
+			timeout); !retry {
+
 			return nil, err
 		}
 	}
@@ -0,0 +1,147 @@
+/**
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package tcp
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"mynewt.apache.org/newtmgr/nmxact/nmp"
+)
+
+const MAX_PACKET_SIZE = 2048
+const DIAL_TIMEOUT = 10 * time.Second
+
+// Determines the length of the first frame in a TCP byte stream.  Returns 0
+// if the stream doesn't contain enough data to determine the length yet.
+type FrameLenFn func(data []byte) int
+
+// NmpFrameLen frames plain NMP messages by the length field of the 8-byte
+// NMP header.
+func NmpFrameLen(data []byte) int {
+	if len(data) < nmp.NMP_HDR_SIZE {
+		return 0
+	}
+
+	return nmp.NMP_HDR_SIZE + int(binary.BigEndian.Uint16(data[2:4]))
+}
+
+// CoapTcpFrameLen frames CoAP messages according to the CoAP-over-TCP
+// encoding (RFC 8323): a length nibble, an optional extended length, a code
+// byte, and the token.
+func CoapTcpFrameLen(data []byte) int {
+	if len(data) < 1 {
+		return 0
+	}
+
+	lenNibble := data[0] >> 4
+	tkl := int(data[0] & 0x0f)
+
+	var extLen int
+	var bodyLen int
+
+	switch lenNibble {
+	case 13:
+		extLen = 1
+		if len(data) < 1+extLen {
+			return 0
+		}
+		bodyLen = int(data[1]) + 13
+
+	case 14:
+		extLen = 2
+		if len(data) < 1+extLen {
+			return 0
+		}
+		bodyLen = int(binary.BigEndian.Uint16(data[1:3])) + 269
+
+	case 15:
+		extLen = 4
+		if len(data) < 1+extLen {
+			return 0
+		}
+		bodyLen = int(binary.BigEndian.Uint32(data[1:5])) + 65805
+
+	default:
+		bodyLen = int(lenNibble)
+	}
+
+	// Length byte + extended length + code + token + options and payload.
+	return 1 + extLen + 1 + tkl + bodyLen
+}
+
+// Dial connects to the specified peer and starts a goroutine that splits the
+// incoming byte stream into frames.  Each complete frame is passed to
+// dispatchCb.  When the connection fails or is closed, errCb is called once
+// and the goroutine terminates.
+func Dial(peerString string, frameLenCb FrameLenFn,
+	dispatchCb func(data []byte), errCb func(err error)) (*net.TCPConn, error) {
+
+	addr, err := net.ResolveTCPAddr("tcp", peerString)
+	if err != nil {
+		return nil,
+			fmt.Errorf("Failure resolving name for TCP session: %s",
+				err.Error())
+	}
+
+	c, err := net.DialTimeout("tcp", addr.String(), DIAL_TIMEOUT)
+	if err != nil {
+		return nil,
+			fmt.Errorf("Failed to connect to TCP peer: %s", err.Error())
+	}
+	conn := c.(*net.TCPConn)
+
+	go func() {
+		var buf []byte
+		chunk := make([]byte, MAX_PACKET_SIZE)
+
+		for {
+			nr, err := conn.Read(chunk)
+			if err != nil {
+				// Connection closed or read error.
+				errCb(err)
+				return
+			}
+
+			log.Debugf("Received %d bytes from %v", nr, conn.RemoteAddr())
+			buf = append(buf, chunk[:nr]...)
+
+			for {
+				fl := frameLenCb(buf)
+				if fl == 0 || len(buf) < fl {
+					// Incomplete frame; wait for more data.
+					break
+				}
+
+				frame := make([]byte, fl)
+				copy(frame, buf[:fl])
+				buf = buf[fl:]
+
+				dispatchCb(frame)
+			}
+		}
+	}()
+
+	return conn, nil
+}
@@ -0,0 +1,237 @@
+/**
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package tcp
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/runtimeco/go-coap"
+
+	"mynewt.apache.org/newtmgr/nmxact/mgmt"
+	"mynewt.apache.org/newtmgr/nmxact/nmcoap"
+	"mynewt.apache.org/newtmgr/nmxact/nmp"
+	"mynewt.apache.org/newtmgr/nmxact/nmxutil"
+	"mynewt.apache.org/newtmgr/nmxact/omp"
+	"mynewt.apache.org/newtmgr/nmxact/sesn"
+)
+
+type TcpSesn struct {
+	cfg  sesn.SesnCfg
+	conn *net.TCPConn
+	txvr *mgmt.Transceiver
+
+	// Protects conn.
+	mtx sync.Mutex
+}
+
+func NewTcpSesn(cfg sesn.SesnCfg) (*TcpSesn, error) {
+	s := &TcpSesn{
+		cfg: cfg,
+	}
+	txvr, err := mgmt.NewTransceiver(cfg.TxFilterCb, cfg.RxFilterCb, true,
+		cfg.MgmtProto, 3)
+	if err != nil {
+		return nil, err
+	}
+	s.txvr = txvr
+
+	return s, nil
+}
+
+func (s *TcpSesn) frameLenFn() FrameLenFn {
+	if s.cfg.MgmtProto == sesn.MGMT_PROTO_NMP {
+		return NmpFrameLen
+	} else {
+		return CoapTcpFrameLen
+	}
+}
+
+func (s *TcpSesn) Open() error {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	if s.conn != nil {
+		return nmxutil.NewSesnAlreadyOpenError(
+			"Attempt to open an already-open TCP session")
+	}
+
+	txvr, err := mgmt.NewTransceiver(s.cfg.TxFilterCb, s.cfg.RxFilterCb, true,
+		s.cfg.MgmtProto, 3)
+	if err != nil {
+		return err
+	}
+	s.txvr = txvr
+
+	var conn *net.TCPConn
+	conn, err = Dial(s.cfg.PeerSpec.Tcp, s.frameLenFn(),
+		func(data []byte) {
+			txvr.DispatchNmpRsp(data)
+		},
+		func(err error) {
+			s.onDisconnect(&conn, err)
+		})
+	if err != nil {
+		return err
+	}
+
+	s.conn = conn
+	return nil
+}
+
+// Called by the receive goroutine when the connection terminates.  If the
+// peer dropped the connection (as opposed to a local Close()), the session is
+// torn down and the client's close callback is executed.  The connection is
+// passed by reference because Open() only assigns it after the receive
+// goroutine has started; it is safe to read once the mutex is held.
+func (s *TcpSesn) onDisconnect(conn **net.TCPConn, err error) {
+	s.mtx.Lock()
+
+	if s.conn == nil || s.conn != *conn {
+		// Session closed locally.
+		s.mtx.Unlock()
+		return
+	}
+
+	s.closeConn(fmt.Errorf("TCP connection lost: %s", err.Error()))
+	s.mtx.Unlock()
+
+	if s.cfg.OnCloseCb != nil {
+		s.cfg.OnCloseCb(s, err)
+	}
+}
+
+// Must be called with the mutex locked.
+func (s *TcpSesn) closeConn(cause error) {
+	s.conn.Close()
+	s.txvr.ErrorAll(cause)
+	s.txvr.Stop()
+	s.conn = nil
+}
+
+func (s *TcpSesn) Close() error {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	if s.conn == nil {
+		return nmxutil.NewSesnClosedError(
+			"Attempt to close an unopened TCP session")
+	}
+
+	s.closeConn(fmt.Errorf("closed"))
+	return nil
+}
+
+func (s *TcpSesn) IsOpen() bool {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	return s.conn != nil
+}
+
+func (s *TcpSesn) MtuIn() int {
+	return MAX_PACKET_SIZE -
+		omp.OMP_MSG_OVERHEAD -
+		nmp.NMP_HDR_SIZE
+}
+
+func (s *TcpSesn) MtuOut() int {
+	return MAX_PACKET_SIZE -
+		omp.OMP_MSG_OVERHEAD -
+		nmp.NMP_HDR_SIZE
+}
+
+func (s *TcpSesn) txRaw(b []byte) error {
+	s.mtx.Lock()
+	conn := s.conn
+	s.mtx.Unlock()
+
+	if conn == nil {
+		return nmxutil.NewSesnClosedError(
+			"Attempt to transmit over closed TCP session")
+	}
+
+	_, err := conn.Write(b)
+	return err
+}
+
+func (s *TcpSesn) TxRxMgmt(m *nmp.NmpMsg,
+	timeout time.Duration) (nmp.NmpRsp, error) {
+
+	return s.TxRxMgmtContext(context.Background(), m, timeout)
+}
+
+func (s *TcpSesn) TxRxMgmtContext(ctx context.Context, m *nmp.NmpMsg,
+	timeout time.Duration) (nmp.NmpRsp, error) {
+
+	if !s.IsOpen() {
+		return nil, nmxutil.NewSesnClosedError(
+			"Attempt to transmit over closed TCP session")
+	}
+
+	return s.txvr.TxRxMgmtContext(ctx, s.txRaw, m, s.MtuOut(), timeout)
+}
+
+func (s *TcpSesn) AbortRx(seq uint8) error {
+	s.txvr.AbortRx(seq)
+	return nil
+}
+
+func (s *TcpSesn) TxCoap(m coap.Message) error {
+	return s.txvr.TxCoap(s.txRaw, m, s.MtuOut())
+}
+
+func (s *TcpSesn) MgmtProto() sesn.MgmtProto {
+	return s.cfg.MgmtProto
+}
+
+func (s *TcpSesn) ListenCoap(mc nmcoap.MsgCriteria) (*nmcoap.Listener, error) {
+	return s.txvr.ListenCoap(mc)
+}
+
+func (s *TcpSesn) StopListenCoap(mc nmcoap.MsgCriteria) {
+	s.txvr.StopListenCoap(mc)
+}
+
+// CoAP messages sent over a TCP stream always use the TCP form of CoAP.
+func (s *TcpSesn) CoapIsTcp() bool {
+	return true
+}
+
+func (s *TcpSesn) RxAccept() (sesn.Sesn, *sesn.SesnCfg, error) {
+	return nil, nil, fmt.Errorf("Op not implemented yet")
+}
+
+func (s *TcpSesn) RxCoap(opt sesn.TxOptions) (coap.Message, error) {
+	return nil, fmt.Errorf("Op not implemented yet")
+}
+
+func (s *TcpSesn) Filters() (nmcoap.MsgFilter, nmcoap.MsgFilter) {
+	return s.txvr.Filters()
+}
+
+func (s *TcpSesn) SetFilters(txFilter nmcoap.MsgFilter,
+	rxFilter nmcoap.MsgFilter) {
+
+	s.txvr.SetFilters(txFilter, rxFilter)
+}
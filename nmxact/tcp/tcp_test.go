@@ -0,0 +1,82 @@
+/**
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package tcp
+
+import (
+	"testing"
+)
+
+func TestNmpFrameLen(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+		want int
+	}{
+		{"empty", nil, 0},
+		{"partial header", []byte{0, 0, 0, 5, 0, 0, 0}, 0},
+		{"header only", []byte{0, 0, 0, 0, 0, 0, 0, 0}, 8},
+		{"with body", []byte{0, 0, 0x01, 0x02, 0, 0, 0, 0}, 8 + 0x102},
+		{"extra data", []byte{0, 0, 0, 3, 0, 0, 0, 0, 1, 2, 3, 4}, 11},
+	}
+
+	for _, tt := range tests {
+		if got := NmpFrameLen(tt.data); got != tt.want {
+			t.Errorf("%s: got %d, want %d", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestCoapTcpFrameLen(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+		want int
+	}{
+		{"empty", nil, 0},
+
+		// Length in the nibble: len/tkl, code, token, body.
+		{"no body", []byte{0x00}, 2},
+		{"token", []byte{0x02, 0x45}, 4},
+		{"nibble length", []byte{0xc4}, 1 + 1 + 4 + 12},
+
+		// 13: one extended length byte, plus 13.
+		{"ext8 partial", []byte{0xd0}, 0},
+		{"ext8 min", []byte{0xd0, 0x00}, 1 + 1 + 1 + 13},
+		{"ext8 max", []byte{0xd1, 0xff}, 1 + 1 + 1 + 1 + 268},
+
+		// 14: two extended length bytes, plus 269.
+		{"ext16 partial", []byte{0xe0, 0x01}, 0},
+		{"ext16 min", []byte{0xe0, 0x00, 0x00}, 1 + 2 + 1 + 269},
+		{"ext16 max", []byte{0xe8, 0xff, 0xff}, 1 + 2 + 1 + 8 + 65804},
+
+		// 15: four extended length bytes, plus 65805.
+		{"ext32 partial", []byte{0xf0, 0x00, 0x00, 0x00}, 0},
+		{"ext32 min", []byte{0xf0, 0x00, 0x00, 0x00, 0x00},
+			1 + 4 + 1 + 65805},
+		{"ext32", []byte{0xf2, 0x00, 0x01, 0x00, 0x00},
+			1 + 4 + 1 + 2 + 65536 + 65805},
+	}
+
+	for _, tt := range tests {
+		if got := CoapTcpFrameLen(tt.data); got != tt.want {
+			t.Errorf("%s: got %d, want %d", tt.name, got, tt.want)
+		}
+	}
+}
@@ -20,6 +20,7 @@
 package udp
 
 import (
+	"context"
 	"fmt"
 	"net"
 	"time"
@@ -107,6 +108,12 @@ func (s *UdpSesn) MtuOut() int {
 func (s *UdpSesn) TxRxMgmt(m *nmp.NmpMsg,
 	timeout time.Duration) (nmp.NmpRsp, error) {
 
+	return s.TxRxMgmtContext(context.Background(), m, timeout)
+}
+
+func (s *UdpSesn) TxRxMgmtContext(ctx context.Context, m *nmp.NmpMsg,
+	timeout time.Duration) (nmp.NmpRsp, error) {
+
 	if !s.IsOpen() {
 		return nil, fmt.Errorf("Attempt to transmit over closed UDP session")
 	}
@@ -115,11 +122,11 @@ func (s *UdpSesn) TxRxMgmt(m *nmp.NmpMsg,
 		_, err := s.conn.WriteToUDP(b, s.addr)
 		return err
 	}
-	return s.txvr.TxRxMgmt(txRaw, m, s.MtuOut(), timeout)
+	return s.txvr.TxRxMgmtContext(ctx, txRaw, m, s.MtuOut(), timeout)
 }
 
 func (s *UdpSesn) AbortRx(seq uint8) error {
-	s.txvr.ErrorAll(fmt.Errorf("Rx aborted"))
+	s.txvr.AbortRx(seq)
 	return nil
 }
 
@@ -20,7 +20,9 @@
 package xact
 
 import (
+	"context"
 	"fmt"
+	"sync"
 
 	"mynewt.apache.org/newtmgr/nmxact/sesn"
 )
@@ -30,24 +32,44 @@ type Result interface {
 }
 
 type Cmd interface {
-	// Transmits request and listens for response; blocking.
+	// Transmits request and listens for response; blocking.  If the device
+	// responds with a nonzero status code, the result is returned along
+	// with an *nmp.NmpError describing the failure.
 	Run(s sesn.Sesn) (Result, error)
+
+	// Like Run, but gives up when the context is cancelled or its deadline
+	// passes, returning the context's error.  A deadline caps the timeout of
+	// each request.
+	RunContext(ctx context.Context, s sesn.Sesn) (Result, error)
+
+	// Cancels the command from another goroutine.  The command returns
+	// an error as soon as possible, and any later attempt to run it fails.
 	Abort() error
 
 	TxOptions() sesn.TxOptions
 	SetTxOptions(opt sesn.TxOptions)
 }
 
+// Tracks the contexts of a command's in-progress operations so that Abort()
+// can cancel them.
+type cmdAbort struct {
+	mtx     sync.Mutex
+	cancels map[int]context.CancelFunc
+	nextId  int
+	err     error
+}
+
 type CmdBase struct {
 	txOptions sesn.TxOptions
-	curNmpSeq uint8
-	curSesn   sesn.Sesn
-	abortErr  error
+	abort     *cmdAbort
 }
 
 func NewCmdBase() CmdBase {
 	return CmdBase{
 		txOptions: sesn.NewTxOptions(),
+		abort: &cmdAbort{
+			cancels: map[int]context.CancelFunc{},
+		},
 	}
 }
 
@@ -60,12 +82,66 @@ func (c *CmdBase) SetTxOptions(opt sesn.TxOptions) {
 }
 
 func (c *CmdBase) Abort() error {
-	if c.curSesn != nil {
-		if err := c.curSesn.AbortRx(c.curNmpSeq); err != nil {
-			return err
-		}
+	a := c.abort
+	a.mtx.Lock()
+	defer a.mtx.Unlock()
+
+	a.err = fmt.Errorf("Command aborted")
+	for _, cancel := range a.cancels {
+		cancel()
 	}
 
-	c.abortErr = fmt.Errorf("Command aborted")
 	return nil
 }
+
+// Returns the error that aborted the command, or nil if it wasn't aborted.
+func (c *CmdBase) abortErr() error {
+	a := c.abort
+	a.mtx.Lock()
+	defer a.mtx.Unlock()
+
+	return a.err
+}
+
+// Derives a context that Abort() cancels.  The returned function releases
+// the context and must be called when the operation completes.  Fails if the
+// command has already been aborted.
+func (c *CmdBase) startCtx(ctx context.Context) (
+	context.Context, func(), error) {
+
+	a := c.abort
+	a.mtx.Lock()
+	defer a.mtx.Unlock()
+
+	if a.err != nil {
+		return nil, nil, a.err
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	id := a.nextId
+	a.nextId++
+	a.cancels[id] = cancel
+
+	done := func() {
+		a.mtx.Lock()
+		delete(a.cancels, id)
+		a.mtx.Unlock()
+
+		cancel()
+	}
+
+	return ctx, done, nil
+}
+
+// Translates the error from an operation that ran under a context created by
+// startCtx: if Abort() cancelled the operation, the abort error is reported
+// rather than context.Canceled.
+func (c *CmdBase) ctxErr(err error) error {
+	if err != nil {
+		if aerr := c.abortErr(); aerr != nil {
+			return aerr
+		}
+	}
+
+	return err
+}
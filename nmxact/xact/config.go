@@ -20,6 +20,8 @@
 package xact
 
 import (
+	"context"
+
 	"mynewt.apache.org/newtmgr/nmxact/nmp"
 	"mynewt.apache.org/newtmgr/nmxact/sesn"
 )
@@ -52,10 +54,16 @@ func (r *ConfigReadResult) Status() int {
 }
 
 func (c *ConfigReadCmd) Run(s sesn.Sesn) (Result, error) {
+	return c.RunContext(context.Background(), s)
+}
+
+func (c *ConfigReadCmd) RunContext(ctx context.Context,
+	s sesn.Sesn) (Result, error) {
+
 	r := nmp.NewConfigReadReq()
 	r.Name = c.Name
 
-	rsp, err := txReq(s, r.Msg(), &c.CmdBase)
+	rsp, err := txReq(ctx, s, r.Msg(), &c.CmdBase)
 	if err != nil {
 		return nil, err
 	}
@@ -63,7 +71,7 @@ func (c *ConfigReadCmd) Run(s sesn.Sesn) (Result, error) {
 
 	res := newConfigReadResult()
 	res.Rsp = srsp
-	return res, nil
+	return res, nmp.RspError(srsp)
 }
 
 //////////////////////////////////////////////////////////////////////////////
@@ -96,12 +104,18 @@ func (r *ConfigWriteResult) Status() int {
 }
 
 func (c *ConfigWriteCmd) Run(s sesn.Sesn) (Result, error) {
+	return c.RunContext(context.Background(), s)
+}
+
+func (c *ConfigWriteCmd) RunContext(ctx context.Context,
+	s sesn.Sesn) (Result, error) {
+
 	r := nmp.NewConfigWriteReq()
 	r.Name = c.Name
 	r.Val = c.Val
 	r.Save = c.Save
 
-	rsp, err := txReq(s, r.Msg(), &c.CmdBase)
+	rsp, err := txReq(ctx, s, r.Msg(), &c.CmdBase)
 	if err != nil {
 		return nil, err
 	}
@@ -109,5 +123,5 @@ func (c *ConfigWriteCmd) Run(s sesn.Sesn) (Result, error) {
 
 	res := newConfigWriteResult()
 	res.Rsp = srsp
-	return res, nil
+	return res, nmp.RspError(srsp)
 }
@@ -20,6 +20,7 @@
 package xact
 
 import (
+	"context"
 	"fmt"
 	"sort"
 
@@ -93,10 +94,16 @@ func (r *CrashResult) Status() int {
 }
 
 func (c *CrashCmd) Run(s sesn.Sesn) (Result, error) {
+	return c.RunContext(context.Background(), s)
+}
+
+func (c *CrashCmd) RunContext(ctx context.Context,
+	s sesn.Sesn) (Result, error) {
+
 	r := nmp.NewCrashReq()
 	r.CrashType = CrashTypeToString(c.CrashType)
 
-	rsp, err := txReq(s, r.Msg(), &c.CmdBase)
+	rsp, err := txReq(ctx, s, r.Msg(), &c.CmdBase)
 	if err != nil {
 		return nil, err
 	}
@@ -104,5 +111,5 @@ func (c *CrashCmd) Run(s sesn.Sesn) (Result, error) {
 
 	res := newCrashResult()
 	res.Rsp = srsp
-	return res, nil
+	return res, nmp.RspError(srsp)
 }
@@ -20,6 +20,8 @@
 package xact
 
 import (
+	"context"
+
 	"mynewt.apache.org/newtmgr/nmxact/nmp"
 	"mynewt.apache.org/newtmgr/nmxact/sesn"
 )
@@ -51,9 +53,15 @@ func (r *DateTimeReadResult) Status() int {
 }
 
 func (c *DateTimeReadCmd) Run(s sesn.Sesn) (Result, error) {
+	return c.RunContext(context.Background(), s)
+}
+
+func (c *DateTimeReadCmd) RunContext(ctx context.Context,
+	s sesn.Sesn) (Result, error) {
+
 	r := nmp.NewDateTimeReadReq()
 
-	rsp, err := txReq(s, r.Msg(), &c.CmdBase)
+	rsp, err := txReq(ctx, s, r.Msg(), &c.CmdBase)
 	if err != nil {
 		return nil, err
 	}
@@ -61,7 +69,7 @@ func (c *DateTimeReadCmd) Run(s sesn.Sesn) (Result, error) {
 
 	res := newDateTimeReadResult()
 	res.Rsp = srsp
-	return res, nil
+	return res, nmp.RspError(srsp)
 }
 
 ///////////////////////////////////////////////////////////////////////////////
@@ -92,10 +100,16 @@ func (r *DateTimeWriteResult) Status() int {
 }
 
 func (c *DateTimeWriteCmd) Run(s sesn.Sesn) (Result, error) {
+	return c.RunContext(context.Background(), s)
+}
+
+func (c *DateTimeWriteCmd) RunContext(ctx context.Context,
+	s sesn.Sesn) (Result, error) {
+
 	r := nmp.NewDateTimeWriteReq()
 	r.DateTime = c.DateTime
 
-	rsp, err := txReq(s, r.Msg(), &c.CmdBase)
+	rsp, err := txReq(ctx, s, r.Msg(), &c.CmdBase)
 	if err != nil {
 		return nil, err
 	}
@@ -103,5 +117,5 @@ func (c *DateTimeWriteCmd) Run(s sesn.Sesn) (Result, error) {
 
 	res := newDateTimeWriteResult()
 	res.Rsp = srsp
-	return res, nil
+	return res, nmp.RspError(srsp)
 }
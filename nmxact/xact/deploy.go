@@ -0,0 +1,550 @@
+/**
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package xact
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"mynewt.apache.org/newtmgr/nmxact/nmp"
+	"mynewt.apache.org/newtmgr/nmxact/sesn"
+)
+
+//////////////////////////////////////////////////////////////////////////////
+// $health checks                                                           //
+//////////////////////////////////////////////////////////////////////////////
+
+// A test that a freshly booted image must pass before it gets confirmed.
+type HealthCheck interface {
+	Check(ctx context.Context, s sesn.Sesn, opts sesn.TxOptions) error
+	String() string
+}
+
+// Passes if the device echoes the payload back.
+type EchoHealthCheck struct {
+	Payload string
+}
+
+func (hc *EchoHealthCheck) Check(ctx context.Context, s sesn.Sesn,
+	opts sesn.TxOptions) error {
+
+	c := NewEchoCmd()
+	c.SetTxOptions(opts)
+	c.Payload = hc.Payload
+
+	res, err := c.RunContext(ctx, s)
+	if err != nil {
+		return err
+	}
+
+	rsp := res.(*EchoResult).Rsp
+	if rsp.Payload != hc.Payload {
+		return fmt.Errorf("echo payload mismatch: sent %q, received %q",
+			hc.Payload, rsp.Payload)
+	}
+
+	return nil
+}
+
+func (hc *EchoHealthCheck) String() string {
+	return fmt.Sprintf("echo %q", hc.Payload)
+}
+
+var statHealthCheckOps = []string{"<=", ">=", "==", "!=", "<", ">"}
+
+// Passes if a statistic satisfies a threshold, e.g., "ble_ll.rx_crc_err < 10".
+type StatHealthCheck struct {
+	Group string
+	Field string
+	Op    string
+	Value int64
+}
+
+// Parses a stat health check of the form "<group>.<field><op><value>", where
+// op is one of <, <=, >, >=, ==, !=.
+func ParseStatHealthCheck(s string) (*StatHealthCheck, error) {
+	for _, op := range statHealthCheckOps {
+		idx := strings.Index(s, op)
+		if idx == -1 {
+			continue
+		}
+
+		name := strings.TrimSpace(s[:idx])
+		valStr := strings.TrimSpace(s[idx+len(op):])
+
+		dot := strings.Index(name, ".")
+		if dot <= 0 || dot == len(name)-1 {
+			return nil, fmt.Errorf(
+				"invalid stat health check \"%s\": expected <group>.<field>", s)
+		}
+
+		val, err := strconv.ParseInt(valStr, 0, 64)
+		if err != nil {
+			return nil, fmt.Errorf(
+				"invalid stat health check \"%s\": bad value \"%s\"", s, valStr)
+		}
+
+		return &StatHealthCheck{
+			Group: name[:dot],
+			Field: name[dot+1:],
+			Op:    op,
+			Value: val,
+		}, nil
+	}
+
+	return nil, fmt.Errorf("invalid stat health check \"%s\": missing operator",
+		s)
+}
+
+func statToInt64(v interface{}) (int64, bool) {
+	switch n := v.(type) {
+	case int:
+		return int64(n), true
+	case int64:
+		return n, true
+	case uint64:
+		return int64(n), true
+	case uint32:
+		return int64(n), true
+	case int32:
+		return int64(n), true
+	case float64:
+		return int64(n), true
+	default:
+		return 0, false
+	}
+}
+
+func (hc *StatHealthCheck) Check(ctx context.Context, s sesn.Sesn,
+	opts sesn.TxOptions) error {
+
+	c := NewStatReadCmd()
+	c.SetTxOptions(opts)
+	c.Name = hc.Group
+
+	res, err := c.RunContext(ctx, s)
+	if err != nil {
+		return err
+	}
+
+	rsp := res.(*StatReadResult).Rsp
+	raw, ok := rsp.Fields[hc.Field]
+	if !ok {
+		return fmt.Errorf("stat not found: %s.%s", hc.Group, hc.Field)
+	}
+	val, ok := statToInt64(raw)
+	if !ok {
+		return fmt.Errorf("stat %s.%s is not a number: %v",
+			hc.Group, hc.Field, raw)
+	}
+
+	var pass bool
+	switch hc.Op {
+	case "<":
+		pass = val < hc.Value
+	case "<=":
+		pass = val <= hc.Value
+	case ">":
+		pass = val > hc.Value
+	case ">=":
+		pass = val >= hc.Value
+	case "==":
+		pass = val == hc.Value
+	case "!=":
+		pass = val != hc.Value
+	default:
+		return fmt.Errorf("invalid stat health check operator: %s", hc.Op)
+	}
+
+	if !pass {
+		return fmt.Errorf("stat threshold not met: %s (actual=%d)",
+			hc.String(), val)
+	}
+
+	return nil
+}
+
+func (hc *StatHealthCheck) String() string {
+	return fmt.Sprintf("%s.%s%s%d", hc.Group, hc.Field, hc.Op, hc.Value)
+}
+
+//////////////////////////////////////////////////////////////////////////////
+// $deploy                                                                  //
+//////////////////////////////////////////////////////////////////////////////
+
+type DeployStage int
+
+const (
+	DEPLOY_STAGE_UPLOAD DeployStage = iota
+	DEPLOY_STAGE_TEST
+	DEPLOY_STAGE_RESET
+	DEPLOY_STAGE_RECONNECT
+	DEPLOY_STAGE_VERIFY
+	DEPLOY_STAGE_HEALTH
+	DEPLOY_STAGE_CONFIRM
+	DEPLOY_STAGE_ROLLBACK
+)
+
+var deployStageMap = map[DeployStage]string{
+	DEPLOY_STAGE_UPLOAD:    "upload",
+	DEPLOY_STAGE_TEST:      "test",
+	DEPLOY_STAGE_RESET:     "reset",
+	DEPLOY_STAGE_RECONNECT: "reconnect",
+	DEPLOY_STAGE_VERIFY:    "verify",
+	DEPLOY_STAGE_HEALTH:    "health",
+	DEPLOY_STAGE_CONFIRM:   "confirm",
+	DEPLOY_STAGE_ROLLBACK:  "rollback",
+}
+
+func (s DeployStage) String() string {
+	return deployStageMap[s]
+}
+
+type ImageDeployStageFn func(c *ImageDeployCmd, stage DeployStage)
+
+// Uploads an image, boots it in test mode, and confirms it once the device
+// comes back running the new image and passes all health checks.  If
+// anything goes wrong after the reset, the image is left unconfirmed so
+// that the bootloader reverts to the old image on the next reset.
+type ImageDeployCmd struct {
+	CmdBase
+
+	// Upload settings; see ImageUpgradeCmd.
+	Data        []byte
+	ImageNum    int
+	Window      int
+	Resume      bool
+	ForceUpload bool
+	SigKey      crypto.PublicKey
+	ProgressCb  ImageUploadProgressFn
+
+	// Called as each stage of the deployment begins.
+	StageCb ImageDeployStageFn
+
+	// How long to wait after resetting the device before trying to talk to
+	// it, and how long to keep trying.
+	ResetDelay       time.Duration
+	ReconnectTimeout time.Duration
+
+	HealthChecks []HealthCheck
+
+	// Leave the new image in test mode rather than confirming it.
+	NoConfirm bool
+
+	// By default, the device is reset after a failed health check so that
+	// it reverts to the old image immediately.  If set, the device is left
+	// running the unconfirmed image instead.
+	NoRollback bool
+}
+
+type ImageDeployResult struct {
+	UpgradeRes *ImageUpgradeResult
+	Hash       []byte
+
+	// Stage at which the deployment stopped.  DEPLOY_STAGE_CONFIRM if it
+	// ran to completion.
+	LastStage DeployStage
+
+	Confirmed bool
+}
+
+func NewImageDeployCmd() *ImageDeployCmd {
+	return &ImageDeployCmd{
+		CmdBase:          NewCmdBase(),
+		ResetDelay:       time.Second,
+		ReconnectTimeout: 60 * time.Second,
+	}
+}
+
+func newImageDeployResult() *ImageDeployResult {
+	return &ImageDeployResult{}
+}
+
+func (r *ImageDeployResult) Status() int {
+	if r.UpgradeRes != nil {
+		return r.UpgradeRes.Status()
+	}
+	return nmp.NMP_ERR_OK
+}
+
+func (c *ImageDeployCmd) stage(ctx context.Context, res *ImageDeployResult,
+	stage DeployStage) error {
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	res.LastStage = stage
+	if c.StageCb != nil {
+		c.StageCb(c, stage)
+	}
+
+	return nil
+}
+
+func (c *ImageDeployCmd) runUpgrade(ctx context.Context, s sesn.Sesn) (
+	*ImageUpgradeResult, error) {
+
+	cmd := NewImageUpgradeCmd()
+	cmd.SetTxOptions(c.TxOptions())
+	cmd.Data = c.Data
+	cmd.ImageNum = c.ImageNum
+	cmd.Window = c.Window
+	cmd.Resume = c.Resume
+	cmd.ForceUpload = c.ForceUpload
+	cmd.SigKey = c.SigKey
+	cmd.ProgressCb = func(uc *ImageUploadCmd, r *nmp.ImageUploadRsp) {
+		if c.ProgressCb != nil {
+			c.ProgressCb(uc, r)
+		}
+	}
+
+	res, err := cmd.RunContext(ctx, s)
+	if err != nil {
+		return nil, err
+	}
+
+	return res.(*ImageUpgradeResult), nil
+}
+
+func (c *ImageDeployCmd) runTest(ctx context.Context, s sesn.Sesn,
+	hash []byte) error {
+
+	cmd := NewImageStateWriteCmd()
+	cmd.SetTxOptions(c.TxOptions())
+	cmd.Hash = hash
+
+	if _, err := cmd.RunContext(ctx, s); err != nil {
+		return fmt.Errorf("Failed to mark image for test: %w", err)
+	}
+
+	return nil
+}
+
+func (c *ImageDeployCmd) runConfirm(ctx context.Context, s sesn.Sesn) error {
+	cmd := NewImageStateWriteCmd()
+	cmd.SetTxOptions(c.TxOptions())
+	cmd.Confirm = true
+
+	if _, err := cmd.RunContext(ctx, s); err != nil {
+		return fmt.Errorf("Failed to confirm image: %w", err)
+	}
+
+	return nil
+}
+
+// Resets the device.  The device may go down before it responds, so a
+// missing response is not an error.
+func (c *ImageDeployCmd) runReset(ctx context.Context, s sesn.Sesn) {
+	cmd := NewResetCmd()
+	cmd.SetTxOptions(c.TxOptions())
+	cmd.RunContext(ctx, s)
+}
+
+func (c *ImageDeployCmd) runStateRead(ctx context.Context, s sesn.Sesn) (
+	*nmp.ImageStateRsp, error) {
+
+	cmd := NewImageStateReadCmd()
+	cmd.SetTxOptions(c.TxOptions())
+
+	res, err := cmd.RunContext(ctx, s)
+	if err != nil {
+		return nil, err
+	}
+
+	return res.(*ImageStateReadResult).Rsp, nil
+}
+
+// Waits for the device to come back after a reset, reopening the session if
+// the reset closed it.  Returns the device's image state.
+func (c *ImageDeployCmd) reconnect(ctx context.Context, s sesn.Sesn) (
+	*nmp.ImageStateRsp, error) {
+
+	if err := sleepContext(ctx, c.ResetDelay); err != nil {
+		return nil, err
+	}
+
+	deadline := time.Now().Add(c.ReconnectTimeout)
+	for {
+
+		var err error
+		if !s.IsOpen() {
+			err = s.Open()
+		}
+		if err == nil {
+			var rsp *nmp.ImageStateRsp
+			if rsp, err = c.runStateRead(ctx, s); err == nil {
+				return rsp, nil
+			}
+		}
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf(
+				"Device did not come back after reset: %w", err)
+		}
+		if err := sleepContext(ctx, 500*time.Millisecond); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// Checks that the device is running the new image.
+func (c *ImageDeployCmd) verify(rsp *nmp.ImageStateRsp, hash []byte) error {
+	for _, img := range rsp.Images {
+		if img.Image == c.ImageNum && img.Active {
+			if !bytes.Equal(img.Hash, hash) {
+				return fmt.Errorf("Device is not running the new image: "+
+					"image=%d expected=%x active=%x",
+					c.ImageNum, hash, img.Hash)
+			}
+			return nil
+		}
+	}
+
+	return fmt.Errorf("Device reports no active image: image=%d", c.ImageNum)
+}
+
+func (c *ImageDeployCmd) runHealthChecks(ctx context.Context,
+	s sesn.Sesn) error {
+
+	for _, hc := range c.HealthChecks {
+		if err := hc.Check(ctx, s, c.TxOptions()); err != nil {
+			return fmt.Errorf("Health check failed: %s: %w",
+				hc.String(), err)
+		}
+	}
+
+	return nil
+}
+
+func (c *ImageDeployCmd) Run(s sesn.Sesn) (Result, error) {
+	return c.RunContext(context.Background(), s)
+}
+
+func (c *ImageDeployCmd) RunContext(ctx context.Context,
+	s sesn.Sesn) (Result, error) {
+
+	ctx, done, err := c.startCtx(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+
+	res, err := c.run(ctx, s)
+	if err != nil {
+		return nil, c.ctxErr(err)
+	}
+
+	return res, nil
+}
+
+func (c *ImageDeployCmd) run(ctx context.Context, s sesn.Sesn) (
+	*ImageDeployResult, error) {
+
+	res := newImageDeployResult()
+	res.Hash = imageHash(c.Data)
+
+	if err := c.stage(ctx, res, DEPLOY_STAGE_UPLOAD); err != nil {
+		return nil, err
+	}
+	ures, err := c.runUpgrade(ctx, s)
+	if err != nil {
+		return nil, err
+	}
+	res.UpgradeRes = ures
+
+	var state *nmp.ImageStateRsp
+
+	if ures.Skipped && ures.PresentSlot == 0 {
+		// The device is already running the image; there may be nothing
+		// left to do but check and confirm it.
+		if err := c.stage(ctx, res, DEPLOY_STAGE_VERIFY); err != nil {
+			return nil, err
+		}
+		if state, err = c.runStateRead(ctx, s); err != nil {
+			return nil, err
+		}
+	} else {
+		if err := c.stage(ctx, res, DEPLOY_STAGE_TEST); err != nil {
+			return nil, err
+		}
+		if err := c.runTest(ctx, s, res.Hash); err != nil {
+			return nil, err
+		}
+
+		if err := c.stage(ctx, res, DEPLOY_STAGE_RESET); err != nil {
+			return nil, err
+		}
+		c.runReset(ctx, s)
+
+		if err := c.stage(ctx, res, DEPLOY_STAGE_RECONNECT); err != nil {
+			return nil, err
+		}
+		if state, err = c.reconnect(ctx, s); err != nil {
+			return nil, err
+		}
+
+		if err := c.stage(ctx, res, DEPLOY_STAGE_VERIFY); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := c.verify(state, res.Hash); err != nil {
+		return nil, err
+	}
+
+	if len(c.HealthChecks) > 0 {
+		if err := c.stage(ctx, res, DEPLOY_STAGE_HEALTH); err != nil {
+			return nil, err
+		}
+		if err := c.runHealthChecks(ctx, s); err != nil {
+			if c.NoRollback {
+				return nil, fmt.Errorf("%w; image left unconfirmed", err)
+			}
+
+			c.stage(ctx, res, DEPLOY_STAGE_ROLLBACK)
+			c.runReset(ctx, s)
+			return nil, fmt.Errorf("%w; device reset to revert to the "+
+				"previous image", err)
+		}
+	}
+
+	if c.NoConfirm {
+		return res, nil
+	}
+
+	if err := c.stage(ctx, res, DEPLOY_STAGE_CONFIRM); err != nil {
+		return nil, err
+	}
+	if err := c.runConfirm(ctx, s); err != nil {
+		return nil, err
+	}
+	res.Confirmed = true
+
+	return res, nil
+}
@@ -20,6 +20,8 @@
 package xact
 
 import (
+	"context"
+
 	"mynewt.apache.org/newtmgr/nmxact/nmp"
 	"mynewt.apache.org/newtmgr/nmxact/sesn"
 )
@@ -48,10 +50,16 @@ func (r *EchoResult) Status() int {
 }
 
 func (c *EchoCmd) Run(s sesn.Sesn) (Result, error) {
+	return c.RunContext(context.Background(), s)
+}
+
+func (c *EchoCmd) RunContext(ctx context.Context,
+	s sesn.Sesn) (Result, error) {
+
 	r := nmp.NewEchoReq()
 	r.Payload = c.Payload
 
-	rsp, err := txReq(s, r.Msg(), &c.CmdBase)
+	rsp, err := txReq(ctx, s, r.Msg(), &c.CmdBase)
 	if err != nil {
 		return nil, err
 	}
@@ -59,5 +67,5 @@ func (c *EchoCmd) Run(s sesn.Sesn) (Result, error) {
 
 	res := newEchoResult()
 	res.Rsp = srsp
-	return res, nil
+	return res, nmp.RspError(srsp)
 }
@@ -20,6 +20,7 @@
 package xact
 
 import (
+	"context"
 	"fmt"
 
 	"mynewt.apache.org/newtmgr/nmxact/mgmt"
@@ -58,6 +59,12 @@ func (r *FsDownloadResult) Status() int {
 }
 
 func (c *FsDownloadCmd) Run(s sesn.Sesn) (Result, error) {
+	return c.RunContext(context.Background(), s)
+}
+
+func (c *FsDownloadCmd) RunContext(ctx context.Context,
+	s sesn.Sesn) (Result, error) {
+
 	res := newFsDownloadResult()
 	off := 0
 
@@ -66,7 +73,7 @@ func (c *FsDownloadCmd) Run(s sesn.Sesn) (Result, error) {
 		r.Name = c.Name
 		r.Off = uint32(off)
 
-		rsp, err := txReq(s, r.Msg(), &c.CmdBase)
+		rsp, err := txReq(ctx, s, r.Msg(), &c.CmdBase)
 		if err != nil {
 			return nil, err
 		}
@@ -89,7 +96,7 @@ func (c *FsDownloadCmd) Run(s sesn.Sesn) (Result, error) {
 		off = int(frsp.Off) + len(frsp.Data)
 	}
 
-	return res, nil
+	return res, nmp.RspError(res.Rsps[len(res.Rsps)-1])
 }
 
 //////////////////////////////////////////////////////////////////////////////
@@ -179,6 +186,12 @@ func nextFsUploadReq(s sesn.Sesn, name string, data []byte, off int) (
 }
 
 func (c *FsUploadCmd) Run(s sesn.Sesn) (Result, error) {
+	return c.RunContext(context.Background(), s)
+}
+
+func (c *FsUploadCmd) RunContext(ctx context.Context,
+	s sesn.Sesn) (Result, error) {
+
 	res := newFsUploadResult()
 
 	for off := 0; off < len(c.Data); {
@@ -187,7 +200,7 @@ func (c *FsUploadCmd) Run(s sesn.Sesn) (Result, error) {
 			return nil, err
 		}
 
-		rsp, err := txReq(s, r.Msg(), &c.CmdBase)
+		rsp, err := txReq(ctx, s, r.Msg(), &c.CmdBase)
 		if err != nil {
 			return nil, err
 		}
@@ -205,5 +218,10 @@ func (c *FsUploadCmd) Run(s sesn.Sesn) (Result, error) {
 		}
 	}
 
-	return res, nil
+	if len(res.Rsps) == 0 {
+		// Empty file; nothing was sent.
+		return res, nil
+	}
+
+	return res, nmp.RspError(res.Rsps[len(res.Rsps)-1])
 }
@@ -20,13 +20,16 @@
 package xact
 
 import (
+	"bytes"
 	"context"
+	"crypto"
 	"crypto/sha256"
 	"fmt"
 	"runtime/trace"
 
 	pb "gopkg.in/cheggaaa/pb.v1"
 
+	"mynewt.apache.org/newtmgr/nmxact/mcuboot"
 	"mynewt.apache.org/newtmgr/nmxact/mgmt"
 	"mynewt.apache.org/newtmgr/nmxact/nmp"
 	"mynewt.apache.org/newtmgr/nmxact/nmxutil"
@@ -47,6 +50,17 @@ type ImageUploadCmd struct {
 	Upgrade    bool
 	ProgressCb ImageUploadProgressFn
 	ImageNum   int
+
+	// Maximum number of upload requests that may be outstanding at once.
+	// Values less than 2 cause each chunk to be acknowledged before the
+	// next one is sent.
+	Window int
+
+	// If set and StartOff is 0, a zero-length request is sent first to ask
+	// the device how much of this image it already has.  The upload
+	// continues from the offset the device reports, or from the start if
+	// the device rejects the request.
+	Resume bool
 }
 
 type ImageUploadResult struct {
@@ -187,16 +201,50 @@ func nextImageUploadReq(s sesn.Sesn, upgrade bool, data []byte, off int, imageNu
 	return r, nil
 }
 
-func (c *ImageUploadCmd) Run(s sesn.Sesn) (Result, error) {
-	res := newImageUploadResult()
+// An upload request that has been sent but not yet answered.
+type imageUploadChunk struct {
+	req   *nmp.ImageUploadReq
+	end   int
+	epoch int
+}
+
+type imageUploadAck struct {
+	chunk *imageUploadChunk
+	rsp   *nmp.ImageUploadRsp
+	err   error
+}
+
+// Sends an upload request containing no data.  A device that has part of the
+// same image from an earlier, interrupted upload responds with the offset
+// it expects next; otherwise the device starts a new upload and responds
+// with an offset of 0.  Devices that don't support resuming expect the first
+// chunk to contain the image header, so they reject the request.  The upgrade
+// flag is left clear because the device needs the image header to check the
+// version; it gets checked when the first chunk is sent.
+func (c *ImageUploadCmd) probe(ctx context.Context, s sesn.Sesn) (
+	*nmp.ImageUploadRsp, error) {
+
+	hash := sha256.Sum256(c.Data)
+	r := buildImageUploadReq(len(c.Data), hash[:], false, []byte{}, 0,
+		c.ImageNum, nmxutil.NextNmpSeq())
+
+	rsp, err := txReq(ctx, s, r.Msg(), &c.CmdBase)
+	if err != nil {
+		return nil, err
+	}
+	return rsp.(*nmp.ImageUploadRsp), nil
+}
 
-	for off := c.StartOff; off < len(c.Data); {
+func (c *ImageUploadCmd) runStopAndWait(ctx context.Context, s sesn.Sesn,
+	startOff int, res *ImageUploadResult) (*ImageUploadResult, error) {
+
+	for off := startOff; off < len(c.Data); {
 		r, err := nextImageUploadReq(s, c.Upgrade, c.Data, off, c.ImageNum)
 		if err != nil {
 			return nil, err
 		}
 
-		rsp, err := txReq(s, r.Msg(), &c.CmdBase)
+		rsp, err := txReq(ctx, s, r.Msg(), &c.CmdBase)
 		if err != nil {
 			return nil, err
 		}
@@ -217,6 +265,176 @@ func (c *ImageUploadCmd) Run(s sesn.Sesn) (Result, error) {
 	return res, nil
 }
 
+// Keeps up to c.Window upload requests in flight.  Responses are matched to
+// requests by sequence number, so they may arrive in any order.  A device
+// that receives a chunk at an unexpected offset drops it and reports the
+// offset it expects next; the upload then rewinds to that offset, and
+// responses to requests sent before the rewind are disregarded.
+func (c *ImageUploadCmd) runWindowed(ctx context.Context, s sesn.Sesn,
+	startOff int, res *ImageUploadResult) (*ImageUploadResult, error) {
+
+	ctx, release, err := c.startCtx(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	ackChan := make(chan imageUploadAck, c.Window)
+	inFlight := 0
+	epoch := 0
+	nextOff := startOff
+
+	// Highest offset the device has acknowledged in the current epoch.
+	ackedOff := startOff
+
+	// Don't pipeline anything until the device has answered once; the first
+	// response tells us where the device actually wants to continue from.
+	synced := len(res.Rsps) > 0
+
+	var firstErr error
+	done := false
+
+	for {
+		for !done && nextOff < len(c.Data) && inFlight < c.Window &&
+			(synced || inFlight == 0) {
+
+			if err := ctx.Err(); err != nil {
+				firstErr = c.ctxErr(err)
+				done = true
+				break
+			}
+
+			r, err := nextImageUploadReq(s, c.Upgrade, c.Data, nextOff,
+				c.ImageNum)
+			if err != nil {
+				firstErr = err
+				done = true
+				break
+			}
+
+			chunk := &imageUploadChunk{
+				req:   r,
+				end:   nextOff + len(r.Data),
+				epoch: epoch,
+			}
+
+			go func() {
+				rsp, err := sesn.TxRxMgmtContext(ctx, s, chunk.req.Msg(),
+					c.TxOptions())
+				ack := imageUploadAck{chunk: chunk, err: err}
+				if err == nil {
+					ack.rsp = rsp.(*nmp.ImageUploadRsp)
+				}
+				ackChan <- ack
+			}()
+
+			inFlight++
+			nextOff = chunk.end
+		}
+
+		if inFlight == 0 {
+			break
+		}
+
+		ack := <-ackChan
+		inFlight--
+
+		if done || ack.chunk.epoch != epoch {
+			// Either we are draining outstanding requests after a failure,
+			// or this request was superseded by a rewind.
+			continue
+		}
+
+		if ack.err != nil {
+			firstErr = c.ctxErr(ack.err)
+			done = true
+			continue
+		}
+
+		synced = true
+
+		if ack.rsp.Rc == 0 && int(ack.rsp.Off) == ack.chunk.end &&
+			ack.chunk.end <= ackedOff {
+
+			// A late response; a later chunk has already been acknowledged.
+			continue
+		}
+
+		if c.ProgressCb != nil {
+			c.ProgressCb(c, ack.rsp)
+		}
+
+		res.Rsps = append(res.Rsps, ack.rsp)
+		if ack.rsp.Rc != 0 {
+			done = true
+			continue
+		}
+
+		if int(ack.rsp.Off) != ack.chunk.end {
+			// The device dropped the chunk; resend from where it left off.
+			nextOff = int(ack.rsp.Off)
+			ackedOff = nextOff
+			epoch++
+		} else {
+			ackedOff = ack.chunk.end
+		}
+	}
+
+	if firstErr != nil {
+		return nil, firstErr
+	}
+
+	return res, nil
+}
+
+func (c *ImageUploadCmd) run(ctx context.Context, s sesn.Sesn) (
+	*ImageUploadResult, error) {
+
+	res := newImageUploadResult()
+	startOff := c.StartOff
+
+	if c.Resume && startOff == 0 {
+		rsp, err := c.probe(ctx, s)
+		if err != nil {
+			return nil, err
+		}
+
+		// If the device rejected the probe, or reported an offset that
+		// makes no sense, disregard the response and start over.
+		if rsp.Rc == 0 && int(rsp.Off) <= len(c.Data) {
+			if c.ProgressCb != nil {
+				c.ProgressCb(c, rsp)
+			}
+			res.Rsps = append(res.Rsps, rsp)
+			startOff = int(rsp.Off)
+		}
+	}
+
+	if c.Window < 2 {
+		return c.runStopAndWait(ctx, s, startOff, res)
+	}
+
+	return c.runWindowed(ctx, s, startOff, res)
+}
+
+func (c *ImageUploadCmd) Run(s sesn.Sesn) (Result, error) {
+	return c.RunContext(context.Background(), s)
+}
+
+func (c *ImageUploadCmd) RunContext(ctx context.Context,
+	s sesn.Sesn) (Result, error) {
+
+	res, err := c.run(ctx, s)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(res.Rsps) == 0 {
+		return res, nil
+	}
+	return res, nmp.RspError(res.Rsps[len(res.Rsps)-1])
+}
+
 //////////////////////////////////////////////////////////////////////////////
 // $upgrade                                                                 //
 //////////////////////////////////////////////////////////////////////////////
@@ -244,11 +462,29 @@ type ImageUpgradeCmd struct {
 	Upgrade     bool
 	ProgressBar *pb.ProgressBar
 	ImageNum    int
+	Window      int
+
+	// Continue an earlier, interrupted upload of the same image rather than
+	// starting over.  Implies NoErase.
+	Resume bool
+
+	// If set, the image's signature is checked against this key before
+	// anything is sent to the device.
+	SigKey crypto.PublicKey
+
+	// Upload the image even if one of the device's slots already contains
+	// it.
+	ForceUpload bool
 }
 
 type ImageUpgradeResult struct {
 	EraseRes  *ImageEraseResult
 	UploadRes *ImageUploadResult
+
+	// Set if the upload was skipped because the device already had the
+	// image; PresentSlot indicates which slot holds it.
+	Skipped     bool
+	PresentSlot int
 }
 
 func NewImageUpgradeCmd() *ImageUpgradeCmd {
@@ -264,7 +500,9 @@ func newImageUpgradeResult() *ImageUpgradeResult {
 }
 
 func (r *ImageUpgradeResult) Status() int {
-	if r.UploadRes != nil {
+	if r.Skipped {
+		return nmp.NMP_ERR_OK
+	} else if r.UploadRes != nil {
 		return r.UploadRes.Status()
 	} else if r.EraseRes != nil {
 		return r.EraseRes.Status()
@@ -274,8 +512,10 @@ func (r *ImageUpgradeResult) Status() int {
 }
 
 // Attempts to recover from a disconnect.
-func (c *ImageUpgradeCmd) rescue(s sesn.Sesn, err error) error {
-	if err != nil {
+func (c *ImageUpgradeCmd) rescue(ctx context.Context, s sesn.Sesn,
+	err error) error {
+
+	if err != nil && ctx.Err() == nil {
 		if !s.IsOpen() {
 			if err := s.Open(); err == nil {
 				return nil
@@ -286,12 +526,19 @@ func (c *ImageUpgradeCmd) rescue(s sesn.Sesn, err error) error {
 	return err
 }
 
-func (c *ImageUpgradeCmd) runErase(s sesn.Sesn) (*ImageEraseResult, error) {
+func (c *ImageUpgradeCmd) runErase(ctx context.Context, s sesn.Sesn) (
+	*ImageEraseResult, error) {
+
 	cmd := NewImageEraseCmd()
 	cmd.SetTxOptions(c.TxOptions())
-	res, err := cmd.Run(s)
+	cmd.ImageNum = c.ImageNum
+	res, err := cmd.RunContext(ctx, s)
+	if isNmpError(err) {
+		// Not fatal; the upload will fail if the slot can't be written.
+		return res.(*ImageEraseResult), nil
+	}
 
-	if err := c.rescue(s, err); err != nil {
+	if err := c.rescue(ctx, s, err); err != nil {
 		return nil, err
 	}
 
@@ -304,7 +551,9 @@ func (c *ImageUpgradeCmd) runErase(s sesn.Sesn) (*ImageEraseResult, error) {
 	return res.(*ImageEraseResult), nil
 }
 
-func (c *ImageUpgradeCmd) runUpload(s sesn.Sesn) (*ImageUploadResult, error) {
+func (c *ImageUpgradeCmd) runUpload(ctx context.Context, s sesn.Sesn) (
+	*ImageUploadResult, error) {
+
 	startOff := 0
 	progressCb := func(uc *ImageUploadCmd, r *nmp.ImageUploadRsp) {
 		if r.Rc == 0 {
@@ -320,14 +569,16 @@ func (c *ImageUpgradeCmd) runUpload(s sesn.Sesn) (*ImageUploadResult, error) {
 		cmd.Upgrade = c.Upgrade
 		cmd.ProgressCb = progressCb
 		cmd.ImageNum = c.ImageNum
+		cmd.Window = c.Window
+		cmd.Resume = c.Resume
 		cmd.SetTxOptions(c.TxOptions())
 
-		res, err := cmd.Run(s)
-		if err == nil {
-			return res.(*ImageUploadResult), nil
+		res, err := cmd.RunContext(ctx, s)
+		if err == nil || isNmpError(err) {
+			return res.(*ImageUploadResult), err
 		}
 
-		if err := c.rescue(s, err); err != nil {
+		if err := c.rescue(ctx, s, err); err != nil {
 			// Disconnected and couldn't recover.
 			return nil, err
 		}
@@ -336,26 +587,162 @@ func (c *ImageUpgradeCmd) runUpload(s sesn.Sesn) (*ImageUploadResult, error) {
 	}
 }
 
+// Checks that the image was signed with the key the device expects.
+func (c *ImageUpgradeCmd) verifySig() error {
+	img, err := mcuboot.ParseImage(c.Data)
+	if err != nil {
+		return fmt.Errorf("Invalid image: %s", err.Error())
+	}
+
+	if err := img.VerifySig(c.SigKey); err != nil {
+		return fmt.Errorf("Image signature check failed: %s", err.Error())
+	}
+
+	return nil
+}
+
+// Returns the hash the device reports for the image: the contents of the
+// SHA256 TLV for an MCUboot image, or the SHA-256 of the whole file
+// otherwise.
+func imageHash(data []byte) []byte {
+	if img, err := mcuboot.ParseImage(data); err == nil {
+		if hash, err := img.Hash(); err == nil {
+			return hash
+		}
+	}
+
+	sum := sha256.Sum256(data)
+	return sum[:]
+}
+
+func (c *ImageUpgradeCmd) runStateRead(ctx context.Context, s sesn.Sesn) (
+	*nmp.ImageStateRsp, error) {
+
+	cmd := NewImageStateReadCmd()
+	cmd.SetTxOptions(c.TxOptions())
+
+	res, err := cmd.RunContext(ctx, s)
+	if err != nil {
+		return nil, err
+	}
+
+	return res.(*ImageStateReadResult).Rsp, nil
+}
+
+// Looks for a slot that already holds the image.  Returns -1 if there is
+// none or if the device's image state couldn't be read.
+func (c *ImageUpgradeCmd) findPresentSlot(ctx context.Context, s sesn.Sesn,
+	hash []byte) int {
+
+	rsp, err := c.runStateRead(ctx, s)
+	if err != nil {
+		// Not fatal; just upload the image.
+		return -1
+	}
+
+	for _, img := range rsp.Images {
+		if img.Image == c.ImageNum && bytes.Equal(img.Hash, hash) {
+			return img.Slot
+		}
+	}
+
+	return -1
+}
+
+// Checks that the secondary slot holds the image that was just uploaded.
+func (c *ImageUpgradeCmd) verifyUpload(ctx context.Context, s sesn.Sesn,
+	hash []byte) error {
+
+	rsp, err := c.runStateRead(ctx, s)
+	if err := c.rescue(ctx, s, err); err != nil {
+		return fmt.Errorf("Failed to verify upload: %w", err)
+	}
+	if rsp == nil {
+		// Reconnected after a disconnect; try again.
+		if rsp, err = c.runStateRead(ctx, s); err != nil {
+			return fmt.Errorf("Failed to verify upload: %w", err)
+		}
+	}
+
+	for _, img := range rsp.Images {
+		if img.Image == c.ImageNum && img.Slot == 1 {
+			if !bytes.Equal(img.Hash, hash) {
+				return fmt.Errorf("Image hash mismatch after upload: "+
+					"image=%d slot=1 expected=%x actual=%x",
+					c.ImageNum, hash, img.Hash)
+			}
+			return nil
+		}
+	}
+
+	return fmt.Errorf("Uploaded image not found on device: image=%d slot=1",
+		c.ImageNum)
+}
+
 func (c *ImageUpgradeCmd) Run(s sesn.Sesn) (Result, error) {
+	return c.RunContext(context.Background(), s)
+}
+
+func (c *ImageUpgradeCmd) RunContext(ctx context.Context,
+	s sesn.Sesn) (Result, error) {
+
+	ctx, done, err := c.startCtx(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+
+	res, err := c.run(ctx, s)
+	return res, c.ctxErr(err)
+}
+
+func (c *ImageUpgradeCmd) run(ctx context.Context, s sesn.Sesn) (
+	*ImageUpgradeResult, error) {
+
 	var eres *ImageEraseResult = nil
 	var err error
 
-	if c.NoErase == false {
-		eres, err = c.runErase(s)
+	if c.SigKey != nil {
+		if err := c.verifySig(); err != nil {
+			return nil, err
+		}
+	}
+
+	hash := imageHash(c.Data)
+
+	if !c.ForceUpload {
+		if slot := c.findPresentSlot(ctx, s, hash); slot >= 0 {
+			upgradeRes := newImageUpgradeResult()
+			upgradeRes.Skipped = true
+			upgradeRes.PresentSlot = slot
+			return upgradeRes, nil
+		}
+	}
+
+	if c.NoErase == false && c.Resume == false {
+		eres, err = c.runErase(ctx, s)
 		if err != nil {
 			return nil, err
 		}
 	} else {
 		eres = nil
 	}
-	ures, err := c.runUpload(s)
-	if err != nil {
+	ures, err := c.runUpload(ctx, s)
+	if ures == nil {
 		return nil, err
 	}
 
 	upgradeRes := newImageUpgradeResult()
 	upgradeRes.EraseRes = eres
 	upgradeRes.UploadRes = ures
+	if err != nil {
+		return upgradeRes, err
+	}
+
+	if err := c.verifyUpload(ctx, s, hash); err != nil {
+		return nil, err
+	}
+
 	return upgradeRes, nil
 }
 
@@ -390,13 +777,19 @@ func (r *ImageStateReadResult) Status() int {
 }
 
 func (c *ImageStateReadCmd) Run(s sesn.Sesn) (Result, error) {
+	return c.RunContext(context.Background(), s)
+}
+
+func (c *ImageStateReadCmd) RunContext(ctx context.Context,
+	s sesn.Sesn) (Result, error) {
+
 	_, task := trace.NewTask(context.Background(), "nmxact/xact/image.go/Run")
 	//// time.Sleep(100 * time.Millisecond) ////
 	defer task.End()
 
 	r := nmp.NewImageStateReadReq()
 
-	rsp, err := txReq(s, r.Msg(), &c.CmdBase)
+	rsp, err := txReq(ctx, s, r.Msg(), &c.CmdBase)
 	if err != nil {
 		return nil, err
 	}
@@ -404,7 +797,7 @@ func (c *ImageStateReadCmd) Run(s sesn.Sesn) (Result, error) {
 
 	res := newImageStateReadResult()
 	res.Rsp = srsp
-	return res, nil
+	return res, nmp.RspError(srsp)
 }
 
 //////////////////////////////////////////////////////////////////////////////
@@ -436,11 +829,17 @@ func (r *ImageStateWriteResult) Status() int {
 }
 
 func (c *ImageStateWriteCmd) Run(s sesn.Sesn) (Result, error) {
+	return c.RunContext(context.Background(), s)
+}
+
+func (c *ImageStateWriteCmd) RunContext(ctx context.Context,
+	s sesn.Sesn) (Result, error) {
+
 	r := nmp.NewImageStateWriteReq()
 	r.Hash = c.Hash
 	r.Confirm = c.Confirm
 
-	rsp, err := txReq(s, r.Msg(), &c.CmdBase)
+	rsp, err := txReq(ctx, s, r.Msg(), &c.CmdBase)
 	if err != nil {
 		return nil, err
 	}
@@ -448,7 +847,7 @@ func (c *ImageStateWriteCmd) Run(s sesn.Sesn) (Result, error) {
 
 	res := newImageStateWriteResult()
 	res.Rsp = srsp
-	return res, nil
+	return res, nmp.RspError(srsp)
 }
 
 //////////////////////////////////////////////////////////////////////////////
@@ -478,9 +877,15 @@ func (r *CoreListResult) Status() int {
 }
 
 func (c *CoreListCmd) Run(s sesn.Sesn) (Result, error) {
+	return c.RunContext(context.Background(), s)
+}
+
+func (c *CoreListCmd) RunContext(ctx context.Context,
+	s sesn.Sesn) (Result, error) {
+
 	r := nmp.NewCoreListReq()
 
-	rsp, err := txReq(s, r.Msg(), &c.CmdBase)
+	rsp, err := txReq(ctx, s, r.Msg(), &c.CmdBase)
 	if err != nil {
 		return nil, err
 	}
@@ -488,15 +893,17 @@ func (c *CoreListCmd) Run(s sesn.Sesn) (Result, error) {
 
 	res := newCoreListResult()
 	res.Rsp = srsp
-	return res, nil
+	return res, nmp.RspError(srsp)
 }
 
 //////////////////////////////////////////////////////////////////////////////
 // $erase                                                                   //
 //////////////////////////////////////////////////////////////////////////////
 
+// Erases the secondary slot of the specified image.
 type ImageEraseCmd struct {
 	CmdBase
+	ImageNum int
 }
 
 type ImageEraseResult struct {
@@ -518,9 +925,21 @@ func (r *ImageEraseResult) Status() int {
 }
 
 func (c *ImageEraseCmd) Run(s sesn.Sesn) (Result, error) {
+	return c.RunContext(context.Background(), s)
+}
+
+func (c *ImageEraseCmd) RunContext(ctx context.Context,
+	s sesn.Sesn) (Result, error) {
+
 	r := nmp.NewImageEraseReq()
 
-	rsp, err := txReq(s, r.Msg(), &c.CmdBase)
+	// Image 0's slot is left for the device to choose, as older devices
+	// don't accept a slot number.
+	if c.ImageNum > 0 {
+		r.Slot = c.ImageNum*2 + 1
+	}
+
+	rsp, err := txReq(ctx, s, r.Msg(), &c.CmdBase)
 	if err != nil {
 		return nil, err
 	}
@@ -528,7 +947,7 @@ func (c *ImageEraseCmd) Run(s sesn.Sesn) (Result, error) {
 
 	res := newImageEraseResult()
 	res.Rsp = srsp
-	return res, nil
+	return res, nmp.RspError(srsp)
 }
 
 //////////////////////////////////////////////////////////////////////////////
@@ -561,6 +980,12 @@ func (r *CoreLoadResult) Status() int {
 }
 
 func (c *CoreLoadCmd) Run(s sesn.Sesn) (Result, error) {
+	return c.RunContext(context.Background(), s)
+}
+
+func (c *CoreLoadCmd) RunContext(ctx context.Context,
+	s sesn.Sesn) (Result, error) {
+
 	res := newCoreLoadResult()
 	off := 0
 
@@ -568,7 +993,7 @@ func (c *CoreLoadCmd) Run(s sesn.Sesn) (Result, error) {
 		r := nmp.NewCoreLoadReq()
 		r.Off = uint32(off)
 
-		rsp, err := txReq(s, r.Msg(), &c.CmdBase)
+		rsp, err := txReq(ctx, s, r.Msg(), &c.CmdBase)
 		if err != nil {
 			return nil, err
 		}
@@ -591,7 +1016,7 @@ func (c *CoreLoadCmd) Run(s sesn.Sesn) (Result, error) {
 		off = int(irsp.Off) + len(irsp.Data)
 	}
 
-	return res, nil
+	return res, nmp.RspError(res.Rsps[len(res.Rsps)-1])
 }
 
 //////////////////////////////////////////////////////////////////////////////
@@ -621,9 +1046,15 @@ func (r *CoreEraseResult) Status() int {
 }
 
 func (c *CoreEraseCmd) Run(s sesn.Sesn) (Result, error) {
+	return c.RunContext(context.Background(), s)
+}
+
+func (c *CoreEraseCmd) RunContext(ctx context.Context,
+	s sesn.Sesn) (Result, error) {
+
 	r := nmp.NewCoreEraseReq()
 
-	rsp, err := txReq(s, r.Msg(), &c.CmdBase)
+	rsp, err := txReq(ctx, s, r.Msg(), &c.CmdBase)
 	if err != nil {
 		return nil, err
 	}
@@ -631,5 +1062,5 @@ func (c *CoreEraseCmd) Run(s sesn.Sesn) (Result, error) {
 
 	res := newCoreEraseResult()
 	res.Rsp = srsp
-	return res, nil
+	return res, nmp.RspError(srsp)
 }
@@ -20,6 +20,8 @@
 package xact
 
 import (
+	"context"
+
 	"mynewt.apache.org/newtmgr/nmxact/nmp"
 	"mynewt.apache.org/newtmgr/nmxact/sesn"
 )
@@ -53,13 +55,29 @@ func (r *LogShowResult) Status() int {
 	return r.Rsp.Rc
 }
 
+// A status code of 1 means the device has more log entries to send; it isn't
+// a failure.
+func logShowRspError(rsp *nmp.LogShowRsp) error {
+	if rsp.Rc == 1 {
+		return nil
+	}
+
+	return nmp.RspError(rsp)
+}
+
 func (c *LogShowCmd) Run(s sesn.Sesn) (Result, error) {
+	return c.RunContext(context.Background(), s)
+}
+
+func (c *LogShowCmd) RunContext(ctx context.Context,
+	s sesn.Sesn) (Result, error) {
+
 	r := nmp.NewLogShowReq()
 	r.Name = c.Name
 	r.Timestamp = c.Timestamp
 	r.Index = c.Index
 
-	rsp, err := txReq(s, r.Msg(), &c.CmdBase)
+	rsp, err := txReq(ctx, s, r.Msg(), &c.CmdBase)
 	if err != nil {
 		return nil, err
 	}
@@ -67,7 +85,7 @@ func (c *LogShowCmd) Run(s sesn.Sesn) (Result, error) {
 
 	res := newLogShowResult()
 	res.Rsp = srsp
-	return res, nil
+	return res, logShowRspError(srsp)
 }
 
 //////////////////////////////////////////////////////////////////////////////
@@ -113,13 +131,19 @@ func (c *LogShowFullCmd) buildReq(idx uint32) *nmp.LogShowReq {
 }
 
 func (c *LogShowFullCmd) Run(s sesn.Sesn) (Result, error) {
+	return c.RunContext(context.Background(), s)
+}
+
+func (c *LogShowFullCmd) RunContext(ctx context.Context,
+	s sesn.Sesn) (Result, error) {
+
 	res := newLogShowFullResult()
 
 	idx := c.Index
 	for {
 		r := c.buildReq(idx)
 
-		rsp, err := txReq(s, r.Msg(), &c.CmdBase)
+		rsp, err := txReq(ctx, s, r.Msg(), &c.CmdBase)
 		if err != nil {
 			return nil, err
 		}
@@ -151,7 +175,7 @@ func (c *LogShowFullCmd) Run(s sesn.Sesn) (Result, error) {
 		idx = lastEntry.Index + 1
 	}
 
-	return res, nil
+	return res, logShowRspError(res.Rsps[len(res.Rsps)-1])
 }
 
 //////////////////////////////////////////////////////////////////////////////
@@ -181,9 +205,15 @@ func (r *LogListResult) Status() int {
 }
 
 func (c *LogListCmd) Run(s sesn.Sesn) (Result, error) {
+	return c.RunContext(context.Background(), s)
+}
+
+func (c *LogListCmd) RunContext(ctx context.Context,
+	s sesn.Sesn) (Result, error) {
+
 	r := nmp.NewLogListReq()
 
-	rsp, err := txReq(s, r.Msg(), &c.CmdBase)
+	rsp, err := txReq(ctx, s, r.Msg(), &c.CmdBase)
 	if err != nil {
 		return nil, err
 	}
@@ -191,7 +221,7 @@ func (c *LogListCmd) Run(s sesn.Sesn) (Result, error) {
 
 	res := newLogListResult()
 	res.Rsp = srsp
-	return res, nil
+	return res, nmp.RspError(srsp)
 }
 
 //////////////////////////////////////////////////////////////////////////////
@@ -221,9 +251,15 @@ func (r *LogModuleListResult) Status() int {
 }
 
 func (c *LogModuleListCmd) Run(s sesn.Sesn) (Result, error) {
+	return c.RunContext(context.Background(), s)
+}
+
+func (c *LogModuleListCmd) RunContext(ctx context.Context,
+	s sesn.Sesn) (Result, error) {
+
 	r := nmp.NewLogModuleListReq()
 
-	rsp, err := txReq(s, r.Msg(), &c.CmdBase)
+	rsp, err := txReq(ctx, s, r.Msg(), &c.CmdBase)
 	if err != nil {
 		return nil, err
 	}
@@ -231,7 +267,7 @@ func (c *LogModuleListCmd) Run(s sesn.Sesn) (Result, error) {
 
 	res := newLogModuleListResult()
 	res.Rsp = srsp
-	return res, nil
+	return res, nmp.RspError(srsp)
 }
 
 //////////////////////////////////////////////////////////////////////////////
@@ -261,9 +297,15 @@ func (r *LogLevelListResult) Status() int {
 }
 
 func (c *LogLevelListCmd) Run(s sesn.Sesn) (Result, error) {
+	return c.RunContext(context.Background(), s)
+}
+
+func (c *LogLevelListCmd) RunContext(ctx context.Context,
+	s sesn.Sesn) (Result, error) {
+
 	r := nmp.NewLogLevelListReq()
 
-	rsp, err := txReq(s, r.Msg(), &c.CmdBase)
+	rsp, err := txReq(ctx, s, r.Msg(), &c.CmdBase)
 	if err != nil {
 		return nil, err
 	}
@@ -271,7 +313,7 @@ func (c *LogLevelListCmd) Run(s sesn.Sesn) (Result, error) {
 
 	res := newLogLevelListResult()
 	res.Rsp = srsp
-	return res, nil
+	return res, nmp.RspError(srsp)
 }
 
 //////////////////////////////////////////////////////////////////////////////
@@ -301,9 +343,15 @@ func (r *LogClearResult) Status() int {
 }
 
 func (c *LogClearCmd) Run(s sesn.Sesn) (Result, error) {
+	return c.RunContext(context.Background(), s)
+}
+
+func (c *LogClearCmd) RunContext(ctx context.Context,
+	s sesn.Sesn) (Result, error) {
+
 	r := nmp.NewLogClearReq()
 
-	rsp, err := txReq(s, r.Msg(), &c.CmdBase)
+	rsp, err := txReq(ctx, s, r.Msg(), &c.CmdBase)
 	if err != nil {
 		return nil, err
 	}
@@ -311,5 +359,5 @@ func (c *LogClearCmd) Run(s sesn.Sesn) (Result, error) {
 
 	res := newLogClearResult()
 	res.Rsp = srsp
-	return res, nil
+	return res, nmp.RspError(srsp)
 }
@@ -20,6 +20,8 @@
 package xact
 
 import (
+	"context"
+
 	"mynewt.apache.org/newtmgr/nmxact/nmp"
 	"mynewt.apache.org/newtmgr/nmxact/sesn"
 )
@@ -47,9 +49,15 @@ func (r *MempoolStatResult) Status() int {
 }
 
 func (c *MempoolStatCmd) Run(s sesn.Sesn) (Result, error) {
+	return c.RunContext(context.Background(), s)
+}
+
+func (c *MempoolStatCmd) RunContext(ctx context.Context,
+	s sesn.Sesn) (Result, error) {
+
 	r := nmp.NewMempoolStatReq()
 
-	rsp, err := txReq(s, r.Msg(), &c.CmdBase)
+	rsp, err := txReq(ctx, s, r.Msg(), &c.CmdBase)
 	if err != nil {
 		return nil, err
 	}
@@ -57,5 +65,5 @@ func (c *MempoolStatCmd) Run(s sesn.Sesn) (Result, error) {
 
 	res := newMempoolStatResult()
 	res.Rsp = srsp
-	return res, nil
+	return res, nmp.RspError(srsp)
 }
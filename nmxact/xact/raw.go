@@ -0,0 +1,146 @@
+/**
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package xact
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"mynewt.apache.org/newtmgr/nmxact/nmp"
+	"mynewt.apache.org/newtmgr/nmxact/sesn"
+)
+
+// Sends a request with an arbitrary op, group, and ID.  Allows management of
+// groups that nmxact has no specific command for.
+type RawCmd struct {
+	CmdBase
+	Op    uint8
+	Group uint16
+	Id    uint8
+
+	// JSON object to send as the request body.  Empty means an empty body.
+	Body []byte
+}
+
+func NewRawCmd() *RawCmd {
+	return &RawCmd{
+		CmdBase: NewCmdBase(),
+		Op:      nmp.NMP_OP_READ,
+	}
+}
+
+type RawResult struct {
+	Rsp *nmp.RawRsp
+}
+
+func newRawResult() *RawResult {
+	return &RawResult{}
+}
+
+func (r *RawResult) Status() int {
+	return r.Rsp.Rc
+}
+
+// Returns the response body as a JSON object.
+func (r *RawResult) Json() ([]byte, error) {
+	return json.MarshalIndent(r.Rsp.Body, "", "    ")
+}
+
+// Parses a JSON object into a map suitable for CBOR encoding.  Whole numbers
+// are converted to integers; JSON only has floating point numbers, but
+// devices generally expect integers.
+func ParseJsonBody(data []byte) (map[string]interface{}, error) {
+	m := map[string]interface{}{}
+	if len(bytes.TrimSpace(data)) == 0 {
+		return m, nil
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	if err := dec.Decode(&m); err != nil {
+		return nil, fmt.Errorf("Invalid JSON body: %s", err.Error())
+	}
+
+	return convertJsonNumbers(m).(map[string]interface{}), nil
+}
+
+func convertJsonNumbers(v interface{}) interface{} {
+	switch t := v.(type) {
+	case json.Number:
+		if i, err := t.Int64(); err == nil {
+			return i
+		}
+		f, _ := t.Float64()
+		return f
+
+	case map[string]interface{}:
+		for k, e := range t {
+			t[k] = convertJsonNumbers(e)
+		}
+		return t
+
+	case []interface{}:
+		for i, e := range t {
+			t[i] = convertJsonNumbers(e)
+		}
+		return t
+
+	default:
+		return v
+	}
+}
+
+func (c *RawCmd) Run(s sesn.Sesn) (Result, error) {
+	return c.RunContext(context.Background(), s)
+}
+
+func (c *RawCmd) RunContext(ctx context.Context,
+	s sesn.Sesn) (Result, error) {
+
+	if c.Op != nmp.NMP_OP_READ && c.Op != nmp.NMP_OP_WRITE {
+		return nil, fmt.Errorf("Invalid NMP request op: %d", c.Op)
+	}
+
+	body, err := ParseJsonBody(c.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	r := nmp.NewRawReq(c.Op, c.Group, c.Id)
+	r.Body = body
+
+	rsp, err := txReq(ctx, s, r.Msg(), &c.CmdBase)
+	if err != nil {
+		return nil, err
+	}
+
+	// Responses with a registered type are decoded into that type; convert
+	// them so that all raw results look the same.
+	rrsp, err := nmp.ToRawRsp(rsp)
+	if err != nil {
+		return nil, err
+	}
+
+	res := newRawResult()
+	res.Rsp = rrsp
+	return res, nmp.RspError(rrsp)
+}
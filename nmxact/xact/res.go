@@ -20,6 +20,8 @@
 package xact
 
 import (
+	"context"
+
 	"github.com/runtimeco/go-coap"
 
 	"mynewt.apache.org/newtmgr/nmxact/nmcoap"
@@ -56,13 +58,22 @@ func (r *ResResult) Status() int {
 }
 
 func (c *ResCmd) Run(s sesn.Sesn) (Result, error) {
-	var rsp coap.Message
-	var err error
+	return c.RunContext(context.Background(), s)
+}
+
+func (c *ResCmd) RunContext(ctx context.Context,
+	s sesn.Sesn) (Result, error) {
 
-	rsp, err = sesn.TxRxCoap(s, c.MsgParams, c.txOptions)
+	ctx, done, err := c.startCtx(ctx)
 	if err != nil {
 		return nil, err
 	}
+	defer done()
+
+	rsp, err := sesn.TxRxCoapContext(ctx, s, c.MsgParams, c.txOptions)
+	if err != nil {
+		return nil, c.ctxErr(err)
+	}
 
 	res := newResResult()
 	res.Rsp = rsp
@@ -93,6 +104,16 @@ func (r *ResNoRxResult) Status() int {
 }
 
 func (c *ResNoRxCmd) Run(s sesn.Sesn) (Result, error) {
+	return c.RunContext(context.Background(), s)
+}
+
+func (c *ResNoRxCmd) RunContext(ctx context.Context,
+	s sesn.Sesn) (Result, error) {
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
 	if err := sesn.TxCoap(s, c.MsgParams); err != nil {
 		return nil, err
 	}
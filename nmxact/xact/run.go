@@ -20,6 +20,8 @@
 package xact
 
 import (
+	"context"
+
 	"mynewt.apache.org/newtmgr/nmxact/nmp"
 	"mynewt.apache.org/newtmgr/nmxact/sesn"
 )
@@ -53,11 +55,17 @@ func (r *RunTestResult) Status() int {
 }
 
 func (c *RunTestCmd) Run(s sesn.Sesn) (Result, error) {
+	return c.RunContext(context.Background(), s)
+}
+
+func (c *RunTestCmd) RunContext(ctx context.Context,
+	s sesn.Sesn) (Result, error) {
+
 	r := nmp.NewRunTestReq()
 	r.Testname = c.Testname
 	r.Token = c.Token
 
-	rsp, err := txReq(s, r.Msg(), &c.CmdBase)
+	rsp, err := txReq(ctx, s, r.Msg(), &c.CmdBase)
 	if err != nil {
 		return nil, err
 	}
@@ -65,7 +73,7 @@ func (c *RunTestCmd) Run(s sesn.Sesn) (Result, error) {
 
 	res := newRunTestResult()
 	res.Rsp = srsp
-	return res, nil
+	return res, nmp.RspError(srsp)
 }
 
 //////////////////////////////////////////////////////////////////////////////
@@ -95,9 +103,15 @@ func (r *RunListResult) Status() int {
 }
 
 func (c *RunListCmd) Run(s sesn.Sesn) (Result, error) {
+	return c.RunContext(context.Background(), s)
+}
+
+func (c *RunListCmd) RunContext(ctx context.Context,
+	s sesn.Sesn) (Result, error) {
+
 	r := nmp.NewRunListReq()
 
-	rsp, err := txReq(s, r.Msg(), &c.CmdBase)
+	rsp, err := txReq(ctx, s, r.Msg(), &c.CmdBase)
 	if err != nil {
 		return nil, err
 	}
@@ -105,5 +119,5 @@ func (c *RunListCmd) Run(s sesn.Sesn) (Result, error) {
 
 	res := newRunListResult()
 	res.Rsp = srsp
-	return res, nil
+	return res, nmp.RspError(srsp)
 }
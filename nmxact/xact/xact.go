@@ -21,33 +21,51 @@ package xact
 
 import (
 	"context"
+	"errors"
 	"runtime/trace"
+	"time"
 
 	"mynewt.apache.org/newtmgr/nmxact/nmp"
 	"mynewt.apache.org/newtmgr/nmxact/sesn"
 )
 
-func txReq(s sesn.Sesn, m *nmp.NmpMsg, c *CmdBase) (
+func txReq(ctx context.Context, s sesn.Sesn, m *nmp.NmpMsg, c *CmdBase) (
 	nmp.NmpRsp, error) {
-	_, task := trace.NewTask(context.Background(), "nmxact/xact/xact.go/txReq")
+	_, task := trace.NewTask(ctx, "nmxact/xact/xact.go/txReq")
 	//// time.Sleep(100 * time.Millisecond) ////
 	defer task.End()
 
-	if c.abortErr != nil {
-		return nil, c.abortErr
+	ctx, done, err := c.startCtx(ctx)
+	if err != nil {
+		return nil, err
 	}
+	defer done()
 
-	c.curNmpSeq = m.Hdr.Seq
-	c.curSesn = s
-	defer func() {
-		c.curNmpSeq = 0
-		c.curSesn = nil
-	}()
-
-	rsp, err := sesn.TxRxMgmt(s, m, c.TxOptions())
+	rsp, err := sesn.TxRxMgmtContext(ctx, s, m, c.TxOptions())
 	if err != nil {
-		return nil, err
+		return nil, c.ctxErr(err)
 	}
 
 	return rsp, nil
 }
+
+// Indicates whether an error is a failure status reported by the device, as
+// opposed to a transport error.
+func isNmpError(err error) bool {
+	var nerr *nmp.NmpError
+	return errors.As(err, &nerr)
+}
+
+// Sleeps for the specified duration or until the context is done, whichever
+// comes first.
+func sleepContext(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}